@@ -12,14 +12,15 @@ func main() {
 	var source = flag.String("source", "", "Source directory path")
 	var dest = flag.String("dest", "", "Destination directory path")
 	var config = flag.String("config", "", "Configuration file path")
+	var output = flag.String("output", "text", "Output format: text or json")
 	
 	flag.Parse()
 
 	if *source == "" || *dest == "" {
 		fmt.Println("ZenSort CLI - File Organizer")
-		fmt.Println("Usage: zensort-cli -source <path> -dest <path> [-config <path>]")
-		os.Exit(1)
+		fmt.Println("Usage: zensort-cli -source <path> -dest <path> [-config <path>] [-output text|json]")
+		os.Exit(cli.ExitFatal)
 	}
 	
-	cli.Run(*source, *dest, *config)
+	cli.Run(*source, *dest, *config, *output)
 }
@@ -10,30 +10,70 @@ import (
 	"zensort/internal/core"
 )
 
-// Run executes the CLI version of the file organizer
-func Run(sourceDir, destDir, configFile string) {
+// Output formats of the CLI
+const (
+	OutputText = "text" // Human-readable progress
+	OutputJSON = "json" // Newline-delimited JSON events on stdout
+)
+
+// Exit codes of the CLI
+const (
+	ExitSuccess        = 0 // Every file was organized, recognized as a duplicate or left out
+	ExitFatal          = 1 // The session could not run or stopped before the end
+	ExitPartialFailure = 2 // The session finished but some files failed
+)
+
+// Run executes the CLI version of the file organizer and exits with one of the exit codes.
+// The run functions return only once progress output has stopped and the processor is
+// closed, since os.Exit skips deferred calls.
+func Run(sourceDir, destDir, configFile, output string) {
+	exitCode := ExitFatal
+	switch output {
+	case "", OutputText:
+		exitCode = runText(sourceDir, destDir, configFile)
+	case OutputJSON:
+		exitCode = runJSON(sourceDir, destDir, configFile)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown output format %q, use %q or %q\n", output, OutputText, OutputJSON)
+	}
+	os.Exit(exitCode)
+}
+
+// runText organizes files while printing human-readable progress
+func runText(sourceDir, destDir, configFile string) int {
 	fmt.Println("ZenSort File Organizer - CLI Mode")
 	fmt.Println("================================")
 	
+	if err := checkSource(sourceDir); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return ExitFatal
+	}
+
 	// Load configuration
 	cfg, err := config.LoadConfig(configFile)
 	if err != nil {
 		fmt.Printf("Error loading configuration: %v\n", err)
-		os.Exit(1)
+		return ExitFatal
 	}
 	
 	// Create processor
 	processor, err := core.NewFileProcessor(cfg, destDir)
 	if err != nil {
 		fmt.Printf("Error creating processor: %v\n", err)
-		os.Exit(1)
+		return ExitFatal
 	}
+	defer closeProcessor(processor)
 	
 	// Subscribe to progress updates
 	progressChan := processor.GetProgressTracker().Subscribe()
 	
 	// Start progress monitoring in background
-	go monitorProgress(progressChan)
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		monitorProgress(progressChan, stop)
+	}()
 	
 	// Create context
 	ctx := context.Background()
@@ -51,20 +91,128 @@ func Run(sourceDir, destDir, configFile string) {
 	err = processor.ProcessDirectory(ctx, sourceDir)
 	duration := time.Since(startTime)
 	
+	// A finished run always delivers the final update; a failed one never does
+	if err != nil {
+		close(stop)
+	}
+	<-stopped
+
 	if err != nil {
 		fmt.Printf("\nError: %v\n", err)
-		os.Exit(1)
+		return ExitFatal
 	}
 	
 	fmt.Printf("\nProcessing completed in %v\n", duration)
 	fmt.Println("Check the destination directory for detailed logs and reports.")
+
+	if failed := processor.GetProgressTracker().GetProgress().FailedFiles; failed > 0 {
+		fmt.Printf("%d files could not be organized\n", failed)
+		return ExitPartialFailure
+	}
+	return ExitSuccess
 }
 
-// monitorProgress displays progress updates in CLI
-func monitorProgress(progressChan <-chan core.ProgressUpdate) {
+// runJSON organizes files while emitting the JSON event stream on stdout. Nothing else is
+// written to stdout, so the stream can be piped into other programs.
+func runJSON(sourceDir, destDir, configFile string) int {
+	events := newEventWriter(os.Stdout)
+	startTime := time.Now()
+	fail := func(err error) int {
+		events.fatal(err)
+		events.summary(time.Since(startTime), ExitFatal)
+		return ExitFatal
+	}
+
+	if err := checkSource(sourceDir); err != nil {
+		return fail(err)
+	}
+
+	cfg, err := config.LoadConfig(configFile)
+	if err != nil {
+		return fail(fmt.Errorf("failed to load configuration: %w", err))
+	}
+
+	processor, err := core.NewFileProcessor(cfg, destDir)
+	if err != nil {
+		return fail(fmt.Errorf("failed to create processor: %w", err))
+	}
+	defer closeProcessor(processor)
+	processor.SetResultHandler(events.file)
+
+	events.sessionStart(sourceDir, destDir, configFile, processor.GetWorkerCount())
+
+	// Emit progress on a timer; subscriber channels drop updates when they fall behind
+	tracker := processor.GetProgressTracker()
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				events.progress(tracker.GetProgress())
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	err = processor.ProcessDirectory(context.Background(), sourceDir)
+	close(stop)
+	<-stopped
+	events.progress(tracker.GetProgress())
+
+	if err != nil {
+		return fail(err)
+	}
+
+	exitCode := ExitSuccess
+	if events.failedFiles() > 0 {
+		exitCode = ExitPartialFailure
+	}
+	events.summary(time.Since(startTime), exitCode)
+	return exitCode
+}
+
+// checkSource makes sure the source is a directory that can be read
+func checkSource(sourceDir string) error {
+	info, err := os.Stat(sourceDir)
+	if err != nil {
+		return fmt.Errorf("cannot read source directory: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("source is not a directory: %s", sourceDir)
+	}
+	return nil
+}
+
+// closeProcessor releases the database and the log file once the session is over. Stdout
+// may carry the JSON event stream, so a failure is reported on stderr.
+func closeProcessor(processor *core.FileProcessor) {
+	if err := processor.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error closing processor: %v\n", err)
+	}
+}
+
+// monitorProgress displays progress updates in CLI until the final update arrives, the
+// tracker drops the channel or stop is closed
+func monitorProgress(progressChan <-chan core.ProgressUpdate, stop <-chan struct{}) {
 	var lastUpdate time.Time
 	
-	for update := range progressChan {
+	for {
+		var update core.ProgressUpdate
+		select {
+		case u, ok := <-progressChan:
+			if !ok {
+				return
+			}
+			update = u
+		case <-stop:
+			return
+		}
+
 		// Throttle updates to avoid spam
 		if time.Since(lastUpdate) < 500*time.Millisecond && !update.Done {
 			continue
@@ -89,12 +237,14 @@ func monitorProgress(progressChan <-chan core.ProgressUpdate) {
 		}
 		
 		if update.Done {
-			fmt.Printf("\n✓ Complete! Processed %d files", update.ProcessedFiles)
+			fmt.Printf("\n✓ Complete! Organized %d files", update.OrganizedFiles)
+			fmt.Printf(" (%d duplicates, %d skipped, %d filtered)",
+				update.DuplicateFiles, update.SkippedFiles, update.FilteredFiles)
 			if update.ErrorCount > 0 {
 				fmt.Printf(" (%d errors)", update.ErrorCount)
 			}
 			fmt.Println()
-			break
+			return
 		}
 	}
 }
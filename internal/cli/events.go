@@ -0,0 +1,216 @@
+package cli
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"zensort/internal/core"
+)
+
+// EventSchemaVersion is the version of the JSON event stream. It only changes when fields
+// are removed or change meaning; new events and fields can appear within a version.
+const EventSchemaVersion = 1
+
+// Names of the events in the JSON event stream
+const (
+	EventSessionStart = "session_start" // Processing is about to start
+	EventFile         = "file"          // The decision taken for one source file
+	EventProgress     = "progress"      // Periodic progress, and once more when processing ends
+	EventError        = "error"         // A file failed, or the session could not run
+	EventSummary      = "summary"       // Always the last event
+)
+
+// progressInterval is how often progress events are emitted
+const progressInterval = time.Second
+
+// eventHeader starts every event
+type eventHeader struct {
+	SchemaVersion int    `json:"schema_version"`
+	Event         string `json:"event"`
+	Time          string `json:"time"` // RFC 3339 with milliseconds
+}
+
+// sessionStartEvent announces a session
+type sessionStartEvent struct {
+	eventHeader
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Config      string `json:"config,omitempty"`
+	Workers     int    `json:"workers"`
+}
+
+// fileEvent reports the decision taken for a source file
+type fileEvent struct {
+	eventHeader
+	core.ManifestEntry
+}
+
+// progressEvent reports how far processing is
+type progressEvent struct {
+	eventHeader
+	ProcessedFiles int64   `json:"processed_files"`
+	TotalFiles     int64   `json:"total_files"`
+	ProcessedBytes int64   `json:"processed_bytes"`
+	TotalBytes     int64   `json:"total_bytes"`
+	Percentage     float64 `json:"percentage"`
+	ElapsedSeconds float64 `json:"elapsed_seconds"`
+	FilesPerSecond float64 `json:"files_per_second"`
+	outcomeCounts
+}
+
+// errorEvent reports a failed file, or a fatal error without a source
+type errorEvent struct {
+	eventHeader
+	Message string `json:"message"`
+	Source  string `json:"source,omitempty"`
+	Fatal   bool   `json:"fatal"`
+}
+
+// summaryEvent closes the stream
+type summaryEvent struct {
+	eventHeader
+	TotalFiles      int64   `json:"total_files"`
+	TotalBytes      int64   `json:"total_bytes"`
+	OrganizedBytes  int64   `json:"organized_bytes"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	ExitCode        int     `json:"exit_code"`
+	outcomeCounts
+}
+
+// outcomeCounts holds the number of files per outcome
+type outcomeCounts struct {
+	Organized  int64 `json:"organized_files"`
+	Duplicates int64 `json:"duplicate_files"`
+	Skipped    int64 `json:"skipped_files"`
+	Filtered   int64 `json:"filtered_files"`
+	Failed     int64 `json:"failed_files"`
+}
+
+// add counts one file with the given outcome
+func (oc *outcomeCounts) add(outcome core.Outcome) {
+	switch outcome {
+	case core.OutcomeOrganized:
+		oc.Organized++
+	case core.OutcomeDuplicate:
+		oc.Duplicates++
+	case core.OutcomeSkipped:
+		oc.Skipped++
+	case core.OutcomeFiltered:
+		oc.Filtered++
+	case core.OutcomeFailed:
+		oc.Failed++
+	}
+}
+
+// eventWriter writes events as newline-delimited JSON and tallies the file events it wrote
+// for the summary. It is safe for concurrent use.
+type eventWriter struct {
+	mu             sync.Mutex
+	encoder        *json.Encoder
+	counts         outcomeCounts
+	totalFiles     int64
+	totalBytes     int64
+	organizedBytes int64
+}
+
+// newEventWriter creates an event writer on w
+func newEventWriter(w io.Writer) *eventWriter {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	return &eventWriter{encoder: encoder}
+}
+
+// header returns the header of a new event
+func header(event string) eventHeader {
+	return eventHeader{
+		SchemaVersion: EventSchemaVersion,
+		Event:         event,
+		Time:          time.Now().Format("2006-01-02T15:04:05.000Z07:00"),
+	}
+}
+
+// write writes one event on its own line
+func (ew *eventWriter) write(event interface{}) {
+	ew.mu.Lock()
+	defer ew.mu.Unlock()
+	ew.encoder.Encode(event)
+}
+
+// sessionStart writes the session start event
+func (ew *eventWriter) sessionStart(sourceDir, destDir, configFile string, workers int) {
+	ew.write(sessionStartEvent{
+		eventHeader: header(EventSessionStart),
+		Source:      sourceDir,
+		Destination: destDir,
+		Config:      configFile,
+		Workers:     workers,
+	})
+}
+
+// file writes the event for a file decision, followed by an error event when it failed
+func (ew *eventWriter) file(entry core.ManifestEntry) {
+	ew.mu.Lock()
+	ew.counts.add(entry.Outcome)
+	ew.totalFiles++
+	ew.totalBytes += entry.Size
+	if entry.Outcome == core.OutcomeOrganized {
+		ew.organizedBytes += entry.Size
+	}
+	ew.mu.Unlock()
+
+	ew.write(fileEvent{eventHeader: header(EventFile), ManifestEntry: entry})
+	if entry.Outcome == core.OutcomeFailed {
+		ew.write(errorEvent{eventHeader: header(EventError), Message: entry.Reason, Source: entry.Source})
+	}
+}
+
+// progress writes a progress event
+func (ew *eventWriter) progress(update core.ProgressUpdate) {
+	ew.write(progressEvent{
+		eventHeader:    header(EventProgress),
+		ProcessedFiles: update.ProcessedFiles,
+		TotalFiles:     update.TotalFiles,
+		ProcessedBytes: update.ProcessedSize,
+		TotalBytes:     update.TotalSize,
+		Percentage:     update.Percentage,
+		ElapsedSeconds: update.ElapsedTime.Seconds(),
+		FilesPerSecond: update.FilesPerSecond,
+		outcomeCounts: outcomeCounts{
+			Organized:  update.OrganizedFiles,
+			Duplicates: update.DuplicateFiles,
+			Skipped:    update.SkippedFiles,
+			Filtered:   update.FilteredFiles,
+			Failed:     update.FailedFiles,
+		},
+	})
+}
+
+// fatal writes the error event for an error that ends the session
+func (ew *eventWriter) fatal(err error) {
+	ew.write(errorEvent{eventHeader: header(EventError), Message: err.Error(), Fatal: true})
+}
+
+// failedFiles returns how many file events reported a failure
+func (ew *eventWriter) failedFiles() int64 {
+	ew.mu.Lock()
+	defer ew.mu.Unlock()
+	return ew.counts.Failed
+}
+
+// summary writes the summary event from the file events written so far
+func (ew *eventWriter) summary(duration time.Duration, exitCode int) {
+	ew.mu.Lock()
+	event := summaryEvent{
+		eventHeader:     header(EventSummary),
+		TotalFiles:      ew.totalFiles,
+		TotalBytes:      ew.totalBytes,
+		OrganizedBytes:  ew.organizedBytes,
+		DurationSeconds: duration.Seconds(),
+		ExitCode:        exitCode,
+		outcomeCounts:   ew.counts,
+	}
+	ew.mu.Unlock()
+	ew.write(event)
+}
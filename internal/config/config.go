@@ -1,10 +1,13 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 )
 
 // Config represents the application configuration
@@ -24,11 +27,7 @@ type Config struct {
 		NoExifYearFolder string `json:"no_exif_year_folder"`
 	} `json:"image_dirs"`
 	
-	AudioCategories map[string]struct {
-		FolderName string   `json:"folder_name"`
-		Extensions []string `json:"extensions"`
-		Patterns   []string `json:"patterns"`
-	} `json:"audio_categories"`
+	AudioCategories AudioCategoryList `json:"audio_categories"`
 	
 	SkipFiles struct {
 		Extensions []string `json:"extensions"`
@@ -37,21 +36,37 @@ type Config struct {
 	} `json:"skip_files"`
 	
 	Processing struct {
-		MaxImageWidth      int  `json:"max_image_width"`
-		MaxImageHeight     int  `json:"max_image_height"`
-		BufferSize         int  `json:"buffer_size"`
-		HashChunkSize      int  `json:"hash_chunk_size"`
-		EnableImageExports bool `json:"enable_image_exports"`
-		JPEGQuality        int  `json:"jpeg_quality"`
-		ShortVideoThreshold int `json:"short_video_threshold_seconds"`
+		MaxImageWidth       int  `json:"max_image_width"`
+		MaxImageHeight      int  `json:"max_image_height"`
+		BufferSize          int  `json:"buffer_size"`
+		HashChunkSize       int  `json:"hash_chunk_size"`
+		EnableImageExports  bool `json:"enable_image_exports"`
+		JPEGQuality         int  `json:"jpeg_quality"`
+		Passthrough         bool `json:"passthrough"` // Copy JPEG, PNG, GIF and WebP originals that already fit unchanged
+		ShortVideoThreshold int  `json:"short_video_threshold_seconds"`
 	} `json:"processing"`
 	
+	// ExportProfiles are the resized copies made of each exported image. An empty list makes
+	// a single export from max_image_width, max_image_height, jpeg_quality and passthrough.
+	ExportProfiles []ExportProfile `json:"export_profiles"`
+
+	// ExportPrivacy controls the metadata exports carry; originals are never changed.
+	// Policies: "keep_all", "strip_gps", "date_camera_only" (capture date, camera and lens)
+	// and "strip_all". Artist and Copyright are written into the EXIF of exports when set.
+	ExportPrivacy struct {
+		Policy    string `json:"policy"`
+		Artist    string `json:"artist"`
+		Copyright string `json:"copyright"`
+	} `json:"export_privacy"`
+
 	MotionPhotos struct {
-		Enabled           bool     `json:"enabled"`
-		IPhonePatterns    []string `json:"iphone_patterns"`
-		SamsungPatterns   []string `json:"samsung_patterns"`
-		Extensions        []string `json:"extensions"`
-		MaxDurationSeconds int     `json:"max_duration_seconds"`
+		Enabled            bool     `json:"enabled"`
+		IPhonePatterns     []string `json:"iphone_patterns"`
+		SamsungPatterns    []string `json:"samsung_patterns"`
+		Extensions         []string `json:"extensions"`
+		MaxDurationSeconds int      `json:"max_duration_seconds"`
+		PairLivePhotos     bool     `json:"pair_live_photos"`
+		ExtractEmbedded    bool     `json:"extract_embedded"`
 	} `json:"motion_photos"`
 
 	Screenshots struct {
@@ -67,6 +82,264 @@ type Config struct {
 		SoftwarePatterns []string `json:"software_patterns"`
 		FolderName       string   `json:"folder_name"`
 	} `json:"edited_images"`
+
+	// PathTemplates override the built-in folder layout below each category directory.
+	// Supported placeholders: {year}, {month}, {day}, {date}, {time}, {make}, {model}, {ext},
+	// {source_dir}, {country}, {country_code}, {city}, {audio_category}, {artist}, {album},
+	// {track}, {title}, {genre} and {author}. Fallbacks fill placeholders a file has no value
+	// for. An empty template keeps the built-in layout.
+	PathTemplates struct {
+		Images    string            `json:"images"`
+		Videos    string            `json:"videos"`
+		Audios    string            `json:"audios"`
+		Documents string            `json:"documents"`
+		Unknown   string            `json:"unknown"`
+		Fallbacks map[string]string `json:"fallbacks"`
+	} `json:"path_templates"`
+
+	// DateTime controls how embedded timestamps are interpreted.
+	// DefaultTimeZone is an IANA zone name (or "Local") used when a file records no UTC offset.
+	DateTime struct {
+		DefaultTimeZone string `json:"default_time_zone"`
+		UseGPSTime      bool   `json:"use_gps_time"`
+	} `json:"date_time"`
+
+	// FilenameDates infer capture dates from filenames when a file has no embedded date.
+	// Extractors are tried in order; each pattern needs a (?P<date>...) group matching the Go layout.
+	FilenameDates struct {
+		Enabled    bool                    `json:"enabled"`
+		Extractors []FilenameDateExtractor `json:"extractors"`
+	} `json:"filename_dates"`
+
+	// Geocoding turns GPS positions into {country} and {city} template values offline
+	Geocoding struct {
+		Enabled       bool    `json:"enabled"`
+		DatasetPath   string  `json:"dataset_path"`    // GeoNames cities file (e.g. cities15000.txt); empty uses the bundled cities
+		MaxDistanceKm float64 `json:"max_distance_km"` // Farthest a position may be from a city to be placed in it
+	} `json:"geocoding"`
+
+	Takeout struct {
+		Enabled bool `json:"enabled"` // Read Google Photos Takeout JSON files as metadata
+	} `json:"takeout"`
+
+	// Archives are expanded into a staging folder below the destination, one at a time, and
+	// their contents organized as if they were directories of the source
+	Archives struct {
+		Enabled           bool  `json:"enabled"`             // Expand .zip, .tar, .tar.gz, .tar.bz2 and .tar.xz (needs xz) archives
+		MaxDepth          int   `json:"max_depth"`           // How deep archives inside archives are expanded
+		MaxExtractedBytes int64 `json:"max_extracted_bytes"` // Per archive, including nested ones; 0 for half the free space of the destination, -1 for no limit
+		MaxEntries        int   `json:"max_entries"`         // Per archive, including nested ones; 0 for no limit
+	} `json:"archives"`
+
+	// Sidecars are placed next to the file they describe (same stem, same directory)
+	Sidecars struct {
+		Enabled    bool     `json:"enabled"`
+		Extensions []string `json:"extensions"`
+	} `json:"sidecars"`
+
+	// RAW+JPEG pairs are a RAW file and a JPEG/HEIF with the same stem in the same directory.
+	// Policies: "together" (RAW next to its JPEG), "raw_subfolder" (RAW in a subfolder next
+	// to its JPEG), "keep_jpeg", "keep_raw" (the other file is skipped) and "independent".
+	RawPairs struct {
+		Policy    string `json:"policy"`    // Empty for "together"
+		Subfolder string `json:"subfolder"` // Subfolder name for the "raw_subfolder" policy
+	} `json:"raw_pairs"`
+
+	// The gallery is a static HTML page for browsing the organized library offline.
+	// Thumbnails are cached in the gallery folder and new files are added after every session.
+	Gallery struct {
+		Enabled       bool   `json:"enabled"`
+		Folder        string `json:"folder"`         // Relative to the destination
+		ThumbnailSize int    `json:"thumbnail_size"` // Longest side of thumbnails in pixels
+	} `json:"gallery"`
+
+	// Rename templates give organized files a new name; an empty template keeps the original name.
+	// Supported placeholders: {date}, {time}, {year}, {month}, {day}, {make}, {model},
+	// {name} (original name without extension) and {counter}. The extension is always kept.
+	Rename struct {
+		Originals     string `json:"originals"`
+		Videos        string `json:"videos"`
+		Audio         string `json:"audio"`
+		CounterDigits int    `json:"counter_digits"`
+	} `json:"rename"`
+
+	// Rules are evaluated in order and the first matching rule decides what happens to a file
+	Rules []Rule `json:"rules"`
+}
+
+// AudioCategory is a folder for audio files that meet its conditions. Categories are
+// checked from the highest priority down (list order breaks ties) and the first match wins.
+// All configured conditions must hold; when patterns or regexes are set, one of them must
+// match the file name. Duration, channel and sample-rate conditions fail for files whose
+// properties can't be read.
+type AudioCategory struct {
+	Key                string   `json:"key"`
+	FolderName         string   `json:"folder_name"`
+	Priority           int      `json:"priority"`
+	Extensions         []string `json:"extensions"`        // Empty matches any extension
+	Patterns           []string `json:"patterns"`          // Case-insensitive file name substrings
+	Regexes            []string `json:"regexes,omitempty"` // Matched against the file name without extension
+	MinDurationSeconds float64  `json:"min_duration_seconds,omitempty"`
+	MaxDurationSeconds float64  `json:"max_duration_seconds,omitempty"`
+	Channels           int      `json:"channels,omitempty"` // 1 = mono, 2 = stereo
+	MinSampleRate      int      `json:"min_sample_rate,omitempty"`
+	MaxSampleRate      int      `json:"max_sample_rate,omitempty"`
+}
+
+// AudioCategoryList is the ordered list of audio categories
+type AudioCategoryList []AudioCategory
+
+// phoneNumberRegex matches file names (without extension) that are only a phone number: "+44 7911 123456",
+// "(555) 123-4567" or a run of 9 to 12 digits. Dates and timestamps ("2023-04-15",
+// "20230415", "20230415_101112") are not phone numbers.
+const phoneNumberRegex = `^(\+\d[\d ()-]{6,}\d|\d{9,12}|\(?\d{3}\)?[ .-]?\d{3}[ .-]?\d{4})$`
+
+// legacyCallPatterns and legacyPhoneNumberRegex are the call recording defaults of older
+// versions: DefaultConfig wrote "+", the shipped zensort-config.json "SIM". "recording" was
+// a voice recording pattern too, "tel", "+" and "SIM" matched names like "hotel", "+1 song"
+// and "simple", and the regex matched any name starting with a date.
+var legacyCallPatterns = [][]string{
+	{"call", "_call", "phone", "tel", "+", "recording"},
+	{"call", "_call", "phone", "tel", "SIM", "recording"},
+}
+
+const legacyPhoneNumberRegex = `^\+?\d[\d ()-]{6,}\d`
+
+// legacyAudioPriorities ranks the categories of configs written before categories were ordered
+var legacyAudioPriorities = map[string]int{
+	"call_recordings":  40,
+	"voice_recordings": 30,
+	"other_audio":      10,
+	"songs":            0,
+}
+
+// UnmarshalJSON reads the category list, migrating the older "audio_categories" object
+// keyed by category name. Migrated categories are ordered by their default priority, then name.
+func (l *AudioCategoryList) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if !bytes.HasPrefix(data, []byte("{")) {
+		var list []AudioCategory
+		if err := json.Unmarshal(data, &list); err != nil {
+			return err
+		}
+		*l = list
+		return nil
+	}
+
+	var legacy map[string]AudioCategory
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return fmt.Errorf("invalid audio_categories: %w", err)
+	}
+
+	list := make(AudioCategoryList, 0, len(legacy))
+	for key, category := range legacy {
+		category.Key = key
+		if category.Priority == 0 {
+			category.Priority = legacyAudioPriorities[key]
+		}
+		list = append(list, category)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Priority != list[j].Priority {
+			return list[i].Priority > list[j].Priority
+		}
+		return list[i].Key < list[j].Key
+	})
+
+	*l = list
+	return nil
+}
+
+// Find returns the category with the given key, or nil
+func (l AudioCategoryList) Find(key string) *AudioCategory {
+	for i := range l {
+		if l[i].Key == key {
+			return &l[i]
+		}
+	}
+	return nil
+}
+
+// FilenameDateExtractor is a named pattern that reads a date from a filename
+type FilenameDateExtractor struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+	Layout  string `json:"layout"`
+}
+
+// ExportProfile describes one resized copy made of every exported image.
+// Sizes are upper bounds (0 means unlimited) and images are never enlarged.
+// Folder is relative to the images directory and defaults to <exports>/<name>.
+// Template is a path template for the file name without extension; besides the path
+// template placeholders it supports {name} (original name) and {profile}. The default
+// template keeps the built-in export layout.
+type ExportProfile struct {
+	Name        string  `json:"name"`
+	MaxWidth    int     `json:"max_width"`
+	MaxHeight   int     `json:"max_height"`
+	Filter      string  `json:"filter,omitempty"`  // lanczos (default), catmullrom, mitchell, linear, box or nearest
+	Sharpen     float64 `json:"sharpen,omitempty"` // Sharpening sigma applied after resizing, 0 disables
+	Quality     int     `json:"quality,omitempty"` // JPEG quality, 0 uses processing.jpeg_quality
+	Folder      string  `json:"folder,omitempty"`
+	Template    string  `json:"template,omitempty"`
+	Passthrough bool    `json:"passthrough,omitempty"` // Copy JPEG, PNG, GIF and WebP originals that already fit unchanged
+}
+
+// Rule describes one entry of the ordered classification rule list.
+// Builtin names one of the built-in classification steps (hidden, screenshot, edited,
+// image, motion_photo, short_video, video, audio, document, unknown); it acts as an
+// extra condition and, when Destination is empty, provides the built-in placement.
+// Destination is a path template relative to the destination directory.
+// Action is one of copy (default), move, skip or export.
+type Rule struct {
+	Name        string    `json:"name"`
+	Match       RuleMatch `json:"match"`
+	Builtin     string    `json:"builtin,omitempty"`
+	Destination string    `json:"destination,omitempty"`
+	Action      string    `json:"action,omitempty"`
+}
+
+// RuleMatch holds the conditions of a rule; all configured conditions must match
+type RuleMatch struct {
+	FileTypes          []string          `json:"file_types,omitempty"`
+	Extensions         []string          `json:"extensions,omitempty"` // With or without the dot, any case: "jpg", ".JPG"
+	MimeTypes          []string          `json:"mime_types,omitempty"`
+	PathRegex          string            `json:"path_regex,omitempty"`
+	NameRegex          string            `json:"name_regex,omitempty"`
+	MinSize            int64             `json:"min_size,omitempty"`
+	MaxSize            int64             `json:"max_size,omitempty"`
+	EXIF               map[string]string `json:"exif,omitempty"`
+	MinDurationSeconds float64           `json:"min_duration_seconds,omitempty"`
+	MaxDurationSeconds float64           `json:"max_duration_seconds,omitempty"`
+}
+
+// defaultTemplateFallbacks is the value of every placeholder that has none
+var defaultTemplateFallbacks = map[string]string{
+	"year":           "0000",
+	"month":          "00",
+	"day":            "00",
+	"date":           "0000-00-00",
+	"time":           "00-00-00",
+	"make":           "Unknown Make",
+	"model":          "Unknown Model",
+	"ext":            "Other",
+	"source_dir":     "Unknown",
+	"country":        "Unknown Country",
+	"country_code":   "Unknown",
+	"city":           "Unknown City",
+	"artist":         "Unknown Artist",
+	"album":          "Unknown Album",
+	"title":          "Unknown Title",
+	"track":          "00",
+	"genre":          "Unknown Genre",
+	"author":         "Unknown Author",
+	"audio_category": "Other Audio",
+}
+
+// DefaultTemplateFallback returns the built-in fallback of a path template placeholder
+func DefaultTemplateFallback(name string) (string, bool) {
+	fallback, exists := defaultTemplateFallbacks[name]
+	return fallback, exists
 }
 
 // DefaultConfig returns a configuration with default values
@@ -86,51 +359,55 @@ func DefaultConfig() *Config {
 	config.ImageDirs.Exports = "Exports"
 	config.ImageDirs.NoExifYearFolder = "0000"
 	
-	// Default audio categories
-	config.AudioCategories = make(map[string]struct {
-		FolderName string   `json:"folder_name"`
-		Extensions []string `json:"extensions"`
-		Patterns   []string `json:"patterns"`
-	})
-	
-	config.AudioCategories["songs"] = struct {
-		FolderName string   `json:"folder_name"`
-		Extensions []string `json:"extensions"`
-		Patterns   []string `json:"patterns"`
-	}{
-		FolderName: "Songs",
-		Extensions: []string{".mp3", ".flac", ".wav", ".aac", ".ogg", ".m4a", ".wma"},
-		Patterns:   []string{},
-	}
-	
-	config.AudioCategories["voice_recordings"] = struct {
-		FolderName string   `json:"folder_name"`
-		Extensions []string `json:"extensions"`
-		Patterns   []string `json:"patterns"`
-	}{
-		FolderName: "Voice Recordings",
-		Extensions: []string{".m4a", ".wav", ".aac", ".3gp"},
-		Patterns:   []string{"voice", "memo", "note", "recording", "_rec"},
-	}
-	
-	config.AudioCategories["call_recordings"] = struct {
-		FolderName string   `json:"folder_name"`
-		Extensions []string `json:"extensions"`
-		Patterns   []string `json:"patterns"`
-	}{
-		FolderName: "Call Recordings",
-		Extensions: []string{".m4a", ".wav", ".aac", ".3gp", ".amr"},
-		Patterns:   []string{"call", "_call", "phone", "tel", "+", "recording"},
-	}
-	
-	config.AudioCategories["other_audio"] = struct {
-		FolderName string   `json:"folder_name"`
-		Extensions []string `json:"extensions"`
-		Patterns   []string `json:"patterns"`
-	}{
-		FolderName: "Other Audio",
-		Extensions: []string{".mp3", ".wav", ".aac", ".ogg", ".wma", ".au", ".aiff"},
-		Patterns:   []string{"podcast", "audiobook", "lecture", "interview", "meeting"},
+	// Default audio categories, most specific first. Calls are mono 8 kHz and
+	// voice memos short mono clips, which tells them apart from untagged music.
+	// Files recognized only by those properties get their own folders to review.
+	config.AudioCategories = AudioCategoryList{
+		{
+			Key:        "call_recordings",
+			FolderName: "Call Recordings",
+			Priority:   40,
+			Extensions: []string{".m4a", ".wav", ".aac", ".3gp", ".amr", ".mp3", ".ogg", ".opus"},
+			Patterns:   []string{"call", "_call", "phone"},
+			Regexes:    []string{phoneNumberRegex}, // Named after the phone number alone
+		},
+		{
+			Key:        "voice_recordings",
+			FolderName: "Voice Recordings",
+			Priority:   30,
+			Extensions: []string{".m4a", ".wav", ".aac", ".3gp", ".amr", ".ogg", ".opus"},
+			Patterns:   []string{"voice", "memo", "note", "recording", "_rec"},
+		},
+		{
+			Key:           "narrowband_calls",
+			FolderName:    "Detected Call Recordings",
+			Priority:      20,
+			Extensions:    []string{".m4a", ".wav", ".aac", ".3gp", ".amr"},
+			Channels:      1,
+			MaxSampleRate: 8000,
+		},
+		{
+			Key:                "short_mono_recordings",
+			FolderName:         "Detected Voice Recordings",
+			Priority:           15,
+			Extensions:         []string{".m4a", ".wav", ".aac", ".3gp", ".amr", ".ogg", ".opus"},
+			Channels:           1,
+			MaxDurationSeconds: 600,
+		},
+		{
+			Key:        "other_audio",
+			FolderName: "Other Audio",
+			Priority:   10,
+			Extensions: []string{},
+			Patterns:   []string{"podcast", "audiobook", "lecture", "interview", "meeting"},
+		},
+		{
+			Key:        "songs",
+			FolderName: "Songs",
+			Priority:   0,
+			Extensions: []string{".mp3", ".flac", ".wav", ".aac", ".ogg", ".m4a", ".wma", ".opus", ".aiff"},
+			Patterns:   []string{},
+		},
 	}
 	
 	// Default skip patterns
@@ -146,13 +423,16 @@ func DefaultConfig() *Config {
 	config.Processing.EnableImageExports = true // Enabled by default
 	config.Processing.JPEGQuality = 85 // Reduced from 90 for faster encoding
 	config.Processing.ShortVideoThreshold = 30 // Videos under 30 seconds go to Short Videos folder
+	config.ExportPrivacy.Policy = "keep_all"
 	
 	// Default Motion Photos settings
 	config.MotionPhotos.Enabled = true
-	config.MotionPhotos.IPhonePatterns = []string{"live", "livephoto", "_live", "img_"}
+	config.MotionPhotos.IPhonePatterns = []string{"live", "livephoto", "_live"}
 	config.MotionPhotos.SamsungPatterns = []string{"motion", "_motion", "motionphoto", "mvimg_"}
 	config.MotionPhotos.Extensions = []string{".mov", ".mp4"}
 	config.MotionPhotos.MaxDurationSeconds = 10
+	config.MotionPhotos.PairLivePhotos = true  // Pair iPhone stills and videos by content identifier
+	config.MotionPhotos.ExtractEmbedded = true // Extract videos embedded in Samsung/Pixel motion photos
 
 	// Screenshots settings
 	config.Screenshots.Enabled = true
@@ -167,6 +447,67 @@ func DefaultConfig() *Config {
 	config.EditedImages.SoftwarePatterns = []string{"photoshop", "adobe", "lightroom", "gimp", "paint.net", "canva", "pixlr", "photo editor", "windows photo"}
 	config.EditedImages.FolderName = "Edited"
 	
+	// Path templates are empty by default (built-in layout), with a fallback for every placeholder
+	config.PathTemplates.Fallbacks = make(map[string]string, len(defaultTemplateFallbacks))
+	for name, fallback := range defaultTemplateFallbacks {
+		config.PathTemplates.Fallbacks[name] = fallback
+	}
+
+	// Timestamps without an offset are in the local time zone; GPS time is not used by default
+	config.DateTime.DefaultTimeZone = "Local"
+	config.DateTime.UseGPSTime = false
+
+	// Filename date extractors for common phone and messenger naming schemes
+	config.FilenameDates.Enabled = true
+	config.FilenameDates.Extractors = []FilenameDateExtractor{
+		{Name: "whatsapp", Pattern: `(?i)^(?:IMG|VID|AUD|PTT|STK|DOC)-(?P<date>\d{8})-WA\d+`, Layout: "20060102"},
+		{Name: "pixel", Pattern: `(?i)^PXL_(?P<date>\d{8}_\d{6})`, Layout: "20060102_150405"},
+		{Name: "android_camera", Pattern: `(?i)^(?:IMG|VID|MVIMG|PANO|BURST\d*)_(?P<date>\d{8}_\d{6})`, Layout: "20060102_150405"},
+		{Name: "samsung_camera", Pattern: `^(?P<date>\d{8}_\d{6})(?:\(\d+\))?\.`, Layout: "20060102_150405"},
+		{Name: "signal", Pattern: `(?i)^signal-(?P<date>\d{4}-\d{2}-\d{2}-\d{6})`, Layout: "2006-01-02-150405"},
+		{Name: "telegram", Pattern: `(?i)^(?:photo|video|file)_(?P<date>\d{4}-\d{2}-\d{2}_\d{2}-\d{2}-\d{2})`, Layout: "2006-01-02_15-04-05"},
+		{Name: "android_screenshot", Pattern: `(?i)^screenshot_(?P<date>\d{8}-\d{6})`, Layout: "20060102-150405"},
+		{Name: "android_screenshot_dashed", Pattern: `(?i)^screenshot_(?P<date>\d{4}-\d{2}-\d{2}-\d{2}-\d{2}-\d{2})`, Layout: "2006-01-02-15-04-05"},
+		{Name: "macos_screenshot", Pattern: `(?i)^screen ?shot (?P<date>\d{4}-\d{2}-\d{2} at \d{2}\.\d{2}\.\d{2})`, Layout: "2006-01-02 at 15.04.05"},
+		{Name: "iso_date", Pattern: `(?:^|[^\d])(?P<date>(?:19|20)\d{2}-\d{2}-\d{2})(?:[^\d]|$)`, Layout: "2006-01-02"},
+	}
+
+	// Reverse geocoding of GPS positions for the place placeholders (opt-in)
+	config.Geocoding.Enabled = false
+	config.Geocoding.MaxDistanceKm = 50
+
+	config.Takeout.Enabled = false // Only for Google Photos Takeout exports
+	config.Archives.Enabled = false
+	config.Archives.MaxDepth = 2
+	config.Archives.MaxExtractedBytes = 0 // Half the free space of the destination when each archive is expanded
+	config.Archives.MaxEntries = 500000
+
+	// Sidecar files follow their primary media file
+	config.Sidecars.Enabled = true
+	config.Sidecars.Extensions = []string{".xmp", ".aae", ".thm", ".lrv", ".srt"}
+	config.RawPairs.Policy = "together"
+	config.RawPairs.Subfolder = "RAW"
+	config.Gallery.Enabled = false
+	config.Gallery.Folder = "Gallery"
+	config.Gallery.ThumbnailSize = 320
+
+	// Renaming is disabled by default; counters are zero-padded to 3 digits
+	config.Rename.CounterDigits = 3
+
+	// Default rules reproduce the built-in classification cascade
+	config.Rules = []Rule{
+		{Name: "Hidden files", Builtin: "hidden"},
+		{Name: "Screenshots", Builtin: "screenshot"},
+		{Name: "Edited images", Builtin: "edited"},
+		{Name: "Images", Builtin: "image"},
+		{Name: "Motion photos", Builtin: "motion_photo"},
+		{Name: "Short videos", Builtin: "short_video"},
+		{Name: "Videos", Builtin: "video"},
+		{Name: "Audio", Builtin: "audio"},
+		{Name: "Documents", Builtin: "document"},
+		{Name: "Unknown files", Builtin: "unknown"},
+	}
+
 	return config
 }
 
@@ -196,10 +537,66 @@ func LoadConfig(configPath string) (*Config, error) {
 	if err := json.Unmarshal(data, config); err != nil {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
+	migrateConfig(config)
 	
 	return config, nil
 }
 
+// migrateConfig removes settings written by older versions that now do harm
+func migrateConfig(config *Config) {
+	// "img_" was an iPhone Live Photo pattern; it matches every ordinary iPhone
+	// video, which then became a motion photo whenever its duration could not be read
+	patterns := config.MotionPhotos.IPhonePatterns[:0]
+	for _, pattern := range config.MotionPhotos.IPhonePatterns {
+		if !strings.EqualFold(strings.TrimSpace(pattern), "img_") {
+			patterns = append(patterns, pattern)
+		}
+	}
+	config.MotionPhotos.IPhonePatterns = patterns
+
+	// Call recordings keep their custom patterns, only the old defaults are replaced
+	if calls := config.AudioCategories.Find("call_recordings"); calls != nil {
+		for _, legacy := range legacyCallPatterns {
+			if equalFoldLists(calls.Patterns, legacy) {
+				calls.Patterns = []string{"call", "_call", "phone"}
+				break
+			}
+		}
+		for i, regex := range calls.Regexes {
+			if regex == legacyPhoneNumberRegex {
+				calls.Regexes[i] = phoneNumberRegex
+			}
+		}
+	}
+
+	// The fixed 64 GB archive budget could exceed the free space of the destination
+	if config.Archives.MaxExtractedBytes == legacyMaxExtractedBytes {
+		config.Archives.MaxExtractedBytes = 0
+	}
+}
+
+// legacyMaxExtractedBytes is the archive budget older versions wrote by default
+const legacyMaxExtractedBytes = 64 << 30
+
+// equalFoldLists reports whether two lists hold the same strings in any order and case
+func equalFoldLists(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	remaining := make(map[string]int, len(a))
+	for _, value := range a {
+		remaining[strings.ToLower(strings.TrimSpace(value))]++
+	}
+	for _, value := range b {
+		key := strings.ToLower(value)
+		if remaining[key] == 0 {
+			return false
+		}
+		remaining[key]--
+	}
+	return true
+}
+
 // SaveConfig saves configuration to file
 func SaveConfig(config *Config, configPath string) error {
 	// Create directory if it doesn't exist
@@ -0,0 +1,275 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// loadTestConfig writes a configuration file and loads it
+func loadTestConfig(t *testing.T, content string) *Config {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "zensort-config.json")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	return config
+}
+
+func TestLoadConfigRemovesLegacyIPhonePattern(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns string
+		want     []string
+	}{
+		{"legacy default", `["live", "livephoto", "_live", "img_"]`, []string{"live", "livephoto", "_live"}},
+		{"any case and spacing", `[" IMG_ ", "live"]`, []string{"live"}},
+		{"only legacy entry", `["img_"]`, []string{}},
+		{"current default", `["live", "livephoto", "_live"]`, []string{"live", "livephoto", "_live"}},
+		{"similar pattern kept", `["img_live"]`, []string{"img_live"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := loadTestConfig(t, `{"motion_photos": {"iphone_patterns": `+tt.patterns+`}}`)
+			if got := config.MotionPhotos.IPhonePatterns; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("iphone_patterns = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigCreatesDefault(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "zensort-config.json")
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("default config was not written: %v", err)
+	}
+	if !reflect.DeepEqual(config.MotionPhotos.IPhonePatterns, DefaultConfig().MotionPhotos.IPhonePatterns) {
+		t.Errorf("iphone_patterns = %q, want the defaults", config.MotionPhotos.IPhonePatterns)
+	}
+}
+
+func TestLoadConfigInvalidJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "zensort-config.json")
+	if err := os.WriteFile(path, []byte(`{"motion_photos": `), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(path); err == nil {
+		t.Error("LoadConfig() error = nil for truncated JSON")
+	}
+}
+
+func TestAudioCategoryListUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name      string
+		json      string
+		wantKeys  []string
+		wantPrios []int
+		wantErr   bool
+	}{
+		{"list kept in order", `[{"key": "b", "priority": 1}, {"key": "a", "priority": 5}]`, []string{"b", "a"}, []int{1, 5}, false},
+		{"legacy object by default priority", `{
+			"songs": {"folder_name": "Songs"},
+			"other_audio": {"folder_name": "Other Audio"},
+			"call_recordings": {"folder_name": "Call Recordings"},
+			"voice_recordings": {"folder_name": "Voice Recordings"}
+		}`, []string{"call_recordings", "voice_recordings", "other_audio", "songs"}, []int{40, 30, 10, 0}, false},
+		{"legacy object with custom categories", `{
+			"zebra": {"folder_name": "Z"},
+			"alpha": {"folder_name": "A"},
+			"songs": {"folder_name": "Songs"},
+			"urgent": {"folder_name": "U", "priority": 50}
+		}`, []string{"urgent", "alpha", "songs", "zebra"}, []int{50, 0, 0, 0}, false},
+		{"empty legacy object", `{}`, []string{}, []int{}, false},
+		{"empty list", `[]`, []string{}, []int{}, false},
+		{"legacy object with a bad category", `{"songs": []}`, nil, nil, true},
+		{"list with a bad category", `[1, 2]`, nil, nil, true},
+		{"truncated", `{"songs": {"folder_name": `, nil, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var list AudioCategoryList
+			err := list.UnmarshalJSON([]byte(tt.json))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("UnmarshalJSON() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			keys, prios := []string{}, []int{}
+			for _, category := range list {
+				keys = append(keys, category.Key)
+				prios = append(prios, category.Priority)
+			}
+			if !reflect.DeepEqual(keys, tt.wantKeys) || !reflect.DeepEqual(prios, tt.wantPrios) {
+				t.Errorf("UnmarshalJSON() = %v %v, want %v %v", keys, prios, tt.wantKeys, tt.wantPrios)
+			}
+		})
+	}
+}
+
+func TestLoadConfigMigratesLegacyAudioCategories(t *testing.T) {
+	config := loadTestConfig(t, `{"audio_categories": {
+		"songs": {"folder_name": "Music", "extensions": [".mp3"], "patterns": []},
+		"call_recordings": {"folder_name": "Calls", "extensions": [".amr"], "patterns": ["tel"]}
+	}}`)
+
+	if len(config.AudioCategories) != 2 {
+		t.Fatalf("audio_categories = %+v, want the two configured categories", config.AudioCategories)
+	}
+	calls := config.AudioCategories.Find("call_recordings")
+	if calls == nil || calls.FolderName != "Calls" || calls.Priority != 40 || !reflect.DeepEqual(calls.Patterns, []string{"tel"}) {
+		t.Errorf("call_recordings = %+v", calls)
+	}
+	if songs := config.AudioCategories.Find("songs"); songs == nil || songs.FolderName != "Music" {
+		t.Errorf("songs = %+v", songs)
+	}
+	if config.AudioCategories[0].Key != "call_recordings" {
+		t.Errorf("first category = %s, want call_recordings", config.AudioCategories[0].Key)
+	}
+}
+
+func TestDefaultAudioCategories(t *testing.T) {
+	categories := DefaultConfig().AudioCategories
+
+	calls := categories.Find("call_recordings")
+	voice := categories.Find("voice_recordings")
+	if calls == nil || voice == nil {
+		t.Fatal("no call_recordings or voice_recordings category")
+	}
+	if calls.Priority <= voice.Priority {
+		t.Errorf("call_recordings priority %d, want above voice_recordings %d", calls.Priority, voice.Priority)
+	}
+	for _, pattern := range calls.Patterns {
+		for _, p := range voice.Patterns {
+			if strings.EqualFold(p, pattern) {
+				t.Errorf("call_recordings and voice_recordings both list %q", pattern)
+			}
+		}
+		for _, name := range []string{"hotel lobby", "simple tune", "new recording 3"} {
+			if strings.Contains(name, strings.ToLower(pattern)) {
+				t.Errorf("call_recordings pattern %q matches %q", pattern, name)
+			}
+		}
+	}
+
+	// Categories matched by audio properties alone have folders of their own
+	folders := make(map[string]string)
+	for _, category := range categories {
+		if other, exists := folders[category.FolderName]; exists {
+			t.Errorf("%s and %s share the folder %q", other, category.Key, category.FolderName)
+		}
+		folders[category.FolderName] = category.Key
+	}
+}
+
+func TestPhoneNumberRegex(t *testing.T) {
+	tests := []struct {
+		stem string
+		want bool
+	}{
+		{"+44 7911 123456", true},
+		{"+1 (555) 123-4567", true},
+		{"(555) 123-4567", true},
+		{"555-123-4567", true},
+		{"555.123.4567", true},
+		{"07911123456", true},
+		{"20230415_101112", false},
+		{"2023-04-15 meeting", false},
+		{"2023-04-15", false},
+		{"20230415", false},
+		{"20230415101112", false},
+		{"+44 7911 123456 meeting", false},
+		{"call 555-123-4567", false},
+		{"12345", false},
+	}
+
+	regex := regexp.MustCompile(phoneNumberRegex)
+	for _, tt := range tests {
+		if got := regex.MatchString(tt.stem); got != tt.want {
+			t.Errorf("MatchString(%q) = %v, want %v", tt.stem, got, tt.want)
+		}
+	}
+}
+
+func TestLoadConfigMigratesLegacyCallDefaults(t *testing.T) {
+	tests := []struct {
+		name        string
+		category    string
+		wantPattern []string
+		wantRegexes []string
+	}{
+		{"legacy object defaults", `{"call_recordings": {"folder_name": "Call Recordings",
+			"patterns": ["call", "_call", "phone", "tel", "SIM", "recording"]}}`,
+			[]string{"call", "_call", "phone"}, nil},
+		{"saved list defaults", `[{"key": "call_recordings", "priority": 40,
+			"patterns": ["recording", "SIM", "tel", "phone", "_call", "call"],
+			"regexes": ["^\\+?\\d[\\d ()-]{6,}\\d", "^x"]}]`,
+			[]string{"call", "_call", "phone"}, []string{phoneNumberRegex, "^x"}},
+		{"legacy DefaultConfig defaults", `{"call_recordings": {"folder_name": "Call Recordings",
+			"patterns": ["call", "_call", "phone", "tel", "+", "recording"]}}`,
+			[]string{"call", "_call", "phone"}, nil},
+		{"custom patterns kept", `{"call_recordings": {"patterns": ["tel", "recording"]}}`,
+			[]string{"tel", "recording"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := loadTestConfig(t, `{"audio_categories": `+tt.category+`}`)
+			calls := config.AudioCategories.Find("call_recordings")
+			if calls == nil {
+				t.Fatal("no call_recordings category")
+			}
+			if !reflect.DeepEqual(calls.Patterns, tt.wantPattern) {
+				t.Errorf("patterns = %q, want %q", calls.Patterns, tt.wantPattern)
+			}
+			if !reflect.DeepEqual(calls.Regexes, tt.wantRegexes) {
+				t.Errorf("regexes = %q, want %q", calls.Regexes, tt.wantRegexes)
+			}
+		})
+	}
+}
+
+func TestLoadConfigMigratesLegacyDefaultConfig(t *testing.T) {
+	// Written by SaveConfig(DefaultConfig()) of the release before audio categories were ordered
+	config, err := LoadConfig(filepath.Join("testdata", "legacy-default-config.json"))
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+
+	calls := config.AudioCategories.Find("call_recordings")
+	if calls == nil {
+		t.Fatal("no call_recordings category")
+	}
+	if want := []string{"call", "_call", "phone"}; !reflect.DeepEqual(calls.Patterns, want) {
+		t.Errorf("call patterns = %q, want %q", calls.Patterns, want)
+	}
+	if calls.Priority != 40 {
+		t.Errorf("call priority = %d, want 40", calls.Priority)
+	}
+}
+
+func TestLoadConfigMigratesLegacyArchiveBudget(t *testing.T) {
+	for content, want := range map[string]int64{
+		`{"archives": {"max_extracted_bytes": 68719476736}}`: 0,
+		`{"archives": {"max_extracted_bytes": 1073741824}}`:  1 << 30,
+		`{"archives": {"max_extracted_bytes": -1}}`:          -1,
+		`{}`: 0,
+	} {
+		if got := loadTestConfig(t, content).Archives.MaxExtractedBytes; got != want {
+			t.Errorf("%s: max_extracted_bytes = %d, want %d", content, got, want)
+		}
+	}
+}
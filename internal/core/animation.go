@@ -0,0 +1,96 @@
+package core
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"os"
+
+	"github.com/disintegration/imaging"
+)
+
+// decodeAnimatedGIF decodes all frames of a GIF, failing for GIFs with a single frame
+func decodeAnimatedGIF(filePath string) (*gif.GIF, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	animation, err := gif.DecodeAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode GIF: %w", err)
+	}
+	if len(animation.Image) < 2 {
+		return nil, fmt.Errorf("GIF is not animated")
+	}
+	return animation, nil
+}
+
+// gifSize returns the logical screen size of a GIF
+func gifSize(animation *gif.GIF) (int, int) {
+	if animation.Config.Width > 0 && animation.Config.Height > 0 {
+		return animation.Config.Width, animation.Config.Height
+	}
+	bounds := animation.Image[0].Bounds()
+	return bounds.Max.X, bounds.Max.Y
+}
+
+// resizeAnimatedGIF scales every frame of an animated GIF to fit within maxWidth x maxHeight.
+// Frames are composited onto the full canvas first, honoring their disposal methods, so
+// each resized frame is a complete picture quantized back to the palette of its source frame.
+func resizeAnimatedGIF(animation *gif.GIF, maxWidth, maxHeight int, filter imaging.ResampleFilter) *gif.GIF {
+	width, height := gifSize(animation)
+	canvas := image.NewNRGBA(image.Rect(0, 0, width, height))
+
+	resized := &gif.GIF{
+		LoopCount: animation.LoopCount,
+		Delay:     append([]int(nil), animation.Delay...),
+	}
+
+	for i, frame := range animation.Image {
+		disposal := byte(gif.DisposalNone)
+		if i < len(animation.Disposal) {
+			disposal = animation.Disposal[i]
+		}
+
+		var previous *image.NRGBA
+		if disposal == gif.DisposalPrevious {
+			previous = imaging.Clone(canvas)
+		}
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+
+		scaled := fitWithin(canvas, maxWidth, maxHeight, filter)
+		paletted := image.NewPaletted(scaled.Bounds(), frame.Palette)
+		draw.FloydSteinberg.Draw(paletted, scaled.Bounds(), scaled, scaled.Bounds().Min)
+		resized.Image = append(resized.Image, paletted)
+		// Every frame is complete, so the canvas is cleared before the next one
+		resized.Disposal = append(resized.Disposal, gif.DisposalBackground)
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+
+	return resized
+}
+
+// isAnimatedWebP reports whether a WebP file is animated (VP8X header with the animation flag)
+func isAnimatedWebP(filePath string) bool {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	header := make([]byte, 21)
+	if _, err := file.ReadAt(header, 0); err != nil {
+		return false
+	}
+	return string(header[0:4]) == "RIFF" && string(header[8:12]) == "WEBP" &&
+		string(header[12:16]) == "VP8X" && header[20]&0x02 != 0
+}
@@ -0,0 +1,389 @@
+package core
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/disk"
+
+	"zensort/internal/config"
+)
+
+// ArchiveSeparator separates the path of an archive from the path of a file inside it
+// in recorded origins, e.g. "export.zip!/DCIM/Camera/IMG_0001.jpg"
+const ArchiveSeparator = "!/"
+
+// archiveSuffixes maps archive name suffixes to their formats, longest suffixes first
+var archiveSuffixes = []struct {
+	suffix string
+	format string
+}{
+	{".tar.gz", "tar.gz"},
+	{".tar.bz2", "tar.bz2"},
+	{".tar.xz", "tar.xz"},
+	{".tgz", "tar.gz"},
+	{".tbz2", "tar.bz2"},
+	{".tbz", "tar.bz2"},
+	{".txz", "tar.xz"},
+	{".tar", "tar"},
+	{".zip", "zip"},
+}
+
+// archiveBudget caps what one archive, together with the archives inside it, may extract,
+// so a zip bomb can't fill the destination disk
+type archiveBudget struct {
+	maxBytes   int64 // 0 or less for no limit
+	maxEntries int   // 0 for no limit
+	bytes      int64
+	entries    int
+}
+
+// newArchiveBudget returns the extraction budget of a top-level archive. Without a
+// configured size an archive may take half the free space of the destination, leaving
+// the other half for the organized copies of its files.
+func newArchiveBudget(cfg *config.Config, destDir string) *archiveBudget {
+	budget := &archiveBudget{maxBytes: cfg.Archives.MaxExtractedBytes, maxEntries: cfg.Archives.MaxEntries}
+	if budget.maxBytes == 0 {
+		if usage, err := disk.Usage(destDir); err == nil {
+			budget.maxBytes = max(int64(usage.Free/2), 1)
+		}
+	}
+	return budget
+}
+
+// reserve accounts for the next entry and its declared size before it is extracted
+func (b *archiveBudget) reserve(declaredSize int64) error {
+	if b.maxEntries > 0 && b.entries+1 > b.maxEntries {
+		return fmt.Errorf("archive has more than %d files", b.maxEntries)
+	}
+	if b.maxBytes > 0 && declaredSize > b.maxBytes-b.bytes {
+		return fmt.Errorf("archive expands to more than %d bytes", b.maxBytes)
+	}
+	b.entries++
+	return nil
+}
+
+// remaining returns how many more bytes may be extracted, or -1 for no limit
+func (b *archiveBudget) remaining() int64 {
+	if b.maxBytes <= 0 {
+		return -1
+	}
+	return b.maxBytes - b.bytes
+}
+
+// archiveEntry is a file extracted from an archive
+type archiveEntry struct {
+	Name string // Slash-separated path inside the archive
+	Path string // Where the file was extracted
+	Err  error  // Why the file could not be extracted, e.g. a clash with a directory
+}
+
+// archiveFormat returns the format of an archive from its name, or "" for other files
+func archiveFormat(filePath string) string {
+	name := strings.ToLower(filepath.Base(filePath))
+	for _, candidate := range archiveSuffixes {
+		if strings.HasSuffix(name, candidate.suffix) {
+			return candidate.format
+		}
+	}
+	return ""
+}
+
+// archiveEntryPath cleans the name of an archive entry so it can only land inside the
+// extraction folder. It returns "" for entries that should not be extracted.
+func archiveEntryPath(name string) string {
+	name = path.Clean("/" + strings.ReplaceAll(name, "\\", "/"))
+	name = strings.TrimPrefix(name, "/")
+	if name == "" || name == "." {
+		return ""
+	}
+	// macOS adds resource forks that are not real files
+	if strings.HasPrefix(name, "__MACOSX/") || strings.HasPrefix(path.Base(name), "._") {
+		return ""
+	}
+	return name
+}
+
+// extractArchive extracts the regular files of an archive into destDir, keeping their
+// modification times. Links, devices and entries escaping destDir are left out, entries
+// that can't be created are returned with their error, and extraction stops with an
+// error once the budget is exceeded.
+func extractArchive(archivePath, format, destDir string, budget *archiveBudget) ([]archiveEntry, error) {
+	if format == "zip" {
+		return extractZip(archivePath, destDir, budget)
+	}
+
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	switch format {
+	case "tar.gz":
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip stream: %w", err)
+		}
+		defer gz.Close()
+		reader = gz
+	case "tar.bz2":
+		reader = bzip2.NewReader(file)
+	case "tar.xz":
+		// The standard library has no xz decoder; use the xz tool when installed
+		cmd := exec.Command("xz", "--decompress", "--stdout")
+		hideConsoleWindow(cmd)
+		cmd.Stdin = file
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			return nil, err
+		}
+		if err := cmd.Start(); err != nil {
+			return nil, fmt.Errorf("xz not available: %w", err)
+		}
+		defer cmd.Wait()
+		defer stdout.Close()
+		reader = stdout
+	}
+
+	return extractTar(reader, destDir, budget)
+}
+
+// extractZip extracts the files of a zip archive
+func extractZip(archivePath, destDir string, budget *archiveBudget) ([]archiveEntry, error) {
+	archive, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid zip archive: %w", err)
+	}
+	defer archive.Close()
+
+	entries := &archiveEntries{}
+	for _, file := range archive.File {
+		name := archiveEntryPath(file.Name)
+		if name == "" || !file.Mode().IsRegular() {
+			continue
+		}
+		if err := budget.reserve(int64(file.UncompressedSize64)); err != nil {
+			return entries.list, err
+		}
+
+		reader, err := file.Open()
+		if err != nil {
+			return entries.list, fmt.Errorf("failed to read %s: %w", file.Name, err)
+		}
+		entry, err := writeArchiveEntry(reader, name, destDir, file.Modified, budget)
+		reader.Close()
+		if err != nil {
+			return entries.list, err
+		}
+		entries.add(entry)
+	}
+	return entries.list, nil
+}
+
+// extractTar extracts the files of a tar stream
+func extractTar(r io.Reader, destDir string, budget *archiveBudget) ([]archiveEntry, error) {
+	reader := tar.NewReader(r)
+	entries := &archiveEntries{}
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return entries.list, nil
+		}
+		if err != nil {
+			return entries.list, fmt.Errorf("invalid tar archive: %w", err)
+		}
+
+		name := archiveEntryPath(header.Name)
+		if name == "" || header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := budget.reserve(header.Size); err != nil {
+			return entries.list, err
+		}
+
+		entry, err := writeArchiveEntry(reader, name, destDir, header.ModTime, budget)
+		if err != nil {
+			return entries.list, err
+		}
+		entries.add(entry)
+	}
+}
+
+// archiveEntries collects extracted files once per name. An entry stored again under
+// the same name replaces the earlier file, as when a tar archive is appended to.
+type archiveEntries struct {
+	list []archiveEntry
+	seen map[string]bool
+}
+
+// add records an extracted file unless its name was extracted before
+func (e *archiveEntries) add(entry archiveEntry) {
+	if e.seen == nil {
+		e.seen = make(map[string]bool)
+	}
+	if !e.seen[entry.Name] {
+		e.seen[entry.Name] = true
+		e.list = append(e.list, entry)
+	}
+}
+
+// writeArchiveEntry streams one archive entry to its place below destDir, within the budget.
+// An entry whose name clashes with a directory of another entry (or the reverse) is
+// returned with its error, without failing the rest of the archive.
+func writeArchiveEntry(r io.Reader, name, destDir string, modTime time.Time, budget *archiveBudget) (archiveEntry, error) {
+	target := filepath.Join(destDir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return archiveEntry{Name: name, Err: fmt.Errorf("failed to create directory for %s: %w", name, err)}, nil
+	}
+
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return archiveEntry{Name: name, Err: fmt.Errorf("failed to create %s: %w", name, err)}, nil
+	}
+	// Declared sizes can lie, so the budget is enforced on the bytes actually written
+	remaining := budget.remaining()
+	if remaining >= 0 {
+		r = io.LimitReader(r, remaining+1)
+	}
+	written, err := io.Copy(out, r)
+	budget.bytes += written
+	if err == nil && remaining >= 0 && written > remaining {
+		err = fmt.Errorf("archive expands to more than %d bytes", budget.maxBytes)
+	}
+	if err != nil {
+		out.Close()
+		os.Remove(target)
+		return archiveEntry{}, fmt.Errorf("failed to extract %s: %w", name, err)
+	}
+	if err := out.Close(); err != nil {
+		return archiveEntry{}, fmt.Errorf("failed to extract %s: %w", name, err)
+	}
+
+	// Files without a better date fall back to their modification time
+	if !modTime.IsZero() {
+		os.Chtimes(target, modTime, modTime)
+	}
+	return archiveEntry{Name: name, Path: target}, nil
+}
+
+// expandArchive extracts an archive into the staging folder and returns the files to
+// organize. Nested archives are expanded up to the configured depth and share the
+// extraction budget of the top-level archive.
+func (fp *FileProcessor) expandArchive(archivePath, origin, format string, depth int, budget *archiveBudget) ([]string, error) {
+	if fp.archiveDir == "" {
+		stagingRoot := filepath.Join(fp.destDir, "zensort-tmp")
+		if err := os.MkdirAll(stagingRoot, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create archive staging directory: %w", err)
+		}
+		dir, err := os.MkdirTemp(stagingRoot, "archives-")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create archive staging directory: %w", err)
+		}
+		fp.archiveDir = dir
+	}
+
+	dir, err := os.MkdirTemp(fp.archiveDir, "archive-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create archive staging directory: %w", err)
+	}
+	entries, err := extractArchive(archivePath, format, dir, budget)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		entryOrigin := origin + ArchiveSeparator + entry.Name
+		if entry.Err != nil {
+			fp.logger.LogError(LogLevelError, "Failed to extract file from archive", entryOrigin, entry.Err)
+			fp.progressTracker.AddError(fmt.Sprintf("Error extracting %s: %v", entryOrigin, entry.Err))
+			fp.manifest.Add(ManifestEntry{
+				Source:   entryOrigin,
+				Category: fp.detector.GetFileTypeString(fp.detector.DetectFileType(entry.Name)),
+				Outcome:  OutcomeFailed,
+				Reason:   entry.Err.Error(),
+			})
+			continue
+		}
+		fp.origins[entry.Path] = entryOrigin
+		if fp.detector.ShouldSkipFile(entryOrigin, fp.config.SkipFiles.Extensions, fp.config.SkipFiles.Patterns, fp.config.SkipFiles.Directories) {
+			fp.skipFile(entry.Path, OutcomeFiltered, "matches skip pattern")
+			continue
+		}
+
+		if nestedFormat := archiveFormat(entry.Name); nestedFormat != "" && depth < fp.config.Archives.MaxDepth {
+			nested, err := fp.expandArchive(entry.Path, entryOrigin, nestedFormat, depth+1, budget)
+			if err == nil {
+				files = append(files, nested...)
+				continue
+			}
+			fp.logger.LogError(LogLevelWarning, "Failed to expand nested archive, organizing it as a file", entryOrigin, err)
+		}
+
+		files = append(files, entry.Path)
+	}
+
+	fp.logger.LogOperation("INFO", fmt.Sprintf("Expanded %s archive with %d files", format, len(files)), origin)
+	return files, nil
+}
+
+// organizeArchive expands one archive, organizes its files and deletes them before the
+// next archive is expanded, so only one archive takes up staging space at a time. An
+// archive that can't be expanded is organized as a file.
+func (fp *FileProcessor) organizeArchive(ctx context.Context, organizer *FileOrganizer, archivePath string) error {
+	defer fp.removeArchiveStaging()
+
+	recorded := fp.manifest.Len()
+	files, err := fp.expandArchive(archivePath, archivePath, archiveFormat(archivePath), 1, newArchiveBudget(fp.config, fp.destDir))
+	if err != nil {
+		fp.logger.LogError(LogLevelWarning, "Failed to expand archive, organizing it as a file", archivePath, err)
+		files = []string{archivePath}
+	}
+
+	// Files of an archive are only grouped with files of the same archive
+	files, fp.groups = fp.groupFiles(files)
+	var totalSize int64
+	for _, path := range files {
+		totalSize += fileSize(path)
+	}
+	fp.progressTracker.AddTotal(int64(len(files)), totalSize)
+	for _, entry := range fp.manifest.EntriesFrom(recorded) {
+		fp.progressTracker.AddOutcome(entry.Outcome)
+	}
+
+	return fp.organizeFiles(ctx, organizer, files)
+}
+
+// originOf returns the path a scanned file is reported under
+func (fp *FileProcessor) originOf(path string) string {
+	if origin, ok := fp.origins[path]; ok {
+		return origin
+	}
+	return path
+}
+
+// removeArchiveStaging deletes the files extracted from the current archive
+func (fp *FileProcessor) removeArchiveStaging() {
+	if fp.archiveDir == "" {
+		return
+	}
+	if err := os.RemoveAll(fp.archiveDir); err != nil {
+		fp.logger.LogError(LogLevelWarning, "Failed to remove archive staging directory", fp.archiveDir, err)
+	}
+	os.Remove(filepath.Dir(fp.archiveDir)) // Only succeeds when no other run is using it
+	fp.archiveDir = ""
+}
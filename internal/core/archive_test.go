@@ -0,0 +1,257 @@
+package core
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"zensort/internal/config"
+)
+
+// testArchiveFile is a file stored in a test archive
+type testArchiveFile struct {
+	name    string
+	content string
+}
+
+// testZip builds a zip archive holding the given files
+func testZip(t *testing.T, files ...testArchiveFile) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	for _, file := range files {
+		w, err := writer.Create(file.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(file.content))
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// testTar builds a tar archive holding the given files
+func testTar(t *testing.T, files ...testArchiveFile) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	writer := tar.NewWriter(&buf)
+	for _, file := range files {
+		header := &tar.Header{Name: file.name, Mode: 0644, Size: int64(len(file.content)), ModTime: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)}
+		if err := writer.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		writer.Write([]byte(file.content))
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestArchiveEntryPath(t *testing.T) {
+	for name, want := range map[string]string{
+		"DCIM/IMG_0001.jpg":       "DCIM/IMG_0001.jpg",
+		"./a/../b.jpg":            "b.jpg",
+		"../../etc/passwd":        "etc/passwd",
+		"/abs/path.jpg":           "abs/path.jpg",
+		`windows\style\name.jpg`:  "windows/style/name.jpg",
+		"__MACOSX/DCIM/._IMG.jpg": "",
+		"DCIM/._IMG_0001.jpg":     "",
+		"":                        "",
+		"..":                      "",
+	} {
+		if got := archiveEntryPath(name); got != want {
+			t.Errorf("archiveEntryPath(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestArchiveFormat(t *testing.T) {
+	for name, want := range map[string]string{
+		"export.zip": "zip", "EXPORT.TAR.GZ": "tar.gz", "a.tgz": "tar.gz", "a.tar.bz2": "tar.bz2",
+		"a.tbz": "tar.bz2", "a.tar.xz": "tar.xz", "a.tar": "tar", "a.gz": "", "photo.jpg": "",
+	} {
+		if got := archiveFormat(name); got != want {
+			t.Errorf("archiveFormat(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestExtractArchive(t *testing.T) {
+	files := []testArchiveFile{
+		{"DCIM/a.jpg", "first"},
+		{"DCIM/b.jpg", "bee"},
+		{"DCIM/a.jpg", "second"},
+		{"../escape.jpg", "up"},
+	}
+
+	for _, format := range []string{"zip", "tar"} {
+		t.Run(format, func(t *testing.T) {
+			data := testTar(t, files...)
+			if format == "zip" {
+				data = testZip(t, files...)
+			}
+			archivePath := writeTestFile(t, "test."+format, data)
+			destDir := t.TempDir()
+
+			entries, err := extractArchive(archivePath, format, destDir, &archiveBudget{})
+			if err != nil {
+				t.Fatalf("extractArchive() error = %v", err)
+			}
+			var names []string
+			for _, entry := range entries {
+				names = append(names, entry.Name)
+			}
+			if strings.Join(names, ",") != "DCIM/a.jpg,DCIM/b.jpg,escape.jpg" {
+				t.Errorf("extractArchive() = %q, want each name once", names)
+			}
+			if content, _ := os.ReadFile(filepath.Join(destDir, "DCIM", "a.jpg")); string(content) != "second" {
+				t.Errorf("duplicate entry = %q, want the later copy", content)
+			}
+		})
+	}
+}
+
+func TestExtractArchiveNameClash(t *testing.T) {
+	files := []testArchiveFile{{"clash", "file"}, {"clash/photo.jpg", "under a file"}, {"other.jpg", "kept"}}
+
+	for _, format := range []string{"zip", "tar"} {
+		t.Run(format, func(t *testing.T) {
+			data := testTar(t, files...)
+			if format == "zip" {
+				data = testZip(t, files...)
+			}
+			entries, err := extractArchive(writeTestFile(t, "test."+format, data), format, t.TempDir(), &archiveBudget{})
+			if err != nil {
+				t.Fatalf("extractArchive() error = %v", err)
+			}
+			var extracted, failed []string
+			for _, entry := range entries {
+				if entry.Err != nil {
+					failed = append(failed, entry.Name)
+				} else {
+					extracted = append(extracted, entry.Name)
+				}
+			}
+			if strings.Join(extracted, ",") != "clash,other.jpg" || strings.Join(failed, ",") != "clash/photo.jpg" {
+				t.Errorf("extracted %q and failed %q, want only the clashing entry to fail", extracted, failed)
+			}
+		})
+	}
+}
+
+func TestExtractArchiveBudget(t *testing.T) {
+	files := []testArchiveFile{{"a.jpg", "12345"}, {"b.jpg", "67890"}, {"c.jpg", "abcde"}}
+
+	tests := []struct {
+		name    string
+		budget  archiveBudget
+		wantErr bool
+	}{
+		{"no limits", archiveBudget{}, false},
+		{"exactly at the limits", archiveBudget{maxBytes: 15, maxEntries: 3}, false},
+		{"too many bytes", archiveBudget{maxBytes: 14}, true},
+		{"too many entries", archiveBudget{maxEntries: 2}, true},
+		{"budget used by an outer archive", archiveBudget{maxBytes: 20, bytes: 10}, true},
+	}
+
+	for _, format := range []string{"zip", "tar"} {
+		for _, tt := range tests {
+			t.Run(format+"/"+tt.name, func(t *testing.T) {
+				data := testTar(t, files...)
+				if format == "zip" {
+					data = testZip(t, files...)
+				}
+				budget := tt.budget
+				_, err := extractArchive(writeTestFile(t, "test."+format, data), format, t.TempDir(), &budget)
+				if (err != nil) != tt.wantErr {
+					t.Errorf("extractArchive() error = %v, wantErr %v", err, tt.wantErr)
+				}
+			})
+		}
+	}
+}
+
+func TestWriteArchiveEntryEnforcesWrittenBytes(t *testing.T) {
+	// The declared size passed the check, but the stream holds more
+	budget := &archiveBudget{maxBytes: 4}
+	destDir := t.TempDir()
+	if _, err := writeArchiveEntry(strings.NewReader("longer than declared"), "bomb.bin", destDir, time.Time{}, budget); err == nil {
+		t.Fatal("writeArchiveEntry() error = nil past the budget")
+	}
+	if _, err := os.Stat(filepath.Join(destDir, "bomb.bin")); !os.IsNotExist(err) {
+		t.Errorf("partial file left behind: %v", err)
+	}
+}
+
+func TestExtractArchiveMalformed(t *testing.T) {
+	valid := testTar(t, testArchiveFile{"a.jpg", strings.Repeat("x", 2000)})
+
+	tests := []struct {
+		name   string
+		format string
+		data   []byte
+	}{
+		{"zip garbage", "zip", []byte("not a zip archive")},
+		{"zip truncated", "zip", testZip(t, testArchiveFile{"a.jpg", "data"})[:20]},
+		{"tar truncated", "tar", valid[:700]},
+		{"tar.gz garbage", "tar.gz", []byte("not gzip")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := extractArchive(writeTestFile(t, "test.bin", tt.data), tt.format, t.TempDir(), &archiveBudget{}); err == nil {
+				t.Error("extractArchive() error = nil")
+			}
+		})
+	}
+}
+
+func TestOrganizeArchiveRemovesStaging(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Archives.Enabled = true
+	destDir := t.TempDir()
+	fp, err := NewFileProcessor(cfg, destDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { fp.Close() })
+	organizer, err := NewFileOrganizer(fp.config, fp.destDir, fp.db, fp.logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	organizer.SetOrigins(fp.origins)
+	organizer.SetManifest(fp.manifest)
+
+	archivePath := writeTestFile(t, "export.zip", testZip(t,
+		testArchiveFile{"notes", "file"}, testArchiveFile{"notes/a.txt", "under a file"}, testArchiveFile{"b.txt", "kept"}))
+	if err := fp.organizeArchive(context.Background(), organizer, archivePath); err != nil {
+		t.Fatalf("organizeArchive() error = %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(destDir, "zensort-tmp")); !os.IsNotExist(err) {
+		t.Errorf("staging folder left behind after the archive: %v", err)
+	}
+	outcomes := make(map[string]Outcome)
+	for _, entry := range fp.manifest.Entries() {
+		outcomes[entry.Source] = entry.Outcome
+	}
+	want := map[string]Outcome{
+		archivePath + "!/notes":       OutcomeOrganized,
+		archivePath + "!/notes/a.txt": OutcomeFailed,
+		archivePath + "!/b.txt":       OutcomeOrganized,
+	}
+	for source, outcome := range want {
+		if outcomes[source] != outcome {
+			t.Errorf("outcome of %s = %q, want %q (manifest %v)", source, outcomes[source], outcome, outcomes)
+		}
+	}
+}
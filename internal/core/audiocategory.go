@@ -0,0 +1,126 @@
+package core
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"zensort/internal/config"
+)
+
+// compiledAudioCategory is an audio category with its regexes compiled
+type compiledAudioCategory struct {
+	category config.AudioCategory
+	regexes  []*regexp.Regexp
+}
+
+// AudioCategorizer assigns audio files to the configured categories in priority order
+type AudioCategorizer struct {
+	categories []compiledAudioCategory
+	fallback   string
+}
+
+// NewAudioCategorizer compiles the audio categories, ordered by priority (list order breaks ties)
+func NewAudioCategorizer(categories config.AudioCategoryList) (*AudioCategorizer, error) {
+	categorizer := &AudioCategorizer{fallback: "Songs"}
+
+	for _, category := range categories {
+		compiled := compiledAudioCategory{category: category}
+		for _, pattern := range category.Regexes {
+			regex, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("audio category %s: invalid regex %q: %w", category.Key, pattern, err)
+			}
+			compiled.regexes = append(compiled.regexes, regex)
+		}
+		categorizer.categories = append(categorizer.categories, compiled)
+	}
+
+	sort.SliceStable(categorizer.categories, func(i, j int) bool {
+		return categorizer.categories[i].category.Priority > categorizer.categories[j].category.Priority
+	})
+
+	// Files no category claims go to the songs folder, as they always have
+	if songs := categories.Find("songs"); songs != nil && songs.FolderName != "" {
+		categorizer.fallback = songs.FolderName
+	} else if len(categorizer.categories) > 0 {
+		categorizer.fallback = categorizer.categories[len(categorizer.categories)-1].category.FolderName
+	}
+
+	return categorizer, nil
+}
+
+// Categorize returns the folder of the first category whose conditions the file meets
+func (ac *AudioCategorizer) Categorize(fc *fileContext) string {
+	for i := range ac.categories {
+		if ac.categories[i].matches(fc) {
+			return ac.categories[i].category.FolderName
+		}
+	}
+	return ac.fallback
+}
+
+// matches reports whether a file meets all conditions of the category.
+// Cheap filename checks run before anything that reads the file.
+func (c *compiledAudioCategory) matches(fc *fileContext) bool {
+	category := &c.category
+	filename := filepath.Base(fc.path)
+
+	if len(category.Extensions) > 0 && !containsFold(category.Extensions, filepath.Ext(filename)) {
+		return false
+	}
+
+	if len(category.Patterns) > 0 || len(c.regexes) > 0 {
+		lowerName := strings.ToLower(filename)
+		nameMatched := false
+		for _, pattern := range category.Patterns {
+			if pattern != "" && strings.Contains(lowerName, strings.ToLower(pattern)) {
+				nameMatched = true
+				break
+			}
+		}
+		stem := strings.TrimSuffix(filename, filepath.Ext(filename))
+		for _, regex := range c.regexes {
+			if nameMatched {
+				break
+			}
+			nameMatched = regex.MatchString(stem)
+		}
+		if !nameMatched {
+			return false
+		}
+	}
+
+	if category.MinDurationSeconds > 0 || category.MaxDurationSeconds > 0 {
+		duration, err := fc.Duration()
+		if err != nil {
+			return false
+		}
+		if category.MinDurationSeconds > 0 && duration < secondsToDuration(category.MinDurationSeconds) {
+			return false
+		}
+		if category.MaxDurationSeconds > 0 && duration > secondsToDuration(category.MaxDurationSeconds) {
+			return false
+		}
+	}
+
+	if category.Channels > 0 || category.MinSampleRate > 0 || category.MaxSampleRate > 0 {
+		props := fc.AudioProperties()
+		if props == nil {
+			return false
+		}
+		if category.Channels > 0 && props.Channels != category.Channels {
+			return false
+		}
+		if category.MinSampleRate > 0 && props.SampleRate < category.MinSampleRate {
+			return false
+		}
+		if category.MaxSampleRate > 0 && (props.SampleRate == 0 || props.SampleRate > category.MaxSampleRate) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,164 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"zensort/internal/config"
+)
+
+// testWAV builds a PCM WAV file whose data chunk lasts the given seconds at byteRate
+func testWAV(channels, sampleRate, byteRate int, seconds float64) []byte {
+	format := binary.LittleEndian.AppendUint16(nil, 1) // PCM
+	format = binary.LittleEndian.AppendUint16(format, uint16(channels))
+	format = binary.LittleEndian.AppendUint32(format, uint32(sampleRate))
+	format = binary.LittleEndian.AppendUint32(format, uint32(byteRate))
+	format = binary.LittleEndian.AppendUint16(format, uint16(channels))
+	format = binary.LittleEndian.AppendUint16(format, 8)
+
+	body := bytes.Join([][]byte{
+		[]byte("WAVE"),
+		riffChunkBytes("fmt ", format),
+		riffChunkBytes("data", make([]byte, int(float64(byteRate)*seconds))),
+	}, nil)
+	return riffChunkBytes("RIFF", body)
+}
+
+// riffChunkBytes builds a RIFF chunk, padded to an even length
+func riffChunkBytes(id string, payload []byte) []byte {
+	out := append([]byte(id), binary.LittleEndian.AppendUint32(nil, uint32(len(payload)))...)
+	out = append(out, payload...)
+	if len(payload)%2 == 1 {
+		out = append(out, 0)
+	}
+	return out
+}
+
+// testAudioContext writes an audio file and returns its context
+func testAudioContext(t *testing.T, name string, data []byte) *fileContext {
+	t.Helper()
+	return &fileContext{path: writeTestFile(t, name, data), fileType: FileTypeAudio}
+}
+
+func TestAudioCategorizerCategorize(t *testing.T) {
+	stereo := testWAV(2, 44100, 100, 5)
+	tests := []struct {
+		name       string
+		categories config.AudioCategoryList
+		file       string
+		data       []byte
+		want       string
+	}{
+		{"priority wins over list order", config.AudioCategoryList{
+			{Key: "low", FolderName: "Low", Priority: 1, Patterns: []string{"call"}},
+			{Key: "high", FolderName: "High", Priority: 5, Patterns: []string{"call"}},
+		}, "call.wav", stereo, "High"},
+		{"list order breaks ties", config.AudioCategoryList{
+			{Key: "first", FolderName: "First", Patterns: []string{"call"}},
+			{Key: "second", FolderName: "Second", Patterns: []string{"call"}},
+		}, "call.wav", stereo, "First"},
+		{"patterns ignore case", config.AudioCategoryList{
+			{Key: "calls", FolderName: "Calls", Patterns: []string{"Call"}},
+		}, "PHONE CALL.wav", stereo, "Calls"},
+		{"extension must match", config.AudioCategoryList{
+			{Key: "calls", FolderName: "Calls", Extensions: []string{".amr"}, Patterns: []string{"call"}},
+			{Key: "songs", FolderName: "Music"},
+		}, "call.wav", stereo, "Music"},
+		{"regex matches the stem", config.AudioCategoryList{
+			{Key: "numbers", FolderName: "Numbers", Regexes: []string{`^\d+$`}},
+		}, "5551234567.wav", stereo, "Numbers"},
+		{"regex misses", config.AudioCategoryList{
+			{Key: "numbers", FolderName: "Numbers", Regexes: []string{`^\d+$`}},
+			{Key: "songs", FolderName: "Music"},
+		}, "5551234567 meeting.wav", stereo, "Music"},
+		{"min duration", config.AudioCategoryList{
+			{Key: "long", FolderName: "Long", MinDurationSeconds: 10},
+			{Key: "songs", FolderName: "Music"},
+		}, "a.wav", stereo, "Music"},
+		{"max duration", config.AudioCategoryList{
+			{Key: "short", FolderName: "Short", MaxDurationSeconds: 10},
+		}, "a.wav", stereo, "Short"},
+		{"channels", config.AudioCategoryList{
+			{Key: "mono", FolderName: "Mono", Channels: 1},
+			{Key: "stereo", FolderName: "Stereo", Channels: 2},
+		}, "a.wav", stereo, "Stereo"},
+		{"min sample rate", config.AudioCategoryList{
+			{Key: "hifi", FolderName: "Hifi", MinSampleRate: 48000},
+			{Key: "cd", FolderName: "CD", MinSampleRate: 44100},
+		}, "a.wav", stereo, "CD"},
+		{"max sample rate", config.AudioCategoryList{
+			{Key: "narrow", FolderName: "Narrow", MaxSampleRate: 8000},
+			{Key: "songs", FolderName: "Music"},
+		}, "a.wav", stereo, "Music"},
+		{"unreadable properties fail the condition", config.AudioCategoryList{
+			{Key: "mono", FolderName: "Mono", Channels: 1},
+			{Key: "songs", FolderName: "Music"},
+		}, "a.wav", []byte("not audio"), "Music"},
+		{"unknown duration fails the condition", config.AudioCategoryList{
+			{Key: "short", FolderName: "Short", MaxDurationSeconds: 10},
+			{Key: "songs", FolderName: "Music"},
+		}, "a.xyz", []byte("not audio"), "Music"},
+		{"fallback without songs category", config.AudioCategoryList{
+			{Key: "calls", FolderName: "Calls", Patterns: []string{"call"}},
+			{Key: "other", FolderName: "Other", Priority: -1, Patterns: []string{"talk"}},
+		}, "a.wav", stereo, "Other"},
+		{"fallback without categories", nil, "a.wav", stereo, "Songs"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			categorizer, err := NewAudioCategorizer(tt.categories)
+			if err != nil {
+				t.Fatalf("NewAudioCategorizer() error = %v", err)
+			}
+			if got := categorizer.Categorize(testAudioContext(t, tt.file, tt.data)); got != tt.want {
+				t.Errorf("Categorize(%s) = %q, want %q", tt.file, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewAudioCategorizerInvalidRegex(t *testing.T) {
+	if _, err := NewAudioCategorizer(config.AudioCategoryList{{Key: "bad", Regexes: []string{"("}}}); err == nil {
+		t.Error("NewAudioCategorizer() error = nil for an invalid regex")
+	}
+}
+
+func TestCategorizeAudioDefaults(t *testing.T) {
+	text := func(s string) []byte { return append([]byte{0}, s...) }
+	tagged := id3Tag(3, 0, id3Frame(3, "TPE1", text("Band")), id3Frame(3, "TIT2", text("Phone Call")))
+	stereo := testWAV(2, 44100, 100, 300)
+
+	tests := []struct {
+		file string
+		data []byte
+		want string
+	}{
+		{"call_with_voice_note.m4a", stereo, "Call Recordings"},
+		{"+44 7911 123456.amr", stereo, "Call Recordings"},
+		{"(555) 123-4567.m4a", stereo, "Call Recordings"},
+		{"New Recording 3.m4a", stereo, "Voice Recordings"},
+		{"Voice Memo.m4a", stereo, "Voice Recordings"},
+		{"20230415_101112.m4a", stereo, "Songs"},
+		{"2023-04-15 meeting.m4a", stereo, "Other Audio"},
+		{"hotel lobby.wav", stereo, "Songs"},
+		{"simple tune.mp3", stereo, "Songs"},
+		{"20230415_101112.wav", testWAV(1, 8000, 100, 1200), "Detected Call Recordings"},
+		{"20230415_101112.m4a", testWAV(1, 44100, 100, 60), "Detected Voice Recordings"},
+		{"20230415_101112.ogg", testWAV(1, 44100, 100, 1200), "Songs"},
+		{"Phone Call.mp3", tagged, "Songs"},
+	}
+
+	cfg := config.DefaultConfig()
+	categorizer, err := NewAudioCategorizer(cfg.AudioCategories)
+	if err != nil {
+		t.Fatal(err)
+	}
+	organizer := &FileOrganizer{config: cfg, audioCategories: categorizer}
+	for _, tt := range tests {
+		if got := organizer.categorizeAudio(testAudioContext(t, tt.file, tt.data)); got != tt.want {
+			t.Errorf("categorizeAudio(%s) = %q, want %q", tt.file, got, tt.want)
+		}
+	}
+}
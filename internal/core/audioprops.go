@@ -0,0 +1,234 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+// AudioProperties describe the audio stream of a file
+type AudioProperties struct {
+	Channels   int
+	SampleRate int // Hz
+}
+
+// mp3SampleRates are the MPEG audio sample rates by version (2.5, reserved, 2, 1) and index
+var mp3SampleRates = [4][3]int{
+	{11025, 12000, 8000},
+	{0, 0, 0},
+	{22050, 24000, 16000},
+	{44100, 48000, 32000},
+}
+
+// ReadAudioProperties reads the channel count and sample rate of an audio file.
+// WAV, MP3, FLAC, Ogg, AMR and MP4/M4A/3GP are read directly; other formats need ffprobe.
+func ReadAudioProperties(filePath string) (*AudioProperties, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	if props := readAudioPropertiesFrom(file, info.Size()); props != nil {
+		return props, nil
+	}
+	return probeAudioProperties(filePath)
+}
+
+// readAudioPropertiesFrom parses the audio properties from the file headers (nil if unknown)
+func readAudioPropertiesFrom(r io.ReaderAt, size int64) *AudioProperties {
+	header := make([]byte, 12)
+	if _, err := r.ReadAt(header, 0); err != nil && err != io.EOF {
+		return nil
+	}
+
+	switch {
+	case bytes.HasPrefix(header, []byte("#!AMR-WB\n")):
+		return &AudioProperties{Channels: 1, SampleRate: 16000}
+	case bytes.HasPrefix(header, []byte("#!AMR\n")):
+		return &AudioProperties{Channels: 1, SampleRate: 8000}
+	case string(header[0:4]) == "RIFF" && string(header[8:12]) == "WAVE":
+		return readWAVProperties(r, size)
+	case string(header[0:4]) == "fLaC":
+		return readFLACProperties(r)
+	case string(header[0:4]) == "OggS":
+		return readOggProperties(r)
+	case string(header[4:8]) == "ftyp":
+		return readMP4AudioProperties(r, size)
+	case bytes.HasPrefix(header, []byte("ID3")) || (header[0] == 0xFF && header[1]&0xE0 == 0xE0):
+		return readMP3Properties(r, size)
+	}
+	return nil
+}
+
+// readWAVProperties reads the "fmt " chunk of a WAV file
+func readWAVProperties(r io.ReaderAt, size int64) *AudioProperties {
+	chunk := make([]byte, 8)
+	for pos := int64(12); pos+8 <= size; {
+		if _, err := r.ReadAt(chunk, pos); err != nil {
+			return nil
+		}
+		chunkSize := int64(binary.LittleEndian.Uint32(chunk[4:8]))
+		if string(chunk[0:4]) == "fmt " {
+			format := make([]byte, 8)
+			if _, err := r.ReadAt(format, pos+8); err != nil {
+				return nil
+			}
+			return &AudioProperties{
+				Channels:   int(binary.LittleEndian.Uint16(format[2:4])),
+				SampleRate: int(binary.LittleEndian.Uint32(format[4:8])),
+			}
+		}
+		pos += 8 + chunkSize + chunkSize%2 // Chunks are word aligned
+	}
+	return nil
+}
+
+// readFLACProperties reads the STREAMINFO block that starts every FLAC file
+func readFLACProperties(r io.ReaderAt) *AudioProperties {
+	info := make([]byte, 18)
+	if _, err := r.ReadAt(info, 8); err != nil {
+		return nil
+	}
+	// 20 bits sample rate, 3 bits channels - 1
+	return &AudioProperties{
+		SampleRate: int(info[10])<<12 | int(info[11])<<4 | int(info[12])>>4,
+		Channels:   int(info[12]>>1&0x07) + 1,
+	}
+}
+
+// readOggProperties reads the identification header of an Ogg Vorbis or Opus stream
+func readOggProperties(r io.ReaderAt) *AudioProperties {
+	header := make([]byte, 27)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return nil
+	}
+	segmentCount := int64(header[26])
+	packet := make([]byte, 30)
+	if _, err := r.ReadAt(packet, 27+segmentCount); err != nil && err != io.EOF {
+		return nil
+	}
+
+	switch {
+	case bytes.HasPrefix(packet, []byte("\x01vorbis")):
+		return &AudioProperties{
+			Channels:   int(packet[11]),
+			SampleRate: int(binary.LittleEndian.Uint32(packet[12:16])),
+		}
+	case bytes.HasPrefix(packet, []byte("OpusHead")):
+		// Opus always decodes at 48 kHz; the header records the original input rate
+		return &AudioProperties{
+			Channels:   int(packet[9]),
+			SampleRate: int(binary.LittleEndian.Uint32(packet[12:16])),
+		}
+	}
+	return nil
+}
+
+// readMP4AudioProperties reads the sample entry of the first sound track of an MP4/M4A/3GP file
+func readMP4AudioProperties(r io.ReaderAt, size int64) *AudioProperties {
+	moov, ok := findBMFFBox(r, 0, size, "moov")
+	if !ok {
+		return nil
+	}
+
+	tracks, _ := readBMFFBoxes(r, moov.Offset, moov.End())
+	for _, track := range tracks {
+		if track.Type != "trak" {
+			continue
+		}
+		hdlr, ok := findBMFFBox(r, track.Offset, track.End(), "mdia", "hdlr")
+		if !ok {
+			continue
+		}
+		handler, err := readBMFFPayload(r, hdlr, 4096)
+		if err != nil || len(handler) < 12 || string(handler[8:12]) != "soun" {
+			continue
+		}
+
+		stsd, ok := findBMFFBox(r, track.Offset, track.End(), "mdia", "minf", "stbl", "stsd")
+		if !ok {
+			continue
+		}
+		// stsd: version/flags and entry count, then the sample entries
+		entries, _ := readBMFFBoxes(r, stsd.Offset+8, stsd.End())
+		if len(entries) == 0 {
+			continue
+		}
+		entry, err := readBMFFPayload(r, entries[0], 4096)
+		if err != nil || len(entry) < 28 {
+			continue
+		}
+		// AudioSampleEntry: 8 bytes SampleEntry, 8 reserved, channel count, sample size,
+		// 4 reserved, then the sample rate as 16.16 fixed point
+		return &AudioProperties{
+			Channels:   int(binary.BigEndian.Uint16(entry[16:18])),
+			SampleRate: int(binary.BigEndian.Uint32(entry[24:28]) >> 16),
+		}
+	}
+	return nil
+}
+
+// readMP3Properties reads the first MPEG audio frame header after any ID3v2 tag
+func readMP3Properties(r io.ReaderAt, size int64) *AudioProperties {
+	start, end := mpegAudioRange(r, size)
+	_, frame, ok := findFirstMPEGFrame(r, start, end)
+	if !ok {
+		return nil
+	}
+	return &AudioProperties{Channels: frame.channels, SampleRate: frame.sampleRate}
+}
+
+// probeAudioProperties asks ffprobe for the properties of the first audio stream
+func probeAudioProperties(filePath string) (*AudioProperties, error) {
+	cmd := exec.Command("ffprobe", "-v", "quiet", "-select_streams", "a:0",
+		"-show_entries", "stream=channels,sample_rate", "-of", "json", filePath)
+	hideConsoleWindow(cmd)
+
+	done := make(chan error, 1)
+	var output []byte
+	go func() {
+		var err error
+		output, err = cmd.Output()
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return nil, fmt.Errorf("ffprobe not available or failed: %w", err)
+		}
+	case <-time.After(10 * time.Second):
+		if cmd.Process != nil {
+			cmd.Process.Kill()
+		}
+		return nil, fmt.Errorf("ffprobe timeout after 10 seconds for file: %s", filePath)
+	}
+
+	var probe struct {
+		Streams []struct {
+			Channels   int    `json:"channels"`
+			SampleRate string `json:"sample_rate"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(output, &probe); err != nil {
+		return nil, fmt.Errorf("failed to parse ffprobe output: %w", err)
+	}
+	if len(probe.Streams) == 0 {
+		return nil, fmt.Errorf("no audio stream in %s", filePath)
+	}
+
+	sampleRate, _ := strconv.Atoi(probe.Streams[0].SampleRate)
+	return &AudioProperties{Channels: probe.Streams[0].Channels, SampleRate: sampleRate}, nil
+}
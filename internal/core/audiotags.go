@@ -0,0 +1,506 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// maxAudioTagSize caps how much tag data is read, so embedded cover art can't exhaust memory
+const maxAudioTagSize = 16 << 20
+
+// AudioTags are the descriptive tags of a music file
+type AudioTags struct {
+	Title       string
+	Artist      string
+	AlbumArtist string
+	Album       string
+	Genre       string
+	Year        int
+	Track       int
+	Disc        int
+}
+
+// IsMusic reports whether the tags describe a piece of music rather than a recording.
+// Voice memos and call recordings carry at most a title, songs name their artist too.
+func (t *AudioTags) IsMusic() bool {
+	return t != nil && t.Artist != "" && (t.Title != "" || t.Album != "")
+}
+
+// FolderArtist returns the artist a song is filed under, preferring the album artist
+// so compilations stay together
+func (t *AudioTags) FolderArtist() string {
+	if t.AlbumArtist != "" {
+		return t.AlbumArtist
+	}
+	return t.Artist
+}
+
+// ReadAudioTags reads ID3v2/ID3v1 (MP3), Vorbis comments (FLAC, Ogg) or MP4 atoms (M4A)
+func ReadAudioTags(filePath string) (*AudioTags, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := info.Size()
+
+	header := make([]byte, 12)
+	if _, err := file.ReadAt(header, 0); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	tags := &AudioTags{}
+	switch {
+	case bytes.HasPrefix(header, []byte("ID3")):
+		if err := readID3v2(file, tags); err != nil {
+			return nil, err
+		}
+		// ID3v1 fills in anything the ID3v2 tag lacks
+		readID3v1(file, size, tags)
+	case bytes.HasPrefix(header, []byte("fLaC")):
+		if err := readFLACComments(file, size, tags); err != nil {
+			return nil, err
+		}
+	case bytes.HasPrefix(header, []byte("OggS")):
+		if err := readOggComments(file, tags); err != nil {
+			return nil, err
+		}
+	case string(header[4:8]) == "ftyp":
+		readMP4Tags(file, size, tags)
+	default:
+		if !readID3v1(file, size, tags) {
+			return nil, fmt.Errorf("no audio tags in %s", filepath.Base(filePath))
+		}
+	}
+
+	return tags, nil
+}
+
+// readID3v2 reads an ID3v2.2, 2.3 or 2.4 tag at the start of a file
+func readID3v2(r io.ReaderAt, tags *AudioTags) error {
+	header := make([]byte, 10)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return err
+	}
+	version := header[3]
+	flags := header[5]
+	size := int64(syncsafeInt(header[6:10]))
+	if version < 2 || version > 4 || size > maxAudioTagSize {
+		return fmt.Errorf("unsupported ID3v2.%d tag", version)
+	}
+
+	data := make([]byte, size)
+	if _, err := r.ReadAt(data, 10); err != nil && err != io.EOF {
+		return err
+	}
+
+	// Whole-tag unsynchronisation (v2.2/v2.3) inserts 0x00 after every 0xFF
+	if flags&0x80 != 0 && version < 4 {
+		data = bytes.ReplaceAll(data, []byte{0xFF, 0x00}, []byte{0xFF})
+	}
+
+	pos := 0
+	if flags&0x40 != 0 && version >= 3 && len(data) >= 4 {
+		// Skip the extended header
+		if version == 4 {
+			pos = int(syncsafeInt(data[0:4]))
+		} else {
+			pos = int(binary.BigEndian.Uint32(data[0:4])) + 4
+		}
+	}
+
+	idSize, headerSize := 4, 10
+	if version == 2 {
+		idSize, headerSize = 3, 6
+	}
+
+	for pos+headerSize <= len(data) {
+		id := string(data[pos : pos+idSize])
+		if data[pos] == 0 {
+			break // Padding
+		}
+
+		var frameSize int
+		switch version {
+		case 2:
+			frameSize = int(data[pos+3])<<16 | int(data[pos+4])<<8 | int(data[pos+5])
+		case 3:
+			frameSize = int(binary.BigEndian.Uint32(data[pos+4 : pos+8]))
+		case 4:
+			frameSize = int(syncsafeInt(data[pos+4 : pos+8]))
+		}
+		pos += headerSize
+		if frameSize <= 0 || pos+frameSize > len(data) {
+			break
+		}
+		frame := data[pos : pos+frameSize]
+		pos += frameSize
+
+		if strings.HasPrefix(id, "T") {
+			setID3Text(tags, id, decodeID3Text(frame))
+		}
+	}
+
+	return nil
+}
+
+// setID3Text stores an ID3v2 text frame (v2.2 three-letter or v2.3+ four-letter IDs)
+func setID3Text(tags *AudioTags, id, value string) {
+	// Multiple values are NUL separated in v2.4, keep the first
+	value = strings.TrimSpace(strings.SplitN(value, "\x00", 2)[0])
+	if value == "" {
+		return
+	}
+
+	switch id {
+	case "TIT2", "TT2":
+		tags.Title = value
+	case "TPE1", "TP1":
+		tags.Artist = value
+	case "TPE2", "TP2":
+		tags.AlbumArtist = value
+	case "TALB", "TAL":
+		tags.Album = value
+	case "TCON", "TCO":
+		tags.Genre = cleanID3Genre(value)
+	case "TRCK", "TRK":
+		tags.Track = leadingNumber(value)
+	case "TPOS", "TPA":
+		tags.Disc = leadingNumber(value)
+	case "TYER", "TYE", "TDRC", "TDOR":
+		if tags.Year == 0 {
+			tags.Year = leadingNumber(value)
+		}
+	}
+}
+
+// decodeID3Text decodes an ID3v2 text frame according to its encoding byte
+func decodeID3Text(frame []byte) string {
+	if len(frame) == 0 {
+		return ""
+	}
+
+	text := frame[1:]
+	switch frame[0] {
+	case 0: // ISO-8859-1
+		return latin1ToString(text)
+	case 1: // UTF-16 with BOM
+		return decodeUTF16(text, true)
+	case 2: // UTF-16BE without BOM
+		return decodeUTF16(text, false)
+	default: // UTF-8
+		return strings.TrimRight(string(text), "\x00")
+	}
+}
+
+// decodeUTF16 decodes UTF-16 text, honouring a byte order mark when present
+func decodeUTF16(data []byte, hasBOM bool) string {
+	littleEndian := false
+	if hasBOM && len(data) >= 2 {
+		switch {
+		case data[0] == 0xFF && data[1] == 0xFE:
+			littleEndian = true
+			data = data[2:]
+		case data[0] == 0xFE && data[1] == 0xFF:
+			data = data[2:]
+		}
+	}
+
+	units := make([]uint16, 0, len(data)/2)
+	for i := 0; i+1 < len(data); i += 2 {
+		if littleEndian {
+			units = append(units, binary.LittleEndian.Uint16(data[i:]))
+		} else {
+			units = append(units, binary.BigEndian.Uint16(data[i:]))
+		}
+	}
+	return strings.TrimRight(string(utf16.Decode(units)), "\x00")
+}
+
+// latin1ToString converts ISO-8859-1 bytes to a string
+func latin1ToString(data []byte) string {
+	runes := make([]rune, 0, len(data))
+	for _, b := range data {
+		if b == 0 {
+			break
+		}
+		runes = append(runes, rune(b))
+	}
+	return string(runes)
+}
+
+// cleanID3Genre turns numeric genre references like "(17)" into plain text when possible
+func cleanID3Genre(genre string) string {
+	if strings.HasPrefix(genre, "(") {
+		if end := strings.Index(genre, ")"); end > 0 {
+			if rest := strings.TrimSpace(genre[end+1:]); rest != "" {
+				return rest
+			}
+			if index, err := strconv.Atoi(genre[1:end]); err == nil && index >= 0 && index < len(id3v1Genres) {
+				return id3v1Genres[index]
+			}
+		}
+	}
+	return genre
+}
+
+// syncsafeInt decodes a 28-bit ID3v2 syncsafe integer
+func syncsafeInt(b []byte) uint32 {
+	return uint32(b[0]&0x7F)<<21 | uint32(b[1]&0x7F)<<14 | uint32(b[2]&0x7F)<<7 | uint32(b[3]&0x7F)
+}
+
+// leadingNumber parses the number at the start of values like "3/12"
+func leadingNumber(value string) int {
+	end := 0
+	for end < len(value) && value[end] >= '0' && value[end] <= '9' {
+		end++
+	}
+	number, _ := strconv.Atoi(value[:end])
+	return number
+}
+
+// readID3v1 reads the 128-byte ID3v1 tag at the end of a file into any empty fields
+func readID3v1(r io.ReaderAt, size int64, tags *AudioTags) bool {
+	if size < 128 {
+		return false
+	}
+	data := make([]byte, 128)
+	if _, err := r.ReadAt(data, size-128); err != nil || string(data[0:3]) != "TAG" {
+		return false
+	}
+
+	field := func(b []byte) string {
+		return strings.TrimSpace(latin1ToString(b))
+	}
+	setIfEmpty := func(target *string, value string) {
+		if *target == "" {
+			*target = value
+		}
+	}
+
+	setIfEmpty(&tags.Title, field(data[3:33]))
+	setIfEmpty(&tags.Artist, field(data[33:63]))
+	setIfEmpty(&tags.Album, field(data[63:93]))
+	if tags.Year == 0 {
+		tags.Year = leadingNumber(field(data[93:97]))
+	}
+	// ID3v1.1 stores the track number in the last comment byte
+	if tags.Track == 0 && data[125] == 0 && data[126] != 0 {
+		tags.Track = int(data[126])
+	}
+	if tags.Genre == "" && int(data[127]) < len(id3v1Genres) {
+		tags.Genre = id3v1Genres[data[127]]
+	}
+	return true
+}
+
+// readFLACComments reads the VORBIS_COMMENT block of a FLAC file
+func readFLACComments(r io.ReaderAt, size int64, tags *AudioTags) error {
+	header := make([]byte, 4)
+	for pos := int64(4); pos+4 <= size; {
+		if _, err := r.ReadAt(header, pos); err != nil {
+			return err
+		}
+		last := header[0]&0x80 != 0
+		blockType := header[0] & 0x7F
+		length := int64(header[1])<<16 | int64(header[2])<<8 | int64(header[3])
+		pos += 4
+
+		if blockType == 4 {
+			if length > maxAudioTagSize {
+				return fmt.Errorf("FLAC comment block too large")
+			}
+			block := make([]byte, length)
+			if _, err := r.ReadAt(block, pos); err != nil && err != io.EOF {
+				return err
+			}
+			parseVorbisComments(block, tags)
+			return nil
+		}
+		if last {
+			break
+		}
+		pos += length
+	}
+	return nil
+}
+
+// readOggComments reads the comment header of an Ogg Vorbis or Opus file
+func readOggComments(r io.ReaderAt, tags *AudioTags) error {
+	// Reassemble the first packets from the Ogg pages
+	var packets [][]byte
+	var current []byte
+	header := make([]byte, 27)
+	for pos := int64(0); len(packets) < 2 && pos < maxAudioTagSize; {
+		if _, err := r.ReadAt(header, pos); err != nil || string(header[0:4]) != "OggS" {
+			break
+		}
+		segmentCount := int(header[26])
+		segments := make([]byte, segmentCount)
+		if _, err := r.ReadAt(segments, pos+27); err != nil {
+			break
+		}
+		pos += 27 + int64(segmentCount)
+
+		for _, segmentSize := range segments {
+			data := make([]byte, segmentSize)
+			if _, err := r.ReadAt(data, pos); err != nil && err != io.EOF {
+				return err
+			}
+			pos += int64(segmentSize)
+			current = append(current, data...)
+			// A segment shorter than 255 bytes ends the packet
+			if segmentSize < 255 {
+				packets = append(packets, current)
+				current = nil
+			}
+		}
+	}
+
+	if len(packets) < 2 {
+		return fmt.Errorf("no Ogg comment header")
+	}
+	comment := packets[1]
+	switch {
+	case bytes.HasPrefix(comment, []byte("\x03vorbis")):
+		parseVorbisComments(comment[7:], tags)
+	case bytes.HasPrefix(comment, []byte("OpusTags")):
+		parseVorbisComments(comment[8:], tags)
+	default:
+		return fmt.Errorf("unsupported Ogg codec")
+	}
+	return nil
+}
+
+// parseVorbisComments reads a Vorbis comment block (vendor string, then KEY=value pairs)
+func parseVorbisComments(data []byte, tags *AudioTags) {
+	if len(data) < 8 {
+		return
+	}
+	pos := 4 + int(binary.LittleEndian.Uint32(data[0:4]))
+	if pos+4 > len(data) {
+		return
+	}
+	count := int(binary.LittleEndian.Uint32(data[pos : pos+4]))
+	pos += 4
+
+	for i := 0; i < count && pos+4 <= len(data); i++ {
+		length := int(binary.LittleEndian.Uint32(data[pos : pos+4]))
+		pos += 4
+		if length < 0 || pos+length > len(data) {
+			break
+		}
+		comment := string(data[pos : pos+length])
+		pos += length
+
+		key, value, found := strings.Cut(comment, "=")
+		if !found {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.ToUpper(key) {
+		case "TITLE":
+			tags.Title = value
+		case "ARTIST":
+			tags.Artist = value
+		case "ALBUMARTIST", "ALBUM ARTIST":
+			tags.AlbumArtist = value
+		case "ALBUM":
+			tags.Album = value
+		case "GENRE":
+			tags.Genre = value
+		case "TRACKNUMBER":
+			tags.Track = leadingNumber(value)
+		case "DISCNUMBER":
+			tags.Disc = leadingNumber(value)
+		case "DATE", "YEAR":
+			if tags.Year == 0 {
+				tags.Year = leadingNumber(value)
+			}
+		}
+	}
+}
+
+// readMP4Tags reads the iTunes-style moov/udta/meta/ilst atoms of an M4A/MP4 file
+func readMP4Tags(r io.ReaderAt, size int64, tags *AudioTags) {
+	ilst, ok := findBMFFBox(r, 0, size, "moov", "udta", "meta", "ilst")
+	if !ok {
+		return
+	}
+
+	items, _ := readBMFFBoxes(r, ilst.Offset, ilst.End())
+	for _, item := range items {
+		dataBox, ok := findBMFFBox(r, item.Offset, item.End(), "data")
+		if !ok {
+			continue
+		}
+		data, err := readBMFFPayload(r, dataBox, 64*1024)
+		if err != nil || len(data) < 8 {
+			continue
+		}
+		value := data[8:] // After the type indicator and locale
+
+		text := strings.TrimSpace(string(value))
+		switch item.Type {
+		case "\xa9nam":
+			tags.Title = text
+		case "\xa9ART":
+			tags.Artist = text
+		case "aART":
+			tags.AlbumArtist = text
+		case "\xa9alb":
+			tags.Album = text
+		case "\xa9gen":
+			tags.Genre = text
+		case "gnre":
+			// Numeric genre: ID3v1 index plus one
+			if len(value) >= 2 {
+				if index := int(binary.BigEndian.Uint16(value[0:2])) - 1; index >= 0 && index < len(id3v1Genres) {
+					tags.Genre = id3v1Genres[index]
+				}
+			}
+		case "\xa9day":
+			tags.Year = leadingNumber(text)
+		case "trkn":
+			if len(value) >= 4 {
+				tags.Track = int(binary.BigEndian.Uint16(value[2:4]))
+			}
+		case "disk":
+			if len(value) >= 4 {
+				tags.Disc = int(binary.BigEndian.Uint16(value[2:4]))
+			}
+		}
+	}
+}
+
+// id3v1Genres are the standard ID3v1 genres (0-79) plus the common Winamp extensions
+var id3v1Genres = []string{
+	"Blues", "Classic Rock", "Country", "Dance", "Disco", "Funk", "Grunge", "Hip-Hop",
+	"Jazz", "Metal", "New Age", "Oldies", "Other", "Pop", "R&B", "Rap",
+	"Reggae", "Rock", "Techno", "Industrial", "Alternative", "Ska", "Death Metal", "Pranks",
+	"Soundtrack", "Euro-Techno", "Ambient", "Trip-Hop", "Vocal", "Jazz+Funk", "Fusion", "Trance",
+	"Classical", "Instrumental", "Acid", "House", "Game", "Sound Clip", "Gospel", "Noise",
+	"AlternRock", "Bass", "Soul", "Punk", "Space", "Meditative", "Instrumental Pop", "Instrumental Rock",
+	"Ethnic", "Gothic", "Darkwave", "Techno-Industrial", "Electronic", "Pop-Folk", "Eurodance", "Dream",
+	"Southern Rock", "Comedy", "Cult", "Gangsta", "Top 40", "Christian Rap", "Pop/Funk", "Jungle",
+	"Native American", "Cabaret", "New Wave", "Psychedelic", "Rave", "Showtunes", "Trailer", "Lo-Fi",
+	"Tribal", "Acid Punk", "Acid Jazz", "Polka", "Retro", "Musical", "Rock & Roll", "Hard Rock",
+	"Folk", "Folk-Rock", "National Folk", "Swing", "Fast Fusion", "Bebop", "Latin", "Revival",
+	"Celtic", "Bluegrass", "Avantgarde", "Gothic Rock", "Progressive Rock", "Psychedelic Rock", "Symphonic Rock", "Slow Rock",
+	"Big Band", "Chorus", "Easy Listening", "Acoustic", "Humour", "Speech", "Chanson", "Opera",
+	"Chamber Music", "Sonata", "Symphony", "Booty Bass", "Primus", "Porn Groove", "Satire", "Slow Jam",
+	"Club", "Tango", "Samba", "Folklore", "Ballad", "Power Ballad", "Rhythmic Soul", "Freestyle",
+	"Duet", "Punk Rock", "Drum Solo", "A Cappella", "Euro-House", "Dance Hall",
+}
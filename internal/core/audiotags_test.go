@@ -0,0 +1,229 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+// syncsafe encodes a 28-bit ID3v2 syncsafe integer
+func syncsafe(v int) []byte {
+	return []byte{byte(v >> 21 & 0x7F), byte(v >> 14 & 0x7F), byte(v >> 7 & 0x7F), byte(v & 0x7F)}
+}
+
+// id3Frame builds an ID3v2 frame for the given tag version
+func id3Frame(version byte, id string, body []byte) []byte {
+	switch version {
+	case 2:
+		return append([]byte{id[0], id[1], id[2], byte(len(body) >> 16), byte(len(body) >> 8), byte(len(body))}, body...)
+	case 3:
+		return bytes.Join([][]byte{[]byte(id), u32(uint32(len(body))), {0, 0}, body}, nil)
+	default:
+		return bytes.Join([][]byte{[]byte(id), syncsafe(len(body)), {0, 0}, body}, nil)
+	}
+}
+
+// id3Tag builds an ID3v2 tag holding the given frames
+func id3Tag(version, flags byte, frames ...[]byte) []byte {
+	body := bytes.Join(frames, nil)
+	return bytes.Join([][]byte{[]byte("ID3"), {version, 0, flags}, syncsafe(len(body)), body}, nil)
+}
+
+// id3v1Tag builds a 128-byte ID3v1.1 tag
+func id3v1Tag(title, artist, album, year string, track, genre byte) []byte {
+	tag := make([]byte, 128)
+	copy(tag, "TAG")
+	copy(tag[3:33], title)
+	copy(tag[33:63], artist)
+	copy(tag[63:93], album)
+	copy(tag[93:97], year)
+	tag[126] = track
+	tag[127] = genre
+	return tag
+}
+
+// vorbisComments builds a Vorbis comment block
+func vorbisComments(comments ...string) []byte {
+	le32 := func(v int) []byte { return binary.LittleEndian.AppendUint32(nil, uint32(v)) }
+	out := append(le32(6), "vendor"...)
+	out = append(out, le32(len(comments))...)
+	for _, comment := range comments {
+		out = append(append(out, le32(len(comment))...), comment...)
+	}
+	return out
+}
+
+// flacBlock builds a FLAC metadata block
+func flacBlock(blockType byte, last bool, body []byte) []byte {
+	if last {
+		blockType |= 0x80
+	}
+	return append([]byte{blockType, byte(len(body) >> 16), byte(len(body) >> 8), byte(len(body))}, body...)
+}
+
+// oggPage builds an Ogg page holding one complete packet
+func oggPage(packet []byte) []byte {
+	var segments []byte
+	for rest := len(packet); ; rest -= 255 {
+		if rest < 255 {
+			segments = append(segments, byte(rest))
+			break
+		}
+		segments = append(segments, 255)
+	}
+	header := make([]byte, 27)
+	copy(header, "OggS")
+	header[26] = byte(len(segments))
+	return bytes.Join([][]byte{header, segments, packet}, nil)
+}
+
+// mp4Item builds an iTunes ilst item with a data box
+func mp4Item(itemType string, dataType uint32, value []byte) []byte {
+	return box(itemType, box("data", u32(dataType), u32(0), value))
+}
+
+// testM4A builds an M4A file with the given ilst items
+func testM4A(items ...[]byte) []byte {
+	meta := box("meta", u32(0), box("hdlr", make([]byte, 24)), box("ilst", items...))
+	return append(box("ftyp", []byte("M4A "), u32(0)), box("moov", box("udta", meta))...)
+}
+
+func TestReadAudioTags(t *testing.T) {
+	song := AudioTags{Title: "Song", Artist: "Artist", AlbumArtist: "Various", Album: "Album", Genre: "Rock", Year: 1999, Track: 3, Disc: 1}
+	text := func(s string) []byte { return append([]byte{0}, s...) }
+
+	tests := []struct {
+		name string
+		file string
+		data []byte
+		want AudioTags
+	}{
+		{"ID3v2.3", "a.mp3", id3Tag(3, 0,
+			id3Frame(3, "TIT2", text("Song")), id3Frame(3, "TPE1", text("Artist")), id3Frame(3, "TPE2", text("Various")),
+			id3Frame(3, "TALB", text("Album")), id3Frame(3, "TCON", text("(17)")), id3Frame(3, "TYER", text("1999")),
+			id3Frame(3, "TRCK", text("3/12")), id3Frame(3, "TPOS", text("1/2")), make([]byte, 20)), song},
+		{"ID3v2.4 UTF-8 and multiple values", "a.mp3", id3Tag(4, 0,
+			id3Frame(4, "TIT2", append([]byte{3}, "Café\x00Other"...)), id3Frame(4, "TPE1", text("Artist")),
+			id3Frame(4, "TDRC", text("2001-02-03"))), AudioTags{Title: "Café", Artist: "Artist", Year: 2001}},
+		{"ID3v2.3 UTF-16", "a.mp3", id3Tag(3, 0,
+			id3Frame(3, "TIT2", []byte{1, 0xFF, 0xFE, 'H', 0, 'i', 0, 0, 0}),
+			id3Frame(3, "TPE1", []byte{1, 0xFE, 0xFF, 0, 'Y', 0, 'o'})), AudioTags{Title: "Hi", Artist: "Yo"}},
+		{"ID3v2.2", "a.mp3", id3Tag(2, 0, id3Frame(2, "TT2", text("Old")), id3Frame(2, "TP1", text("Band")), id3Frame(2, "TCO", text("Pop"))),
+			AudioTags{Title: "Old", Artist: "Band", Genre: "Pop"}},
+		{"ID3v2.3 extended header", "a.mp3", id3Tag(3, 0x40, u32(6), make([]byte, 6), id3Frame(3, "TIT2", text("Ext"))), AudioTags{Title: "Ext"}},
+		{"ID3v2.3 unsynchronised", "a.mp3", id3Tag(3, 0x80, []byte("TIT2"), u32(4), []byte{0, 0}, []byte{0, 'A', 0xFF, 0x00, 'B'}), AudioTags{Title: "AÿB"}},
+		{"ID3v2 with ID3v1 fallback", "a.mp3", append(append(id3Tag(3, 0, id3Frame(3, "TIT2", text("Song"))), make([]byte, 50)...),
+			id3v1Tag("Ignored", "V1 Artist", "V1 Album", "1985", 7, 13)...),
+			AudioTags{Title: "Song", Artist: "V1 Artist", Album: "V1 Album", Year: 1985, Track: 7, Genre: "Pop"}},
+		{"ID3v1 only", "a.mp3", append(make([]byte, 64), id3v1Tag("Title", "Artist", "", "2004", 0, 255)...),
+			AudioTags{Title: "Title", Artist: "Artist", Year: 2004}},
+		{"FLAC", "a.flac", append([]byte("fLaC"), append(flacBlock(0, false, make([]byte, 34)),
+			flacBlock(4, true, vorbisComments("TITLE=Song", "artist=Artist", "ALBUMARTIST=Various", "ALBUM=Album",
+				"GENRE=Rock", "DATE=1999-01-01", "TRACKNUMBER=3", "DISCNUMBER=1/1", "NOEQUALSIGN"))...)...), song},
+		{"Ogg Vorbis", "a.ogg", append(oggPage([]byte("\x01vorbis header")),
+			oggPage(append([]byte("\x03vorbis"), vorbisComments("TITLE=Song", "ARTIST=Artist")...))...), AudioTags{Title: "Song", Artist: "Artist"}},
+		{"Opus", "a.opus", append(oggPage([]byte("OpusHead")),
+			oggPage(append([]byte("OpusTags"), vorbisComments("TITLE="+string(bytes.Repeat([]byte("x"), 300)))...))...),
+			AudioTags{Title: string(bytes.Repeat([]byte("x"), 300))}},
+		{"M4A", "a.m4a", testM4A(
+			mp4Item("\xa9nam", 1, []byte("Song")), mp4Item("\xa9ART", 1, []byte("Artist")), mp4Item("aART", 1, []byte("Various")),
+			mp4Item("\xa9alb", 1, []byte("Album")), mp4Item("gnre", 0, u16(18)), mp4Item("\xa9day", 1, []byte("1999-05-01T00:00:00Z")),
+			mp4Item("trkn", 0, []byte{0, 0, 0, 3, 0, 12, 0, 0}), mp4Item("disk", 0, []byte{0, 0, 0, 1, 0, 2})), song},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tags, err := ReadAudioTags(writeTestFile(t, tt.file, tt.data))
+			if err != nil {
+				t.Fatalf("ReadAudioTags() error = %v", err)
+			}
+			if !reflect.DeepEqual(*tags, tt.want) {
+				t.Errorf("ReadAudioTags() = %+v, want %+v", *tags, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadAudioTagsMalformed(t *testing.T) {
+	validID3 := id3Tag(3, 0, id3Frame(3, "TIT2", []byte("\x00Song")))
+	validFLAC := append([]byte("fLaC"), flacBlock(4, true, vorbisComments("TITLE=Song"))...)
+	validM4A := testM4A(mp4Item("\xa9nam", 1, []byte("Song")))
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr bool
+	}{
+		{"empty", nil, true},
+		{"no tags", []byte("just some audio frames"), true},
+		{"ID3v2 header only", []byte("ID3"), true},
+		{"ID3v2 unsupported version", id3Tag(5, 0), true},
+		{"ID3v2 tag too large", append([]byte("ID3\x03\x00\x00"), syncsafe(maxAudioTagSize+1)...), true},
+		{"ID3v2 truncated", validID3[:len(validID3)-3], false},
+		{"ID3v2 frame size past the tag", id3Tag(3, 0, []byte("TIT2"), u32(1000), []byte{0, 0, 0, 'S'}), false},
+		{"ID3v2 empty frame", id3Tag(3, 0, id3Frame(3, "TIT2", nil)), false},
+		{"ID3v2 extended header past the tag", id3Tag(4, 0x40, syncsafe(5000), id3Frame(4, "TIT2", []byte("\x00Song"))), false},
+		{"FLAC truncated", validFLAC[:len(validFLAC)-4], false},
+		{"FLAC header only", []byte("fLaC"), false},
+		{"FLAC without comments", append([]byte("fLaC"), flacBlock(0, true, make([]byte, 34))...), false},
+		{"FLAC block past the end", append([]byte("fLaC"), 0x04, 0x7F, 0xFF, 0xFF), false},
+		{"FLAC comment count past the block", append([]byte("fLaC"), flacBlock(4, true, append(vorbisComments(), 0xFF))...), false},
+		{"Vorbis vendor length past the block", append([]byte("fLaC"), flacBlock(4, true, []byte{0xFF, 0xFF, 0, 0, 0, 0, 0, 0})...), false},
+		{"Ogg single page", oggPage([]byte("OpusHead")), true},
+		{"Ogg unknown codec", append(oggPage([]byte("Speex")), oggPage([]byte("comments"))...), true},
+		{"Ogg truncated segment table", append(oggPage([]byte("OpusHead")), []byte("OggS\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x09")...), true},
+		{"Ogg truncated packet", append(oggPage([]byte("OpusHead")), oggPage(append([]byte("OpusTags"), vorbisComments("TITLE=Song")...))[:40]...), false},
+		{"M4A truncated", validM4A[:len(validM4A)-5], false},
+		{"M4A without ilst", box("ftyp", []byte("M4A ")), false},
+		{"M4A short numeric items", testM4A(mp4Item("trkn", 0, []byte{0, 0}), mp4Item("gnre", 0, []byte{0}), mp4Item("disk", 0, nil)), false},
+		{"M4A genre out of range", testM4A(mp4Item("gnre", 0, u16(5000))), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tags, err := ReadAudioTags(writeTestFile(t, "test.audio", tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadAudioTags() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && tags.IsMusic() {
+				t.Errorf("ReadAudioTags() = %+v from malformed input", *tags)
+			}
+		})
+	}
+}
+
+func TestAudioTagsIsMusic(t *testing.T) {
+	tests := []struct {
+		tags *AudioTags
+		want bool
+	}{
+		{nil, false},
+		{&AudioTags{}, false},
+		{&AudioTags{Title: "Voice 001"}, false},
+		{&AudioTags{Artist: "Artist"}, false},
+		{&AudioTags{Artist: "Artist", Title: "Song"}, true},
+		{&AudioTags{Artist: "Artist", Album: "Album"}, true},
+	}
+
+	for _, tt := range tests {
+		if got := tt.tags.IsMusic(); got != tt.want {
+			t.Errorf("%+v.IsMusic() = %v, want %v", tt.tags, got, tt.want)
+		}
+	}
+}
+
+func TestCleanID3Genre(t *testing.T) {
+	for genre, want := range map[string]string{
+		"(17)":        "Rock",
+		"(17)Rock'n'": "Rock'n'",
+		"(999)":       "(999)",
+		"(x)":         "(x)",
+		"Jazz":        "Jazz",
+		"(":           "(",
+	} {
+		if got := cleanID3Genre(genre); got != want {
+			t.Errorf("cleanID3Genre(%q) = %q, want %q", genre, got, want)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package core
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// bmffBox is a box (atom) in an ISO base media file (MP4, MOV, HEIC)
+type bmffBox struct {
+	Type   string
+	Offset int64 // Start of the payload (after the header)
+	Size   int64 // Payload size
+}
+
+// End returns the offset just past the box payload
+func (b bmffBox) End() int64 {
+	return b.Offset + b.Size
+}
+
+// readBMFFBoxes lists the boxes between start and end
+func readBMFFBoxes(r io.ReaderAt, start, end int64) ([]bmffBox, error) {
+	var boxes []bmffBox
+	header := make([]byte, 16)
+
+	for pos := start; pos+8 <= end; {
+		if _, err := r.ReadAt(header[:8], pos); err != nil {
+			return boxes, err
+		}
+
+		size := int64(binary.BigEndian.Uint32(header[0:4]))
+		boxType := string(header[4:8])
+		headerSize := int64(8)
+
+		switch size {
+		case 0:
+			size = end - pos // Box extends to the end of its container
+		case 1:
+			if _, err := r.ReadAt(header[8:16], pos+8); err != nil {
+				return boxes, err
+			}
+			size = int64(binary.BigEndian.Uint64(header[8:16]))
+			headerSize = 16
+		}
+
+		if size < headerSize || size > end-pos {
+			return boxes, fmt.Errorf("invalid %q box size %d at offset %d", boxType, size, pos)
+		}
+
+		boxes = append(boxes, bmffBox{Type: boxType, Offset: pos + headerSize, Size: size - headerSize})
+		pos += size
+	}
+
+	return boxes, nil
+}
+
+// findBMFFBox follows a path of box types (e.g. "moov", "mvhd") and returns the last box
+func findBMFFBox(r io.ReaderAt, start, end int64, path ...string) (bmffBox, bool) {
+	var found bmffBox
+	for _, boxType := range path {
+		boxes, _ := readBMFFBoxes(r, start, end)
+
+		matched := false
+		for _, box := range boxes {
+			if box.Type == boxType {
+				found = box
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return bmffBox{}, false
+		}
+
+		start, end = found.Offset, found.End()
+		// "meta" is a full box in ISO files but a plain container in QuickTime
+		if boxType == "meta" {
+			start = metaChildrenOffset(r, found)
+		}
+	}
+	return found, true
+}
+
+// metaChildrenOffset returns where the children of a meta box start.
+// ISO meta boxes carry 4 bytes of version/flags, QuickTime ones do not.
+func metaChildrenOffset(r io.ReaderAt, meta bmffBox) int64 {
+	buf := make([]byte, 4)
+	if _, err := r.ReadAt(buf, meta.Offset); err == nil && binary.BigEndian.Uint32(buf) == 0 {
+		return meta.Offset + 4
+	}
+	return meta.Offset
+}
+
+// readBMFFPayload reads a box payload, refusing unreasonably large boxes
+func readBMFFPayload(r io.ReaderAt, box bmffBox, limit int64) ([]byte, error) {
+	if box.Size > limit {
+		return nil, fmt.Errorf("%q box too large (%d bytes)", box.Type, box.Size)
+	}
+	buf := make([]byte, box.Size)
+	if n, err := r.ReadAt(buf, box.Offset); n < len(buf) {
+		return nil, fmt.Errorf("truncated %q box: %w", box.Type, err)
+	}
+	return buf, nil
+}
+
+// readQuickTimeMetadata reads the QuickTime "mdta" metadata (moov/meta keys + ilst)
+// and returns the values of string items keyed by their key names
+func readQuickTimeMetadata(r io.ReaderAt, size int64) map[string]string {
+	values := make(map[string]string)
+
+	meta, ok := findBMFFBox(r, 0, size, "moov", "meta")
+	if !ok {
+		return values
+	}
+	children := metaChildrenOffset(r, meta)
+
+	keysBox, ok := findBMFFBox(r, children, meta.End(), "keys")
+	if !ok {
+		return values
+	}
+	ilstBox, ok := findBMFFBox(r, children, meta.End(), "ilst")
+	if !ok {
+		return values
+	}
+
+	keysData, err := readBMFFPayload(r, keysBox, 1<<20)
+	if err != nil || len(keysData) < 8 {
+		return values
+	}
+
+	// keys: version/flags, entry count, then (size, namespace, name) entries
+	var keys []string
+	count := binary.BigEndian.Uint32(keysData[4:8])
+	for pos := 8; uint32(len(keys)) < count && pos+8 <= len(keysData); {
+		entrySize := int(binary.BigEndian.Uint32(keysData[pos : pos+4]))
+		if entrySize < 8 || pos+entrySize > len(keysData) {
+			break
+		}
+		keys = append(keys, string(keysData[pos+8:pos+entrySize]))
+		pos += entrySize
+	}
+
+	// ilst: one box per item, typed by the 1-based key index, holding a "data" box
+	items, _ := readBMFFBoxes(r, ilstBox.Offset, ilstBox.End())
+	for _, item := range items {
+		index := int(binary.BigEndian.Uint32([]byte(item.Type)))
+		if index < 1 || index > len(keys) {
+			continue
+		}
+		dataBox, ok := findBMFFBox(r, item.Offset, item.End(), "data")
+		if !ok {
+			continue
+		}
+		data, err := readBMFFPayload(r, dataBox, 64*1024)
+		if err != nil || len(data) < 8 {
+			continue
+		}
+		// Type indicator 1 is UTF-8 text; value follows the type and locale fields
+		if binary.BigEndian.Uint32(data[0:4])&0xFFFFFF == 1 {
+			values[keys[index-1]] = string(data[8:])
+		}
+	}
+
+	return values
+}
@@ -0,0 +1,176 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// box builds a BMFF box with a 32-bit size
+func box(boxType string, payload ...[]byte) []byte {
+	body := bytes.Join(payload, nil)
+	out := make([]byte, 8, 8+len(body))
+	binary.BigEndian.PutUint32(out[0:4], uint32(8+len(body)))
+	copy(out[4:8], boxType)
+	return append(out, body...)
+}
+
+// u32 encodes a big-endian 32-bit value
+func u32(v uint32) []byte {
+	return binary.BigEndian.AppendUint32(nil, v)
+}
+
+// quickTimeMeta builds a QuickTime moov/meta box with keys and string items
+func quickTimeMeta(isoMeta bool, keys ...string) []byte {
+	var keyEntries [][]byte
+	var items [][]byte
+	for i, key := range keys {
+		keyEntries = append(keyEntries, box("mdta", []byte(key)))
+		value := []byte("value of " + key)
+		items = append(items, box(string(u32(uint32(i+1))), box("data", u32(1), u32(0), value)))
+	}
+	keysBox := box("keys", u32(0), u32(uint32(len(keys))), bytes.Join(keyEntries, nil))
+	ilstBox := box("ilst", items...)
+
+	var meta []byte
+	if isoMeta {
+		meta = box("meta", u32(0), box("hdlr", make([]byte, 24)), keysBox, ilstBox)
+	} else {
+		meta = box("meta", box("hdlr", make([]byte, 24)), keysBox, ilstBox)
+	}
+	return box("moov", box("mvhd", make([]byte, 100)), meta)
+}
+
+func TestReadBMFFBoxes(t *testing.T) {
+	largeSize := append([]byte{0, 0, 0, 1}, []byte("mdat")...)
+	largeSize = append(largeSize, binary.BigEndian.AppendUint64(nil, 16+4)...)
+	largeSize = append(largeSize, 1, 2, 3, 4)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		types   []string
+		sizes   []int64
+		wantErr bool
+	}{
+		{"two boxes", append(box("ftyp", []byte("heic")), box("free")...), []string{"ftyp", "free"}, []int64{4, 0}, false},
+		{"size zero extends to end", append(box("ftyp"), 0, 0, 0, 0, 'm', 'd', 'a', 't', 9, 9), []string{"ftyp", "mdat"}, []int64{0, 2}, false},
+		{"64-bit size", largeSize, []string{"mdat"}, []int64{4}, false},
+		{"empty", nil, nil, nil, false},
+		{"trailing bytes shorter than a header", append(box("free"), 1, 2, 3), []string{"free"}, []int64{0}, false},
+		{"size past the end", []byte{0, 0, 0, 99, 'm', 'o', 'o', 'v'}, nil, nil, true},
+		{"size smaller than its header", []byte{0, 0, 0, 4, 'm', 'o', 'o', 'v'}, nil, nil, true},
+		{"64-bit size truncated", []byte{0, 0, 0, 1, 'm', 'd', 'a', 't', 0, 0}, nil, nil, true},
+		{"64-bit size too small", append([]byte{0, 0, 0, 1, 'm', 'd', 'a', 't'}, binary.BigEndian.AppendUint64(nil, 8)...), nil, nil, true},
+		{"64-bit size overflowing", append([]byte{0, 0, 0, 1, 'm', 'd', 'a', 't'}, binary.BigEndian.AppendUint64(nil, 1<<63)...), nil, nil, true},
+		{"64-bit size at the int64 limit", append([]byte{0, 0, 0, 1, 'm', 'd', 'a', 't'}, binary.BigEndian.AppendUint64(nil, 1<<63-1)...), nil, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			boxes, err := readBMFFBoxes(bytes.NewReader(tt.data), 0, int64(len(tt.data)))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readBMFFBoxes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(boxes) != len(tt.types) {
+				t.Fatalf("readBMFFBoxes() = %d boxes, want %d", len(boxes), len(tt.types))
+			}
+			for i, b := range boxes {
+				if b.Type != tt.types[i] || b.Size != tt.sizes[i] {
+					t.Errorf("box %d = %s/%d, want %s/%d", i, b.Type, b.Size, tt.types[i], tt.sizes[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFindBMFFBox(t *testing.T) {
+	data := append(box("ftyp", []byte("qt  ")), box("moov", box("trak", box("tkhd", []byte{7})))...)
+	r := bytes.NewReader(data)
+
+	found, ok := findBMFFBox(r, 0, int64(len(data)), "moov", "trak", "tkhd")
+	if !ok || found.Size != 1 || data[found.Offset] != 7 {
+		t.Errorf("findBMFFBox() = %+v, %v", found, ok)
+	}
+	if _, ok := findBMFFBox(r, 0, int64(len(data)), "moov", "udta"); ok {
+		t.Error("findBMFFBox() found a missing box")
+	}
+	if _, ok := findBMFFBox(r, 0, int64(len(data)-3), "moov", "trak"); ok {
+		t.Error("findBMFFBox() found a box in a truncated file")
+	}
+}
+
+func TestReadBMFFPayload(t *testing.T) {
+	data := box("udta", []byte("hello"))
+	r := bytes.NewReader(data)
+	b := bmffBox{Type: "udta", Offset: 8, Size: 5}
+
+	if payload, err := readBMFFPayload(r, b, 5); err != nil || string(payload) != "hello" {
+		t.Errorf("readBMFFPayload() = %q, %v", payload, err)
+	}
+	if _, err := readBMFFPayload(r, b, 4); err == nil {
+		t.Error("readBMFFPayload() read a box over the limit")
+	}
+}
+
+func TestReadQuickTimeMetadata(t *testing.T) {
+	for _, isoMeta := range []bool{false, true} {
+		data := append(box("ftyp", []byte("qt  ")), quickTimeMeta(isoMeta, "com.apple.quicktime.content.identifier", "com.apple.quicktime.make")...)
+		values := readQuickTimeMetadata(bytes.NewReader(data), int64(len(data)))
+		if got := values["com.apple.quicktime.content.identifier"]; got != "value of com.apple.quicktime.content.identifier" {
+			t.Errorf("iso meta %v: content identifier = %q", isoMeta, got)
+		}
+		if got := values["com.apple.quicktime.make"]; got != "value of com.apple.quicktime.make" {
+			t.Errorf("iso meta %v: make = %q", isoMeta, got)
+		}
+	}
+}
+
+func TestReadQuickTimeMetadataMalformed(t *testing.T) {
+	valid := quickTimeMeta(false, "key")
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"no moov", box("ftyp", []byte("qt  "))},
+		{"moov without meta", box("moov", box("mvhd"))},
+		{"meta without keys", box("moov", box("meta", box("ilst")))},
+		{"keys too short", box("moov", box("meta", box("keys", u32(0)), box("ilst")))},
+		{"key count larger than entries", box("moov", box("meta", box("keys", u32(0), u32(1000)), box("ilst")))},
+		{"key entry size past the end", box("moov", box("meta", box("keys", u32(0), u32(1), u32(500), []byte("mdta")), box("ilst")))},
+		{"key entry size too small", box("moov", box("meta", box("keys", u32(0), u32(1), u32(2), []byte("mdta")), box("ilst")))},
+		{"item index out of range", box("moov", box("meta",
+			box("keys", u32(0), u32(1), box("mdta", []byte("key"))),
+			box("ilst", box(string(u32(5)), box("data", u32(1), u32(0), []byte("x"))))))},
+		{"item without data", box("moov", box("meta",
+			box("keys", u32(0), u32(1), box("mdta", []byte("key"))),
+			box("ilst", box(string(u32(1)), box("free")))))},
+		{"data too short", box("moov", box("meta",
+			box("keys", u32(0), u32(1), box("mdta", []byte("key"))),
+			box("ilst", box(string(u32(1)), box("data", u32(1))))))},
+		{"truncated", valid[:len(valid)-6]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values := readQuickTimeMetadata(bytes.NewReader(tt.data), int64(len(tt.data)))
+			if len(values) != 0 {
+				t.Errorf("readQuickTimeMetadata() = %v, want nothing", values)
+			}
+		})
+	}
+}
+
+func TestReadQuickTimeMetadataSkipsBinaryItems(t *testing.T) {
+	data := box("moov", box("meta",
+		box("keys", u32(0), u32(2), box("mdta", []byte("binary")), box("mdta", []byte("text"))),
+		box("ilst",
+			box(string(u32(1)), box("data", u32(0), u32(0), []byte{1, 2})),
+			box(string(u32(2)), box("data", u32(1), u32(0), []byte("ok"))))))
+	values := readQuickTimeMetadata(bytes.NewReader(data), int64(len(data)))
+	if len(values) != 1 || values["text"] != "ok" {
+		t.Errorf("readQuickTimeMetadata() = %v, want only the text item", values)
+	}
+}
@@ -0,0 +1,614 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"time"
+)
+
+// ContainerInfo is what the native container parsers read from a media file
+type ContainerInfo struct {
+	Format          string // "mp4", "matroska", "avi", "wav" or "mp3"
+	Duration        time.Duration
+	HasDuration     bool
+	CreationTime    time.Time
+	HasCreationTime bool
+	Tags            map[string]string // QuickTime metadata such as com.apple.quicktime.make
+}
+
+var (
+	// quickTimeEpoch is where MP4/MOV timestamps count from
+	quickTimeEpoch = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+	// matroskaEpoch is where Matroska DateUTC counts from
+	matroskaEpoch = time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// Matroska element IDs (with their length markers)
+const (
+	ebmlHeaderID        = 0x1A45DFA3
+	matroskaSegmentID   = 0x18538067
+	matroskaInfoID      = 0x1549A966
+	matroskaClusterID   = 0x1F43B675
+	matroskaTimescaleID = 0x2AD7B1
+	matroskaDurationID  = 0x4489
+	matroskaDateUTCID   = 0x4461
+)
+
+// mpegBitrates are the MPEG audio bitrates in kbit/s by MPEG 1/2, layer I/II/III and index
+var mpegBitrates = [2][3][16]int{
+	{
+		{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448, 0},
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384, 0},
+		{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0},
+	},
+	{
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256, 0},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+	},
+}
+
+// ReadContainerInfo reads the duration and creation time of MP4/MOV/M4A/3GP, Matroska/WebM,
+// AVI, WAV and MP3 files without external tools
+func ReadContainerInfo(filePath string) (*ContainerInfo, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := info.Size()
+
+	header := make([]byte, 12)
+	if _, err := file.ReadAt(header, 0); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	var result *ContainerInfo
+	switch {
+	case isBMFFHeader(header):
+		result = readMP4ContainerInfo(file, size)
+	case binary.BigEndian.Uint32(header[0:4]) == ebmlHeaderID:
+		result = readMatroskaInfo(file, size)
+	case string(header[0:4]) == "RIFF" && string(header[8:12]) == "AVI ":
+		result = readAVIInfo(file, size)
+	case string(header[0:4]) == "RIFF" && string(header[8:12]) == "WAVE":
+		result = readWAVInfo(file, size)
+	case bytes.HasPrefix(header, []byte("ID3")) || (header[0] == 0xFF && header[1]&0xE0 == 0xE0):
+		result = readMP3Info(file, size)
+	}
+
+	if result == nil {
+		return nil, fmt.Errorf("unsupported or damaged container: %s", filePath)
+	}
+	return result, nil
+}
+
+// isBMFFHeader reports whether a file starts with an MP4/QuickTime box.
+// Old QuickTime files may start with their media data instead of a file type box.
+func isBMFFHeader(header []byte) bool {
+	switch string(header[4:8]) {
+	case "ftyp", "moov", "mdat", "wide", "free":
+		return true
+	}
+	return false
+}
+
+// readMP4ContainerInfo reads the movie header (mvhd) and QuickTime metadata of an MP4 or MOV file
+func readMP4ContainerInfo(r io.ReaderAt, size int64) *ContainerInfo {
+	mvhd, ok := findBMFFBox(r, 0, size, "moov", "mvhd")
+	if !ok {
+		return nil
+	}
+	payload, err := readBMFFPayload(r, mvhd, 4096)
+	if err != nil || len(payload) < 20 {
+		return nil
+	}
+
+	info := &ContainerInfo{Format: "mp4", Tags: readQuickTimeMetadata(r, size)}
+
+	// Version 0 stores 32-bit times and duration, version 1 64-bit ones
+	var created, timescale, duration uint64
+	if payload[0] == 1 {
+		if len(payload) < 32 {
+			return nil
+		}
+		created = binary.BigEndian.Uint64(payload[4:12])
+		timescale = uint64(binary.BigEndian.Uint32(payload[20:24]))
+		duration = binary.BigEndian.Uint64(payload[24:32])
+	} else {
+		created = uint64(binary.BigEndian.Uint32(payload[4:8]))
+		timescale = uint64(binary.BigEndian.Uint32(payload[12:16]))
+		duration = uint64(binary.BigEndian.Uint32(payload[16:20]))
+		if duration == math.MaxUint32 {
+			duration = 0 // Unknown
+		}
+	}
+
+	// Fragmented files leave mvhd empty and record the total in the movie extends header
+	if duration == 0 {
+		if mehd, ok := findBMFFBox(r, 0, size, "moov", "mvex", "mehd"); ok {
+			if data, err := readBMFFPayload(r, mehd, 64); err == nil && len(data) >= 8 {
+				if data[0] == 1 && len(data) >= 12 {
+					duration = binary.BigEndian.Uint64(data[4:12])
+				} else {
+					duration = uint64(binary.BigEndian.Uint32(data[4:8]))
+				}
+			}
+		}
+	}
+
+	if timescale > 0 && duration > 0 {
+		info.Duration = time.Duration(float64(duration) / float64(timescale) * float64(time.Second))
+		info.HasDuration = true
+	}
+	// Zero means unset; the value is seconds since 1904 in UTC
+	if created > 0 && created < 1<<40 {
+		info.CreationTime = quickTimeEpoch.Add(time.Duration(created) * time.Second)
+		info.HasCreationTime = true
+	}
+
+	return info
+}
+
+// readEBMLElement reads the ID and size of the Matroska element at pos.
+// Elements of unknown size extend to end.
+func readEBMLElement(r io.ReaderAt, pos, end int64) (uint32, int64, int64, bool) {
+	buf := make([]byte, 12)
+	n, err := r.ReadAt(buf, pos)
+	if err != nil && err != io.EOF {
+		return 0, 0, 0, false
+	}
+	buf = buf[:n]
+
+	idLength := ebmlVarIntLength(buf)
+	if idLength == 0 || idLength > 4 || len(buf) < idLength {
+		return 0, 0, 0, false
+	}
+	var id uint32
+	for _, b := range buf[:idLength] {
+		id = id<<8 | uint32(b)
+	}
+
+	sizeBytes := buf[idLength:]
+	sizeLength := ebmlVarIntLength(sizeBytes)
+	if sizeLength == 0 || len(sizeBytes) < sizeLength {
+		return 0, 0, 0, false
+	}
+	// The size drops its length marker; all value bits set means unknown
+	size := uint64(sizeBytes[0]) & (0xFF >> uint(sizeLength))
+	unknown := size == 0xFF>>uint(sizeLength)
+	for _, b := range sizeBytes[1:sizeLength] {
+		size = size<<8 | uint64(b)
+		unknown = unknown && b == 0xFF
+	}
+
+	dataStart := pos + int64(idLength+sizeLength)
+	if unknown || int64(size) > end-dataStart {
+		size = uint64(end - dataStart)
+	}
+	return id, dataStart, int64(size), true
+}
+
+// ebmlVarIntLength returns the length of the EBML variable-size integer starting buf (0 if invalid)
+func ebmlVarIntLength(buf []byte) int {
+	if len(buf) == 0 {
+		return 0
+	}
+	for length := 1; length <= 8; length++ {
+		if buf[0]&(0x80>>uint(length-1)) != 0 {
+			return length
+		}
+	}
+	return 0
+}
+
+// readMatroskaInfo reads the segment information of a Matroska or WebM file
+func readMatroskaInfo(r io.ReaderAt, size int64) *ContainerInfo {
+	id, dataStart, dataSize, ok := readEBMLElement(r, 0, size)
+	if !ok || id != ebmlHeaderID {
+		return nil
+	}
+
+	for pos := dataStart + dataSize; pos < size; {
+		id, dataStart, dataSize, ok := readEBMLElement(r, pos, size)
+		if !ok {
+			return nil
+		}
+		if id == matroskaSegmentID {
+			return readMatroskaSegment(r, dataStart, dataStart+dataSize)
+		}
+		pos = dataStart + dataSize
+	}
+	return nil
+}
+
+// readMatroskaSegment finds the Info element among the top-level elements of a segment
+func readMatroskaSegment(r io.ReaderAt, start, end int64) *ContainerInfo {
+	for pos := start; pos < end; {
+		id, dataStart, dataSize, ok := readEBMLElement(r, pos, end)
+		if !ok || id == matroskaClusterID {
+			return nil // Info always precedes the media data
+		}
+		if id != matroskaInfoID {
+			pos = dataStart + dataSize
+			continue
+		}
+
+		info := &ContainerInfo{Format: "matroska"}
+		timescale := uint64(1000000) // Nanoseconds per tick unless stated otherwise
+		var ticks float64
+		for child := dataStart; child < dataStart+dataSize; {
+			childID, childStart, childSize, ok := readEBMLElement(r, child, dataStart+dataSize)
+			if !ok {
+				break
+			}
+			if childSize > 8 {
+				child = childStart + childSize // Title, muxing app and other strings
+				continue
+			}
+			value := make([]byte, childSize)
+			if _, err := r.ReadAt(value, childStart); err != nil && err != io.EOF {
+				break
+			}
+
+			switch childID {
+			case matroskaTimescaleID:
+				timescale = ebmlUint(value)
+			case matroskaDurationID:
+				switch len(value) {
+				case 4:
+					ticks = float64(math.Float32frombits(binary.BigEndian.Uint32(value)))
+				case 8:
+					ticks = math.Float64frombits(binary.BigEndian.Uint64(value))
+				}
+			case matroskaDateUTCID:
+				if len(value) == 8 {
+					nanos := int64(binary.BigEndian.Uint64(value))
+					info.CreationTime = matroskaEpoch.Add(time.Duration(nanos))
+					info.HasCreationTime = true
+				}
+			}
+			child = childStart + childSize
+		}
+
+		if ticks > 0 && timescale > 0 {
+			info.Duration = time.Duration(ticks * float64(timescale))
+			info.HasDuration = true
+		}
+		return info
+	}
+	return nil
+}
+
+// ebmlUint decodes a big-endian unsigned integer of up to 8 bytes
+func ebmlUint(value []byte) uint64 {
+	var n uint64
+	for _, b := range value {
+		n = n<<8 | uint64(b)
+	}
+	return n
+}
+
+// riffChunk is a chunk of a RIFF (AVI, WAV) file
+type riffChunk struct {
+	ID     string
+	Offset int64 // Start of the payload
+	Size   int64
+	List   string // List type for "LIST" chunks
+}
+
+// readRIFFChunks lists the chunks between start and end
+func readRIFFChunks(r io.ReaderAt, start, end int64) []riffChunk {
+	var chunks []riffChunk
+	header := make([]byte, 12)
+	for pos := start; pos+8 <= end; {
+		if _, err := r.ReadAt(header, pos); err != nil && err != io.EOF {
+			break
+		}
+		chunk := riffChunk{
+			ID:     string(header[0:4]),
+			Offset: pos + 8,
+			Size:   int64(binary.LittleEndian.Uint32(header[4:8])),
+		}
+		if chunk.Offset+chunk.Size > end {
+			chunk.Size = end - chunk.Offset // Truncated file, or a size left unset while recording
+		}
+		if chunk.ID == "LIST" && chunk.Size >= 4 {
+			chunk.List = string(header[8:12])
+		}
+		chunks = append(chunks, chunk)
+		pos = chunk.Offset + chunk.Size + chunk.Size%2 // Chunks are word aligned
+	}
+	return chunks
+}
+
+// readAVIInfo reads the main AVI header (frame rate and count) and the IDIT creation date
+func readAVIInfo(r io.ReaderAt, size int64) *ContainerInfo {
+	for _, chunk := range readRIFFChunks(r, 12, size) {
+		if chunk.List != "hdrl" {
+			continue
+		}
+
+		info := &ContainerInfo{Format: "avi"}
+		var microsPerFrame, totalFrames uint32
+		for _, child := range readRIFFChunks(r, chunk.Offset+4, chunk.Offset+chunk.Size) {
+			switch {
+			case child.ID == "avih" && child.Size >= 20:
+				avih := make([]byte, 20)
+				if _, err := r.ReadAt(avih, child.Offset); err != nil {
+					return nil
+				}
+				microsPerFrame = binary.LittleEndian.Uint32(avih[0:4])
+				if totalFrames == 0 {
+					totalFrames = binary.LittleEndian.Uint32(avih[16:20])
+				}
+			case child.List == "odml":
+				// OpenDML files over 1 GB count all frames here; avih only counts the first RIFF
+				for _, odml := range readRIFFChunks(r, child.Offset+4, child.Offset+child.Size) {
+					if odml.ID == "dmlh" && odml.Size >= 4 {
+						dmlh := make([]byte, 4)
+						if _, err := r.ReadAt(dmlh, odml.Offset); err == nil && binary.LittleEndian.Uint32(dmlh) > 0 {
+							totalFrames = binary.LittleEndian.Uint32(dmlh)
+						}
+					}
+				}
+			case child.ID == "IDIT" && child.Size > 0 && child.Size < 64:
+				// e.g. "Mon Mar 10 15:04:05 2008\n"
+				value := make([]byte, child.Size)
+				if _, err := r.ReadAt(value, child.Offset); err == nil {
+					text := strings.Join(strings.Fields(strings.Trim(string(value), "\x00")), " ")
+					if created := parseVideoDateTime(text); !created.IsZero() {
+						info.CreationTime = created
+						info.HasCreationTime = true
+					}
+				}
+			}
+		}
+
+		if microsPerFrame > 0 && totalFrames > 0 {
+			info.Duration = time.Duration(microsPerFrame) * time.Duration(totalFrames) * time.Microsecond
+			info.HasDuration = true
+		}
+		return info
+	}
+	return nil
+}
+
+// readWAVInfo computes the duration of a WAV file from its byte rate and data size,
+// and reads the origination date of Broadcast Wave (bext) files
+func readWAVInfo(r io.ReaderAt, size int64) *ContainerInfo {
+	info := &ContainerInfo{Format: "wav"}
+	var byteRate, dataSize int64
+
+	for _, chunk := range readRIFFChunks(r, 12, size) {
+		switch chunk.ID {
+		case "fmt ":
+			format := make([]byte, 12)
+			if _, err := r.ReadAt(format, chunk.Offset); err != nil {
+				return nil
+			}
+			byteRate = int64(binary.LittleEndian.Uint32(format[8:12]))
+		case "data":
+			dataSize = chunk.Size
+		case "bext":
+			if chunk.Size >= 338 {
+				origination := make([]byte, 18)
+				if _, err := r.ReadAt(origination, chunk.Offset+320); err == nil {
+					if created, ok := parseBextDateTime(string(origination[:10]), string(origination[10:])); ok {
+						info.CreationTime = created
+						info.HasCreationTime = true
+					}
+				}
+			}
+		}
+	}
+
+	if byteRate == 0 {
+		return nil
+	}
+	if dataSize > 0 {
+		info.Duration = time.Duration(float64(dataSize) / float64(byteRate) * float64(time.Second))
+		info.HasDuration = true
+	}
+	return info
+}
+
+// parseBextDateTime parses the origination date and time of a Broadcast Wave file
+// ("yyyy-mm-dd" and "hh:mm:ss", with any separators)
+func parseBextDateTime(date, clock string) (time.Time, bool) {
+	digits := func(s string) []int {
+		var values []int
+		for _, field := range strings.FieldsFunc(s, func(r rune) bool { return r < '0' || r > '9' }) {
+			n := 0
+			for _, c := range field {
+				n = n*10 + int(c-'0')
+			}
+			values = append(values, n)
+		}
+		return values
+	}
+
+	d, t := digits(date), digits(clock)
+	if len(d) != 3 || len(t) != 3 || d[0] < 1970 || d[1] < 1 || d[1] > 12 || d[2] < 1 || d[2] > 31 {
+		return time.Time{}, false
+	}
+	// Broadcast Wave times are local wall-clock times without a zone
+	return time.Date(d[0], time.Month(d[1]), d[2], t[0], t[1], t[2], 0, time.UTC), true
+}
+
+// mpegFrameHeader is a parsed MPEG audio frame header
+type mpegFrameHeader struct {
+	version    byte // 0 = MPEG 2.5, 2 = MPEG 2, 3 = MPEG 1
+	layer      byte // 1 = Layer III, 2 = Layer II, 3 = Layer I
+	sampleRate int
+	channels   int
+	length     int // Frame length in bytes, header included
+	samples    int // Samples per channel in the frame
+}
+
+// parseMPEGFrameHeader parses the 4-byte header of an MPEG audio frame.
+// Free-format and reserved values are rejected, which also weeds out false syncs.
+func parseMPEGFrameHeader(b []byte) (mpegFrameHeader, bool) {
+	if len(b) < 4 || b[0] != 0xFF || b[1]&0xE0 != 0xE0 {
+		return mpegFrameHeader{}, false
+	}
+	h := mpegFrameHeader{version: b[1] >> 3 & 0x03, layer: b[1] >> 1 & 0x03}
+	rateIndex := b[2] >> 2 & 0x03
+	bitrateIndex := b[2] >> 4
+	if h.version == 1 || h.layer == 0 || rateIndex == 3 || bitrateIndex == 0 || bitrateIndex == 0x0F {
+		return mpegFrameHeader{}, false
+	}
+
+	mpeg := 1 // MPEG 2 and 2.5 share their bitrates
+	if h.version == 3 {
+		mpeg = 0
+	}
+	bitrate := mpegBitrates[mpeg][3-h.layer][bitrateIndex] * 1000
+	h.sampleRate = mp3SampleRates[h.version][rateIndex]
+	padding := int(b[2] >> 1 & 0x01)
+
+	h.channels = 2
+	if b[3]>>6 == 3 {
+		h.channels = 1
+	}
+
+	switch {
+	case h.layer == 3: // Layer I
+		h.samples = 384
+		h.length = (12*bitrate/h.sampleRate + padding) * 4
+	case h.layer == 2 || h.version == 3: // Layer II, MPEG 1 Layer III
+		h.samples = 1152
+		h.length = 144*bitrate/h.sampleRate + padding
+	default: // MPEG 2/2.5 Layer III
+		h.samples = 576
+		h.length = 72*bitrate/h.sampleRate + padding
+	}
+	return h, h.length > 4
+}
+
+// mpegAudioRange returns where the MPEG audio frames of a file start and end,
+// skipping any ID3v2 tag at the start and ID3v1 tag at the end
+func mpegAudioRange(r io.ReaderAt, size int64) (int64, int64) {
+	start, end := int64(0), size
+
+	header := make([]byte, 10)
+	if _, err := r.ReadAt(header, 0); err == nil && bytes.HasPrefix(header, []byte("ID3")) {
+		start = 10 + int64(syncsafeInt(header[6:10]))
+		if header[5]&0x10 != 0 {
+			start += 10 // Footer
+		}
+	}
+
+	trailer := make([]byte, 3)
+	if size >= 128 {
+		if _, err := r.ReadAt(trailer, size-128); err == nil && string(trailer) == "TAG" {
+			end -= 128
+		}
+	}
+	return start, end
+}
+
+// findFirstMPEGFrame finds the first MPEG audio frame whose successor is also a valid frame
+func findFirstMPEGFrame(r io.ReaderAt, start, end int64) (int64, mpegFrameHeader, bool) {
+	buf := make([]byte, 64*1024)
+	n, err := r.ReadAt(buf, start)
+	if err != nil && err != io.EOF {
+		return 0, mpegFrameHeader{}, false
+	}
+	buf = buf[:n]
+
+	for i := 0; i+4 <= len(buf); i++ {
+		h, ok := parseMPEGFrameHeader(buf[i:])
+		if !ok {
+			continue
+		}
+		// Accept a lone frame at the very end of the file, otherwise require a matching successor
+		next := make([]byte, 4)
+		nextPos := start + int64(i) + int64(h.length)
+		if nextPos+4 > end {
+			return start + int64(i), h, true
+		}
+		if _, err := r.ReadAt(next, nextPos); err == nil {
+			if nh, ok := parseMPEGFrameHeader(next); ok && nh.version == h.version && nh.layer == h.layer && nh.sampleRate == h.sampleRate {
+				return start + int64(i), h, true
+			}
+		}
+	}
+	return 0, mpegFrameHeader{}, false
+}
+
+// readMP3Info reads the duration of an MP3 file from its Xing/Info or VBRI header,
+// or by counting the frames of files without one
+func readMP3Info(r io.ReaderAt, size int64) *ContainerInfo {
+	start, end := mpegAudioRange(r, size)
+	pos, first, ok := findFirstMPEGFrame(r, start, end)
+	if !ok {
+		return nil
+	}
+	info := &ContainerInfo{Format: "mp3"}
+
+	frame := make([]byte, 192)
+	if _, err := r.ReadAt(frame, pos); err != nil && err != io.EOF {
+		return nil
+	}
+
+	// The Xing/Info header follows the side information of the first Layer III frame
+	sideInfo := 17
+	switch {
+	case first.version == 3 && first.channels == 2:
+		sideInfo = 32
+	case first.version != 3 && first.channels == 1:
+		sideInfo = 9
+	}
+	frames := int64(-1)
+	if xing := frame[4+sideInfo:]; first.layer == 1 && (bytes.HasPrefix(xing, []byte("Xing")) || bytes.HasPrefix(xing, []byte("Info"))) {
+		if flags := binary.BigEndian.Uint32(xing[4:8]); flags&0x01 != 0 {
+			frames = int64(binary.BigEndian.Uint32(xing[8:12]))
+		}
+	} else if vbri := frame[36:]; bytes.HasPrefix(vbri, []byte("VBRI")) {
+		frames = int64(binary.BigEndian.Uint32(vbri[14:18]))
+	}
+
+	if frames < 0 {
+		frames = countMPEGFrames(r, pos, end)
+	}
+	if frames > 0 {
+		info.Duration = time.Duration(float64(frames*int64(first.samples)) / float64(first.sampleRate) * float64(time.Second))
+		info.HasDuration = true
+	}
+	return info
+}
+
+// countMPEGFrames walks the frames of an MPEG audio stream, resynchronizing after junk
+func countMPEGFrames(r io.ReaderAt, start, end int64) int64 {
+	reader := bufio.NewReaderSize(io.NewSectionReader(r, start, end-start), 64*1024)
+	var frames int64
+	for {
+		header, err := reader.Peek(4)
+		if err != nil {
+			return frames
+		}
+		h, ok := parseMPEGFrameHeader(header)
+		if !ok {
+			if _, err := reader.Discard(1); err != nil {
+				return frames
+			}
+			continue
+		}
+		if _, err := reader.Discard(h.length); err != nil {
+			return frames // Truncated last frame
+		}
+		frames++
+	}
+}
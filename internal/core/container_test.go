@@ -0,0 +1,360 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+)
+
+// ebml builds a Matroska element with an 8-byte size
+func ebml(id uint32, payload ...[]byte) []byte {
+	body := bytes.Join(payload, nil)
+	size := binary.BigEndian.AppendUint64(nil, uint64(len(body)))
+	size[0] = 0x01 // Length marker of an 8-byte size
+	return bytes.Join([][]byte{ebmlID(id), size, body}, nil)
+}
+
+// ebmlUnknownSize builds a Matroska element whose size is left unknown
+func ebmlUnknownSize(id uint32, payload ...[]byte) []byte {
+	return bytes.Join([][]byte{ebmlID(id), {0xFF}, bytes.Join(payload, nil)}, nil)
+}
+
+// ebmlID encodes an element ID without its leading zero bytes
+func ebmlID(id uint32) []byte {
+	return bytes.TrimLeft(binary.BigEndian.AppendUint32(nil, id), "\x00")
+}
+
+// testMatroska builds a WebM file whose segment holds the given top-level elements
+func testMatroska(segment ...[]byte) []byte {
+	return append(ebml(ebmlHeaderID, ebml(0x4282, []byte("webm"))), ebml(matroskaSegmentID, segment...)...)
+}
+
+// testAVI builds an AVI file with a main header and any further hdrl chunks
+func testAVI(microsPerFrame, frames uint32, hdrl ...[]byte) []byte {
+	avih := make([]byte, 56)
+	binary.LittleEndian.PutUint32(avih[0:4], microsPerFrame)
+	binary.LittleEndian.PutUint32(avih[16:20], frames)
+	list := riffChunkBytes("LIST", bytes.Join(append([][]byte{[]byte("hdrl"), riffChunkBytes("avih", avih)}, hdrl...), nil))
+	return riffChunkBytes("RIFF", bytes.Join([][]byte{[]byte("AVI "), list, riffChunkBytes("LIST", []byte("movi"))}, nil))
+}
+
+// testMPEGFrame builds an MPEG audio frame of the given length with body after its header
+func testMPEGFrame(header []byte, length int, body []byte) []byte {
+	frame := make([]byte, length)
+	copy(frame, header)
+	copy(frame[4:], body)
+	return frame
+}
+
+// checkContainerInfo compares the fields the container parsers fill in
+func checkContainerInfo(t *testing.T, got, want *ContainerInfo) {
+	t.Helper()
+	if want == nil {
+		if got != nil {
+			t.Errorf("got %+v, want nil", got)
+		}
+		return
+	}
+	if got == nil {
+		t.Fatalf("got nil, want %+v", want)
+	}
+	if got.Format != want.Format || got.HasDuration != want.HasDuration || got.Duration != want.Duration {
+		t.Errorf("got %s duration %v (%v), want %s duration %v (%v)", got.Format, got.Duration, got.HasDuration, want.Format, want.Duration, want.HasDuration)
+	}
+	if got.HasCreationTime != want.HasCreationTime || !got.CreationTime.Equal(want.CreationTime) {
+		t.Errorf("got creation time %v (%v), want %v (%v)", got.CreationTime, got.HasCreationTime, want.CreationTime, want.HasCreationTime)
+	}
+}
+
+func TestReadMatroskaInfo(t *testing.T) {
+	created := time.Date(2023, 4, 15, 10, 11, 12, 0, time.UTC)
+	dateUTC := ebml(matroskaDateUTCID, binary.BigEndian.AppendUint64(nil, uint64(created.Sub(matroskaEpoch))))
+	float64Duration := func(ticks float64) []byte {
+		return ebml(matroskaDurationID, binary.BigEndian.AppendUint64(nil, math.Float64bits(ticks)))
+	}
+	float32Duration := ebml(matroskaDurationID, binary.BigEndian.AppendUint32(nil, math.Float32bits(12345)))
+	timescale := ebml(matroskaTimescaleID, []byte{0x01, 0x86, 0xA0}) // 100000 ns per tick
+	title := ebml(0x7BA9, []byte("A title longer than eight bytes"))
+	void := ebml(0xEC, make([]byte, 16))
+	complete := testMatroska(ebml(matroskaInfoID, float64Duration(5000)))
+
+	tests := []struct {
+		name string
+		data []byte
+		want *ContainerInfo
+	}{
+		{"default timescale", testMatroska(ebml(matroskaInfoID, float64Duration(5000), dateUTC)),
+			&ContainerInfo{Format: "matroska", Duration: 5 * time.Second, HasDuration: true, CreationTime: created, HasCreationTime: true}},
+		{"timescale and float32 duration", testMatroska(ebml(matroskaInfoID, timescale, float32Duration)),
+			&ContainerInfo{Format: "matroska", Duration: 1234500 * time.Microsecond, HasDuration: true}},
+		{"strings and elements before info skipped", testMatroska(void, ebml(matroskaInfoID, title, float64Duration(5000))),
+			&ContainerInfo{Format: "matroska", Duration: 5 * time.Second, HasDuration: true}},
+		{"segment of unknown size", append(ebml(ebmlHeaderID), ebmlUnknownSize(matroskaSegmentID, ebml(matroskaInfoID, float64Duration(2500)))...),
+			&ContainerInfo{Format: "matroska", Duration: 2500 * time.Millisecond, HasDuration: true}},
+		{"info without duration", testMatroska(ebml(matroskaInfoID, dateUTC)),
+			&ContainerInfo{Format: "matroska", CreationTime: created, HasCreationTime: true}},
+		{"zero duration", testMatroska(ebml(matroskaInfoID, float64Duration(0))), &ContainerInfo{Format: "matroska"}},
+		{"odd sized duration", testMatroska(ebml(matroskaInfoID, ebml(matroskaDurationID, []byte{1, 2, 3}))), &ContainerInfo{Format: "matroska"}},
+		{"truncated duration", complete[:len(complete)-3], &ContainerInfo{Format: "matroska"}},
+		{"cluster before info", testMatroska(ebml(matroskaClusterID, make([]byte, 8)), ebml(matroskaInfoID, float64Duration(5000))), nil},
+		{"segment without info", testMatroska(void), nil},
+		{"no segment", ebml(ebmlHeaderID, ebml(0x4282, []byte("webm"))), nil},
+		{"not an EBML header", ebml(matroskaSegmentID, ebml(matroskaInfoID, float64Duration(5000))), nil},
+		{"invalid size", []byte{0x1A, 0x45, 0xDF, 0xA3, 0x00}, nil},
+		{"invalid element after the header", append(ebml(ebmlHeaderID), 0x00, 0x00), nil},
+		{"empty", nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkContainerInfo(t, readMatroskaInfo(bytes.NewReader(tt.data), int64(len(tt.data))), tt.want)
+		})
+	}
+}
+
+func TestReadRIFFChunks(t *testing.T) {
+	data := bytes.Join([][]byte{
+		riffChunkBytes("fmt ", make([]byte, 16)),
+		riffChunkBytes("LIST", []byte("INFOabc")),
+		riffChunkBytes("data", make([]byte, 20)),
+	}, nil)
+
+	tests := []struct {
+		name string
+		data []byte
+		want []riffChunk
+	}{
+		{"padded odd chunk", data, []riffChunk{
+			{ID: "fmt ", Offset: 8, Size: 16},
+			{ID: "LIST", Offset: 32, Size: 7, List: "INFO"},
+			{ID: "data", Offset: 48, Size: 20},
+		}},
+		{"truncated last chunk", data[:58], []riffChunk{
+			{ID: "fmt ", Offset: 8, Size: 16},
+			{ID: "LIST", Offset: 32, Size: 7, List: "INFO"},
+			{ID: "data", Offset: 48, Size: 10},
+		}},
+		{"short list", riffChunkBytes("LIST", []byte("IN")), []riffChunk{{ID: "LIST", Offset: 8, Size: 2}}},
+		{"partial header", []byte("data\x10\x00"), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := readRIFFChunks(bytes.NewReader(tt.data), 0, int64(len(tt.data)))
+			if len(got) != len(tt.want) {
+				t.Fatalf("readRIFFChunks() = %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("chunk %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestReadWAVInfo(t *testing.T) {
+	fmtChunk := testWAV(1, 8000, 16000, 0)[12:36] // The fmt chunk of a 16000 bytes/s WAV
+	wav := func(chunks ...[]byte) []byte {
+		return riffChunkBytes("RIFF", bytes.Join(append([][]byte{[]byte("WAVE")}, chunks...), nil))
+	}
+	bext := make([]byte, 346)
+	copy(bext[320:], "2023-04-15"+"10:11:12")
+	created := time.Date(2023, 4, 15, 10, 11, 12, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		data []byte
+		want *ContainerInfo
+	}{
+		{"pcm", testWAV(2, 44100, 176400, 2.5), &ContainerInfo{Format: "wav", Duration: 2500 * time.Millisecond, HasDuration: true}},
+		{"broadcast wave", wav(fmtChunk, riffChunkBytes("bext", bext), riffChunkBytes("data", make([]byte, 8000))),
+			&ContainerInfo{Format: "wav", Duration: 500 * time.Millisecond, HasDuration: true, CreationTime: created, HasCreationTime: true}},
+		{"short bext", wav(fmtChunk, riffChunkBytes("bext", bext[:330]), riffChunkBytes("data", make([]byte, 8000))),
+			&ContainerInfo{Format: "wav", Duration: 500 * time.Millisecond, HasDuration: true}},
+		{"data size left unset", wav(fmtChunk, []byte("data\xFF\xFF\xFF\xFF"), make([]byte, 16000)),
+			&ContainerInfo{Format: "wav", Duration: time.Second, HasDuration: true}},
+		{"no data", wav(fmtChunk), &ContainerInfo{Format: "wav"}},
+		{"no fmt", wav(riffChunkBytes("data", make([]byte, 100))), nil},
+		{"zero byte rate", testWAV(1, 8000, 0, 1), nil},
+		{"truncated fmt", wav([]byte("fmt \x10\x00\x00\x00\x01\x00")), nil},
+		{"header only", []byte("RIFF\x04\x00\x00\x00WAVE"), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkContainerInfo(t, readWAVInfo(bytes.NewReader(tt.data), int64(len(tt.data))), tt.want)
+		})
+	}
+}
+
+func TestParseBextDateTime(t *testing.T) {
+	tests := []struct {
+		date, clock string
+		want        time.Time
+		ok          bool
+	}{
+		{"2023-04-15", "10:11:12", time.Date(2023, 4, 15, 10, 11, 12, 0, time.UTC), true},
+		{"2023:04:15", "10.11.12", time.Date(2023, 4, 15, 10, 11, 12, 0, time.UTC), true},
+		{"1969-12-31", "23:59:59", time.Time{}, false},
+		{"2023-13-01", "10:11:12", time.Time{}, false},
+		{"2023-04-00", "10:11:12", time.Time{}, false},
+		{"2023-04", "10:11:12", time.Time{}, false},
+		{"2023-04-15", "10:11", time.Time{}, false},
+		{"\x00\x00\x00", "\x00\x00", time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseBextDateTime(tt.date, tt.clock)
+		if ok != tt.ok || !got.Equal(tt.want) {
+			t.Errorf("parseBextDateTime(%q, %q) = %v, %v, want %v, %v", tt.date, tt.clock, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestReadAVIInfo(t *testing.T) {
+	dmlh := riffChunkBytes("LIST", append([]byte("odml"), riffChunkBytes("dmlh", binary.LittleEndian.AppendUint32(nil, 1000))...))
+	emptyDMLH := riffChunkBytes("LIST", append([]byte("odml"), riffChunkBytes("dmlh", make([]byte, 4))...))
+	idit := riffChunkBytes("IDIT", []byte("Mon Mar 10 15:04:05 2008\n\x00"))
+	truncated := testAVI(40000, 250)
+
+	tests := []struct {
+		name string
+		data []byte
+		want *ContainerInfo
+	}{
+		{"main header", testAVI(40000, 250), &ContainerInfo{Format: "avi", Duration: 10 * time.Second, HasDuration: true}},
+		{"opendml frame count", testAVI(40000, 250, dmlh), &ContainerInfo{Format: "avi", Duration: 40 * time.Second, HasDuration: true}},
+		{"empty opendml frame count", testAVI(40000, 250, emptyDMLH), &ContainerInfo{Format: "avi", Duration: 10 * time.Second, HasDuration: true}},
+		{"creation date", testAVI(40000, 250, idit), &ContainerInfo{Format: "avi", Duration: 10 * time.Second, HasDuration: true,
+			CreationTime: time.Date(2008, 3, 10, 15, 4, 5, 0, time.UTC), HasCreationTime: true}},
+		{"unparseable creation date", testAVI(40000, 250, riffChunkBytes("IDIT", []byte("yesterday"))),
+			&ContainerInfo{Format: "avi", Duration: 10 * time.Second, HasDuration: true}},
+		{"no frames", testAVI(40000, 0), &ContainerInfo{Format: "avi"}},
+		{"truncated main header", truncated[:42], &ContainerInfo{Format: "avi"}},
+		{"no header list", riffChunkBytes("RIFF", append([]byte("AVI "), riffChunkBytes("LIST", []byte("movi"))...)), nil},
+		{"header only", []byte("RIFF\x04\x00\x00\x00AVI "), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkContainerInfo(t, readAVIInfo(bytes.NewReader(tt.data), int64(len(tt.data))), tt.want)
+		})
+	}
+}
+
+func TestParseMPEGFrameHeader(t *testing.T) {
+	tests := []struct {
+		name   string
+		header []byte
+		want   mpegFrameHeader
+		ok     bool
+	}{
+		{"mpeg 1 layer III stereo", []byte{0xFF, 0xFB, 0x90, 0x00}, mpegFrameHeader{version: 3, layer: 1, sampleRate: 44100, channels: 2, length: 417, samples: 1152}, true},
+		{"padded mono", []byte{0xFF, 0xFB, 0x92, 0xC0}, mpegFrameHeader{version: 3, layer: 1, sampleRate: 44100, channels: 1, length: 418, samples: 1152}, true},
+		{"mpeg 2 layer III", []byte{0xFF, 0xF3, 0x80, 0xC0}, mpegFrameHeader{version: 2, layer: 1, sampleRate: 22050, channels: 1, length: 208, samples: 576}, true},
+		{"mpeg 2.5 layer III", []byte{0xFF, 0xE3, 0x18, 0xC0}, mpegFrameHeader{version: 0, layer: 1, sampleRate: 8000, channels: 1, length: 72, samples: 576}, true},
+		{"layer II", []byte{0xFF, 0xFD, 0x10, 0x00}, mpegFrameHeader{version: 3, layer: 2, sampleRate: 44100, channels: 2, length: 104, samples: 1152}, true},
+		{"layer I", []byte{0xFF, 0xFF, 0x10, 0x00}, mpegFrameHeader{version: 3, layer: 3, sampleRate: 44100, channels: 2, length: 32, samples: 384}, true},
+		{"reserved version", []byte{0xFF, 0xEB, 0x90, 0x00}, mpegFrameHeader{}, false},
+		{"reserved layer", []byte{0xFF, 0xF9, 0x90, 0x00}, mpegFrameHeader{}, false},
+		{"free format", []byte{0xFF, 0xFB, 0x00, 0x00}, mpegFrameHeader{}, false},
+		{"bad bitrate", []byte{0xFF, 0xFB, 0xF0, 0x00}, mpegFrameHeader{}, false},
+		{"reserved sample rate", []byte{0xFF, 0xFB, 0x9C, 0x00}, mpegFrameHeader{}, false},
+		{"no sync", []byte{0xFF, 0x1B, 0x90, 0x00}, mpegFrameHeader{}, false},
+		{"too short", []byte{0xFF, 0xFB, 0x90}, mpegFrameHeader{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseMPEGFrameHeader(tt.header)
+			if ok != tt.ok || (ok && got != tt.want) {
+				t.Errorf("parseMPEGFrameHeader() = %+v, %v, want %+v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestReadMP3Info(t *testing.T) {
+	stereo := []byte{0xFF, 0xFB, 0x90, 0x00} // MPEG 1 Layer III, 128 kbit/s, 44.1 kHz
+	mono := []byte{0xFF, 0xFB, 0x90, 0xC0}
+	frame := testMPEGFrame(stereo, 417, nil)
+	monoFrame := testMPEGFrame(mono, 417, nil)
+	mpeg2 := testMPEGFrame([]byte{0xFF, 0xF3, 0x80, 0xC0}, 208, nil)
+	frames := func(n int) []byte { return bytes.Repeat(frame, n) }
+	join := func(parts ...[]byte) []byte { return bytes.Join(parts, nil) }
+	duration := func(frames, samples, sampleRate int) *ContainerInfo {
+		seconds := float64(frames*samples) / float64(sampleRate)
+		return &ContainerInfo{Format: "mp3", Duration: time.Duration(seconds * float64(time.Second)), HasDuration: true}
+	}
+
+	// The Xing/Info header follows 32 bytes of side information in MPEG 1 stereo, 17 in mono
+	xing := testMPEGFrame(stereo, 417, join(make([]byte, 32), []byte("Xing"), u32(0x0F), u32(1000)))
+	info := testMPEGFrame(mono, 417, join(make([]byte, 17), []byte("Info"), u32(0x01), u32(500)))
+	xingWithoutCount := testMPEGFrame(stereo, 417, join(make([]byte, 32), []byte("Xing"), u32(0x0E)))
+	vbri := testMPEGFrame(stereo, 417, join(make([]byte, 32), []byte("VBRI"), make([]byte, 10), u32(2000)))
+	tag := id3Tag(3, 0, id3Frame(3, "TIT2", []byte("\x00Song")))
+
+	tests := []struct {
+		name string
+		data []byte
+		want *ContainerInfo
+	}{
+		{"counted frames", frames(10), duration(10, 1152, 44100)},
+		{"xing header", join(xing, frames(2)), duration(1000, 1152, 44100)},
+		{"info header in mono", join(info, bytes.Repeat(monoFrame, 2)), duration(500, 1152, 44100)},
+		{"xing header without frame count", join(xingWithoutCount, frames(2)), duration(3, 1152, 44100)},
+		{"vbri header", join(vbri, frames(2)), duration(2000, 1152, 44100)},
+		{"mpeg 2", bytes.Repeat(mpeg2, 5), duration(5, 576, 22050)},
+		{"id3 tags", join(tag, frames(3), id3v1Tag("Song", "Band", "Album", "2023", 1, 0)), duration(3, 1152, 44100)},
+		{"junk before and between frames", join([]byte("junk"), frames(2), []byte("junk"), frames(2)), duration(4, 1152, 44100)},
+		{"truncated last frame", join(frames(3), frame[:100]), duration(3, 1152, 44100)},
+		{"lone frame", frames(1), duration(1, 1152, 44100)},
+		{"false sync", join(stereo, make([]byte, 500)), nil},
+		{"id3 tag only", tag, nil},
+		{"not mpeg audio", []byte("not an mp3 file at all"), nil},
+		{"empty", nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkContainerInfo(t, readMP3Info(bytes.NewReader(tt.data), int64(len(tt.data))), tt.want)
+		})
+	}
+}
+
+func TestReadContainerInfo(t *testing.T) {
+	tests := []struct {
+		file   string
+		data   []byte
+		format string
+	}{
+		{"clip.webm", testMatroska(ebml(matroskaInfoID, ebml(matroskaDurationID, binary.BigEndian.AppendUint64(nil, math.Float64bits(1000))))), "matroska"},
+		{"clip.avi", testAVI(40000, 25), "avi"},
+		{"sound.wav", testWAV(1, 8000, 8000, 1), "wav"},
+		{"song.mp3", bytes.Repeat(testMPEGFrame([]byte{0xFF, 0xFB, 0x90, 0x00}, 417, nil), 3), "mp3"},
+		{"image.png", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR"), ""},
+		{"text.mp3", []byte("not audio"), ""},
+		{"empty.avi", nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			info, err := ReadContainerInfo(writeTestFile(t, tt.file, tt.data))
+			if tt.format == "" {
+				if err == nil {
+					t.Errorf("ReadContainerInfo() = %+v, want an error", info)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReadContainerInfo() error = %v", err)
+			}
+			if info.Format != tt.format || !info.HasDuration {
+				t.Errorf("ReadContainerInfo() = %+v, want %s with a duration", info, tt.format)
+			}
+		})
+	}
+}
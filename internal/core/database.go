@@ -12,12 +12,28 @@ import (
 
 // FileRecord represents a file entry in the database
 type FileRecord struct {
-	ID              int       `json:"id"`
-	Hash            string    `json:"hash"`
-	OriginalPath    string    `json:"original_path"`
-	DestinationPath string    `json:"destination_path"`
-	Size            int64     `json:"size"`
-	ProcessedAt     time.Time `json:"processed_at"`
+	ID                int             `json:"id"`
+	Hash              string          `json:"hash"`
+	OriginalPath      string          `json:"original_path"`
+	OriginalName      string          `json:"original_name,omitempty"`
+	DestinationPath   string          `json:"destination_path"`
+	Size              int64           `json:"size"`
+	CaptureDate       time.Time       `json:"capture_date"`
+	DateSource        string          `json:"date_source,omitempty"`
+	SidecarOf         string          `json:"sidecar_of,omitempty"`     // Hash of the primary file for sidecars
+	PairedWith        string          `json:"paired_with,omitempty"`    // Hash of the other half of a Live Photo
+	EmbeddedVideo     string          `json:"embedded_video,omitempty"` // Hash of the video extracted from a motion photo
+	ContentIdentifier string          `json:"content_identifier,omitempty"`
+	Description       string          `json:"description,omitempty"`
+	Title             string          `json:"title,omitempty"`
+	Author            string          `json:"author,omitempty"`
+	GPS               *GPSCoordinates `json:"gps,omitempty"`
+	Category          string          `json:"category,omitempty"` // Images, Videos, Audios, Documents or Unknown
+	Make              string          `json:"make,omitempty"`
+	Model             string          `json:"model,omitempty"`
+	City              string          `json:"city,omitempty"`
+	Country           string          `json:"country,omitempty"`
+	ProcessedAt       time.Time       `json:"processed_at"`
 }
 
 // Database provides efficient file tracking using BadgerDB
@@ -96,13 +112,22 @@ func (db *Database) CheckDuplicate(hash string) (bool, string, error) {
 
 // AddFile adds a new file record to the database
 func (db *Database) AddFile(hash, originalPath, destinationPath string, size int64) error {
-	record := FileRecord{
-		ID:              db.nextID,
+	return db.AddRecord(FileRecord{
 		Hash:            hash,
 		OriginalPath:    originalPath,
 		DestinationPath: destinationPath,
 		Size:            size,
-		ProcessedAt:     time.Now(),
+	})
+}
+
+// AddRecord adds a new file record with optional metadata to the database.
+// The ID and processing time are assigned here.
+func (db *Database) AddRecord(record FileRecord) error {
+	hash := record.Hash
+	record.ID = db.nextID
+	record.ProcessedAt = time.Now()
+	if record.OriginalName == "" {
+		record.OriginalName = filepath.Base(record.OriginalPath)
 	}
 	
 	recordData, err := json.Marshal(record)
@@ -164,6 +189,34 @@ func (db *Database) GetStats() (int, int64, error) {
 	return count, totalSize, err
 }
 
+// Records returns every file record in the database
+func (db *Database) Records() ([]FileRecord, error) {
+	var records []FileRecord
+
+	err := db.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		prefix := []byte("hash:")
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			err := it.Item().Value(func(val []byte) error {
+				var record FileRecord
+				if err := json.Unmarshal(val, &record); err != nil {
+					return err
+				}
+				records = append(records, record)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	return records, err
+}
+
 // Close closes the BadgerDB database
 func (db *Database) Close() error {
 	return db.db.Close()
@@ -40,7 +40,8 @@ func NewFileTypeDetectorWithConfig(cfg *config.Config) *FileTypeDetector {
 		imageExts: map[string]bool{
 			".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".bmp": true,
 			".tiff": true, ".tif": true, ".webp": true, ".svg": true, ".ico": true,
-			".raw": true, ".cr2": true, ".nef": true, ".arw": true, ".dng": true,
+			".raw": true, ".cr2": true, ".cr3": true, ".nef": true, ".nrw": true, ".arw": true,
+			".dng": true, ".orf": true, ".rw2": true, ".raf": true, ".pef": true, ".srw": true,
 			".heic": true, ".heif": true, ".avif": true,
 		},
 		videoExts: map[string]bool{
@@ -113,6 +114,12 @@ func (d *FileTypeDetector) DetectFileType(filePath string) FileType {
 	return FileTypeUnknown
 }
 
+// IsMediaExtension reports whether an extension belongs to an image or video format
+func (d *FileTypeDetector) IsMediaExtension(ext string) bool {
+	ext = strings.ToLower(ext)
+	return d.imageExts[ext] || d.videoExts[ext]
+}
+
 // IsHiddenFile checks if a file is hidden (starts with dot on Unix or has hidden attribute on Windows)
 func (d *FileTypeDetector) IsHiddenFile(filePath string) bool {
 	filename := filepath.Base(filePath)
@@ -218,11 +225,13 @@ func (d *FileTypeDetector) isLiveOrMotionPhoto(filePath string) bool {
 
 // isMotionPhotoWithConfig uses configuration-based detection for Motion Photos
 func (d *FileTypeDetector) isMotionPhotoWithConfig(filePath string, motionConfig *struct {
-	Enabled           bool     `json:"enabled"`
-	IPhonePatterns    []string `json:"iphone_patterns"`
-	SamsungPatterns   []string `json:"samsung_patterns"`
-	Extensions        []string `json:"extensions"`
-	MaxDurationSeconds int     `json:"max_duration_seconds"`
+	Enabled            bool     `json:"enabled"`
+	IPhonePatterns     []string `json:"iphone_patterns"`
+	SamsungPatterns    []string `json:"samsung_patterns"`
+	Extensions         []string `json:"extensions"`
+	MaxDurationSeconds int      `json:"max_duration_seconds"`
+	PairLivePhotos     bool     `json:"pair_live_photos"`
+	ExtractEmbedded    bool     `json:"extract_embedded"`
 }) bool {
 	if !motionConfig.Enabled {
 		return false
@@ -265,12 +274,12 @@ func (d *FileTypeDetector) isMotionPhotoFallback(filePath string) bool {
 	filename := strings.ToLower(filepath.Base(filePath))
 	ext := strings.ToLower(filepath.Ext(filePath))
 	
-	// iPhone Live Photos detection
+	// iPhone Live Photos detection (plain IMG_ names are regular videos too,
+	// Live Photo videos are recognized by their content identifier instead)
 	if ext == ".mov" {
 		if strings.Contains(filename, "live") ||
-		   strings.Contains(filename, "livephoto") ||
-		   strings.Contains(filename, "_live") ||
-		   strings.HasPrefix(filename, "img_") {
+			strings.Contains(filename, "livephoto") ||
+			strings.Contains(filename, "_live") {
 			return true
 		}
 	}
@@ -0,0 +1,443 @@
+package core
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// pdfSampleSize is how much of the start and end of a large PDF is searched for metadata.
+// The Info dictionary sits near the trailer at the end, or at the start of linearized files.
+const pdfSampleSize = 4 * 1024 * 1024
+
+var (
+	// pdfInfoReference matches the trailer reference to the document Info dictionary
+	pdfInfoReference = regexp.MustCompile(`/Info\s+(\d+)\s+(\d+)\s+R`)
+	// xmpTitle, xmpCreator, xmpCreateDate and xmpModifyDate read the XMP packet of a PDF
+	xmpTitle      = regexp.MustCompile(`(?s)<dc:title>.*?<rdf:li[^>]*>(.*?)</rdf:li>`)
+	xmpCreator    = regexp.MustCompile(`(?s)<dc:creator>.*?<rdf:li[^>]*>(.*?)</rdf:li>`)
+	xmpCreateDate = regexp.MustCompile(`xmp:CreateDate(?:="([^"]*)"|>([^<]*)<)`)
+	xmpModifyDate = regexp.MustCompile(`xmp:ModifyDate(?:="([^"]*)"|>([^<]*)<)`)
+)
+
+// DocumentMetadata is the title, author and dates stored inside a document
+type DocumentMetadata struct {
+	Title       string
+	Author      string
+	Created     time.Time
+	HasCreated  bool
+	Modified    time.Time
+	HasModified bool
+}
+
+// ooxmlCoreProperties mirrors docProps/core.xml of Word, Excel and PowerPoint files
+type ooxmlCoreProperties struct {
+	Title    string `xml:"title"`
+	Creator  string `xml:"creator"`
+	Created  string `xml:"created"`
+	Modified string `xml:"modified"`
+}
+
+// odfMeta mirrors meta.xml of OpenDocument files
+type odfMeta struct {
+	Meta struct {
+		Title          string `xml:"title"`
+		InitialCreator string `xml:"initial-creator"`
+		Creator        string `xml:"creator"` // Last editor
+		CreationDate   string `xml:"creation-date"`
+		Date           string `xml:"date"` // Last modification
+	} `xml:"meta"`
+}
+
+// ReadDocumentMetadata reads the metadata of PDF, OOXML (docx, xlsx, pptx) and
+// OpenDocument (odt, ods, odp) files. Dates without an offset are in the given location.
+func ReadDocumentMetadata(path string, location *time.Location) (*DocumentMetadata, error) {
+	if location == nil {
+		location = time.Local
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 1024)
+	n, err := file.ReadAt(header, 0)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	header = header[:n]
+
+	switch {
+	case bytes.Contains(header, []byte("%PDF-")):
+		return readPDFMetadata(file, info.Size(), location)
+	case bytes.HasPrefix(header, []byte("PK\x03\x04")):
+		return readPackageMetadata(file, info.Size(), location)
+	}
+	return nil, fmt.Errorf("no document metadata format recognized: %s", path)
+}
+
+// readPackageMetadata reads the core properties of OOXML files or the meta.xml of ODF files
+func readPackageMetadata(r io.ReaderAt, size int64, location *time.Location) (*DocumentMetadata, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("invalid document package: %w", err)
+	}
+
+	for _, entry := range archive.File {
+		switch entry.Name {
+		case "docProps/core.xml":
+			var core ooxmlCoreProperties
+			if err := readZipXML(entry, &core); err != nil {
+				return nil, err
+			}
+			metadata := &DocumentMetadata{
+				Title:  strings.TrimSpace(core.Title),
+				Author: strings.TrimSpace(core.Creator),
+			}
+			metadata.Created, metadata.HasCreated = parseDocumentDate(core.Created, location)
+			metadata.Modified, metadata.HasModified = parseDocumentDate(core.Modified, location)
+			return metadata, nil
+
+		case "meta.xml":
+			var meta odfMeta
+			if err := readZipXML(entry, &meta); err != nil {
+				return nil, err
+			}
+			metadata := &DocumentMetadata{
+				Title:  strings.TrimSpace(meta.Meta.Title),
+				Author: strings.TrimSpace(meta.Meta.InitialCreator),
+			}
+			if metadata.Author == "" {
+				metadata.Author = strings.TrimSpace(meta.Meta.Creator)
+			}
+			metadata.Created, metadata.HasCreated = parseDocumentDate(meta.Meta.CreationDate, location)
+			metadata.Modified, metadata.HasModified = parseDocumentDate(meta.Meta.Date, location)
+			return metadata, nil
+		}
+	}
+	return nil, fmt.Errorf("document package has no core properties")
+}
+
+// readZipXML decodes an XML file inside a zip archive, refusing unreasonably large entries
+func readZipXML(entry *zip.File, target interface{}) error {
+	if entry.UncompressedSize64 > 1024*1024 {
+		return fmt.Errorf("%s too large (%d bytes)", entry.Name, entry.UncompressedSize64)
+	}
+	reader, err := entry.Open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	if err := xml.NewDecoder(reader).Decode(target); err != nil {
+		return fmt.Errorf("invalid %s: %w", entry.Name, err)
+	}
+	return nil
+}
+
+// readPDFMetadata reads the document Info dictionary of a PDF, filling gaps from its XMP packet.
+// Info dictionaries inside compressed object streams are only found through the XMP packet.
+func readPDFMetadata(r io.ReaderAt, size int64, location *time.Location) (*DocumentMetadata, error) {
+	data, err := readPDFSample(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := &DocumentMetadata{}
+	if dict := pdfInfoDictionary(data); dict != nil {
+		metadata.Title = pdfDictString(dict, "Title")
+		metadata.Author = pdfDictString(dict, "Author")
+		metadata.Created, metadata.HasCreated = parsePDFDate(pdfDictString(dict, "CreationDate"), location)
+		metadata.Modified, metadata.HasModified = parsePDFDate(pdfDictString(dict, "ModDate"), location)
+	}
+
+	// XMP packets are stored uncompressed so they can be found without parsing the PDF
+	if metadata.Title == "" {
+		metadata.Title = xmpValue(xmpTitle, data)
+	}
+	if metadata.Author == "" {
+		metadata.Author = xmpValue(xmpCreator, data)
+	}
+	if !metadata.HasCreated {
+		metadata.Created, metadata.HasCreated = parseDocumentDate(xmpValue(xmpCreateDate, data), location)
+	}
+	if !metadata.HasModified {
+		metadata.Modified, metadata.HasModified = parseDocumentDate(xmpValue(xmpModifyDate, data), location)
+	}
+
+	return metadata, nil
+}
+
+// readPDFSample reads a whole PDF, or only its start and end when it is large
+func readPDFSample(r io.ReaderAt, size int64) ([]byte, error) {
+	if size <= 2*pdfSampleSize {
+		data := make([]byte, size)
+		if _, err := r.ReadAt(data, 0); err != nil && err != io.EOF {
+			return nil, err
+		}
+		return data, nil
+	}
+
+	data := make([]byte, 2*pdfSampleSize)
+	if _, err := r.ReadAt(data[:pdfSampleSize], 0); err != nil {
+		return nil, err
+	}
+	if _, err := r.ReadAt(data[pdfSampleSize:], size-pdfSampleSize); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return data, nil
+}
+
+// pdfInfoDictionary returns the body of the Info object named by the last trailer,
+// which is the newest one in incrementally updated files
+func pdfInfoDictionary(data []byte) []byte {
+	references := pdfInfoReference.FindAllSubmatch(data, -1)
+	if len(references) == 0 {
+		return nil
+	}
+	reference := references[len(references)-1]
+
+	object := regexp.MustCompile(`(?:^|[^0-9])` + string(reference[1]) + `\s+` + string(reference[2]) + `\s+obj\b`)
+	locations := object.FindAllIndex(data, -1)
+	if len(locations) == 0 {
+		return nil
+	}
+	body := data[locations[len(locations)-1][1]:]
+	if end := bytes.Index(body, []byte("endobj")); end >= 0 {
+		body = body[:end]
+	}
+	return body
+}
+
+// pdfDictString returns the string value of a key in a PDF dictionary
+func pdfDictString(dict []byte, key string) string {
+	name := []byte("/" + key)
+	for offset := 0; ; {
+		index := bytes.Index(dict[offset:], name)
+		if index < 0 {
+			return ""
+		}
+		pos := offset + index + len(name)
+		offset = pos
+
+		// Skip longer names sharing the prefix (/Title vs /TitleSort)
+		if pos < len(dict) && !isPDFDelimiter(dict[pos]) {
+			continue
+		}
+		for pos < len(dict) && isPDFWhitespace(dict[pos]) {
+			pos++
+		}
+		if pos >= len(dict) {
+			return ""
+		}
+
+		switch dict[pos] {
+		case '(':
+			return decodePDFText(parsePDFLiteral(dict[pos+1:]))
+		case '<':
+			return decodePDFText(parsePDFHex(dict[pos+1:]))
+		}
+		return "" // Indirect references and other value types are not followed
+	}
+}
+
+// parsePDFLiteral parses a literal string (after its opening parenthesis)
+func parsePDFLiteral(data []byte) []byte {
+	var out []byte
+	depth := 0
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case c == '\\' && i+1 < len(data):
+			i++
+			switch next := data[i]; next {
+			case 'n':
+				out = append(out, '\n')
+			case 'r':
+				out = append(out, '\r')
+			case 't':
+				out = append(out, '\t')
+			case 'b':
+				out = append(out, '\b')
+			case 'f':
+				out = append(out, '\f')
+			case '\r':
+				if i+1 < len(data) && data[i+1] == '\n' {
+					i++
+				}
+			case '\n':
+				// Line continuation
+			default:
+				if next >= '0' && next <= '7' {
+					value := 0
+					for digits := 0; digits < 3 && i < len(data) && data[i] >= '0' && data[i] <= '7'; digits++ {
+						value = value*8 + int(data[i]-'0')
+						i++
+					}
+					i--
+					out = append(out, byte(value))
+				} else {
+					out = append(out, next)
+				}
+			}
+		case c == '(':
+			depth++
+			out = append(out, c)
+		case c == ')':
+			if depth == 0 {
+				return out
+			}
+			depth--
+			out = append(out, c)
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// parsePDFHex parses a hexadecimal string (after its opening angle bracket)
+func parsePDFHex(data []byte) []byte {
+	var digits []byte
+	for _, c := range data {
+		if c == '>' {
+			break
+		}
+		if (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F') {
+			digits = append(digits, c)
+		}
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+
+	out := make([]byte, 0, len(digits)/2)
+	for i := 0; i < len(digits); i += 2 {
+		value, _ := strconv.ParseUint(string(digits[i:i+2]), 16, 8)
+		out = append(out, byte(value))
+	}
+	return out
+}
+
+// decodePDFText decodes a PDF text string: UTF-16BE or UTF-8 with a byte order mark, else PDFDocEncoding
+func decodePDFText(data []byte) string {
+	var text string
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		text = decodeUTF16(data, true)
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		text = string(data[3:])
+	case utf8.Valid(data):
+		text = string(data)
+	default:
+		text = latin1ToString(data) // PDFDocEncoding matches Latin-1 for printable text
+	}
+	return strings.TrimSpace(text)
+}
+
+// isPDFWhitespace reports whether a byte is PDF whitespace
+func isPDFWhitespace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' || c == 0
+}
+
+// isPDFDelimiter reports whether a byte ends a PDF name
+func isPDFDelimiter(c byte) bool {
+	return isPDFWhitespace(c) || strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+// parsePDFDate parses a PDF date such as "D:20230415123000+02'00'".
+// Every part after the year is optional; dates without an offset are in the given location.
+func parsePDFDate(value string, location *time.Location) (time.Time, bool) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "D:")
+
+	var digits []int
+	for _, width := range []int{4, 2, 2, 2, 2, 2} {
+		if len(value) < width || strings.IndexFunc(value[:width], func(r rune) bool { return r < '0' || r > '9' }) >= 0 {
+			break
+		}
+		n, _ := strconv.Atoi(value[:width])
+		digits = append(digits, n)
+		value = value[width:]
+	}
+	if len(digits) == 0 {
+		return time.Time{}, false
+	}
+	parts := []int{0, 1, 1, 0, 0, 0}
+	copy(parts, digits)
+	if parts[1] < 1 || parts[1] > 12 || parts[2] < 1 || parts[2] > 31 {
+		return time.Time{}, false
+	}
+
+	// Offset: Z, or +HH'mm' / -HH'mm'
+	zone := location
+	if value != "" {
+		switch value[0] {
+		case 'Z':
+			zone = time.UTC
+		case '+', '-':
+			offset := strings.FieldsFunc(value[1:], func(r rune) bool { return r == '\'' })
+			hours, minutes := 0, 0
+			if len(offset) > 0 {
+				hours, _ = strconv.Atoi(offset[0])
+			}
+			if len(offset) > 1 {
+				minutes, _ = strconv.Atoi(offset[1])
+			}
+			seconds := hours*3600 + minutes*60
+			if value[0] == '-' {
+				seconds = -seconds
+			}
+			zone = time.FixedZone("", seconds)
+		}
+	}
+
+	parsed := time.Date(parts[0], time.Month(parts[1]), parts[2], parts[3], parts[4], parts[5], 0, zone)
+	return parsed, isPlausibleDate(parsed)
+}
+
+// parseDocumentDate parses the ISO 8601 dates of XMP, OOXML and ODF metadata
+func parseDocumentDate(value string, location *time.Location) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, false
+	}
+	if parsed, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return parsed, isPlausibleDate(parsed)
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05.999999999", "2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02"} {
+		if parsed, err := time.ParseInLocation(layout, value, location); err == nil {
+			return parsed, isPlausibleDate(parsed)
+		}
+	}
+	return time.Time{}, false
+}
+
+// xmpValue returns the first match of an XMP pattern with XML entities decoded
+func xmpValue(pattern *regexp.Regexp, data []byte) string {
+	match := pattern.FindSubmatch(data)
+	if match == nil {
+		return ""
+	}
+	for _, group := range match[1:] {
+		if len(group) > 0 {
+			return strings.TrimSpace(html.UnescapeString(string(group)))
+		}
+	}
+	return ""
+}
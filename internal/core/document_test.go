@@ -0,0 +1,199 @@
+package core
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testPDF builds a PDF skeleton from its objects and trailer dictionaries
+func testPDF(parts ...string) []byte {
+	return []byte("%PDF-1.7\n" + strings.Join(parts, "\n") + "\n%%EOF\n")
+}
+
+// testCoreXML builds the docProps/core.xml of an OOXML file
+func testCoreXML(properties string) string {
+	return `<?xml version="1.0" encoding="UTF-8"?>
+<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:dcterms="http://purl.org/dc/terms/" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">` +
+		properties + `</cp:coreProperties>`
+}
+
+// testMetaXML builds the meta.xml of an OpenDocument file
+func testMetaXML(meta string) string {
+	return `<?xml version="1.0" encoding="UTF-8"?>
+<office:document-meta xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:meta="urn:oasis:names:tc:opendocument:xmlns:meta:1.0"><office:meta>` +
+		meta + `</office:meta></office:document-meta>`
+}
+
+func TestReadDocumentMetadata(t *testing.T) {
+	local := time.FixedZone("local", 3600)
+	xmp := `<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF><rdf:Description xmp:CreateDate="2023-04-15T10:11:12Z">
+<dc:title><rdf:Alt><rdf:li xml:lang="x-default">XMP title</rdf:li></rdf:Alt></dc:title>
+<dc:creator><rdf:Seq><rdf:li>Anne &amp; Bob</rdf:li></rdf:Seq></dc:creator>
+<xmp:ModifyDate>2023-04-16T08:00:00</xmp:ModifyDate>
+</rdf:Description></rdf:RDF></x:xmpmeta>`
+
+	tests := []struct {
+		name    string
+		file    string
+		data    []byte
+		want    DocumentMetadata
+		wantErr bool
+	}{
+		{"pdf info dictionary", "a.pdf", testPDF(
+			`1 0 obj`,
+			`<< /TitleSort (Sort key) /Title (Holiday \(2023\)) /Author <FEFF004A006F> /CreationDate (D:20230415123000+02'00') /ModDate (D:20230416) >>`,
+			`endobj`,
+			`trailer << /Size 2 /Info 1 0 R >>`,
+		), DocumentMetadata{
+			Title:   "Holiday (2023)",
+			Author:  "Jo",
+			Created: time.Date(2023, 4, 15, 12, 30, 0, 0, time.FixedZone("", 7200)), HasCreated: true,
+			Modified: time.Date(2023, 4, 16, 0, 0, 0, 0, local), HasModified: true,
+		}, false},
+		{"pdf incremental update", "a.pdf", testPDF(
+			`1 0 obj << /Title (Draft) /CreationDate (D:20220101) >> endobj`,
+			`trailer << /Info 1 0 R >>`,
+			`11 0 obj << /Title (Final) /CreationDate (D:20230415Z) >> endobj`,
+			`trailer << /Info 11 0 R /Prev 9 >>`,
+		), DocumentMetadata{Title: "Final", Created: time.Date(2023, 4, 15, 0, 0, 0, 0, time.UTC), HasCreated: true}, false},
+		{"pdf XMP fills gaps", "a.pdf", testPDF(
+			`1 0 obj << /Title (Info title) /Author 4 0 R >> endobj`,
+			`2 0 obj << /Type /Metadata /Subtype /XML >> stream`, xmp, `endstream endobj`,
+			`trailer << /Info 1 0 R >>`,
+		), DocumentMetadata{
+			Title:   "Info title",
+			Author:  "Anne & Bob",
+			Created: time.Date(2023, 4, 15, 10, 11, 12, 0, time.UTC), HasCreated: true,
+			Modified: time.Date(2023, 4, 16, 8, 0, 0, 0, local), HasModified: true,
+		}, false},
+		{"pdf XMP only", "a.pdf", testPDF(`2 0 obj << /Type /Metadata >> stream`, xmp, `endstream endobj`), DocumentMetadata{
+			Title:   "XMP title",
+			Author:  "Anne & Bob",
+			Created: time.Date(2023, 4, 15, 10, 11, 12, 0, time.UTC), HasCreated: true,
+			Modified: time.Date(2023, 4, 16, 8, 0, 0, 0, local), HasModified: true,
+		}, false},
+		{"pdf info object missing", "a.pdf", testPDF(`trailer << /Info 7 0 R >>`), DocumentMetadata{}, false},
+		{"pdf invalid dates", "a.pdf", testPDF(
+			`1 0 obj << /Title () /CreationDate (yesterday) /ModDate (D:20231301) >> endobj`,
+			`trailer << /Info 1 0 R >>`,
+		), DocumentMetadata{}, false},
+		{"pdf without metadata", "a.pdf", testPDF(), DocumentMetadata{}, false},
+		{"docx core properties", "a.docx", testZip(t,
+			testArchiveFile{"[Content_Types].xml", `<Types/>`},
+			testArchiveFile{"docProps/core.xml", testCoreXML(`<dc:title> Report </dc:title><dc:creator>Jo</dc:creator>` +
+				`<dcterms:created xsi:type="dcterms:W3CDTF">2023-04-15T10:11:12Z</dcterms:created>` +
+				`<dcterms:modified xsi:type="dcterms:W3CDTF">2023-04-16T08:00:00+02:00</dcterms:modified>`)},
+		), DocumentMetadata{
+			Title:   "Report",
+			Author:  "Jo",
+			Created: time.Date(2023, 4, 15, 10, 11, 12, 0, time.UTC), HasCreated: true,
+			Modified: time.Date(2023, 4, 16, 6, 0, 0, 0, time.UTC), HasModified: true,
+		}, false},
+		{"xlsx without dates", "a.xlsx", testZip(t, testArchiveFile{"docProps/core.xml", testCoreXML(`<dc:creator>Jo</dc:creator>`)}),
+			DocumentMetadata{Author: "Jo"}, false},
+		{"pptx local dates", "a.pptx", testZip(t, testArchiveFile{"docProps/core.xml", testCoreXML(`<dcterms:created>2023-04-15T10:11</dcterms:created><dcterms:modified>not a date</dcterms:modified>`)}),
+			DocumentMetadata{Created: time.Date(2023, 4, 15, 10, 11, 0, 0, local), HasCreated: true}, false},
+		{"odt meta", "a.odt", testZip(t,
+			testArchiveFile{"mimetype", "application/vnd.oasis.opendocument.text"},
+			testArchiveFile{"meta.xml", testMetaXML(`<dc:title>Letter</dc:title><meta:initial-creator>Jo</meta:initial-creator><dc:creator>Sam</dc:creator>` +
+				`<meta:creation-date>2023-04-15T10:11:12.345</meta:creation-date><dc:date>2023-04-16T08:00:00Z</dc:date>`)},
+		), DocumentMetadata{
+			Title:   "Letter",
+			Author:  "Jo",
+			Created: time.Date(2023, 4, 15, 10, 11, 12, 345000000, local), HasCreated: true,
+			Modified: time.Date(2023, 4, 16, 8, 0, 0, 0, time.UTC), HasModified: true,
+		}, false},
+		{"ods without initial creator", "a.ods", testZip(t, testArchiveFile{"meta.xml", testMetaXML(`<dc:creator>Sam</dc:creator><meta:creation-date>2023-04-15</meta:creation-date>`)}),
+			DocumentMetadata{Author: "Sam", Created: time.Date(2023, 4, 15, 0, 0, 0, 0, local), HasCreated: true}, false},
+		{"odp empty meta", "a.odp", testZip(t, testArchiveFile{"meta.xml", testMetaXML(``)}), DocumentMetadata{}, false},
+		{"package without properties", "a.docx", testZip(t, testArchiveFile{"word/document.xml", `<w:document/>`}), DocumentMetadata{}, true},
+		{"malformed core properties", "a.docx", testZip(t, testArchiveFile{"docProps/core.xml", `<cp:coreProperties><dc:title>`}), DocumentMetadata{}, true},
+		{"truncated package", "a.docx", []byte("PK\x03\x04 truncated"), DocumentMetadata{}, true},
+		{"not a document", "a.txt", []byte("plain text"), DocumentMetadata{}, true},
+		{"empty", "a.pdf", nil, DocumentMetadata{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadDocumentMetadata(writeTestFile(t, tt.file, tt.data), local)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadDocumentMetadata() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.Title != tt.want.Title || got.Author != tt.want.Author {
+				t.Errorf("title, author = %q, %q, want %q, %q", got.Title, got.Author, tt.want.Title, tt.want.Author)
+			}
+			if got.HasCreated != tt.want.HasCreated || !got.Created.Equal(tt.want.Created) {
+				t.Errorf("created = %v (%v), want %v (%v)", got.Created, got.HasCreated, tt.want.Created, tt.want.HasCreated)
+			}
+			if got.HasModified != tt.want.HasModified || !got.Modified.Equal(tt.want.Modified) {
+				t.Errorf("modified = %v (%v), want %v (%v)", got.Modified, got.HasModified, tt.want.Modified, tt.want.HasModified)
+			}
+		})
+	}
+
+	if _, err := ReadDocumentMetadata(filepath.Join(t.TempDir(), "missing.pdf"), local); err == nil {
+		t.Error("ReadDocumentMetadata() on a missing file returned no error")
+	}
+}
+
+func TestParsePDFDate(t *testing.T) {
+	local := time.FixedZone("local", 3600)
+	tests := []struct {
+		value string
+		want  time.Time
+		ok    bool
+	}{
+		{"D:20230415123000+02'00'", time.Date(2023, 4, 15, 12, 30, 0, 0, time.FixedZone("", 7200)), true},
+		{"D:20230415123000-05'30", time.Date(2023, 4, 15, 12, 30, 0, 0, time.FixedZone("", -19800)), true},
+		{"D:20230415123000+02", time.Date(2023, 4, 15, 12, 30, 0, 0, time.FixedZone("", 7200)), true},
+		{"D:20230415123000Z", time.Date(2023, 4, 15, 12, 30, 0, 0, time.UTC), true},
+		{"D:20230415123000Z00'00'", time.Date(2023, 4, 15, 12, 30, 0, 0, time.UTC), true},
+		{"D:20230415123000", time.Date(2023, 4, 15, 12, 30, 0, 0, local), true},
+		{"D:202304151230", time.Date(2023, 4, 15, 12, 30, 0, 0, local), true},
+		{"20230415", time.Date(2023, 4, 15, 0, 0, 0, 0, local), true},
+		{" D:2023 ", time.Date(2023, 1, 1, 0, 0, 0, 0, local), true},
+		{"D:20231301", time.Time{}, false},
+		{"D:20230400", time.Time{}, false},
+		{"D:18500101", time.Time{}, false},
+		{"D:abcd", time.Time{}, false},
+		{"", time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := parsePDFDate(tt.value, local)
+		if ok != tt.ok || (ok && !got.Equal(tt.want)) {
+			t.Errorf("parsePDFDate(%q) = %v, %v, want %v, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseDocumentDate(t *testing.T) {
+	local := time.FixedZone("local", 3600)
+	tests := []struct {
+		value string
+		want  time.Time
+		ok    bool
+	}{
+		{"2023-04-15T10:11:12Z", time.Date(2023, 4, 15, 10, 11, 12, 0, time.UTC), true},
+		{"2023-04-15T10:11:12.5+02:00", time.Date(2023, 4, 15, 8, 11, 12, 500000000, time.UTC), true},
+		{"2023-04-15T10:11:12.123456789", time.Date(2023, 4, 15, 10, 11, 12, 123456789, local), true},
+		{"2023-04-15T10:11:12", time.Date(2023, 4, 15, 10, 11, 12, 0, local), true},
+		{"2023-04-15T10:11", time.Date(2023, 4, 15, 10, 11, 0, 0, local), true},
+		{" 2023-04-15 ", time.Date(2023, 4, 15, 0, 0, 0, 0, local), true},
+		{"0001-01-01T00:00:00Z", time.Time{}, false},
+		{"15/04/2023", time.Time{}, false},
+		{"", time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseDocumentDate(tt.value, local)
+		if ok != tt.ok || (ok && !got.Equal(tt.want)) {
+			t.Errorf("parseDocumentDate(%q) = %v, %v, want %v, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
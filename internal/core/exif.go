@@ -1,28 +1,92 @@
 package core
 
 import (
+	"bytes"
+	"encoding/binary"
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/rwcarlsen/goexif/exif"
+	"github.com/rwcarlsen/goexif/tiff"
 	"zensort/internal/config"
 )
 
 // EXIFData represents extracted EXIF metadata
 type EXIFData struct {
-	Make         string
-	Model        string
-	DateTime     time.Time
-	HasDateTime  bool
-	Orientation  int
-	Software     string
+	Make              string
+	Model             string
+	DateTime          time.Time
+	HasDateTime       bool
+	DateTag           string // Tag the date was read from (DateTimeOriginal, DateTimeDigitized, DateTime or GPS)
+	HasTimeZone       bool   // DateTime uses an offset recorded by the camera rather than the default zone
+	Orientation       int
+	Software          string
+	ContentIdentifier string // Apple Live Photo pairing identifier from the maker notes
+	Latitude          float64
+	Longitude         float64
+	Altitude          float64 // Meters above sea level
+	HasGPS            bool
+}
+
+// EXIFOptions controls how EXIF timestamps are interpreted
+type EXIFOptions struct {
+	DefaultLocation *time.Location // Zone for timestamps without a recorded offset (nil means local time)
+	UseGPSTime      bool           // Fall back to the GPS UTC timestamp when no date tag is present
+}
+
+// Tags from EXIF 2.31 that goexif does not map itself
+const (
+	exifOffsetTime          exif.FieldName = "OffsetTime"
+	exifOffsetTimeOriginal  exif.FieldName = "OffsetTimeOriginal"
+	exifOffsetTimeDigitized exif.FieldName = "OffsetTimeDigitized"
+)
+
+// offsetTimeFields maps the EXIF sub-IFD offset tags to field names
+var offsetTimeFields = map[uint16]exif.FieldName{
+	0x9010: exifOffsetTime,
+	0x9011: exifOffsetTimeOriginal,
+	0x9012: exifOffsetTimeDigitized,
+}
+
+// exifDateTags lists the date tags in order of preference with their sub-second and offset tags.
+// DateTime is last because editors rewrite it when saving.
+var exifDateTags = []struct {
+	date, subSec, offset exif.FieldName
+}{
+	{exif.DateTimeOriginal, exif.SubSecTimeOriginal, exifOffsetTimeOriginal},
+	{exif.DateTimeDigitized, exif.SubSecTimeDigitized, exifOffsetTimeDigitized},
+	{exif.DateTime, exif.SubSecTime, exifOffsetTime},
+}
+
+// EXIFOptionsFromConfig builds EXIF options from the date/time configuration. An unknown
+// default time zone is an error.
+func EXIFOptionsFromConfig(cfg *config.Config) (EXIFOptions, error) {
+	opts := EXIFOptions{UseGPSTime: cfg.DateTime.UseGPSTime}
+
+	zone := strings.TrimSpace(cfg.DateTime.DefaultTimeZone)
+	if zone != "" && !strings.EqualFold(zone, "local") {
+		location, err := time.LoadLocation(zone)
+		if err != nil {
+			return opts, fmt.Errorf("unknown default time zone %q: %w", zone, err)
+		}
+		opts.DefaultLocation = location
+	}
+
+	return opts, nil
 }
 
 // ExtractEXIF extracts EXIF data from an image file with timeout protection
 func ExtractEXIF(filePath string) (*EXIFData, error) {
+	return ExtractEXIFWithOptions(filePath, EXIFOptions{})
+}
+
+// ExtractEXIFWithOptions extracts EXIF data using the given timestamp options
+func ExtractEXIFWithOptions(filePath string, opts EXIFOptions) (*EXIFData, error) {
 	// Set timeout to prevent hanging on corrupted files
 	done := make(chan *EXIFData, 1)
 	errChan := make(chan error, 1)
@@ -35,14 +99,21 @@ func ExtractEXIF(filePath string) (*EXIFData, error) {
 		}
 		defer file.Close()
 
-		x, err := exif.Decode(file)
-		if err != nil {
+		var data *EXIFData
+		x, err := decodeEXIF(file, filePath)
+		if err != nil && (x == nil || exif.IsCriticalError(err)) {
 			// Return empty EXIF data if no EXIF found
-			done <- &EXIFData{}
-			return
+			data = &EXIFData{}
+		} else {
+			data = extractEXIFFields(x, opts)
 		}
 		
-		data := extractEXIFFields(x)
+		// goexif cannot follow the layout of many RAW formats, read their headers directly
+		if !data.HasDateTime && IsRawFile(filePath) {
+			if rawData, ok := readRawEXIF(file, opts); ok {
+				data = rawData
+			}
+		}
 		done <- data
 	}()
 	
@@ -56,8 +127,26 @@ func ExtractEXIF(filePath string) (*EXIFData, error) {
 	}
 }
 
+// decodeEXIF decodes EXIF from a JPEG/TIFF file, or from the Exif item of a HEIC/HEIF file
+func decodeEXIF(file *os.File, filePath string) (*exif.Exif, error) {
+	ext := strings.ToLower(filepath.Ext(filePath))
+	if ext != ".heic" && ext != ".heif" {
+		return exif.Decode(file)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	tiffData, err := extractHEIFExif(file, info.Size())
+	if err != nil {
+		return nil, err
+	}
+	return exif.Decode(bytes.NewReader(tiffData))
+}
+
 // extractEXIFFields extracts EXIF fields from decoded EXIF data
-func extractEXIFFields(x *exif.Exif) *EXIFData {
+func extractEXIFFields(x *exif.Exif, opts EXIFOptions) *EXIFData {
 
 	data := &EXIFData{}
 
@@ -75,15 +164,12 @@ func extractEXIFFields(x *exif.Exif) *EXIFData {
 		}
 	}
 
-	// Extract date/time with multiple format support
-	if dateTime, err := x.Get(exif.DateTime); err == nil {
-		if dateTimeStr, err := dateTime.StringVal(); err == nil {
-			if parsedTime := parseDateTime(dateTimeStr); !parsedTime.IsZero() {
-				data.DateTime = parsedTime
-				data.HasDateTime = true
-			}
-		}
-	}
+	// Extract capture date/time, preferring the original over digitized and modified dates
+	loadOffsetTimeTags(x)
+	extractCaptureTime(x, data, opts)
+
+	// Extract GPS position
+	extractGPSPosition(x, data)
 
 	// Extract orientation
 	if orientation, err := x.Get(exif.Orientation); err == nil {
@@ -99,9 +185,223 @@ func extractEXIFFields(x *exif.Exif) *EXIFData {
 		}
 	}
 
+	// Extract the Live Photo content identifier from Apple maker notes
+	if makerNote, err := x.Get(exif.MakerNote); err == nil {
+		data.ContentIdentifier = appleContentIdentifier(makerNote.Val)
+	}
+
 	return data
 }
 
+// extractCaptureTime fills the date fields from the best available date tag,
+// including sub-seconds and the recorded UTC offset
+func extractCaptureTime(x *exif.Exif, data *EXIFData, opts EXIFOptions) {
+	defaultLocation := opts.DefaultLocation
+	if defaultLocation == nil {
+		defaultLocation = time.Local
+	}
+
+	// Canon and a few other makers record the zone in their maker notes
+	if tz, err := x.TimeZone(); err == nil && tz != nil {
+		defaultLocation = tz
+	}
+
+	lookup := func(name exif.FieldName) string { return exifString(x, name) }
+	if applyEXIFDateTags(data, lookup, defaultLocation) {
+		return
+	}
+
+	// Optional fallback: GPS timestamps are always UTC
+	if opts.UseGPSTime {
+		if gpsTime, ok := extractGPSTime(x); ok {
+			data.DateTime = gpsTime.In(defaultLocation)
+			data.HasDateTime = true
+			data.DateTag = "GPS"
+			data.HasTimeZone = true
+		}
+	}
+}
+
+// applyEXIFDateTags fills the date fields from the first usable date tag returned by lookup.
+// It reports whether a date was found.
+func applyEXIFDateTags(data *EXIFData, lookup func(exif.FieldName) string, defaultLocation *time.Location) bool {
+	for _, tags := range exifDateTags {
+		dateStr := lookup(tags.date)
+		if dateStr == "" {
+			continue
+		}
+
+		location := defaultLocation
+		offsetLocation, hasOffset := parseEXIFOffset(lookup(tags.offset))
+		if hasOffset {
+			location = offsetLocation
+		}
+
+		parsedTime := parseDateTimeInLocation(dateStr, location)
+		if parsedTime.IsZero() {
+			continue
+		}
+
+		data.DateTime = parsedTime.Add(parseSubSeconds(lookup(tags.subSec)))
+		data.HasDateTime = true
+		data.DateTag = string(tags.date)
+		data.HasTimeZone = hasOffset
+		return true
+	}
+	return false
+}
+
+// appleContentIdentifier reads tag 0x0011 (ContentIdentifier) from an Apple iOS maker note.
+// The maker note is "Apple iOS\0", a version, "MM" and a big-endian IFD whose
+// value offsets are relative to the start of the maker note.
+func appleContentIdentifier(makerNote []byte) string {
+	const headerSize = 14
+	if len(makerNote) < headerSize+2 || !bytes.HasPrefix(makerNote, []byte("Apple iOS\x00")) {
+		return ""
+	}
+
+	count := int(binary.BigEndian.Uint16(makerNote[headerSize : headerSize+2]))
+	for i := 0; i < count; i++ {
+		entry := headerSize + 2 + i*12
+		if entry+12 > len(makerNote) {
+			break
+		}
+		tagID := binary.BigEndian.Uint16(makerNote[entry : entry+2])
+		tagType := binary.BigEndian.Uint16(makerNote[entry+2 : entry+4])
+		length := int(binary.BigEndian.Uint32(makerNote[entry+4 : entry+8]))
+		if tagID != 0x0011 || tagType != 2 {
+			continue
+		}
+
+		// ASCII values up to 4 bytes are stored inline, longer ones at an offset
+		valueStart := entry + 8
+		if length > 4 {
+			valueStart = int(binary.BigEndian.Uint32(makerNote[entry+8 : entry+12]))
+		}
+		if valueStart < 0 || length < 0 || valueStart+length > len(makerNote) {
+			return ""
+		}
+		return strings.TrimRight(string(makerNote[valueStart:valueStart+length]), "\x00 ")
+	}
+
+	return ""
+}
+
+// loadOffsetTimeTags loads the EXIF 2.31 offset tags from the EXIF sub-IFD
+func loadOffsetTimeTags(x *exif.Exif) {
+	if x.Tiff == nil {
+		return
+	}
+	pointer, err := x.Get(exif.ExifIFDPointer)
+	if err != nil {
+		return
+	}
+	offset, err := pointer.Int64(0)
+	if err != nil {
+		return
+	}
+
+	r := bytes.NewReader(x.Raw)
+	if _, err := r.Seek(offset, 0); err != nil {
+		return
+	}
+	subDir, _, err := tiff.DecodeDir(r, x.Tiff.Order)
+	if err != nil {
+		return
+	}
+	x.LoadTags(subDir, offsetTimeFields, false)
+}
+
+// exifString returns a trimmed string tag value (empty if missing)
+func exifString(x *exif.Exif, name exif.FieldName) string {
+	tag, err := x.Get(name)
+	if err != nil {
+		return ""
+	}
+	value, err := tag.StringVal()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimRight(value, "\x00"))
+}
+
+// parseEXIFOffset parses an EXIF offset such as "+02:00" into a fixed zone
+func parseEXIFOffset(offset string) (*time.Location, bool) {
+	if len(offset) != 6 || (offset[0] != '+' && offset[0] != '-') || offset[3] != ':' {
+		return nil, false
+	}
+	hours, err1 := strconv.Atoi(offset[1:3])
+	minutes, err2 := strconv.Atoi(offset[4:6])
+	if err1 != nil || err2 != nil || hours > 14 || minutes > 59 {
+		return nil, false
+	}
+	seconds := hours*3600 + minutes*60
+	if offset[0] == '-' {
+		seconds = -seconds
+	}
+	return time.FixedZone(offset, seconds), true
+}
+
+// parseSubSeconds converts an EXIF SubSecTime value ("123" = 0.123s) to a duration
+func parseSubSeconds(subSec string) time.Duration {
+	if subSec == "" {
+		return 0
+	}
+	fraction, err := strconv.ParseFloat("0."+subSec, 64)
+	if err != nil {
+		return 0
+	}
+	return time.Duration(fraction * float64(time.Second))
+}
+
+// extractGPSPosition reads the GPS latitude, longitude and altitude.
+// 0,0 is what cameras write without a fix, so it counts as no position.
+func extractGPSPosition(x *exif.Exif, data *EXIFData) {
+	lat, long, err := x.LatLong()
+	if err != nil || (lat == 0 && long == 0) || math.IsNaN(lat) || math.IsNaN(long) {
+		return
+	}
+	data.Latitude = lat
+	data.Longitude = long
+	data.HasGPS = true
+
+	if tag, err := x.Get(exif.GPSAltitude); err == nil {
+		if num, den, err := tag.Rat2(0); err == nil && den != 0 {
+			data.Altitude = float64(num) / float64(den)
+			// GPSAltitudeRef 1 means below sea level
+			if ref, err := x.Get(exif.GPSAltitudeRef); err == nil {
+				if value, err := ref.Int(0); err == nil && value == 1 {
+					data.Altitude = -data.Altitude
+				}
+			}
+		}
+	}
+}
+
+// extractGPSTime combines GPSDateStamp and GPSTimeStamp into a UTC time
+func extractGPSTime(x *exif.Exif) (time.Time, bool) {
+	date, err := time.Parse("2006:01:02", exifString(x, exif.GPSDateStamp))
+	if err != nil {
+		return time.Time{}, false
+	}
+	tag, err := x.Get(exif.GPSTimeStamp)
+	if err != nil || tag.Count < 3 {
+		return time.Time{}, false
+	}
+
+	var parts [3]float64
+	for i := range parts {
+		num, den, err := tag.Rat2(i)
+		if err != nil || den == 0 {
+			return time.Time{}, false
+		}
+		parts[i] = float64(num) / float64(den)
+	}
+
+	seconds := parts[0]*3600 + parts[1]*60 + parts[2]
+	return date.Add(time.Duration(seconds * float64(time.Second))), true
+}
+
 // IsEditedImage checks if an image has been edited based on EXIF software field
 func IsEditedImage(exifData *EXIFData, config *config.Config) bool {
 	if exifData.Software == "" {
@@ -121,13 +421,18 @@ func IsEditedImage(exifData *EXIFData, config *config.Config) bool {
 
 // parseDateTime attempts to parse datetime string with multiple formats
 func parseDateTime(dateTimeStr string) time.Time {
+	return parseDateTimeInLocation(dateTimeStr, time.UTC)
+}
+
+// parseDateTimeInLocation parses a datetime string, interpreting zone-less values in location
+func parseDateTimeInLocation(dateTimeStr string, location *time.Location) time.Time {
 	// Try standard EXIF format first: "2006:01:02 15:04:05"
-	if parsedTime, err := time.Parse("2006:01:02 15:04:05", dateTimeStr); err == nil {
+	if parsedTime, err := time.ParseInLocation("2006:01:02 15:04:05", dateTimeStr, location); err == nil {
 		return parsedTime
 	}
 	
 	// Try ISO format: "2006-01-02 15:04:05"
-	if parsedTime, err := time.Parse("2006-01-02 15:04:05", dateTimeStr); err == nil {
+	if parsedTime, err := time.ParseInLocation("2006-01-02 15:04:05", dateTimeStr, location); err == nil {
 		return parsedTime
 	}
 	
@@ -142,7 +447,7 @@ func parseDateTime(dateTimeStr string) time.Time {
 	}
 	
 	for _, format := range formats {
-		if parsedTime, err := time.Parse(format, dateTimeStr); err == nil {
+		if parsedTime, err := time.ParseInLocation(format, dateTimeStr, location); err == nil {
 			return parsedTime
 		}
 	}
@@ -152,38 +457,16 @@ func parseDateTime(dateTimeStr string) time.Time {
 
 // GetImageDestinationPath generates the destination path based on EXIF data
 func GetImageDestinationPath(basePath, fileName string, exifData *EXIFData, config *config.Config, isExport bool) string {
-	var subDir string
-	
 	if isExport {
-		subDir = config.ImageDirs.Exports
-	} else {
-		subDir = config.ImageDirs.Originals
+		return GetExportDestinationPath(basePath, config.ImageDirs.Exports, fileName, exifData, config)
 	}
+	subDir := config.ImageDirs.Originals
 
 	// If no EXIF data or missing camera info, use Collections
 	if exifData.Make == "" || exifData.Model == "" {
 		return filepath.Join(basePath, config.Directories.Images, subDir, "Collections", fileName)
 	}
 
-	// For exports: Images/Exports/Year/Date - HH-MM-SS -- Make - Model -- filename.jpg
-	if isExport && exifData.HasDateTime {
-		year := fmt.Sprintf("%04d", exifData.DateTime.Year())
-		date := exifData.DateTime.Format("2006-01-02")
-		time := exifData.DateTime.Format("15-04-05")
-		exportName := fmt.Sprintf("%s - %s -- %s - %s -- %s.jpg", 
-			date, time, exifData.Make, exifData.Model, 
-			strings.TrimSuffix(fileName, filepath.Ext(fileName)))
-		return filepath.Join(basePath, config.Directories.Images, subDir, year, exportName)
-	}
-	
-	// For exports without EXIF date: use configured no-EXIF year folder
-	if isExport {
-		exportName := fmt.Sprintf("%s - %s -- %s.jpg", 
-			exifData.Make, exifData.Model, 
-			strings.TrimSuffix(fileName, filepath.Ext(fileName)))
-		return filepath.Join(basePath, config.Directories.Images, subDir, config.ImageDirs.NoExifYearFolder, exportName)
-	}
-
 	// For originals: Images/Originals/Make - Model/Year/filename
 	if exifData.HasDateTime {
 		cameraDir := fmt.Sprintf("%s - %s", exifData.Make, exifData.Model)
@@ -196,10 +479,35 @@ func GetImageDestinationPath(basePath, fileName string, exifData *EXIFData, conf
 	return filepath.Join(basePath, config.Directories.Images, subDir, cameraDir, config.ImageDirs.NoExifYearFolder, fileName)
 }
 
+// GetExportDestinationPath generates the built-in export path below the given export folder
+func GetExportDestinationPath(basePath, folder, fileName string, exifData *EXIFData, config *config.Config) string {
+	// If no EXIF data or missing camera info, use Collections
+	if exifData.Make == "" || exifData.Model == "" {
+		return filepath.Join(basePath, config.Directories.Images, folder, "Collections", fileName)
+	}
+
+	// For exports: Images/Exports/Year/Date - HH-MM-SS -- Make - Model -- filename.jpg
+	if exifData.HasDateTime {
+		year := fmt.Sprintf("%04d", exifData.DateTime.Year())
+		date := exifData.DateTime.Format("2006-01-02")
+		time := exifData.DateTime.Format("15-04-05")
+		exportName := fmt.Sprintf("%s - %s -- %s - %s -- %s.jpg",
+			date, time, exifData.Make, exifData.Model,
+			strings.TrimSuffix(fileName, filepath.Ext(fileName)))
+		return filepath.Join(basePath, config.Directories.Images, folder, year, exportName)
+	}
+
+	// For exports without EXIF date: use configured no-EXIF year folder
+	exportName := fmt.Sprintf("%s - %s -- %s.jpg",
+		exifData.Make, exifData.Model,
+		strings.TrimSuffix(fileName, filepath.Ext(fileName)))
+	return filepath.Join(basePath, config.Directories.Images, folder, config.ImageDirs.NoExifYearFolder, exportName)
+}
+
 // IsImageFile checks if a file is an image based on extension
 func IsImageFile(fileName string) bool {
 	ext := strings.ToLower(filepath.Ext(fileName))
-	imageExts := []string{".jpg", ".jpeg", ".png", ".gif", ".bmp", ".tiff", ".tif", ".webp", ".heic", ".heif", ".raw", ".cr2", ".nef", ".arw", ".dng"}
+	imageExts := []string{".jpg", ".jpeg", ".png", ".gif", ".bmp", ".tiff", ".tif", ".webp", ".heic", ".heif", ".raw", ".cr2", ".cr3", ".nef", ".nrw", ".arw", ".dng", ".orf", ".rw2", ".raf", ".pef", ".srw"}
 	
 	for _, imgExt := range imageExts {
 		if ext == imgExt {
@@ -0,0 +1,351 @@
+package core
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+)
+
+// Identifiers of the metadata segments in JPEG files
+var (
+	jpegEXIFHeader        = []byte("Exif\x00\x00")
+	jpegXMPHeader         = []byte("http://ns.adobe.com/xap/1.0/\x00")
+	jpegExtendedXMPHeader = []byte("http://ns.adobe.com/xmp/extension/\x00")
+	jpegICCHeader         = []byte("ICC_PROFILE\x00")
+)
+
+// pngSignature starts every PNG file
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// pngXMPKeyword is the iTXt keyword of XMP packets in PNG files
+const pngXMPKeyword = "XML:com.adobe.xmp"
+
+// maxJPEGSegmentPayload is the largest payload of a JPEG marker segment
+const maxJPEGSegmentPayload = 65535 - 2
+
+// xmpOrientation matches the orientation property of an XMP packet in attribute or element form
+var xmpOrientation = regexp.MustCompile(`(tiff:Orientation(?:="|>))\d(["<])`)
+
+// imageMetadata is the metadata carried from an original image into its exports
+type imageMetadata struct {
+	EXIF        []byte   // TIFF structure of the EXIF data, without the JPEG "Exif" header
+	ICC         []byte   // ICC color profile
+	XMP         []byte   // XMP packet
+	ExtendedXMP [][]byte // Payloads of JPEG extended XMP segments, written back unchanged
+}
+
+// empty reports whether there is no metadata to write
+func (m *imageMetadata) empty() bool {
+	return m == nil || (len(m.EXIF) == 0 && len(m.ICC) == 0 && len(m.XMP) == 0 && len(m.ExtendedXMP) == 0)
+}
+
+// readImageMetadata reads the EXIF, ICC profile and XMP of a JPEG, PNG or WebP file
+func readImageMetadata(filePath string) (*imageMetadata, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8}):
+		return readJPEGMetadata(data)
+	case bytes.HasPrefix(data, pngSignature):
+		return readPNGMetadata(data)
+	case len(data) >= 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return readWebPMetadata(data), nil
+	}
+	return &imageMetadata{}, nil
+}
+
+// readJPEGMetadata collects the metadata segments before the image data of a JPEG file
+func readJPEGMetadata(data []byte) (*imageMetadata, error) {
+	meta := &imageMetadata{}
+	iccChunks := make(map[byte][]byte)
+
+	for pos := 2; pos+4 <= len(data); {
+		if data[pos] != 0xFF {
+			return nil, fmt.Errorf("invalid JPEG marker at offset %d", pos)
+		}
+		marker := data[pos+1]
+		if marker == 0xFF {
+			pos++ // Fill byte
+			continue
+		}
+		if marker == 0xDA || marker == 0xD9 { // Start of scan or end of image: no more metadata
+			break
+		}
+
+		length := int(binary.BigEndian.Uint16(data[pos+2 : pos+4]))
+		if length < 2 || pos+2+length > len(data) {
+			return nil, fmt.Errorf("invalid JPEG segment length at offset %d", pos)
+		}
+		payload := data[pos+4 : pos+2+length]
+
+		switch {
+		case marker == 0xE1 && bytes.HasPrefix(payload, jpegEXIFHeader) && meta.EXIF == nil:
+			meta.EXIF = payload[len(jpegEXIFHeader):]
+		case marker == 0xE1 && bytes.HasPrefix(payload, jpegXMPHeader) && meta.XMP == nil:
+			meta.XMP = payload[len(jpegXMPHeader):]
+		case marker == 0xE1 && bytes.HasPrefix(payload, jpegExtendedXMPHeader):
+			meta.ExtendedXMP = append(meta.ExtendedXMP, payload[len(jpegExtendedXMPHeader):])
+		case marker == 0xE2 && bytes.HasPrefix(payload, jpegICCHeader) && len(payload) > len(jpegICCHeader)+2:
+			// Profiles larger than a segment are split, each chunk numbered from 1
+			sequence := payload[len(jpegICCHeader)]
+			iccChunks[sequence] = payload[len(jpegICCHeader)+2:]
+		}
+		pos += 2 + length
+	}
+
+	if len(iccChunks) > 0 {
+		sequences := make([]int, 0, len(iccChunks))
+		for sequence := range iccChunks {
+			sequences = append(sequences, int(sequence))
+		}
+		sort.Ints(sequences)
+		for _, sequence := range sequences {
+			meta.ICC = append(meta.ICC, iccChunks[byte(sequence)]...)
+		}
+	}
+	return meta, nil
+}
+
+// readPNGMetadata reads the eXIf, iCCP and XMP iTXt chunks of a PNG file
+func readPNGMetadata(data []byte) (*imageMetadata, error) {
+	meta := &imageMetadata{}
+	for pos := len(pngSignature); pos+12 <= len(data); {
+		length := int(binary.BigEndian.Uint32(data[pos : pos+4]))
+		chunkType := string(data[pos+4 : pos+8])
+		if length < 0 || pos+12+length > len(data) {
+			return nil, fmt.Errorf("invalid PNG chunk length at offset %d", pos)
+		}
+		chunk := data[pos+8 : pos+8+length]
+
+		switch chunkType {
+		case "eXIf":
+			meta.EXIF = chunk
+		case "iCCP":
+			// Profile name, null separator, compression method, zlib data
+			if nameEnd := bytes.IndexByte(chunk, 0); nameEnd >= 0 && nameEnd+2 <= len(chunk) {
+				if profile, err := inflate(chunk[nameEnd+2:]); err == nil {
+					meta.ICC = profile
+				}
+			}
+		case "iTXt":
+			if xmp, ok := pngXMP(chunk); ok {
+				meta.XMP = xmp
+			}
+		case "IEND":
+			return meta, nil
+		}
+		pos += 12 + length
+	}
+	return meta, nil
+}
+
+// pngXMP returns the text of an iTXt chunk holding an XMP packet
+func pngXMP(chunk []byte) ([]byte, bool) {
+	// Keyword, null, compression flag, compression method, language tag, null,
+	// translated keyword, null, text
+	parts := bytes.SplitN(chunk, []byte{0}, 2)
+	if len(parts) != 2 || string(parts[0]) != pngXMPKeyword || len(parts[1]) < 2 {
+		return nil, false
+	}
+	compressed := parts[1][0] == 1
+	rest := bytes.SplitN(parts[1][2:], []byte{0}, 3)
+	if len(rest) != 3 {
+		return nil, false
+	}
+	if !compressed {
+		return rest[2], true
+	}
+	text, err := inflate(rest[2])
+	return text, err == nil
+}
+
+// readWebPMetadata reads the ICCP, EXIF and XMP chunks of an extended WebP file
+func readWebPMetadata(data []byte) *imageMetadata {
+	meta := &imageMetadata{}
+	for pos := 12; pos+8 <= len(data); {
+		size := int(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+		if size < 0 || pos+8+size > len(data) {
+			break
+		}
+		chunk := data[pos+8 : pos+8+size]
+
+		switch string(data[pos : pos+4]) {
+		case "ICCP":
+			meta.ICC = chunk
+		case "EXIF":
+			// Some writers keep the JPEG "Exif" header
+			meta.EXIF = bytes.TrimPrefix(chunk, jpegEXIFHeader)
+		case "XMP ":
+			meta.XMP = chunk
+		}
+		pos += 8 + size + size%2 // Chunks are padded to an even size
+	}
+	return meta
+}
+
+// inflate decompresses zlib data, refusing implausibly large results
+func inflate(data []byte) ([]byte, error) {
+	reader, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	const limit = 16 * 1024 * 1024
+	inflated, err := io.ReadAll(io.LimitReader(reader, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(inflated) > limit {
+		return nil, fmt.Errorf("compressed metadata larger than %d bytes", limit)
+	}
+	return inflated, nil
+}
+
+// withUprightOrientation returns a copy of the metadata whose EXIF and XMP orientation
+// say 1 (upright), for exports whose pixels were already rotated
+func (m *imageMetadata) withUprightOrientation() *imageMetadata {
+	if m == nil {
+		return nil
+	}
+	upright := *m
+	if len(m.EXIF) > 0 {
+		upright.EXIF = append([]byte(nil), m.EXIF...)
+		setEXIFOrientation(upright.EXIF, 1)
+	}
+	if len(m.XMP) > 0 {
+		upright.XMP = xmpOrientation.ReplaceAll(m.XMP, []byte("${1}1${2}"))
+	}
+	return &upright
+}
+
+// setEXIFOrientation overwrites the Orientation tag in IFD0 of a TIFF structure in place
+func setEXIFOrientation(tiffData []byte, orientation uint16) {
+	if len(tiffData) < 8 {
+		return
+	}
+	var order binary.ByteOrder
+	switch string(tiffData[0:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return
+	}
+
+	ifd := int(order.Uint32(tiffData[4:8]))
+	if ifd < 8 || ifd+2 > len(tiffData) {
+		return
+	}
+	count := int(order.Uint16(tiffData[ifd : ifd+2]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiffData) {
+			return
+		}
+		// Orientation is a single SHORT stored inline
+		if order.Uint16(tiffData[entry:entry+2]) == 0x0112 && order.Uint16(tiffData[entry+2:entry+4]) == 3 {
+			order.PutUint16(tiffData[entry+8:entry+10], orientation)
+			return
+		}
+	}
+}
+
+// jpegMetadataSegments builds the APP1 (EXIF, XMP) and APP2 (ICC) segments for a JPEG file.
+// EXIF and XMP too large for a single segment are left out.
+func jpegMetadataSegments(meta *imageMetadata) [][]byte {
+	var segments [][]byte
+	if meta == nil {
+		return segments
+	}
+
+	if len(meta.EXIF) > 0 && len(jpegEXIFHeader)+len(meta.EXIF) <= maxJPEGSegmentPayload {
+		segments = append(segments, jpegSegment(0xE1, jpegEXIFHeader, meta.EXIF))
+	}
+	if len(meta.XMP) > 0 && len(jpegXMPHeader)+len(meta.XMP) <= maxJPEGSegmentPayload {
+		segments = append(segments, jpegSegment(0xE1, jpegXMPHeader, meta.XMP))
+		for _, extended := range meta.ExtendedXMP {
+			segments = append(segments, jpegSegment(0xE1, jpegExtendedXMPHeader, extended))
+		}
+	}
+
+	// ICC profiles are split into numbered chunks of at most one segment each
+	if len(meta.ICC) > 0 {
+		chunkSize := maxJPEGSegmentPayload - len(jpegICCHeader) - 2
+		count := (len(meta.ICC) + chunkSize - 1) / chunkSize
+		if count <= 255 {
+			for i := 0; i < count; i++ {
+				end := (i + 1) * chunkSize
+				if end > len(meta.ICC) {
+					end = len(meta.ICC)
+				}
+				header := append(append([]byte(nil), jpegICCHeader...), byte(i+1), byte(count))
+				segments = append(segments, jpegSegment(0xE2, header, meta.ICC[i*chunkSize:end]))
+			}
+		}
+	}
+	return segments
+}
+
+// jpegSegment builds a marker segment from a header and payload
+func jpegSegment(marker byte, header, payload []byte) []byte {
+	length := 2 + len(header) + len(payload)
+	segment := make([]byte, 0, 2+length)
+	segment = append(segment, 0xFF, marker, byte(length>>8), byte(length))
+	segment = append(segment, header...)
+	return append(segment, payload...)
+}
+
+// writePNGWithMetadata writes PNG data with iCCP, eXIf and XMP iTXt chunks inserted after IHDR
+func writePNGWithMetadata(w io.Writer, pngData []byte, meta *imageMetadata) error {
+	// The signature is followed by the 13-byte IHDR chunk
+	const ihdrEnd = 8 + 12 + 13
+	if len(pngData) < ihdrEnd || !bytes.HasPrefix(pngData, pngSignature) {
+		return fmt.Errorf("invalid PNG data")
+	}
+
+	var chunks bytes.Buffer
+	if meta != nil && len(meta.ICC) > 0 {
+		var compressed bytes.Buffer
+		writer := zlib.NewWriter(&compressed)
+		writer.Write(meta.ICC)
+		writer.Close()
+		writePNGChunk(&chunks, "iCCP", append([]byte("ICC Profile\x00\x00"), compressed.Bytes()...))
+	}
+	if meta != nil && len(meta.EXIF) > 0 {
+		writePNGChunk(&chunks, "eXIf", meta.EXIF)
+	}
+	if meta != nil && len(meta.XMP) > 0 {
+		// Uncompressed, no language tag and no translated keyword
+		header := append([]byte(pngXMPKeyword), 0, 0, 0, 0, 0)
+		writePNGChunk(&chunks, "iTXt", append(header, meta.XMP...))
+	}
+
+	for _, part := range [][]byte{pngData[:ihdrEnd], chunks.Bytes(), pngData[ihdrEnd:]} {
+		if _, err := w.Write(part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writePNGChunk appends a PNG chunk with its length and CRC
+func writePNGChunk(buf *bytes.Buffer, chunkType string, data []byte) {
+	binary.Write(buf, binary.BigEndian, uint32(len(data)))
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(chunkType))
+	crc.Write(data)
+	buf.WriteString(chunkType)
+	buf.Write(data)
+	binary.Write(buf, binary.BigEndian, crc.Sum32())
+}
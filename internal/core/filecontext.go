@@ -0,0 +1,307 @@
+package core
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+// DateSource records where the capture date of a file came from
+type DateSource string
+
+const (
+	DateSourceNone          DateSource = ""
+	DateSourceEXIF          DateSource = "exif"
+	DateSourceVideoMetadata DateSource = "video_metadata"
+	DateSourceTakeout       DateSource = "takeout"
+	DateSourceDocument      DateSource = "document_metadata"
+	DateSourceFilename      DateSource = "filename"
+	DateSourceModTime       DateSource = "mod_time"
+)
+
+// metadataOptions bundles the settings used to extract metadata from source files
+type metadataOptions struct {
+	exif          EXIFOptions
+	filenameDates *FilenameDateParser
+	geocoder      *ReverseGeocoder // Nil when geocoding is disabled
+}
+
+// fileContext carries facts about a source file through classification.
+// Expensive lookups (MIME sniffing, EXIF, video duration) run at most once and only when needed.
+type fileContext struct {
+	path     string
+	origin   string // Path shown to rules and recorded; archive.zip!/inner/path for extracted files
+	info     os.FileInfo
+	fileType FileType
+	hidden   bool
+	opts     *metadataOptions
+
+	takeoutPath   string // Google Takeout JSON sidecar, if any
+	takeoutLoaded bool
+	takeout       *TakeoutMetadata
+
+	mimeLoaded bool
+	mimeType   string
+
+	exifLoaded bool
+	exifData   *EXIFData
+	exifErr    error
+
+	durationLoaded bool
+	duration       time.Duration
+	durationErr    error
+
+	videoLoaded bool
+	videoMeta   *VideoMetadata
+
+	placeLoaded bool
+	place       Place
+	hasPlace    bool
+
+	audioTagsLoaded bool
+	audioTags       *AudioTags
+
+	audioPropsLoaded bool
+	audioProps       *AudioProperties
+
+	documentLoaded bool
+	document       *DocumentMetadata
+}
+
+// newFileContext creates a file context for a source file
+func newFileContext(path string, info os.FileInfo, detector *FileTypeDetector, opts *metadataOptions) *fileContext {
+	return &fileContext{
+		path:     path,
+		origin:   path,
+		info:     info,
+		fileType: detector.DetectFileType(path),
+		hidden:   detector.IsHiddenFile(path),
+		opts:     opts,
+	}
+}
+
+// MIME returns the sniffed MIME type without parameters (empty if detection fails)
+func (fc *fileContext) MIME() string {
+	if !fc.mimeLoaded {
+		fc.mimeLoaded = true
+		if mtype, err := mimetype.DetectFile(fc.path); err == nil {
+			fc.mimeType = strings.TrimSpace(strings.SplitN(mtype.String(), ";", 2)[0])
+		}
+	}
+	return fc.mimeType
+}
+
+// EXIF returns the EXIF data of an image file
+func (fc *fileContext) EXIF() (*EXIFData, error) {
+	if !fc.exifLoaded {
+		fc.exifLoaded = true
+		fc.exifData, fc.exifErr = ExtractEXIFWithOptions(fc.path, fc.opts.exif)
+		if takeout := fc.Takeout(); takeout != nil && fc.exifErr == nil && fc.exifData != nil {
+			takeout.applyTo(fc.exifData)
+		}
+	}
+	return fc.exifData, fc.exifErr
+}
+
+// Takeout returns the metadata from the file's Google Takeout JSON sidecar (nil if none)
+func (fc *fileContext) Takeout() *TakeoutMetadata {
+	if !fc.takeoutLoaded {
+		fc.takeoutLoaded = true
+		if fc.takeoutPath != "" {
+			fc.takeout, _ = ReadTakeoutMetadata(fc.takeoutPath, fc.opts.exif.DefaultLocation)
+		}
+	}
+	return fc.takeout
+}
+
+// Duration returns the measured playback duration of a video or audio file.
+// Unknown durations are reported as errors rather than estimated.
+func (fc *fileContext) Duration() (time.Duration, error) {
+	if !fc.durationLoaded {
+		fc.durationLoaded = true
+		fc.duration, fc.durationErr = NewVideoAnalyzer().MeasureDuration(fc.path)
+	}
+	return fc.duration, fc.durationErr
+}
+
+// ContentIdentifier returns the Apple Live Photo content identifier of a still or video
+func (fc *fileContext) ContentIdentifier() string {
+	switch fc.fileType {
+	case FileTypeImage:
+		if exifData, err := fc.EXIF(); err == nil && exifData != nil {
+			return exifData.ContentIdentifier
+		}
+	case FileTypeVideo:
+		return ReadVideoContentIdentifier(fc.path)
+	}
+	return ""
+}
+
+// AudioTags returns the ID3/Vorbis/MP4 tags of an audio file (nil if it has none)
+func (fc *fileContext) AudioTags() *AudioTags {
+	if !fc.audioTagsLoaded {
+		fc.audioTagsLoaded = true
+		if fc.fileType == FileTypeAudio {
+			fc.audioTags, _ = ReadAudioTags(fc.path)
+		}
+	}
+	return fc.audioTags
+}
+
+// AudioProperties returns the channel count and sample rate of an audio file (nil if unknown)
+func (fc *fileContext) AudioProperties() *AudioProperties {
+	if !fc.audioPropsLoaded {
+		fc.audioPropsLoaded = true
+		if fc.fileType == FileTypeAudio {
+			fc.audioProps, _ = ReadAudioProperties(fc.path)
+		}
+	}
+	return fc.audioProps
+}
+
+// Document returns the title, author and dates stored inside a document (nil if unavailable)
+func (fc *fileContext) Document() *DocumentMetadata {
+	if !fc.documentLoaded {
+		fc.documentLoaded = true
+		if fc.fileType == FileTypeDocument {
+			fc.document, _ = ReadDocumentMetadata(fc.path, fc.opts.exif.DefaultLocation)
+		}
+	}
+	return fc.document
+}
+
+// Title returns the embedded title of a document or tagged audio file (empty if none)
+func (fc *fileContext) Title() string {
+	if document := fc.Document(); document != nil && document.Title != "" {
+		return document.Title
+	}
+	if tags := fc.AudioTags(); tags != nil {
+		return tags.Title
+	}
+	return ""
+}
+
+// VideoMetadata returns the container metadata of a video file (empty if unavailable)
+func (fc *fileContext) VideoMetadata() *VideoMetadata {
+	if !fc.videoLoaded {
+		fc.videoLoaded = true
+		meta, err := NewVideoAnalyzer().ExtractVideoMetadata(fc.path)
+		if err != nil || meta == nil {
+			meta = &VideoMetadata{}
+		}
+		// Read the location directly when ffprobe is unavailable or found none
+		if !meta.HasGPS {
+			if lat, long, alt, ok := ReadQuickTimeLocation(fc.path); ok {
+				meta.Latitude, meta.Longitude, meta.Altitude = lat, long, alt
+				meta.HasGPS = true
+			}
+		}
+		fc.videoMeta = meta
+	}
+	return fc.videoMeta
+}
+
+// CaptureDate returns the best known capture date of a file and where it came from.
+// Embedded metadata wins over Takeout sidecars, which win over dates inferred from the
+// filename. Images never fall back to the modification time, so undated images keep
+// using the no-EXIF folder.
+func (fc *fileContext) CaptureDate() (time.Time, DateSource) {
+	switch fc.fileType {
+	case FileTypeImage:
+		if exifData, err := fc.EXIF(); err == nil && exifData != nil && exifData.HasDateTime {
+			if exifData.DateTag == takeoutDateTag {
+				return exifData.DateTime, DateSourceTakeout
+			}
+			return exifData.DateTime, DateSourceEXIF
+		}
+	case FileTypeVideo:
+		if meta := fc.VideoMetadata(); meta.HasDateTime && isPlausibleDate(meta.CreationTime) {
+			return meta.CreationTime, DateSourceVideoMetadata
+		}
+	case FileTypeDocument:
+		if document := fc.Document(); document != nil {
+			if document.HasCreated {
+				return document.Created, DateSourceDocument
+			}
+			if document.HasModified {
+				return document.Modified, DateSourceDocument
+			}
+		}
+	}
+
+	if takeout := fc.Takeout(); takeout != nil && takeout.HasTakenTime {
+		return takeout.TakenTime, DateSourceTakeout
+	}
+
+	if fc.fileType == FileTypeImage || fc.fileType == FileTypeVideo || fc.fileType == FileTypeAudio {
+		if inferred, _, ok := fc.opts.filenameDates.ParseDate(fc.path); ok {
+			return inferred, DateSourceFilename
+		}
+	}
+
+	if fc.fileType == FileTypeImage {
+		return time.Time{}, DateSourceNone
+	}
+	return fc.info.ModTime(), DateSourceModTime
+}
+
+// CameraInfo returns the camera make and model from EXIF or video metadata
+func (fc *fileContext) CameraInfo() (string, string) {
+	switch fc.fileType {
+	case FileTypeImage:
+		if exifData, err := fc.EXIF(); err == nil && exifData != nil {
+			return exifData.Make, exifData.Model
+		}
+	case FileTypeVideo:
+		meta := fc.VideoMetadata()
+		return strings.TrimSpace(meta.Make), strings.TrimSpace(meta.Model)
+	}
+	return "", ""
+}
+
+// cameraName joins a camera make and model for display; models usually repeat the
+// make ("Canon" / "Canon EOS R5"), which is then not doubled
+func cameraName(cameraMake, model string) string {
+	cameraMake, model = strings.TrimSpace(cameraMake), strings.TrimSpace(model)
+	if cameraMake == "" || strings.HasPrefix(strings.ToLower(model), strings.ToLower(cameraMake)) {
+		return model
+	}
+	return strings.TrimSpace(cameraMake + " " + model)
+}
+
+// GPS returns where a photo or video was taken, from embedded metadata or a Takeout sidecar
+func (fc *fileContext) GPS() (GPSCoordinates, bool) {
+	switch fc.fileType {
+	case FileTypeImage:
+		if exifData, err := fc.EXIF(); err == nil && exifData != nil && exifData.HasGPS {
+			return GPSCoordinates{Latitude: exifData.Latitude, Longitude: exifData.Longitude, Altitude: exifData.Altitude}, true
+		}
+	case FileTypeVideo:
+		if meta := fc.VideoMetadata(); meta.HasGPS {
+			return GPSCoordinates{Latitude: meta.Latitude, Longitude: meta.Longitude, Altitude: meta.Altitude}, true
+		}
+	}
+
+	if takeout := fc.Takeout(); takeout != nil && takeout.HasGPS {
+		return GPSCoordinates{Latitude: takeout.Latitude, Longitude: takeout.Longitude, Altitude: takeout.Altitude}, true
+	}
+	return GPSCoordinates{}, false
+}
+
+// Place returns the nearest known city and country of the file's GPS position
+func (fc *fileContext) Place() (Place, bool) {
+	if !fc.placeLoaded {
+		fc.placeLoaded = true
+		if gps, ok := fc.GPS(); ok {
+			fc.place, fc.hasPlace = fc.opts.geocoder.Lookup(gps.Latitude, gps.Longitude)
+		}
+	}
+	return fc.place, fc.hasPlace
+}
+
+// isPlausibleDate rejects zero and placeholder dates (e.g. the QuickTime 1904 epoch)
+func isPlausibleDate(t time.Time) bool {
+	return !t.IsZero() && t.Year() >= 1970 && t.Before(time.Now().AddDate(1, 0, 0))
+}
@@ -0,0 +1,84 @@
+package core
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"zensort/internal/config"
+)
+
+// filenameDateExtractor is a configured extractor with its pattern compiled
+type filenameDateExtractor struct {
+	name    string
+	pattern *regexp.Regexp
+	layout  string
+}
+
+// FilenameDateParser infers capture dates from well-known filename conventions
+// (WhatsApp, Pixel, Signal, Telegram, screenshots, ...). It is ranked below
+// embedded metadata and only used when a file carries no date of its own.
+type FilenameDateParser struct {
+	extractors []filenameDateExtractor
+	location   *time.Location
+}
+
+// NewFilenameDateParser compiles the configured filename date extractors.
+// Each pattern must contain a capture group named "date" whose text matches the layout.
+func NewFilenameDateParser(cfg *config.Config, location *time.Location) (*FilenameDateParser, error) {
+	if location == nil {
+		location = time.Local
+	}
+
+	parser := &FilenameDateParser{location: location}
+	if !cfg.FilenameDates.Enabled {
+		return parser, nil
+	}
+
+	for _, extractor := range cfg.FilenameDates.Extractors {
+		pattern, err := regexp.Compile(extractor.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("filename date extractor %s: invalid pattern: %w", extractor.Name, err)
+		}
+		if pattern.SubexpIndex("date") < 0 {
+			return nil, fmt.Errorf("filename date extractor %s: pattern has no (?P<date>...) group", extractor.Name)
+		}
+		if extractor.Layout == "" {
+			return nil, fmt.Errorf("filename date extractor %s: layout is required", extractor.Name)
+		}
+
+		parser.extractors = append(parser.extractors, filenameDateExtractor{
+			name:    extractor.Name,
+			pattern: pattern,
+			layout:  extractor.Layout,
+		})
+	}
+
+	return parser, nil
+}
+
+// ParseDate returns the date encoded in a filename and the name of the extractor that found it
+func (p *FilenameDateParser) ParseDate(filePath string) (time.Time, string, bool) {
+	if p == nil {
+		return time.Time{}, "", false
+	}
+
+	filename := filepath.Base(filePath)
+	for _, extractor := range p.extractors {
+		match := extractor.pattern.FindStringSubmatch(filename)
+		if match == nil {
+			continue
+		}
+
+		dateText := strings.TrimSpace(match[extractor.pattern.SubexpIndex("date")])
+		parsedTime, err := time.ParseInLocation(extractor.layout, dateText, p.location)
+		if err != nil || !isPlausibleDate(parsedTime) {
+			continue // Looks like a date but isn't one (e.g. a counter), try the next extractor
+		}
+		return parsedTime, extractor.name, true
+	}
+
+	return time.Time{}, "", false
+}
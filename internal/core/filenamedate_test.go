@@ -0,0 +1,120 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"zensort/internal/config"
+)
+
+func TestFilenameDateParserDefaults(t *testing.T) {
+	parser, err := NewFilenameDateParser(config.DefaultConfig(), time.UTC)
+	if err != nil {
+		t.Fatalf("NewFilenameDateParser() error = %v", err)
+	}
+
+	tests := []struct {
+		file      string
+		want      time.Time
+		extractor string
+	}{
+		{"IMG-20210506-WA0001.jpg", time.Date(2021, 5, 6, 0, 0, 0, 0, time.UTC), "whatsapp"},
+		{"PTT-20190101-WA0003.opus", time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC), "whatsapp"},
+		{"PXL_20220304_123456789.jpg", time.Date(2022, 3, 4, 12, 34, 56, 0, time.UTC), "pixel"},
+		{"IMG_20200102_030405.jpg", time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), "android_camera"},
+		{"VID_20200102_030405.mp4", time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), "android_camera"},
+		{"20200102_030405(1).jpg", time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), "samsung_camera"},
+		{"signal-2021-07-08-091011.jpg", time.Date(2021, 7, 8, 9, 10, 11, 0, time.UTC), "signal"},
+		{"photo_2021-07-08_09-10-11.jpg", time.Date(2021, 7, 8, 9, 10, 11, 0, time.UTC), "telegram"},
+		{"Screenshot_20230102-030405.png", time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC), "android_screenshot"},
+		{"Screenshot_2023-01-02-03-04-05.png", time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC), "android_screenshot_dashed"},
+		{"Screen Shot 2020-01-02 at 03.04.05.png", time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), "macos_screenshot"},
+		{"Screen Shot 2020-01-02 .png", time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC), "iso_date"},
+		{"Holiday 2018-08-15 beach.jpg", time.Date(2018, 8, 15, 0, 0, 0, 0, time.UTC), "iso_date"},
+		{"/some/dir/IMG-20210506-WA0001.jpg", time.Date(2021, 5, 6, 0, 0, 0, 0, time.UTC), "whatsapp"},
+	}
+
+	for _, tt := range tests {
+		got, extractor, ok := parser.ParseDate(tt.file)
+		if !ok {
+			t.Errorf("ParseDate(%q) found no date", tt.file)
+			continue
+		}
+		if !got.Equal(tt.want) || extractor != tt.extractor {
+			t.Errorf("ParseDate(%q) = %v, %s; want %v, %s", tt.file, got, extractor, tt.want, tt.extractor)
+		}
+	}
+}
+
+func TestFilenameDateParserRejects(t *testing.T) {
+	parser, err := NewFilenameDateParser(config.DefaultConfig(), time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range []string{
+		"IMG_0001.jpg",            // Counter, not a date
+		"IMG-20211345-WA0001.jpg", // Month 13
+		"IMG_19000101_000000.jpg", // Before 1970
+		"IMG_29990101_000000.jpg", // Far future
+		"scan 12018-08-15.jpg",    // Digits glued to the year
+		"report-2018-02-30.pdf",   // February 30th
+		"",                        // Empty name
+		"PXL_2022.jpg",            // Truncated
+	} {
+		if got, extractor, ok := parser.ParseDate(file); ok {
+			t.Errorf("ParseDate(%q) = %v from %s, want no date", file, got, extractor)
+		}
+	}
+}
+
+func TestFilenameDateParserLocation(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("time zone database not available")
+	}
+	parser, err := NewFilenameDateParser(config.DefaultConfig(), berlin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _, ok := parser.ParseDate("PXL_20220704_120000000.jpg")
+	if !ok || got.UTC().Hour() != 10 {
+		t.Errorf("ParseDate() = %v, want 12:00 Berlin time", got)
+	}
+}
+
+func TestFilenameDateParserDisabled(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.FilenameDates.Enabled = false
+	parser, err := NewFilenameDateParser(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok := parser.ParseDate("IMG-20210506-WA0001.jpg"); ok {
+		t.Error("disabled parser found a date")
+	}
+
+	var nilParser *FilenameDateParser
+	if _, _, ok := nilParser.ParseDate("IMG-20210506-WA0001.jpg"); ok {
+		t.Error("nil parser found a date")
+	}
+}
+
+func TestNewFilenameDateParserErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		extractor config.FilenameDateExtractor
+	}{
+		{"bad pattern", config.FilenameDateExtractor{Name: "bad", Pattern: "(", Layout: "2006"}},
+		{"no date group", config.FilenameDateExtractor{Name: "nogroup", Pattern: `\d{4}`, Layout: "2006"}},
+		{"no layout", config.FilenameDateExtractor{Name: "nolayout", Pattern: `(?P<date>\d{4})`}},
+	}
+
+	for _, tt := range tests {
+		cfg := config.DefaultConfig()
+		cfg.FilenameDates.Extractors = []config.FilenameDateExtractor{tt.extractor}
+		if _, err := NewFilenameDateParser(cfg, time.UTC); err == nil {
+			t.Errorf("%s: NewFilenameDateParser() error = nil", tt.name)
+		}
+	}
+}
@@ -0,0 +1,344 @@
+package core
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/disintegration/imaging"
+
+	"zensort/internal/config"
+)
+
+// galleryPage is the offline gallery page; the file list replaces its data placeholder
+//
+//go:embed assets/gallery.html
+var galleryPage string
+
+const (
+	// galleryDataPlaceholder marks where the file list goes in the gallery page
+	galleryDataPlaceholder = "/*GALLERY_DATA*/null"
+	// galleryIndexName is the file remembering the thumbnail of every file already seen
+	galleryIndexName = "gallery-index.json"
+	// videoThumbnailTimeout bounds how long ffmpeg may take to grab one frame
+	videoThumbnailTimeout = 30 * time.Second
+)
+
+// galleryImageExtensions are the image formats thumbnails can be decoded from
+var galleryImageExtensions = map[string]bool{
+	".jpg": true, ".jpeg": true, ".png": true, ".gif": true,
+	".bmp": true, ".tif": true, ".tiff": true, ".webp": true,
+}
+
+// galleryData is the content of the gallery page
+type galleryData struct {
+	Generated string        `json:"generated"`
+	Items     []galleryItem `json:"items"`
+}
+
+// galleryItem is a file as listed in the gallery page
+type galleryItem struct {
+	Name     string `json:"name"`
+	Path     string `json:"path"`            // URL of the original, relative to the gallery folder
+	Thumb    string `json:"thumb,omitempty"` // URL of the thumbnail, relative to the gallery folder
+	Date     string `json:"date,omitempty"`
+	Year     string `json:"year,omitempty"`
+	Month    string `json:"month,omitempty"`
+	Camera   string `json:"camera,omitempty"`
+	Category string `json:"category"`
+	Location string `json:"location,omitempty"`
+	Size     int64  `json:"size"`
+
+	captureDate time.Time
+}
+
+// GalleryGenerator keeps the thumbnail cache and the static gallery page of a destination
+// up to date with its database
+type GalleryGenerator struct {
+	config   *config.Config
+	dir      string
+	detector *FileTypeDetector
+	logger   *Logger
+	ffmpeg   string // Path of ffmpeg for video thumbnails, empty when it is not installed
+}
+
+// NewGalleryGenerator creates a gallery generator for a destination
+func NewGalleryGenerator(cfg *config.Config, destDir string, logger *Logger) *GalleryGenerator {
+	folder := cfg.Gallery.Folder
+	if folder == "" {
+		folder = "Gallery"
+	}
+	ffmpeg, _ := exec.LookPath("ffmpeg")
+	return &GalleryGenerator{
+		config:   cfg,
+		dir:      filepath.Join(destDir, folder),
+		detector: NewFileTypeDetectorWithConfig(cfg),
+		logger:   logger,
+		ffmpeg:   ffmpeg,
+	}
+}
+
+// Update makes thumbnails for the files recorded since the last update and rewrites the
+// gallery page. It returns how many thumbnails were made.
+func (gg *GalleryGenerator) Update(db *Database) (int, error) {
+	records, err := db.Records()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read database: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Join(gg.dir, "thumbs"), 0755); err != nil {
+		return 0, fmt.Errorf("failed to create gallery directory: %w", err)
+	}
+
+	index := gg.loadIndex()
+	created := 0
+	var items []galleryItem
+	for _, record := range records {
+		category := record.Category
+		if category == "" {
+			// Records from before categories were stored
+			category = gg.detector.GetFileTypeString(gg.detector.DetectFileType(record.DestinationPath))
+		}
+		if !inGallery(record, category) {
+			continue
+		}
+
+		thumb, known := index[record.Hash]
+		if !known && gg.canThumbnail(record.DestinationPath, category) {
+			thumb = ""
+			if err := gg.createThumbnail(record, category); err != nil {
+				gg.logger.LogError(LogLevelWarning, "Failed to create gallery thumbnail", record.DestinationPath, err)
+			} else {
+				thumb = thumbnailName(record.Hash)
+				created++
+			}
+			// Failures are remembered too, so they are not retried every session
+			index[record.Hash] = thumb
+		}
+
+		items = append(items, gg.item(record, category, thumb))
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		a, b := items[i].captureDate, items[j].captureDate
+		if a.IsZero() != b.IsZero() {
+			return b.IsZero()
+		}
+		if !a.Equal(b) {
+			return a.After(b)
+		}
+		return items[i].Name < items[j].Name
+	})
+
+	if err := gg.saveIndex(index); err != nil {
+		return created, err
+	}
+	if err := gg.writePage(items); err != nil {
+		return created, err
+	}
+	return created, nil
+}
+
+// inGallery reports whether a record is shown in the gallery. Sidecars and the video or RAW
+// half of Live Photos, motion photos and RAW+JPEG pairs are left out: their still stands for them.
+func inGallery(record FileRecord, category string) bool {
+	if record.SidecarOf != "" {
+		return false
+	}
+	if record.PairedWith != "" && (category == "Videos" || IsRawFile(record.DestinationPath)) {
+		return false
+	}
+	return true
+}
+
+// canThumbnail reports whether a thumbnail can be made for a file
+func (gg *GalleryGenerator) canThumbnail(path, category string) bool {
+	switch category {
+	case "Images":
+		return galleryImageExtensions[strings.ToLower(filepath.Ext(path))]
+	case "Videos":
+		return gg.ffmpeg != ""
+	}
+	return false
+}
+
+// thumbnailName returns the path of a file's thumbnail relative to the gallery folder
+func thumbnailName(hash string) string {
+	prefix := hash
+	if len(prefix) > 2 {
+		prefix = prefix[:2]
+	}
+	return "thumbs/" + prefix + "/" + hash + ".jpg"
+}
+
+// createThumbnail makes the thumbnail of a recorded file
+func (gg *GalleryGenerator) createThumbnail(record FileRecord, category string) error {
+	thumbPath := filepath.Join(gg.dir, filepath.FromSlash(thumbnailName(record.Hash)))
+	if info, err := os.Stat(thumbPath); err == nil && info.Size() > 0 {
+		return nil // Left over from a lost index
+	}
+	if err := os.MkdirAll(filepath.Dir(thumbPath), 0755); err != nil {
+		return err
+	}
+
+	var err error
+	if category == "Videos" {
+		err = gg.videoThumbnail(record.DestinationPath, thumbPath)
+	} else {
+		err = gg.imageThumbnail(record.DestinationPath, thumbPath)
+	}
+	if err != nil {
+		os.Remove(thumbPath)
+	}
+	return err
+}
+
+// thumbnailSize returns the longest side of thumbnails
+func (gg *GalleryGenerator) thumbnailSize() int {
+	if gg.config.Gallery.ThumbnailSize > 0 {
+		return gg.config.Gallery.ThumbnailSize
+	}
+	return 320
+}
+
+// imageThumbnail scales an upright copy of an image down to thumbnail size
+func (gg *GalleryGenerator) imageThumbnail(imagePath, thumbPath string) error {
+	img, err := imaging.Open(imagePath, imaging.AutoOrientation(true))
+	if err != nil {
+		return fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	size := gg.thumbnailSize()
+	thumb := fitWithin(img, size, size, imaging.Lanczos)
+	if hasAlpha(thumb) {
+		// JPEG has no transparency, transparent areas would turn black
+		bounds := thumb.Bounds()
+		thumb = imaging.OverlayCenter(imaging.New(bounds.Dx(), bounds.Dy(), color.White), thumb, 1)
+	}
+	return imaging.Save(thumb, thumbPath, imaging.JPEGQuality(80))
+}
+
+// videoThumbnail grabs a frame of a video with ffmpeg
+func (gg *GalleryGenerator) videoThumbnail(videoPath, thumbPath string) error {
+	size := gg.thumbnailSize()
+	scale := fmt.Sprintf("scale=%d:%d:force_original_aspect_ratio=decrease", size, size)
+
+	// A frame one second in skips black lead-ins; clips shorter than that use their first frame
+	var err error
+	for _, offset := range []string{"1", "0"} {
+		ctx, cancel := context.WithTimeout(context.Background(), videoThumbnailTimeout)
+		cmd := exec.CommandContext(ctx, gg.ffmpeg, "-v", "error", "-y", "-ss", offset, "-i", videoPath,
+			"-frames:v", "1", "-vf", scale, "-q:v", "4", thumbPath)
+		hideConsoleWindow(cmd)
+		err = cmd.Run()
+		cancel()
+		if info, statErr := os.Stat(thumbPath); err == nil && statErr == nil && info.Size() > 0 {
+			return nil
+		}
+	}
+	if err == nil {
+		err = fmt.Errorf("no frame extracted")
+	}
+	return fmt.Errorf("ffmpeg failed: %w", err)
+}
+
+// item describes a recorded file for the gallery page
+func (gg *GalleryGenerator) item(record FileRecord, category, thumb string) galleryItem {
+	item := galleryItem{
+		Name:        filepath.Base(record.DestinationPath),
+		Path:        gg.relativeURL(record.DestinationPath),
+		Thumb:       thumb,
+		Category:    category,
+		Size:        record.Size,
+		captureDate: record.CaptureDate,
+	}
+	if !record.CaptureDate.IsZero() {
+		item.Date = record.CaptureDate.Format("2006-01-02 15:04")
+		item.Year = record.CaptureDate.Format("2006")
+		item.Month = record.CaptureDate.Format("01")
+	}
+
+	item.Camera = cameraName(record.Make, record.Model)
+
+	switch {
+	case record.City != "" && record.Country != "":
+		item.Location = record.City + ", " + record.Country
+	default:
+		item.Location = record.City + record.Country
+	}
+	return item
+}
+
+// relativeURL returns the URL of a file relative to the gallery folder
+func (gg *GalleryGenerator) relativeURL(path string) string {
+	rel, err := filepath.Rel(gg.dir, path)
+	if err != nil {
+		rel = path
+	}
+	segments := strings.Split(filepath.ToSlash(rel), "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// loadIndex reads the thumbnails made by earlier sessions
+func (gg *GalleryGenerator) loadIndex() map[string]string {
+	index := make(map[string]string)
+	data, err := os.ReadFile(filepath.Join(gg.dir, galleryIndexName))
+	if err != nil {
+		return index
+	}
+	if err := json.Unmarshal(data, &index); err != nil {
+		gg.logger.LogError(LogLevelWarning, "Failed to read gallery index, rebuilding it", gg.dir, err)
+		return make(map[string]string)
+	}
+	return index
+}
+
+// saveIndex stores the thumbnails made so far
+func (gg *GalleryGenerator) saveIndex(index map[string]string) error {
+	data, err := json.Marshal(index)
+	if err != nil {
+		return fmt.Errorf("failed to marshal gallery index: %w", err)
+	}
+	return writeFileAtomic(filepath.Join(gg.dir, galleryIndexName), data)
+}
+
+// writePage writes the gallery page with its file list
+func (gg *GalleryGenerator) writePage(items []galleryItem) error {
+	if items == nil {
+		items = []galleryItem{}
+	}
+	// json.Marshal escapes "<", so file names can't close the script element
+	data, err := json.Marshal(galleryData{
+		Generated: time.Now().Format("2006-01-02 15:04"),
+		Items:     items,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal gallery data: %w", err)
+	}
+	page := strings.Replace(galleryPage, galleryDataPlaceholder, string(data), 1)
+	return writeFileAtomic(filepath.Join(gg.dir, "index.html"), []byte(page))
+}
+
+// writeFileAtomic replaces a file through a temporary file, so readers never see it half written
+func writeFileAtomic(path string, data []byte) error {
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filepath.Base(path), err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to replace %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
@@ -0,0 +1,237 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"zensort/internal/config"
+)
+
+// Bundled cities and country names so reverse geocoding works offline
+var (
+	//go:embed geodata/cities.txt
+	bundledCities []byte
+	//go:embed geodata/countries.txt
+	bundledCountries []byte
+)
+
+const (
+	// earthRadiusKm is the mean radius of the Earth
+	earthRadiusKm = 6371.0
+	// kmPerDegree is the length of one degree of latitude
+	kmPerDegree = 111.2
+	// countryMaxDistanceKm is how far from its nearest known city a position may be
+	// and still be given that city's country
+	countryMaxDistanceKm = 1000.0
+)
+
+// Place is the result of reverse geocoding a position
+type Place struct {
+	City        string // Empty when no known city is within the configured distance
+	Country     string
+	CountryCode string
+	DistanceKm  float64 // Distance to the nearest known city
+}
+
+// geoCity is a city of the geocoding dataset
+type geoCity struct {
+	name        string
+	countryCode string
+	lat, lon    float64
+	population  int64
+}
+
+// geoCell identifies a one-degree latitude/longitude cell of the city index
+type geoCell struct {
+	lat, lon int
+}
+
+// ReverseGeocoder finds the nearest city to a position without network access,
+// using the bundled dataset or a GeoNames cities file (e.g. cities15000.txt)
+type ReverseGeocoder struct {
+	cells         map[geoCell][]geoCity
+	countries     map[string]string
+	maxDistanceKm float64
+}
+
+// NewReverseGeocoder loads the configured cities dataset
+func NewReverseGeocoder(cfg *config.Config) (*ReverseGeocoder, error) {
+	geocoder := &ReverseGeocoder{
+		cells:         make(map[geoCell][]geoCity),
+		countries:     parseCountryNames(bytes.NewReader(bundledCountries)),
+		maxDistanceKm: cfg.Geocoding.MaxDistanceKm,
+	}
+	if geocoder.maxDistanceKm <= 0 {
+		geocoder.maxDistanceKm = 50
+	}
+
+	var source io.Reader = bytes.NewReader(bundledCities)
+	if cfg.Geocoding.DatasetPath != "" {
+		file, err := os.Open(cfg.Geocoding.DatasetPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open geocoding dataset: %w", err)
+		}
+		defer file.Close()
+		source = file
+	}
+
+	count, err := geocoder.loadCities(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read geocoding dataset: %w", err)
+	}
+	if count == 0 {
+		return nil, fmt.Errorf("geocoding dataset contains no cities")
+	}
+
+	return geocoder, nil
+}
+
+// loadCities reads cities in the GeoNames layout and indexes them by cell
+func (g *ReverseGeocoder) loadCities(r io.Reader) (int, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024) // GeoNames alternate names can be long
+
+	count := 0
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) < 15 {
+			continue
+		}
+		lat, err1 := strconv.ParseFloat(fields[4], 64)
+		lon, err2 := strconv.ParseFloat(fields[5], 64)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		population, _ := strconv.ParseInt(fields[14], 10, 64)
+
+		city := geoCity{
+			name:        fields[1],
+			countryCode: strings.ToUpper(fields[8]),
+			lat:         lat,
+			lon:         lon,
+			population:  population,
+		}
+		cell := cellOf(lat, lon)
+		g.cells[cell] = append(g.cells[cell], city)
+		count++
+	}
+
+	return count, scanner.Err()
+}
+
+// parseCountryNames reads "code<TAB>name" lines, or GeoNames countryInfo.txt lines
+func parseCountryNames(r io.Reader) map[string]string {
+	countries := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		switch {
+		case len(fields) >= 5:
+			countries[fields[0]] = fields[4]
+		case len(fields) == 2:
+			countries[fields[0]] = fields[1]
+		}
+	}
+	return countries
+}
+
+// Lookup returns the place nearest to a position
+func (g *ReverseGeocoder) Lookup(lat, lon float64) (Place, bool) {
+	if g == nil {
+		return Place{}, false
+	}
+
+	center := cellOf(lat, lon)
+	maxRing := int(math.Ceil(countryMaxDistanceKm/kmPerDegree)) + 1
+
+	var best *geoCity
+	bestDistance := math.MaxFloat64
+	for ring := 0; ring <= maxRing; ring++ {
+		// Cells of this ring are at least (ring-1) degrees away; stop once nothing closer can remain
+		if best != nil && float64(ring-1)*kmPerDegree*math.Cos(toRadians(math.Min(math.Abs(lat)+float64(ring), 89))) > bestDistance {
+			break
+		}
+		for dLat := -ring; dLat <= ring; dLat++ {
+			for dLon := -ring; dLon <= ring; dLon++ {
+				if absInt(dLat) != ring && absInt(dLon) != ring {
+					continue // Inner cells were searched by earlier rings
+				}
+				cell := geoCell{lat: center.lat + dLat, lon: wrapLongitudeCell(center.lon + dLon)}
+				for i := range g.cells[cell] {
+					city := &g.cells[cell][i]
+					distance := haversineKm(lat, lon, city.lat, city.lon)
+					// Prefer the bigger city when two are practically as close
+					if distance < bestDistance-0.5 || (distance < bestDistance+0.5 && best != nil && city.population > best.population) {
+						best = city
+						bestDistance = distance
+					}
+				}
+			}
+		}
+	}
+
+	if best == nil || bestDistance > countryMaxDistanceKm {
+		return Place{}, false
+	}
+
+	place := Place{
+		Country:     g.countries[best.countryCode],
+		CountryCode: best.countryCode,
+		DistanceKm:  bestDistance,
+	}
+	if place.Country == "" {
+		place.Country = best.countryCode
+	}
+	if bestDistance <= g.maxDistanceKm {
+		place.City = best.name
+	}
+	return place, true
+}
+
+// cellOf returns the index cell of a position
+func cellOf(lat, lon float64) geoCell {
+	return geoCell{lat: int(math.Floor(lat)), lon: wrapLongitudeCell(int(math.Floor(lon)))}
+}
+
+// wrapLongitudeCell keeps longitude cells in [-180, 180) across the antimeridian
+func wrapLongitudeCell(lon int) int {
+	return ((lon+180)%360+360)%360 - 180
+}
+
+// haversineKm returns the great-circle distance between two positions
+func haversineKm(lat1, lon1, lat2, lon2 float64) float64 {
+	dLat := toRadians(lat2 - lat1)
+	dLon := toRadians(lon2 - lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRadians(lat1))*math.Cos(toRadians(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(math.Min(1, a)))
+}
+
+// toRadians converts degrees to radians
+func toRadians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
+
+// absInt returns the absolute value of an int
+func absInt(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,185 @@
+package core
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// extractHEIFExif locates the Exif item of a HEIC/HEIF file and returns its TIFF data.
+// goexif only understands JPEG and TIFF, so HEIC files need this extra step.
+func extractHEIFExif(r io.ReaderAt, size int64) ([]byte, error) {
+	meta, ok := findBMFFBox(r, 0, size, "meta")
+	if !ok {
+		return nil, fmt.Errorf("no meta box")
+	}
+	children := metaChildrenOffset(r, meta)
+
+	iinf, ok := findBMFFBox(r, children, meta.End(), "iinf")
+	if !ok {
+		return nil, fmt.Errorf("no iinf box")
+	}
+	exifID, err := findHEIFItemID(r, iinf, "Exif")
+	if err != nil {
+		return nil, err
+	}
+
+	iloc, ok := findBMFFBox(r, children, meta.End(), "iloc")
+	if !ok {
+		return nil, fmt.Errorf("no iloc box")
+	}
+	offset, length, err := findHEIFItemLocation(r, iloc, exifID)
+	if err != nil {
+		return nil, err
+	}
+	if length < 4 || length > 16<<20 {
+		return nil, fmt.Errorf("invalid Exif item length %d", length)
+	}
+
+	data := make([]byte, length)
+	if n, err := r.ReadAt(data, offset); n < len(data) {
+		return nil, fmt.Errorf("truncated Exif item: %w", err)
+	}
+
+	// The item starts with the offset of the TIFF header (usually skipping "Exif\0\0")
+	headerOffset := int64(binary.BigEndian.Uint32(data[0:4])) + 4
+	if headerOffset >= int64(len(data)) {
+		return nil, fmt.Errorf("invalid Exif header offset")
+	}
+	return data[headerOffset:], nil
+}
+
+// findHEIFItemID returns the ID of the first item of the given type in an iinf box
+func findHEIFItemID(r io.ReaderAt, iinf bmffBox, itemType string) (uint32, error) {
+	header := make([]byte, 8)
+	if _, err := r.ReadAt(header, iinf.Offset); err != nil {
+		return 0, err
+	}
+
+	// Full box header, then a 16-bit (v0) or 32-bit entry count
+	start := iinf.Offset + 6
+	if header[0] != 0 {
+		start = iinf.Offset + 8
+	}
+
+	entries, _ := readBMFFBoxes(r, start, iinf.End())
+	for _, entry := range entries {
+		if entry.Type != "infe" {
+			continue
+		}
+		data, err := readBMFFPayload(r, entry, 4096)
+		if err != nil || len(data) < 12 {
+			continue
+		}
+
+		// Only infe version 2 and 3 carry an item type
+		var id uint32
+		var typePos int
+		switch data[0] {
+		case 2:
+			id = uint32(binary.BigEndian.Uint16(data[4:6]))
+			typePos = 8
+		case 3:
+			id = binary.BigEndian.Uint32(data[4:8])
+			typePos = 10
+		default:
+			continue
+		}
+		if typePos+4 <= len(data) && string(data[typePos:typePos+4]) == itemType {
+			return id, nil
+		}
+	}
+
+	return 0, fmt.Errorf("no %s item", itemType)
+}
+
+// findHEIFItemLocation returns the file offset and length of an item from an iloc box.
+// Only items stored in the file itself (construction method 0) with one extent are supported.
+func findHEIFItemLocation(r io.ReaderAt, iloc bmffBox, itemID uint32) (int64, int64, error) {
+	data, err := readBMFFPayload(r, iloc, 1<<20)
+	if err != nil || len(data) < 8 {
+		return 0, 0, fmt.Errorf("invalid iloc box")
+	}
+
+	version := data[0]
+	offsetSize := int(data[4] >> 4)
+	lengthSize := int(data[4] & 0x0F)
+	baseOffsetSize := int(data[5] >> 4)
+	indexSize := 0
+	if version == 1 || version == 2 {
+		indexSize = int(data[5] & 0x0F)
+	}
+
+	pos := 6
+	readUint := func(size int) (uint64, bool) {
+		if pos+size > len(data) {
+			return 0, false
+		}
+		var value uint64
+		for i := 0; i < size; i++ {
+			value = value<<8 | uint64(data[pos+i])
+		}
+		pos += size
+		return value, true
+	}
+
+	countSize := 2
+	if version == 2 {
+		countSize = 4
+	}
+	itemCount, ok := readUint(countSize)
+	if !ok {
+		return 0, 0, fmt.Errorf("truncated iloc box")
+	}
+
+	for i := uint64(0); i < itemCount; i++ {
+		id, ok := readUint(countSize)
+		if !ok {
+			break
+		}
+		constructionMethod := uint64(0)
+		if version == 1 || version == 2 {
+			if constructionMethod, ok = readUint(2); !ok {
+				break
+			}
+			constructionMethod &= 0x0F
+		}
+		if _, ok = readUint(2); !ok { // data_reference_index
+			break
+		}
+		baseOffset, ok := readUint(baseOffsetSize)
+		if !ok {
+			break
+		}
+		extentCount, ok := readUint(2)
+		if !ok {
+			break
+		}
+
+		var firstOffset, firstLength uint64
+		for e := uint64(0); e < extentCount; e++ {
+			if indexSize > 0 {
+				if _, ok = readUint(indexSize); !ok {
+					break
+				}
+			}
+			extentOffset, ok1 := readUint(offsetSize)
+			extentLength, ok2 := readUint(lengthSize)
+			if !ok1 || !ok2 {
+				return 0, 0, fmt.Errorf("truncated iloc box")
+			}
+			if e == 0 {
+				firstOffset, firstLength = extentOffset, extentLength
+			}
+		}
+
+		if uint32(id) == itemID {
+			if constructionMethod != 0 || extentCount != 1 {
+				return 0, 0, fmt.Errorf("unsupported item storage")
+			}
+			return int64(baseOffset + firstOffset), int64(firstLength), nil
+		}
+	}
+
+	return 0, 0, fmt.Errorf("item %d not found in iloc", itemID)
+}
@@ -0,0 +1,132 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// u16 encodes a big-endian 16-bit value
+func u16(v uint16) []byte {
+	return binary.BigEndian.AppendUint16(nil, v)
+}
+
+// infeV2 builds a version 2 item info entry
+func infeV2(id uint16, itemType string) []byte {
+	return box("infe", []byte{2, 0, 0, 0}, u16(id), u16(0), []byte(itemType), []byte{0})
+}
+
+// testHEIF builds a HEIF file whose Exif item holds tiff, with iloc built by ilocFor
+func testHEIF(tiff []byte, ilocFor func(offset, length uint32) []byte) []byte {
+	exifItem := append(append(u32(6), []byte("Exif\x00\x00")...), tiff...)
+	iinf := box("iinf", u32(0), u16(2), infeV2(1, "hvc1"), infeV2(2, "Exif"))
+	ftyp := box("ftyp", []byte("heic"))
+
+	// The iloc size does not depend on the offset, so lay out once to find it
+	build := func(offset uint32) []byte {
+		meta := box("meta", u32(0), box("hdlr", make([]byte, 24)), iinf, ilocFor(offset, uint32(len(exifItem))))
+		return append(append(append([]byte{}, ftyp...), meta...), box("mdat", exifItem)...)
+	}
+	layout := build(0)
+	return build(uint32(len(layout) - len(exifItem)))
+}
+
+// ilocV0 builds a version 0 iloc box with 4-byte offsets and lengths for items 1 and 2
+func ilocV0(offset, length uint32) []byte {
+	return box("iloc", u32(0), []byte{0x44, 0x00}, u16(2),
+		u16(1), u16(0), u16(1), u32(0), u32(0),
+		u16(2), u16(0), u16(1), u32(offset), u32(length))
+}
+
+func TestExtractHEIFExif(t *testing.T) {
+	tiff := []byte("II*\x00\x08\x00\x00\x00rest")
+
+	tests := []struct {
+		name string
+		iloc func(offset, length uint32) []byte
+	}{
+		{"iloc v0", ilocV0},
+		{"iloc v1 with base offset", func(offset, length uint32) []byte {
+			return box("iloc", u32(1<<24), []byte{0x44, 0x40}, u16(1),
+				u16(2), u16(0), u16(0), u32(offset-10), u16(1), u32(10), u32(length))
+		}},
+		{"iloc v2", func(offset, length uint32) []byte {
+			return box("iloc", u32(2<<24), []byte{0x44, 0x00}, u32(1),
+				u32(2), u16(0), u16(0), u16(1), u32(offset), u32(length))
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := testHEIF(tiff, tt.iloc)
+			got, err := extractHEIFExif(bytes.NewReader(data), int64(len(data)))
+			if err != nil {
+				t.Fatalf("extractHEIFExif() error = %v", err)
+			}
+			if !bytes.Equal(got, tiff) {
+				t.Errorf("extractHEIFExif() = %q, want %q", got, tiff)
+			}
+		})
+	}
+}
+
+func TestExtractHEIFExifMalformed(t *testing.T) {
+	valid := testHEIF([]byte("II*\x00"), ilocV0)
+	noExif := box("meta", u32(0), box("iinf", u32(0), u16(1), infeV2(1, "hvc1")), ilocV0(0, 0))
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"no meta", box("ftyp", []byte("heic"))},
+		{"no iinf", box("meta", u32(0), ilocV0(0, 0))},
+		{"no Exif item", noExif},
+		{"no iloc", box("meta", u32(0), box("iinf", u32(0), u16(1), infeV2(2, "Exif")))},
+		{"Exif item not in iloc", box("meta", u32(0), box("iinf", u32(0), u16(1), infeV2(9, "Exif")), ilocV0(0, 0))},
+		{"iloc too short", box("meta", u32(0), box("iinf", u32(0), u16(1), infeV2(2, "Exif")), box("iloc", u32(0)))},
+		{"iloc count without items", box("meta", u32(0), box("iinf", u32(0), u16(1), infeV2(2, "Exif")),
+			box("iloc", u32(0), []byte{0x44, 0x00}, u16(500)))},
+		{"iloc extent truncated", box("meta", u32(0), box("iinf", u32(0), u16(1), infeV2(2, "Exif")),
+			box("iloc", u32(0), []byte{0x44, 0x00}, u16(1), u16(2), u16(0), u16(1), u32(0)))},
+		{"two extents", box("meta", u32(0), box("iinf", u32(0), u16(1), infeV2(2, "Exif")),
+			box("iloc", u32(0), []byte{0x44, 0x00}, u16(1), u16(2), u16(0), u16(2), u32(0), u32(8), u32(8), u32(8)))},
+		{"item length too small", box("meta", u32(0), box("iinf", u32(0), u16(1), infeV2(2, "Exif")), ilocV0(0, 2))},
+		{"item length too large", box("meta", u32(0), box("iinf", u32(0), u16(1), infeV2(2, "Exif")), ilocV0(0, 1<<30))},
+		{"item past the end", box("meta", u32(0), box("iinf", u32(0), u16(1), infeV2(2, "Exif")), ilocV0(1<<20, 16))},
+		{"header offset past the item", func() []byte {
+			data := append([]byte{}, valid...)
+			// The Exif item is the mdat payload at the end: point its header offset past it
+			binary.BigEndian.PutUint32(data[len(data)-14:], 100)
+			return data
+		}()},
+		{"truncated", valid[:len(valid)-8]},
+		{"infe too short", box("meta", u32(0), box("iinf", u32(0), u16(1), box("infe", []byte{2, 0, 0, 0})), ilocV0(0, 0))},
+		{"iinf truncated", box("meta", u32(0), box("iinf", u32(0)))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := extractHEIFExif(bytes.NewReader(tt.data), int64(len(tt.data))); err == nil {
+				t.Errorf("extractHEIFExif() = %q, want an error", got)
+			}
+		})
+	}
+}
+
+func TestFindHEIFItemIDVersion3(t *testing.T) {
+	infe := box("infe", []byte{3, 0, 0, 0}, u32(70000), u16(0), []byte("Exif"), []byte{0})
+	iinfBytes := box("iinf", []byte{1, 0, 0, 0}, u32(1), infe)
+	boxes, err := readBMFFBoxes(bytes.NewReader(iinfBytes), 0, int64(len(iinfBytes)))
+	if err != nil || len(boxes) != 1 {
+		t.Fatalf("readBMFFBoxes() = %v, %v", boxes, err)
+	}
+
+	id, err := findHEIFItemID(bytes.NewReader(iinfBytes), boxes[0], "Exif")
+	if err != nil || id != 70000 {
+		t.Errorf("findHEIFItemID() = %d, %v; want 70000", id, err)
+	}
+	if _, err := findHEIFItemID(bytes.NewReader(iinfBytes), boxes[0], "mime"); err == nil {
+		t.Error("findHEIFItemID() found a missing item type")
+	}
+}
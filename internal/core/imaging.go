@@ -4,25 +4,103 @@ import (
 	"bytes"
 	"fmt"
 	"image"
+	"image/gif"
 	"image/jpeg"
+	"image/png"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/disintegration/imaging"
-	"github.com/rwcarlsen/goexif/exif"
+	_ "golang.org/x/image/webp" // Registers the WebP decoder for exports
 	"zensort/internal/config"
 )
 
+// exportFilters maps the resampling filter names of export profiles to filters
+var exportFilters = map[string]imaging.ResampleFilter{
+	"lanczos":    imaging.Lanczos,
+	"catmullrom": imaging.CatmullRom,
+	"mitchell":   imaging.MitchellNetravali,
+	"linear":     imaging.Linear,
+	"box":        imaging.Box,
+	"nearest":    imaging.NearestNeighbor,
+}
+
+// passthroughFormats are the formats exported unchanged by passthrough profiles
+var passthroughFormats = map[string]bool{".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".webp": true}
+
 // ImageProcessor handles image resizing and export operations
 type ImageProcessor struct {
-	config *config.Config
+	config      *config.Config
+	destDir     string // Destination root the export folders are created in
+	profiles    []config.ExportProfile
+	forceExport bool // Set by the "export" rule action
+}
+
+// NewImageProcessor creates a new image processor writing exports below destDir
+func NewImageProcessor(config *config.Config, destDir string) *ImageProcessor {
+	return &ImageProcessor{config: config, destDir: destDir, profiles: exportProfiles(config)}
+}
+
+// exportProfiles returns the configured export profiles with their defaults filled in.
+// Without configured profiles the single legacy export is described as a "default" profile.
+func exportProfiles(cfg *config.Config) []config.ExportProfile {
+	if len(cfg.ExportProfiles) == 0 {
+		return []config.ExportProfile{{
+			Name:        "default",
+			MaxWidth:    cfg.Processing.MaxImageWidth,
+			MaxHeight:   cfg.Processing.MaxImageHeight,
+			Filter:      "linear",
+			Quality:     cfg.Processing.JPEGQuality,
+			Folder:      cfg.ImageDirs.Exports,
+			Passthrough: cfg.Processing.Passthrough,
+		}}
+	}
+
+	profiles := make([]config.ExportProfile, len(cfg.ExportProfiles))
+	for i, profile := range cfg.ExportProfiles {
+		if profile.Filter == "" {
+			profile.Filter = "lanczos"
+		}
+		if profile.Quality == 0 {
+			profile.Quality = cfg.Processing.JPEGQuality
+		}
+		if profile.Folder == "" {
+			profile.Folder = filepath.Join(cfg.ImageDirs.Exports, profile.Name)
+		}
+		profiles[i] = profile
+	}
+	return profiles
 }
 
-// NewImageProcessor creates a new image processor
-func NewImageProcessor(config *config.Config) *ImageProcessor {
-	return &ImageProcessor{config: config}
+// validateExportProfiles checks that export profiles are named uniquely and use known settings
+func validateExportProfiles(profiles []config.ExportProfile) error {
+	names := make(map[string]bool)
+	for i, profile := range profiles {
+		name := strings.TrimSpace(profile.Name)
+		if name == "" {
+			return fmt.Errorf("export profile %d has no name", i+1)
+		}
+		if names[strings.ToLower(name)] {
+			return fmt.Errorf("duplicate export profile %q", name)
+		}
+		names[strings.ToLower(name)] = true
+
+		if profile.MaxWidth < 0 || profile.MaxHeight < 0 {
+			return fmt.Errorf("export profile %q: sizes cannot be negative", name)
+		}
+		if _, known := exportFilters[strings.ToLower(profile.Filter)]; profile.Filter != "" && !known {
+			return fmt.Errorf("export profile %q: unknown filter %q", name, profile.Filter)
+		}
+		if profile.Quality < 0 || profile.Quality > 100 {
+			return fmt.Errorf("export profile %q: quality must be between 1 and 100", name)
+		}
+		if profile.Sharpen < 0 {
+			return fmt.Errorf("export profile %q: sharpening cannot be negative", name)
+		}
+	}
+	return nil
 }
 
 // ProcessImage handles both original copying and export generation
@@ -32,12 +110,11 @@ func (ip *ImageProcessor) ProcessImage(srcPath, destPath string, exifData *EXIFD
 		return fmt.Errorf("failed to copy original: %w", err)
 	}
 
-	// Generate export if enabled and it's a supported format
-	if ip.config.Processing.EnableImageExports && ip.shouldCreateExport(srcPath, exifData) {
-		exportPath := ip.getExportPath(destPath, exifData)
-		if err := ip.createExport(srcPath, exportPath, exifData); err != nil {
+	// Generate exports if enabled and it's a supported format
+	if (ip.config.Processing.EnableImageExports || ip.forceExport) && ip.shouldCreateExport(srcPath, exifData) {
+		if err := ip.createExports(srcPath, destPath, exifData); err != nil {
 			// Log error but don't fail the whole operation
-			fmt.Printf("Warning: Failed to create export for %s: %v\n", srcPath, err)
+			fmt.Fprintf(os.Stderr, "Warning: Failed to create export for %s: %v\n", srcPath, err)
 		}
 	}
 
@@ -55,11 +132,46 @@ func (ip *ImageProcessor) copyOriginal(srcPath, destPath string) error {
 	return copyFile(srcPath, destPath)
 }
 
-// createExport creates a resized JPEG export of the image
-func (ip *ImageProcessor) createExport(srcPath, exportPath string, exifData *EXIFData) error {
-	// Ensure export directory exists
-	if err := os.MkdirAll(filepath.Dir(exportPath), 0755); err != nil {
-		return err
+// createExports writes the export of every export profile that does not exist yet.
+// The image is decoded and oriented once and each profile is resized from it.
+// Animated GIFs are resized frame by frame and animated WebPs are exported unchanged.
+func (ip *ImageProcessor) createExports(srcPath, destPath string, exifData *EXIFData) error {
+	type pendingExport struct {
+		profile config.ExportProfile
+		stem    string // Export path without extension, the format decides the extension
+	}
+
+	srcExt := strings.ToLower(filepath.Ext(srcPath))
+	var pending []pendingExport
+	for _, profile := range ip.profiles {
+		stem := ip.getExportPath(destPath, profile, exifData)
+		if exportExists(stem, srcExt) {
+			continue // Already exported by an earlier run
+		}
+		pending = append(pending, pendingExport{profile: profile, stem: stem})
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	if srcExt == ".gif" {
+		if animation, err := decodeAnimatedGIF(srcPath); err == nil {
+			for _, export := range pending {
+				if err := ip.createAnimatedExport(srcPath, export.stem, animation, export.profile); err != nil {
+					return fmt.Errorf("export profile %q: %w", export.profile.Name, err)
+				}
+			}
+			return nil
+		}
+	}
+	if srcExt == ".webp" && isAnimatedWebP(srcPath) {
+		// Animated WebPs cannot be decoded, so every profile gets the original
+		for _, export := range pending {
+			if err := ip.passthroughExport(srcPath, export.stem+srcExt); err != nil {
+				return fmt.Errorf("export profile %q: %w", export.profile.Name, err)
+			}
+		}
+		return nil
 	}
 
 	// Open and decode image
@@ -68,61 +180,203 @@ func (ip *ImageProcessor) createExport(srcPath, exportPath string, exifData *EXI
 		return fmt.Errorf("failed to open image: %w", err)
 	}
 
-	// Get current dimensions
-	bounds := src.Bounds()
-	width := bounds.Dx()
-	height := bounds.Dy()
+	// Apply EXIF orientation correction first, so the size limits apply to the displayed image
+	var oriented image.Image = src
+	if exifData != nil && exifData.Orientation > 1 {
+		oriented = ip.applyOrientation(src, exifData.Orientation)
+	}
 
-	// Check if resizing is needed (only downscale, never upscale)
-	maxWidth := ip.config.Processing.MaxImageWidth
-	maxHeight := ip.config.Processing.MaxImageHeight
-	
-	var resized image.Image = src
-	
-	if width > maxWidth || height > maxHeight {
-		// Calculate aspect ratio preserving dimensions
-		aspectRatio := float64(width) / float64(height)
-		
-		var newWidth, newHeight int
-		if aspectRatio > float64(maxWidth)/float64(maxHeight) {
-			// Width is the limiting factor
-			newWidth = maxWidth
-			newHeight = int(float64(maxWidth) / aspectRatio)
-		} else {
-			// Height is the limiting factor
-			newHeight = maxHeight
-			newWidth = int(float64(maxHeight) * aspectRatio)
+	// The color profile and metadata of the original go into every export. The pixels
+	// are upright now, so the orientation must not be applied a second time by viewers.
+	metadata, err := readImageMetadata(srcPath)
+	if err != nil {
+		return fmt.Errorf("failed to read image metadata: %w", err)
+	}
+	metadata = applyPrivacy(metadata.withUprightOrientation(), ip.config)
+
+	for _, export := range pending {
+		if err := ip.createExport(srcPath, export.stem, oriented, metadata, export.profile); err != nil {
+			return fmt.Errorf("export profile %q: %w", export.profile.Name, err)
 		}
-		
-		resized = imaging.Resize(src, newWidth, newHeight, imaging.Linear)
 	}
+	return nil
+}
 
-	// Apply EXIF orientation correction if needed
-	if exifData != nil && exifData.Orientation > 1 {
-		resized = ip.applyOrientation(resized, exifData.Orientation)
+// createExport resizes an image for one export profile. Images with transparency are
+// saved as PNG, all others as JPEG. With passthrough enabled, web images that already
+// fit the profile are copied unchanged.
+func (ip *ImageProcessor) createExport(srcPath, stem string, img image.Image, metadata *imageMetadata, profile config.ExportProfile) error {
+	srcExt := strings.ToLower(filepath.Ext(srcPath))
+	bounds := img.Bounds()
+	// PNGs are re-encoded rather than scrubbed, which is lossless for them anyway
+	canPassthrough := passthroughFormats[srcExt] && !(srcExt == ".png" && scrubsMetadata(ip.config))
+	if profile.Passthrough && canPassthrough && fitsWithin(bounds.Dx(), bounds.Dy(), profile.MaxWidth, profile.MaxHeight) {
+		return ip.passthroughExport(srcPath, stem+srcExt)
+	}
+
+	resized := fitWithin(img, profile.MaxWidth, profile.MaxHeight, exportFilters[strings.ToLower(profile.Filter)])
+	if profile.Sharpen > 0 {
+		resized = imaging.Sharpen(resized, profile.Sharpen)
+	}
+
+	// Encode to memory first so the metadata can be inserted
+	var encoded bytes.Buffer
+	exportPath := stem + ".jpg"
+	if hasAlpha(resized) {
+		// PNG keeps the transparency that JPEG would turn black
+		exportPath = stem + ".png"
+		if err := png.Encode(&encoded, resized); err != nil {
+			return fmt.Errorf("failed to encode PNG: %w", err)
+		}
+	} else {
+		// Save with configurable quality
+		quality := profile.Quality
+		if quality <= 0 || quality > 100 {
+			quality = 85 // Default fallback
+		}
+		if err := jpeg.Encode(&encoded, resized, &jpeg.Options{Quality: quality}); err != nil {
+			return fmt.Errorf("failed to encode JPEG: %w", err)
+		}
+	}
+
+	// Ensure export directory exists
+	if err := os.MkdirAll(filepath.Dir(exportPath), 0755); err != nil {
+		return err
 	}
 
-	// Save as JPEG with high quality
 	outFile, err := os.Create(exportPath)
 	if err != nil {
 		return fmt.Errorf("failed to create export file: %w", err)
 	}
-	defer outFile.Close()
 
-	// Save with configurable quality
-	quality := ip.config.Processing.JPEGQuality
-	if quality <= 0 || quality > 100 {
-		quality = 85 // Default fallback
+	if filepath.Ext(exportPath) == ".png" {
+		err = writePNGWithMetadata(outFile, encoded.Bytes(), metadata)
+	} else {
+		err = ip.writeJPEGWithEXIF(outFile, encoded.Bytes(), metadata)
 	}
-	
-	// Preserve EXIF data by copying from original file
-	if err := ip.preserveEXIFData(srcPath, outFile, resized, quality); err != nil {
-		// Fallback to standard encoding if EXIF preservation fails
-		return jpeg.Encode(outFile, resized, &jpeg.Options{Quality: quality})
+	if closeErr := outFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		// A partial export would count as done on the next run
+		os.Remove(exportPath)
+		return fmt.Errorf("failed to write export: %w", err)
 	}
 	return nil
 }
 
+// createAnimatedExport resizes an animated GIF for one export profile
+func (ip *ImageProcessor) createAnimatedExport(srcPath, stem string, animation *gif.GIF, profile config.ExportProfile) error {
+	width, height := gifSize(animation)
+	if fitsWithin(width, height, profile.MaxWidth, profile.MaxHeight) {
+		// Nothing to resize, re-encoding would only lose quality
+		return ip.passthroughExport(srcPath, stem+".gif")
+	}
+
+	resized := resizeAnimatedGIF(animation, profile.MaxWidth, profile.MaxHeight, exportFilters[strings.ToLower(profile.Filter)])
+	exportPath := stem + ".gif"
+	if err := os.MkdirAll(filepath.Dir(exportPath), 0755); err != nil {
+		return err
+	}
+
+	outFile, err := os.Create(exportPath)
+	if err != nil {
+		return fmt.Errorf("failed to create export file: %w", err)
+	}
+	err = gif.EncodeAll(outFile, resized)
+	if closeErr := outFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(exportPath)
+		return fmt.Errorf("failed to write export: %w", err)
+	}
+	return nil
+}
+
+// passthroughExport copies the original image as its export. When the privacy settings
+// change the metadata, the metadata of JPEGs is rewritten without re-encoding them and
+// WebPs lose their EXIF and XMP. Unless all metadata is kept, JPEGs also lose the data
+// appended after the image.
+func (ip *ImageProcessor) passthroughExport(srcPath, exportPath string) error {
+	if err := os.MkdirAll(filepath.Dir(exportPath), 0755); err != nil {
+		return err
+	}
+	srcExt := strings.ToLower(filepath.Ext(srcPath))
+	if !scrubsMetadata(ip.config) || (srcExt != ".jpg" && srcExt != ".jpeg" && srcExt != ".webp") {
+		return copyFile(srcPath, exportPath)
+	}
+
+	data, err := os.ReadFile(srcPath)
+	if err != nil {
+		return err
+	}
+
+	var out bytes.Buffer
+	if srcExt == ".webp" {
+		out.Write(stripWebPMetadata(data))
+	} else {
+		metadata, err := readJPEGMetadata(data)
+		if err != nil {
+			return fmt.Errorf("failed to read image metadata: %w", err)
+		}
+		if policy := ip.config.ExportPrivacy.Policy; policy != "" && policy != PrivacyKeepAll {
+			// Motion photo videos and secondary images after the image carry their own metadata
+			var ok bool
+			if data, ok = jpegWithoutTrailer(data); !ok {
+				return fmt.Errorf("failed to find the end of the JPEG image")
+			}
+		}
+		// The pixels are not rotated, so the orientation stays as recorded
+		if err := ip.writeJPEGWithEXIF(&out, data, applyPrivacy(metadata, ip.config)); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(exportPath, out.Bytes(), 0644)
+}
+
+// exportExists reports whether an export was already written in any of the export formats
+func exportExists(stem, srcExt string) bool {
+	for _, ext := range []string{".jpg", ".png", ".gif", srcExt} {
+		if _, err := os.Stat(stem + ext); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// hasAlpha reports whether an image has transparent or translucent pixels
+func hasAlpha(img image.Image) bool {
+	if opaque, ok := img.(interface{ Opaque() bool }); ok {
+		return !opaque.Opaque()
+	}
+	return false
+}
+
+// fitsWithin reports whether a size fits the limits of a profile (0 means unlimited)
+func fitsWithin(width, height, maxWidth, maxHeight int) bool {
+	return (maxWidth <= 0 || width <= maxWidth) && (maxHeight <= 0 || height <= maxHeight)
+}
+
+// fitWithin scales an image down to fit within maxWidth x maxHeight, keeping its aspect ratio.
+// Images are never enlarged and a limit of 0 leaves that dimension unlimited.
+func fitWithin(img image.Image, maxWidth, maxHeight int, filter imaging.ResampleFilter) image.Image {
+	bounds := img.Bounds()
+	if fitsWithin(bounds.Dx(), bounds.Dy(), maxWidth, maxHeight) {
+		return img
+	}
+	if maxWidth <= 0 {
+		maxWidth = bounds.Dx()
+	}
+	if maxHeight <= 0 {
+		maxHeight = bounds.Dy()
+	}
+	if filter.Support == 0 && filter.Kernel == nil {
+		filter = imaging.Lanczos
+	}
+	return imaging.Fit(img, maxWidth, maxHeight, filter)
+}
+
 // applyOrientation applies EXIF orientation correction
 func (ip *ImageProcessor) applyOrientation(img image.Image, orientation int) image.Image {
 	switch orientation {
@@ -154,7 +408,7 @@ func (ip *ImageProcessor) shouldCreateExport(filePath string, exifData *EXIFData
 	
 	ext := strings.ToLower(filepath.Ext(filePath))
 	// Create exports for common image formats
-	exportFormats := []string{".jpg", ".jpeg", ".png", ".tiff", ".tif", ".bmp", ".webp"}
+	exportFormats := []string{".jpg", ".jpeg", ".png", ".gif", ".tiff", ".tif", ".bmp", ".webp"}
 	
 	for _, format := range exportFormats {
 		if ext == format {
@@ -164,154 +418,77 @@ func (ip *ImageProcessor) shouldCreateExport(filePath string, exifData *EXIFData
 	return false
 }
 
-// getExportPath generates the export file path based on EXIF data
-func (ip *ImageProcessor) getExportPath(originalDestPath string, exifData *EXIFData) string {
-	// Get the base destination directory (not the Images subdirectory)
-	// originalDestPath is like: /dest/Images/Originals/Collections/file.jpg
-	// We need to get: /dest (base destination)
-	
+// getExportPath generates the export file path of a profile based on EXIF data.
+// The path has no extension, it depends on the format the export is written in.
+func (ip *ImageProcessor) getExportPath(originalDestPath string, profile config.ExportProfile, exifData *EXIFData) string {
 	fileName := filepath.Base(originalDestPath)
-	
-	// Find the base destination by going up until we're above "Images"
-	currentPath := originalDestPath
-	for {
-		parent := filepath.Dir(currentPath)
-		if filepath.Base(parent) != "Images" && filepath.Base(currentPath) == "Images" {
-			// parent is the base destination directory
-			return GetImageDestinationPath(parent, fileName, exifData, ip.config, true)
-		}
-		if parent == currentPath {
-			// Reached root, fallback
-			break
-		}
-		currentPath = parent
-	}
-	
-	// Fallback: assume standard structure
-	baseDir := filepath.Dir(filepath.Dir(filepath.Dir(originalDestPath))) // Go up 3 levels from Collections
-	return GetImageDestinationPath(baseDir, fileName, exifData, ip.config, true)
-}
-
-// preserveEXIFData extracts EXIF from source and embeds it in the processed image
-func (ip *ImageProcessor) preserveEXIFData(srcPath string, outFile *os.File, img image.Image, quality int) error {
-	// Read original file to extract EXIF data
-	srcFile, err := os.Open(srcPath)
-	if err != nil {
-		return err
+	if profile.Template == "" {
+		exportPath := GetExportDestinationPath(ip.destDir, profile.Folder, fileName, exifData, ip.config)
+		return strings.TrimSuffix(exportPath, filepath.Ext(exportPath))
 	}
-	defer srcFile.Close()
 
-	// Verify EXIF data exists (we don't need to decode it, just check)
-	_, err = exif.Decode(srcFile)
-	if err != nil {
-		return err // No EXIF data or decode error
+	values := map[string]string{
+		"name":    strings.TrimSuffix(fileName, filepath.Ext(fileName)),
+		"profile": profile.Name,
+		"make":    exifData.Make,
+		"model":   exifData.Model,
+		"ext":     strings.TrimPrefix(strings.ToLower(filepath.Ext(fileName)), "."),
 	}
-
-	// Encode processed image to buffer first
-	var imgBuf bytes.Buffer
-	if err := jpeg.Encode(&imgBuf, img, &jpeg.Options{Quality: quality}); err != nil {
-		return err
-	}
-
-	// Read original file completely to get raw EXIF segments
-	srcFile.Seek(0, 0)
-	originalData, err := io.ReadAll(srcFile)
-	if err != nil {
-		return err
-	}
-
-	// Extract EXIF segment from original JPEG
-	exifSegment, err := ip.extractEXIFSegment(originalData)
-	if err != nil {
-		return err
-	}
-
-	// Combine processed image with original EXIF
-	return ip.writeJPEGWithEXIF(outFile, imgBuf.Bytes(), exifSegment)
-}
-
-// extractEXIFSegment extracts the EXIF APP1 segment from JPEG data
-func (ip *ImageProcessor) extractEXIFSegment(jpegData []byte) ([]byte, error) {
-	if len(jpegData) < 4 || jpegData[0] != 0xFF || jpegData[1] != 0xD8 {
-		return nil, fmt.Errorf("not a valid JPEG file")
-	}
-
-	pos := 2
-	for pos < len(jpegData)-1 {
-		if jpegData[pos] != 0xFF {
-			return nil, fmt.Errorf("invalid JPEG marker")
-		}
-		
-		marker := jpegData[pos+1]
-		pos += 2
-		
-		if marker == 0xE1 { // APP1 segment (EXIF)
-			if pos+2 > len(jpegData) {
-				break
-			}
-			length := int(jpegData[pos])<<8 | int(jpegData[pos+1])
-			if pos+length > len(jpegData) {
-				break
-			}
-			
-			// Check if this is EXIF data
-			if length > 6 && string(jpegData[pos+2:pos+6]) == "Exif" {
-				return jpegData[pos-2:pos+length], nil
-			}
-		}
-		
-		if marker == 0xDA { // Start of scan - no more metadata
-			break
-		}
-		
-		if pos+2 > len(jpegData) {
-			break
-		}
-		length := int(jpegData[pos])<<8 | int(jpegData[pos+1])
-		pos += length
-	}
-	
-	return nil, fmt.Errorf("no EXIF segment found")
+	setTemplateDate(values, exifData.DateTime, exifData.HasDateTime)
+	relative := ExpandPathTemplate(profile.Template, values, ip.config.PathTemplates.Fallbacks)
+	return filepath.Join(ip.destDir, ip.config.Directories.Images, profile.Folder, relative)
 }
 
-// writeJPEGWithEXIF writes JPEG with EXIF segment inserted
-func (ip *ImageProcessor) writeJPEGWithEXIF(outFile *os.File, jpegData []byte, exifSegment []byte) error {
+// writeJPEGWithEXIF writes JPEG data with the EXIF, XMP and ICC profile segments inserted
+// after the start of image marker
+func (ip *ImageProcessor) writeJPEGWithEXIF(w io.Writer, jpegData []byte, metadata *imageMetadata) error {
 	if len(jpegData) < 4 || jpegData[0] != 0xFF || jpegData[1] != 0xD8 {
 		return fmt.Errorf("invalid JPEG data")
 	}
 
 	// Write JPEG SOI marker
-	if _, err := outFile.Write(jpegData[0:2]); err != nil {
+	if _, err := w.Write(jpegData[0:2]); err != nil {
 		return err
 	}
 
-	// Write EXIF segment
-	if _, err := outFile.Write(exifSegment); err != nil {
-		return err
+	// Write metadata segments
+	for _, segment := range jpegMetadataSegments(metadata) {
+		if _, err := w.Write(segment); err != nil {
+			return err
+		}
 	}
 
-	// Find where to continue writing from processed image (skip SOI and any existing APP segments)
+	// Copy the remaining segments of the image, leaving out the metadata segments it
+	// already had (EXIF and XMP in APP1, ICC profiles in APP2, IPTC in APP13)
 	pos := 2
-	for pos < len(jpegData)-1 {
+	for pos+4 <= len(jpegData) {
 		if jpegData[pos] != 0xFF {
 			break
 		}
 		
 		marker := jpegData[pos+1]
-		if marker >= 0xE0 && marker <= 0xEF { // APP segments
-			if pos+2 >= len(jpegData) {
-				break
-			}
-			length := int(jpegData[pos])<<8 | int(jpegData[pos+1])
-			pos += 2 + length
-		} else {
+		if marker < 0xE0 || marker > 0xEF { // Not an APP segment
 			break
 		}
+		length := int(jpegData[pos+2])<<8 | int(jpegData[pos+3])
+		if pos+2+length > len(jpegData) {
+			break
+		}
+
+		segment := jpegData[pos : pos+2+length]
+		isMetadata := marker == 0xE1 || marker == 0xED ||
+			(marker == 0xE2 && bytes.HasPrefix(segment[4:], jpegICCHeader))
+		if !isMetadata {
+			if _, err := w.Write(segment); err != nil {
+				return err
+			}
+		}
+		pos += 2 + length
 	}
 
 	// Write remaining JPEG data
 	if pos < len(jpegData) {
-		if _, err := outFile.Write(jpegData[pos:]); err != nil {
+		if _, err := w.Write(jpegData[pos:]); err != nil {
 			return err
 		}
 	}
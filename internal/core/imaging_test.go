@@ -0,0 +1,40 @@
+package core
+
+import (
+	"image"
+	"image/color/palette"
+	"image/gif"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"zensort/internal/config"
+)
+
+func TestCreateAnimatedExportRemovesPartialFile(t *testing.T) {
+	frame := image.NewPaletted(image.Rect(0, 0, 40, 40), palette.Plan9)
+	// More frames than delays makes the encoder fail
+	animation := &gif.GIF{Image: []*image.Paletted{frame, frame}, Delay: []int{10}}
+	processor := NewImageProcessor(config.DefaultConfig(), t.TempDir())
+	stem := filepath.Join(t.TempDir(), "anim")
+
+	err := processor.createAnimatedExport("anim.gif", stem, animation, config.ExportProfile{MaxWidth: 20, MaxHeight: 20})
+	if err == nil {
+		t.Fatal("createAnimatedExport() error = nil, want an encoding error")
+	}
+	if _, err := os.Stat(stem + ".gif"); !os.IsNotExist(err) {
+		t.Errorf("partial export left behind: %v", err)
+	}
+}
+
+func TestExportProfilesLegacyPassthrough(t *testing.T) {
+	for _, passthrough := range []bool{false, true} {
+		cfg := config.DefaultConfig()
+		cfg.ExportProfiles = nil
+		cfg.Processing.Passthrough = passthrough
+		profiles := exportProfiles(cfg)
+		if len(profiles) != 1 || profiles[0].Passthrough != passthrough {
+			t.Errorf("exportProfiles() with passthrough %v = %+v, want one default profile carrying it", passthrough, profiles)
+		}
+	}
+}
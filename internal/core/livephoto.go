@@ -0,0 +1,147 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// quickTimeContentIdentifierKey is the QuickTime metadata key holding the Live Photo pairing identifier
+const quickTimeContentIdentifierKey = "com.apple.quicktime.content.identifier"
+
+// livePhotoStillExts are the still image formats an iPhone writes for Live Photos
+var livePhotoStillExts = map[string]bool{".heic": true, ".heif": true, ".jpg": true, ".jpeg": true}
+
+// ReadVideoContentIdentifier returns the Apple Live Photo content identifier of a MOV/MP4 file,
+// or an empty string if the video has none
+func ReadVideoContentIdentifier(filePath string) string {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return ""
+	}
+
+	metadata := readQuickTimeMetadata(file, info.Size())
+	return strings.TrimSpace(metadata[quickTimeContentIdentifierKey])
+}
+
+// pairLivePhotos matches iPhone Live Photo stills with their videos by content identifier.
+// Paired videos are removed from the file list and returned keyed by their still,
+// so they are organized together with it instead of on their own.
+func (fp *FileProcessor) pairLivePhotos(files []string) ([]string, map[string]string) {
+	pairs := make(map[string]string)
+
+	// Only directories holding videos can contain Live Photo pairs
+	videoDirs := make(map[string]bool)
+	for _, path := range files {
+		if strings.EqualFold(filepath.Ext(path), ".mov") {
+			videoDirs[filepath.Dir(path)] = true
+		}
+	}
+	if len(videoDirs) == 0 {
+		return files, pairs
+	}
+
+	// Index stills by directory + content identifier
+	stills := make(map[string]string)
+	for _, path := range files {
+		if !videoDirs[filepath.Dir(path)] || !livePhotoStillExts[strings.ToLower(filepath.Ext(path))] {
+			continue
+		}
+		exifData, err := ExtractEXIF(path)
+		if err != nil || exifData == nil || exifData.ContentIdentifier == "" {
+			continue
+		}
+		stills[filepath.Join(filepath.Dir(path), exifData.ContentIdentifier)] = path
+	}
+	if len(stills) == 0 {
+		return files, pairs
+	}
+
+	paired := make(map[string]bool)
+	for _, path := range files {
+		if !strings.EqualFold(filepath.Ext(path), ".mov") {
+			continue
+		}
+		contentID := ReadVideoContentIdentifier(path)
+		if contentID == "" {
+			continue
+		}
+		still, found := stills[filepath.Join(filepath.Dir(path), contentID)]
+		if !found {
+			continue
+		}
+		if _, taken := pairs[still]; taken {
+			continue // Keep the first video, any others are organized on their own
+		}
+		pairs[still] = path
+		paired[path] = true
+	}
+
+	var remaining []string
+	for _, path := range files {
+		if !paired[path] {
+			remaining = append(remaining, path)
+		}
+	}
+	return remaining, pairs
+}
+
+// placeLiveVideo copies the video of a Live Photo next to its still, following any rename.
+// It returns the hash of the video so the still can record its pair.
+// Failures are logged but never fail the still.
+func (fo *FileOrganizer) placeLiveVideo(stillSource, stillDest, stillHash, videoPath, contentID string, move bool) string {
+	hash, err := calculateFileHash(videoPath)
+	if err != nil {
+		fo.logger.LogError(LogLevelWarning, "Failed to hash Live Photo video", videoPath, err)
+		fo.recordGrouped(videoPath, "", OutcomeFailed, fmt.Sprintf("failed to calculate file hash: %v", err))
+		return ""
+	}
+
+	if exists, existingPath, _ := fo.db.CheckDuplicate(hash); exists {
+		fo.logger.LogFileDuplicate(videoPath, existingPath, hash, "")
+		fo.recordGrouped(videoPath, "", OutcomeDuplicate, fmt.Sprintf("same content as %s", existingPath))
+		return hash
+	}
+
+	destPath := fo.resolveNamingConflict(sidecarDestination(videoPath, stillSource, stillDest))
+	if err := fo.regularCopy(videoPath, destPath); err != nil {
+		fo.logger.LogError(LogLevelWarning, "Failed to copy Live Photo video", videoPath, err)
+		fo.recordGrouped(videoPath, "", OutcomeFailed, fmt.Sprintf("failed to copy file: %v", err))
+		return ""
+	}
+
+	var size int64
+	if info, err := os.Stat(videoPath); err == nil {
+		size = info.Size()
+	}
+
+	record := FileRecord{
+		Hash:              hash,
+		OriginalPath:      fo.originOf(videoPath),
+		DestinationPath:   destPath,
+		Size:              size,
+		PairedWith:        stillHash,
+		ContentIdentifier: contentID,
+		Category:          fo.detector.GetFileTypeString(FileTypeVideo),
+	}
+	if err := fo.db.AddRecord(record); err != nil {
+		fo.logger.LogError(LogLevelWarning, "Failed to add Live Photo video to database", videoPath, err)
+	}
+	fo.recordGrouped(videoPath, destPath, OutcomeOrganized, fmt.Sprintf("Live Photo video of %s", filepath.Base(stillSource)))
+
+	if move {
+		if err := os.Remove(videoPath); err != nil {
+			fo.logger.LogError(LogLevelWarning, "Failed to remove Live Photo video after move", videoPath, err)
+		}
+	}
+
+	fo.logger.LogOperation("LIVE_PHOTO", fmt.Sprintf("Live Photo video placed with %q - Destination: %q", stillDest, destPath), videoPath)
+	return hash
+}
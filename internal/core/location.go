@@ -0,0 +1,75 @@
+package core
+
+import (
+	"encoding/binary"
+	"os"
+	"regexp"
+	"strconv"
+)
+
+// quickTimeLocationKey is the QuickTime metadata key of the recording location
+const quickTimeLocationKey = "com.apple.quicktime.location.iso6709"
+
+// iso6709Pattern matches decimal-degree ISO 6709 positions such as "+37.7749-122.4194+010.000/"
+var iso6709Pattern = regexp.MustCompile(`^([+-]\d{1,2}(?:\.\d+)?)([+-]\d{1,3}(?:\.\d+)?)([+-]\d+(?:\.\d+)?)?(?:CRS[^/]*)?/?$`)
+
+// GPSCoordinates is a position on Earth
+type GPSCoordinates struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Altitude  float64 `json:"altitude,omitempty"` // Meters above sea level
+}
+
+// parseISO6709 parses a decimal-degree ISO 6709 position
+func parseISO6709(value string) (float64, float64, float64, bool) {
+	match := iso6709Pattern.FindStringSubmatch(value)
+	if match == nil {
+		return 0, 0, 0, false
+	}
+
+	lat, err1 := strconv.ParseFloat(match[1], 64)
+	long, err2 := strconv.ParseFloat(match[2], 64)
+	if err1 != nil || err2 != nil || lat < -90 || lat > 90 || long < -180 || long > 180 || (lat == 0 && long == 0) {
+		return 0, 0, 0, false
+	}
+
+	var alt float64
+	if match[3] != "" {
+		alt, _ = strconv.ParseFloat(match[3], 64)
+	}
+	return lat, long, alt, true
+}
+
+// ReadQuickTimeLocation reads the recording location of a MOV/MP4 file without ffprobe,
+// from the QuickTime metadata keys or the classic moov/udta/©xyz atom
+func ReadQuickTimeLocation(filePath string) (float64, float64, float64, bool) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return 0, 0, 0, false
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, 0, 0, false
+	}
+
+	if lat, long, alt, ok := parseISO6709(readQuickTimeMetadata(file, info.Size())[quickTimeLocationKey]); ok {
+		return lat, long, alt, true
+	}
+
+	// ©xyz holds a 16-bit string length and language code before the text
+	xyz, ok := findBMFFBox(file, 0, info.Size(), "moov", "udta", "\xa9xyz")
+	if !ok {
+		return 0, 0, 0, false
+	}
+	data, err := readBMFFPayload(file, xyz, 1024)
+	if err != nil || len(data) < 4 {
+		return 0, 0, 0, false
+	}
+	length := int(binary.BigEndian.Uint16(data[0:2]))
+	if 4+length > len(data) {
+		length = len(data) - 4
+	}
+	return parseISO6709(string(data[4 : 4+length]))
+}
@@ -97,8 +97,14 @@ func (l *Logger) LogFileProcessed(sourcePath, destPath, hash string, size int64)
 		sourcePath)
 }
 
-// LogFileDuplicate logs a duplicate file detection
-func (l *Logger) LogFileDuplicate(sourcePath, existingPath, hash string) {
+// LogFileDuplicate logs a duplicate file detection, naming it by its title when it has one
+func (l *Logger) LogFileDuplicate(sourcePath, existingPath, hash, title string) {
+	if title != "" {
+		l.LogOperation("DUPLICATE",
+			fmt.Sprintf("Duplicate detected - Title: %q, Existing: %q", title, existingPath),
+			sourcePath)
+		return
+	}
 	l.LogOperation("DUPLICATE", 
 		fmt.Sprintf("Duplicate detected - Hash: %s, Existing: %q", hash, existingPath), 
 		sourcePath)
@@ -112,8 +118,8 @@ func (l *Logger) LogFileSkipped(filePath, reason string) {
 // LogStatistics logs processing statistics
 func (l *Logger) LogStatistics(stats ProcessingStats) {
 	l.LogOperation("STATS", fmt.Sprintf(
-		"Processing complete - Total: %d, Processed: %d, Skipped: %d, Duplicates: %d, Errors: %d, Duration: %v",
-		stats.TotalFiles, stats.ProcessedFiles, stats.SkippedFiles, stats.DuplicateFiles, stats.ErrorFiles, stats.Duration), "")
+		"Processing complete - Total: %d, Processed: %d, Skipped: %d, Filtered: %d, Duplicates: %d, Errors: %d, Duration: %v",
+		stats.TotalFiles, stats.ProcessedFiles, stats.SkippedFiles, stats.FilteredFiles, stats.DuplicateFiles, stats.ErrorFiles, stats.Duration), "")
 }
 
 // Close closes the log files
@@ -156,7 +162,10 @@ type ProcessingStats struct {
 	ProcessedFiles int64
 	SkippedFiles   int64
 	DuplicateFiles int64
+	FilteredFiles  int64
 	ErrorFiles     int64
+	InferredDates  int64            // Files dated from their filename
+	DateSources    map[string]int64 // Files per capture date source
 	TotalSize      int64
 	ProcessedSize  int64
 	Duration       time.Duration
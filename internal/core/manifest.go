@@ -0,0 +1,142 @@
+package core
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// Outcome is what happened to a file during a session
+type Outcome string
+
+const (
+	OutcomeOrganized Outcome = "organized" // Copied, moved or exported to the destination
+	OutcomeDuplicate Outcome = "duplicate" // Same content as a file organized before
+	OutcomeSkipped   Outcome = "skipped"   // Left out by a rule, a pairing policy or its primary file
+	OutcomeFiltered  Outcome = "filtered"  // Left out by the skip patterns or skip_unknown
+	OutcomeFailed    Outcome = "failed"    // Could not be organized
+)
+
+// ManifestEntry records the decision taken for one source file
+type ManifestEntry struct {
+	Source      string  `json:"source"`
+	Destination string  `json:"destination,omitempty"`
+	Category    string  `json:"category"`
+	Outcome     Outcome `json:"outcome"`
+	Reason      string  `json:"reason"`
+	Size        int64   `json:"size"`
+	Date        string  `json:"date,omitempty"` // Capture date as 2006-01-02
+	Camera      string  `json:"camera,omitempty"`
+}
+
+// FileOutcome is what organizing a file led to
+type FileOutcome struct {
+	ManifestEntry
+	Err error // Why a failed file could not be organized
+}
+
+// Manifest collects the decisions of a session for the reports
+type Manifest struct {
+	mu      sync.Mutex
+	entries []ManifestEntry
+	handler func(ManifestEntry) // Called for every decision as it is recorded
+}
+
+// NewManifest creates an empty manifest
+func NewManifest() *Manifest {
+	return &Manifest{}
+}
+
+// Add records a decision. A nil manifest ignores it.
+func (m *Manifest) Add(entry ManifestEntry) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries = append(m.entries, entry)
+	if m.handler != nil {
+		m.handler(entry)
+	}
+}
+
+// SetHandler registers a function called with every decision as it is recorded. Calls
+// are made one at a time in recording order; the handler must not use the manifest.
+func (m *Manifest) SetHandler(handler func(ManifestEntry)) {
+	m.mu.Lock()
+	m.handler = handler
+	m.mu.Unlock()
+}
+
+// Entries returns the recorded decisions in the order they were taken
+func (m *Manifest) Entries() []ManifestEntry {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]ManifestEntry(nil), m.entries...)
+}
+
+// Len returns how many decisions were recorded
+func (m *Manifest) Len() int {
+	if m == nil {
+		return 0
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.entries)
+}
+
+// EntriesFrom returns the decisions recorded after the first start ones
+func (m *Manifest) EntriesFrom(start int) []ManifestEntry {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if start >= len(m.entries) {
+		return nil
+	}
+	return append([]ManifestEntry(nil), m.entries[start:]...)
+}
+
+// fileSize returns the size of a file, or 0 when it can't be read
+func fileSize(path string) int64 {
+	if info, err := os.Stat(path); err == nil {
+		return info.Size()
+	}
+	return 0
+}
+
+// writeManifestCSV writes the decisions as a CSV file with a header row
+func writeManifestCSV(path string, entries []ManifestEntry) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"source", "destination", "category", "outcome", "reason", "size", "date", "camera"})
+	for _, entry := range entries {
+		writer.Write([]string{
+			entry.Source,
+			entry.Destination,
+			entry.Category,
+			string(entry.Outcome),
+			entry.Reason,
+			strconv.FormatInt(entry.Size, 10),
+			entry.Date,
+			entry.Camera,
+		})
+	}
+	writer.Flush()
+
+	if err := writer.Error(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return file.Close()
+}
@@ -0,0 +1,307 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// motionPhotoXMPScanSize is how much of an image is searched for its XMP packet
+const motionPhotoXMPScanSize = 512 * 1024
+
+// motionPhotoMarkerScanSize is how much of the end of a Samsung image is searched for the
+// marker when its trailer directory does not list it
+const motionPhotoMarkerScanSize = 64 * 1024 * 1024
+
+// samsungTrailerFooter ends the trailer Samsung appends to its images, after the size of
+// the trailer directory
+var samsungTrailerFooter = []byte("SEFT")
+
+// motionPhotoExtensions are the formats phones write motion photos in
+var motionPhotoExtensions = map[string]bool{".jpg": true, ".jpeg": true, ".heic": true, ".heif": true}
+
+// samsungMotionPhotoMarker precedes the MP4 appended to Samsung motion photos
+var samsungMotionPhotoMarker = []byte("MotionPhoto_Data")
+
+var (
+	// Older Pixel "MVIMG" files: the video is the last MicroVideoOffset bytes
+	microVideoOffsetPattern = regexp.MustCompile(`MicroVideoOffset(?:="|>)(\d+)`)
+	// Newer Pixel/Samsung files list the video as a Container:Directory item
+	motionPhotoItemPattern = regexp.MustCompile(`(?s)<rdf:li[^>]*>\s*<Container:Item[^>]*Item:Semantic="MotionPhoto"[^>]*>`)
+	itemLengthPattern      = regexp.MustCompile(`Item:Length="(\d+)"`)
+	itemPaddingPattern     = regexp.MustCompile(`Item:Padding="(\d+)"`)
+)
+
+// EmbeddedVideo locates an MP4 video appended to a still image
+type EmbeddedVideo struct {
+	Offset int64
+	Length int64
+	Format string // How the payload was found: "xmp_container", "micro_video" or "samsung"
+}
+
+// CanEmbedVideo reports whether a file is in a format that can carry a motion photo video
+func CanEmbedVideo(filePath string) bool {
+	return motionPhotoExtensions[strings.ToLower(filepath.Ext(filePath))]
+}
+
+// FindEmbeddedVideo looks for a motion photo video embedded in a JPEG or HEIC file.
+// It returns nil if the image has no embedded video.
+func FindEmbeddedVideo(filePath string) (*EmbeddedVideo, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := info.Size()
+
+	head := make([]byte, motionPhotoXMPScanSize)
+	n, err := file.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	head = head[:n]
+
+	// Google camera XMP describes where the video starts, counted from the end of the file
+	if videoLength, format := xmpMotionPhotoLength(head); videoLength > 0 && videoLength < size {
+		offset := size - videoLength
+		if length := mp4PayloadLength(file, offset, size); length > 0 {
+			return &EmbeddedVideo{Offset: offset, Length: length, Format: format}, nil
+		}
+	}
+
+	// Samsung appends the video after a marker, followed by its own trailer
+	if offset := findSamsungMarker(file, size); offset >= 0 {
+		start := offset + int64(len(samsungMotionPhotoMarker))
+		if length := mp4PayloadLength(file, start, size); length > 0 {
+			return &EmbeddedVideo{Offset: start, Length: length, Format: "samsung"}, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// xmpMotionPhotoLength returns the length of the embedded video declared in the XMP packet
+func xmpMotionPhotoLength(head []byte) (int64, string) {
+	if item := motionPhotoItemPattern.Find(head); item != nil {
+		if match := itemLengthPattern.FindSubmatch(item); match != nil {
+			length, _ := strconv.ParseInt(string(match[1]), 10, 64)
+			if match := itemPaddingPattern.FindSubmatch(item); match != nil {
+				padding, _ := strconv.ParseInt(string(match[1]), 10, 64)
+				length += padding
+			}
+			return length, "xmp_container"
+		}
+	}
+
+	if match := microVideoOffsetPattern.FindSubmatch(head); match != nil {
+		length, _ := strconv.ParseInt(string(match[1]), 10, 64)
+		return length, "micro_video"
+	}
+
+	return 0, ""
+}
+
+// findSamsungMarker returns the offset of the motion photo marker in a Samsung trailer, or -1.
+// The trailer ends with a directory of its data blocks ("SEFH", version, count, then per block
+// its type, its offset back from the directory and its length), the directory size and "SEFT".
+// Files without the trailer are not scanned at all.
+func findSamsungMarker(r io.ReaderAt, size int64) int64 {
+	footer := make([]byte, 8)
+	if size < int64(len(footer)) {
+		return -1
+	}
+	if _, err := r.ReadAt(footer, size-int64(len(footer))); err != nil || !bytes.Equal(footer[4:], samsungTrailerFooter) {
+		return -1
+	}
+
+	dirSize := int64(binary.LittleEndian.Uint32(footer))
+	dirStart := size - int64(len(footer)) - dirSize
+	if dirSize >= 12 && dirSize <= 64*1024 && dirStart >= 0 {
+		dir := make([]byte, dirSize)
+		if _, err := r.ReadAt(dir, dirStart); err == nil && bytes.HasPrefix(dir, []byte("SEFH")) {
+			// Each block starts with its type and the length of its name, then the name
+			header := make([]byte, 8+len(samsungMotionPhotoMarker))
+			count := int(binary.LittleEndian.Uint32(dir[8:]))
+			for i := 0; i < count && 12+12*(i+1) <= len(dir); i++ {
+				entry := dir[12+12*i:]
+				blockStart := dirStart - int64(binary.LittleEndian.Uint32(entry[4:]))
+				if blockStart < 0 {
+					continue
+				}
+				if _, err := r.ReadAt(header, blockStart); err != nil {
+					continue
+				}
+				if int(binary.LittleEndian.Uint32(header[4:])) == len(samsungMotionPhotoMarker) && bytes.Equal(header[8:], samsungMotionPhotoMarker) {
+					return blockStart + 8
+				}
+			}
+		}
+	}
+
+	// Fall back to looking for the marker when the directory is missing or unreadable
+	return findMarker(r, size, samsungMotionPhotoMarker, motionPhotoMarkerScanSize)
+}
+
+// findMarker returns the offset of the last occurrence of marker within the
+// last limit bytes of the file, or -1
+func findMarker(r io.ReaderAt, size int64, marker []byte, limit int64) int64 {
+	const chunkSize = 1024 * 1024
+	buf := make([]byte, chunkSize+len(marker))
+
+	floor := size - limit
+	if floor < 0 {
+		floor = 0
+	}
+
+	// Scan backwards since the payload sits at the end of the file
+	for end := size; end > floor; end -= chunkSize {
+		start := end - chunkSize
+		if start < floor {
+			start = floor
+		}
+		readEnd := end + int64(len(marker))
+		if readEnd > size {
+			readEnd = size
+		}
+		n, err := r.ReadAt(buf[:readEnd-start], start)
+		if err != nil && err != io.EOF {
+			return -1
+		}
+		if index := bytes.LastIndex(buf[:n], marker); index >= 0 {
+			return start + int64(index)
+		}
+	}
+	return -1
+}
+
+// mp4PayloadLength returns the length of the MP4 stream starting at offset,
+// measured by walking its top-level boxes. It returns 0 if no MP4 starts there.
+func mp4PayloadLength(r io.ReaderAt, offset, end int64) int64 {
+	header := make([]byte, 8)
+	if _, err := r.ReadAt(header, offset); err != nil || string(header[4:8]) != "ftyp" {
+		return 0
+	}
+
+	// Stop at the first thing that isn't a box (trailers such as Samsung's SEF data)
+	boxes, _ := readBMFFBoxes(r, offset, end)
+	var length int64
+	for _, box := range boxes {
+		if !isBoxType(box.Type) {
+			break
+		}
+		length = box.End() - offset
+	}
+	return length
+}
+
+// isBoxType reports whether a box type consists of printable ASCII
+func isBoxType(boxType string) bool {
+	for i := 0; i < len(boxType); i++ {
+		if boxType[i] < 0x20 || boxType[i] > 0x7E {
+			return false
+		}
+	}
+	return len(boxType) == 4
+}
+
+// extractEmbeddedVideo writes the video of a motion photo to Videos/Motion Photos/{year},
+// leaving the original image untouched, and records it in the manifest under the origin
+// of the image. It returns the hash of the video so the image can record its pair.
+// Failures are logged but never fail the image.
+func (fo *FileOrganizer) extractEmbeddedVideo(fc *fileContext, video *EmbeddedVideo, imageDest, imageHash string) string {
+	source, err := os.Open(fc.path)
+	if err != nil {
+		fo.logger.LogError(LogLevelWarning, "Failed to open motion photo", fc.path, err)
+		return ""
+	}
+	defer source.Close()
+
+	payload := io.NewSectionReader(source, video.Offset, video.Length)
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, payload); err != nil {
+		fo.logger.LogError(LogLevelWarning, "Failed to read motion photo video", fc.path, err)
+		return ""
+	}
+	hash := fmt.Sprintf("%x", hasher.Sum(nil))
+
+	if exists, existingPath, _ := fo.db.CheckDuplicate(hash); exists {
+		fo.logger.LogOperation("DUPLICATE", fmt.Sprintf("Embedded motion photo video already extracted to %q", existingPath), fc.path)
+		fo.manifest.Add(ManifestEntry{
+			Source:   fc.origin,
+			Category: fo.detector.GetFileTypeString(FileTypeVideo),
+			Outcome:  OutcomeDuplicate,
+			Reason:   fmt.Sprintf("embedded video has the same content as %s", existingPath),
+			Size:     video.Length,
+		})
+		return hash
+	}
+
+	// Name the video after the image so the pair is easy to spot
+	captureDate, _ := fc.CaptureDate()
+	imageName := filepath.Base(imageDest)
+	videoName := strings.TrimSuffix(imageName, filepath.Ext(imageName)) + ".mp4"
+	destDir := filepath.Join(fo.destDir, fo.config.Directories.Videos, "Motion Photos", fo.extractYear(captureDate))
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		fo.logger.LogError(LogLevelWarning, "Failed to create motion photo directory", fc.path, err)
+		return ""
+	}
+	destPath := fo.resolveNamingConflict(filepath.Join(destDir, videoName))
+
+	if err := writeSection(source, video.Offset, video.Length, destPath); err != nil {
+		fo.logger.LogError(LogLevelWarning, "Failed to extract motion photo video", fc.path, err)
+		return ""
+	}
+
+	record := FileRecord{
+		Hash:            hash,
+		OriginalPath:    fc.origin,
+		OriginalName:    videoName,
+		DestinationPath: destPath,
+		Size:            video.Length,
+		CaptureDate:     captureDate,
+		PairedWith:      imageHash,
+		Category:        fo.detector.GetFileTypeString(FileTypeVideo),
+	}
+	if err := fo.db.AddRecord(record); err != nil {
+		fo.logger.LogError(LogLevelWarning, "Failed to add motion photo video to database", fc.path, err)
+	}
+
+	fo.logger.LogOperation("MOTION_PHOTO", fmt.Sprintf("Embedded video (%s) extracted from %q - Destination: %q", video.Format, imageDest, destPath), fc.path)
+	fo.manifest.Add(ManifestEntry{
+		Source:      fc.origin,
+		Destination: destPath,
+		Category:    record.Category,
+		Outcome:     OutcomeOrganized,
+		Reason:      fmt.Sprintf("video embedded in motion photo %s", filepath.Base(fc.origin)),
+		Size:        video.Length,
+	})
+	return hash
+}
+
+// writeSection copies a byte range of a file into a new file
+func writeSection(source io.ReaderAt, offset, length int64, destPath string) error {
+	destFile, err := os.Create(destPath)
+	if err != nil {
+		return err
+	}
+
+	_, copyErr := io.Copy(destFile, io.NewSectionReader(source, offset, length))
+	closeErr := destFile.Close()
+	if copyErr != nil {
+		os.Remove(destPath)
+		return copyErr
+	}
+	return closeErr
+}
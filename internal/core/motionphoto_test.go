@@ -0,0 +1,204 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// testMP4 builds a minimal MP4 stream of ftyp, moov and mdat boxes
+func testMP4() []byte {
+	return bytes.Join([][]byte{
+		box("ftyp", []byte("isom"), u32(0)),
+		box("moov", box("mvhd", make([]byte, 20))),
+		box("mdat", []byte("video data")),
+	}, nil)
+}
+
+// samsungTrailer appends a Samsung trailer to image: one data block with the given name and
+// payload, the SEFH directory listing it, the directory size and the SEFT footer
+func samsungTrailer(image []byte, name string, payload []byte) []byte {
+	block := append([]byte{0, 0, 0x30, 0x0A}, u32le(uint32(len(name)))...)
+	block = append(append(block, name...), payload...)
+
+	dir := append([]byte("SEFH"), u32le(106)...)
+	dir = append(dir, u32le(1)...)
+	dir = append(dir, 0, 0, 0x30, 0x0A)
+	dir = append(dir, u32le(uint32(len(block)))...)
+	dir = append(dir, u32le(uint32(len(block)))...)
+
+	return bytes.Join([][]byte{image, block, dir, u32le(uint32(len(dir))), []byte("SEFT")}, nil)
+}
+
+// u32le encodes v as a little-endian uint32
+func u32le(v uint32) []byte {
+	return binary.LittleEndian.AppendUint32(nil, v)
+}
+
+// writeTestFile writes data to a file in a temporary directory and returns its path
+func writeTestFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestXMPMotionPhotoLength(t *testing.T) {
+	tests := []struct {
+		name       string
+		xmp        string
+		wantLength int64
+		wantFormat string
+	}{
+		{"container item", `<rdf:li rdf:parseType="Resource"><Container:Item Item:Mime="video/mp4" Item:Semantic="MotionPhoto" Item:Length="1234"/></rdf:li>`, 1234, "xmp_container"},
+		{"container item with padding", `<rdf:li><Container:Item Item:Semantic="MotionPhoto" Item:Length="1000" Item:Padding="8"/></rdf:li>`, 1008, "xmp_container"},
+		{"micro video attribute", `GCamera:MicroVideo="1" GCamera:MicroVideoOffset="5678"`, 5678, "micro_video"},
+		{"micro video element", `<GCamera:MicroVideoOffset>42</GCamera:MicroVideoOffset>`, 42, "micro_video"},
+		{"primary item only", `<rdf:li><Container:Item Item:Semantic="Primary" Item:Length="0"/></rdf:li>`, 0, ""},
+		{"container item without length", `<rdf:li><Container:Item Item:Semantic="MotionPhoto"/></rdf:li>`, 0, ""},
+		{"no XMP", "plain JPEG data", 0, ""},
+		{"empty", "", 0, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			length, format := xmpMotionPhotoLength([]byte(tt.xmp))
+			if length != tt.wantLength || format != tt.wantFormat {
+				t.Errorf("xmpMotionPhotoLength() = %d, %q; want %d, %q", length, format, tt.wantLength, tt.wantFormat)
+			}
+		})
+	}
+}
+
+func TestFindMarker(t *testing.T) {
+	marker := []byte("MotionPhoto_Data")
+	// Place a marker so that it straddles the 1 MB chunk boundary counted from the end
+	straddling := make([]byte, 3*1024*1024)
+	straddleAt := len(straddling) - 1024*1024 - 5
+	copy(straddling[straddleAt:], marker)
+
+	tests := []struct {
+		name  string
+		data  []byte
+		limit int64
+		want  int64
+	}{
+		{"at the start", append(append([]byte{}, marker...), 1, 2, 3), 1 << 20, 0},
+		{"last of two", append(append(append([]byte("xx"), marker...), 'y'), marker...), 1 << 20, 19},
+		{"across a chunk boundary", straddling, 64 << 20, int64(straddleAt)},
+		{"before the scanned tail", straddling, 1024 * 1024, -1},
+		{"partly in the scanned tail", append(append([]byte("xxxx"), marker...), 1, 2), 10, -1},
+		{"not present", []byte("no marker in here"), 1 << 20, -1},
+		{"shorter than the marker", []byte("Motion"), 1 << 20, -1},
+		{"empty", nil, 1 << 20, -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := findMarker(bytes.NewReader(tt.data), int64(len(tt.data)), marker, tt.limit)
+			if got != tt.want {
+				t.Errorf("findMarker() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMP4PayloadLength(t *testing.T) {
+	mp4 := testMP4()
+
+	tests := []struct {
+		name   string
+		data   []byte
+		offset int64
+		want   int64
+	}{
+		{"whole stream", mp4, 0, int64(len(mp4))},
+		{"after a prefix", append([]byte("JPEG"), mp4...), 4, int64(len(mp4))},
+		{"followed by a binary trailer", append(append([]byte{}, mp4...), 0, 0, 0, 16, 0xFF, 0xFE, 0, 1, 1, 2, 3, 4, 5, 6, 7, 8), 0, int64(len(mp4))},
+		{"truncated last box", mp4[:len(mp4)-3], 0, int64(len(mp4) - len(box("mdat", []byte("video data"))))},
+		{"no ftyp", box("moov"), 0, 0},
+		{"offset past the end", mp4, int64(len(mp4)) + 10, 0},
+		{"shorter than a header", []byte{0, 0, 0}, 0, 0},
+		{"ftyp size invalid", []byte{0, 0, 0, 99, 'f', 't', 'y', 'p'}, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mp4PayloadLength(bytes.NewReader(tt.data), tt.offset, int64(len(tt.data)))
+			if got != tt.want {
+				t.Errorf("mp4PayloadLength() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindEmbeddedVideo(t *testing.T) {
+	jpeg := []byte("\xFF\xD8\xFF\xE1 still image data \xFF\xD9")
+	mp4 := testMP4()
+	xmp := func(length int) []byte {
+		return []byte(`<rdf:li><Container:Item Item:Semantic="MotionPhoto" Item:Length="` + strconv.Itoa(length) + `"/></rdf:li>`)
+	}
+	join := func(parts ...[]byte) []byte { return bytes.Join(parts, nil) }
+
+	tests := []struct {
+		name       string
+		data       []byte
+		wantOffset int64
+		wantLength int64
+		wantFormat string
+	}{
+		{"xmp container", join(jpeg, xmp(len(mp4)), mp4), int64(len(jpeg) + len(xmp(len(mp4)))), int64(len(mp4)), "xmp_container"},
+		{"samsung trailer", samsungTrailer(jpeg, "MotionPhoto_Data", mp4), int64(len(jpeg) + 8 + len(samsungMotionPhotoMarker)), int64(len(mp4)), "samsung"},
+		{"xmp length wrong, samsung trailer", samsungTrailer(join(jpeg, xmp(5)), "MotionPhoto_Data", mp4), int64(len(jpeg) + len(xmp(5)) + 8 + len(samsungMotionPhotoMarker)), int64(len(mp4)), "samsung"},
+		{"samsung trailer with a broken directory", join(jpeg, samsungMotionPhotoMarker, mp4, u32le(4), []byte("SEFT")), int64(len(jpeg) + len(samsungMotionPhotoMarker)), int64(len(mp4)), "samsung"},
+		{"samsung trailer without video", samsungTrailer(jpeg, "Image_UTC_Data", []byte("1681553472000")), 0, 0, ""},
+		{"marker without samsung trailer", join(jpeg, samsungMotionPhotoMarker, mp4), 0, 0, ""},
+		{"xmp length larger than the file", join(jpeg, xmp(1<<20), mp4), 0, 0, ""},
+		{"marker without MP4", samsungTrailer(jpeg, "MotionPhoto_Data", []byte("garbage")), 0, 0, ""},
+		{"marker with truncated ftyp", samsungTrailer(jpeg, "MotionPhoto_Data", mp4[:6]), 0, 0, ""},
+		{"directory size larger than the file", join(jpeg, u32le(1<<20), []byte("SEFT")), 0, 0, ""},
+		{"plain image", jpeg, 0, 0, ""},
+		{"empty file", nil, 0, 0, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			video, err := FindEmbeddedVideo(writeTestFile(t, "motion.jpg", tt.data))
+			if err != nil {
+				t.Fatalf("FindEmbeddedVideo() error = %v", err)
+			}
+			if tt.wantFormat == "" {
+				if video != nil {
+					t.Errorf("FindEmbeddedVideo() = %+v, want nil", video)
+				}
+				return
+			}
+			if video == nil {
+				t.Fatal("FindEmbeddedVideo() = nil, want a video")
+			}
+			if video.Offset != tt.wantOffset || video.Length != tt.wantLength || video.Format != tt.wantFormat {
+				t.Errorf("FindEmbeddedVideo() = %+v, want offset %d length %d format %s", video, tt.wantOffset, tt.wantLength, tt.wantFormat)
+			}
+		})
+	}
+
+	if _, err := FindEmbeddedVideo(filepath.Join(t.TempDir(), "missing.jpg")); err == nil {
+		t.Error("FindEmbeddedVideo() on a missing file returned no error")
+	}
+}
+
+func TestCanEmbedVideo(t *testing.T) {
+	for file, want := range map[string]bool{
+		"a.jpg": true, "b.JPEG": true, "c.heic": true, "d.HEIF": true,
+		"e.png": false, "f.dng": false, "g.gif": false, "h.tiff": false, "noext": false,
+	} {
+		if got := CanEmbedVideo(file); got != want {
+			t.Errorf("CanEmbedVideo(%q) = %v, want %v", file, got, want)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package core
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -12,108 +13,481 @@ import (
 )
 
 
+// errUnknownFileType is returned for unknown files when skip_unknown is set; these files
+// are filtered rather than failed
+var errUnknownFileType = errors.New("skipping unknown file type")
+
 // FileOrganizer handles the actual file organization logic
 type FileOrganizer struct {
 	config   *config.Config
 	destDir  string
 	detector *FileTypeDetector
+	rules    *RuleEngine
+	metadata *metadataOptions
 	db       *Database
 	logger   *Logger
+
+	audioCategories *AudioCategorizer
+	origins         map[string]string // Extracted file -> origin inside its archive
+	manifest        *Manifest         // Decisions of the session, for the reports
+	takeoutRecorded map[string]bool   // Takeout JSON files already recorded (originals and edited copies share one)
+
+	dateSourceCounts map[DateSource]int64
 }
 
 // NewFileOrganizer creates a new file organizer
-func NewFileOrganizer(cfg *config.Config, destDir string, db *Database, logger *Logger) *FileOrganizer {
+func NewFileOrganizer(cfg *config.Config, destDir string, db *Database, logger *Logger) (*FileOrganizer, error) {
+	rules, err := NewRuleEngine(cfg.Rules)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rules configuration: %w", err)
+	}
+
+	exifOpts, err := EXIFOptionsFromConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date/time configuration: %w", err)
+	}
+	filenameDates, err := NewFilenameDateParser(cfg, exifOpts.DefaultLocation)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filename dates configuration: %w", err)
+	}
+
+	var geocoder *ReverseGeocoder
+	if cfg.Geocoding.Enabled {
+		if geocoder, err = NewReverseGeocoder(cfg); err != nil {
+			return nil, fmt.Errorf("invalid geocoding configuration: %w", err)
+		}
+	}
+
+	audioCategories, err := NewAudioCategorizer(cfg.AudioCategories)
+	if err != nil {
+		return nil, fmt.Errorf("invalid audio categories configuration: %w", err)
+	}
+
+	if !validRawPairPolicy(cfg.RawPairs.Policy) {
+		return nil, fmt.Errorf("invalid RAW+JPEG pairing policy %q", cfg.RawPairs.Policy)
+	}
+
+	if err := validateExportProfiles(cfg.ExportProfiles); err != nil {
+		return nil, fmt.Errorf("invalid export profiles configuration: %w", err)
+	}
+	if !validPrivacyPolicy(cfg.ExportPrivacy.Policy) {
+		return nil, fmt.Errorf("invalid export privacy policy %q", cfg.ExportPrivacy.Policy)
+	}
+
 	return &FileOrganizer{
 		config:   cfg,
 		destDir:  destDir,
 		detector: NewFileTypeDetectorWithConfig(cfg),
-		db:       db,
-		logger:   logger,
+		rules:    rules,
+		metadata: &metadataOptions{
+			exif:          exifOpts,
+			filenameDates: filenameDates,
+			geocoder:      geocoder,
+		},
+		db:               db,
+		logger:           logger,
+		audioCategories:  audioCategories,
+		takeoutRecorded:  make(map[string]bool),
+		dateSourceCounts: make(map[DateSource]int64),
+	}, nil
+}
+
+// DateSourceCounts returns how many organized files took their date from each source
+func (fo *FileOrganizer) DateSourceCounts() map[DateSource]int64 {
+	counts := make(map[DateSource]int64, len(fo.dateSourceCounts))
+	for source, count := range fo.dateSourceCounts {
+		counts[source] = count
+	}
+	return counts
+}
+
+// SetOrigins registers where files extracted from archives came from (archive.zip!/inner/path).
+// Origins are recorded and logged in place of the extracted paths.
+func (fo *FileOrganizer) SetOrigins(origins map[string]string) {
+	fo.origins = origins
+}
+
+// originOf returns the path a source file is recorded under
+func (fo *FileOrganizer) originOf(path string) string {
+	if origin, ok := fo.origins[path]; ok {
+		return origin
 	}
+	return path
+}
+
+// SetManifest registers where the decisions for grouped files (sidecars, Live Photo videos,
+// RAW files) are recorded; primary files report theirs as the outcome of OrganizeGroup
+func (fo *FileOrganizer) SetManifest(manifest *Manifest) {
+	fo.manifest = manifest
+}
+
+// categoryOf returns the category of a file that has no file context
+func (fo *FileOrganizer) categoryOf(path string) string {
+	return fo.detector.GetFileTypeString(fo.detector.DetectFileType(path))
+}
+
+// skipGrouped logs and records the files grouped with a primary file that is left out
+func (fo *FileOrganizer) skipGrouped(grouped []string, outcome Outcome, reason string) {
+	for _, path := range grouped {
+		fo.logger.LogFileSkipped(fo.originOf(path), reason)
+		fo.recordGrouped(path, "", outcome, reason)
+	}
+}
+
+// recordGrouped records the decision for a file that travels with a primary file
+func (fo *FileOrganizer) recordGrouped(path, destPath string, outcome Outcome, reason string) {
+	fo.manifest.Add(ManifestEntry{
+		Source:      fo.originOf(path),
+		Destination: destPath,
+		Category:    fo.categoryOf(path),
+		Outcome:     outcome,
+		Reason:      reason,
+		Size:        fileSize(path),
+	})
+}
+
+// FileGroup holds the files that travel with a primary file
+type FileGroup struct {
+	Sidecars    []string // Sidecar files (.xmp, .aae, ...) of the primary file
+	LiveVideo   string   // Video half of a Live Photo whose still is the primary file
+	Takeout     string   // Google Takeout JSON metadata of the primary file
+	Raw         string   // RAW file shot together with the primary JPEG
+	RawSidecars []string // Sidecar files of the RAW file
 }
 
 // OrganizeFile processes and organizes a single file
-func (fo *FileOrganizer) OrganizeFile(sourcePath string) error {
+func (fo *FileOrganizer) OrganizeFile(sourcePath string) FileOutcome {
+	return fo.OrganizeGroup(sourcePath, FileGroup{})
+}
+
+// OrganizeGroup organizes a file together with the files grouped with it and returns what
+// happened to the file. Grouped files follow the primary file and are skipped or failed
+// with it when the primary is left out; their own outcomes are recorded in the manifest.
+func (fo *FileOrganizer) OrganizeGroup(sourcePath string, group FileGroup) FileOutcome {
+	origin := fo.originOf(sourcePath)
+	outcome := FileOutcome{ManifestEntry: ManifestEntry{Source: origin}}
+	var takeout string
+	sidecars := group.Sidecars
+	if group.LiveVideo != "" {
+		sidecars = append(sidecars[:len(sidecars):len(sidecars)], group.LiveVideo)
+	}
+	if group.Raw != "" {
+		sidecars = append(sidecars[:len(sidecars):len(sidecars)], group.Raw)
+		sidecars = append(sidecars, group.RawSidecars...)
+	}
+	// A Takeout JSON is recorded with the first file that uses it
+	if group.Takeout != "" && !fo.takeoutRecorded[group.Takeout] {
+		takeout = group.Takeout
+		fo.takeoutRecorded[takeout] = true
+		sidecars = append(sidecars[:len(sidecars):len(sidecars)], takeout)
+	}
+	fail := func(err error) FileOutcome {
+		// Grouped files stay behind with the primary, each recorded as failed
+		for _, path := range sidecars {
+			fo.recordGrouped(path, "", OutcomeFailed, fmt.Sprintf("grouped with %s, which failed", filepath.Base(origin)))
+		}
+		if outcome.Category == "" {
+			outcome.Category = fo.categoryOf(sourcePath)
+		}
+		outcome.Outcome = OutcomeFailed
+		outcome.Reason = err.Error()
+		outcome.Err = err
+		return outcome
+	}
+
 	// Get file info
 	fileInfo, err := os.Stat(sourcePath)
 	if err != nil {
-		return fmt.Errorf("failed to get file info: %w", err)
+		return fail(fmt.Errorf("failed to get file info: %w", err))
 	}
+	outcome.Size = fileInfo.Size()
 
 	// Skip directories
 	if fileInfo.IsDir() {
-		return nil
+		outcome.Category = fo.categoryOf(sourcePath)
+		outcome.Outcome = OutcomeFiltered
+		outcome.Reason = "is a directory"
+		return outcome
 	}
 
 	// Check if file should be skipped
-	if fo.detector.ShouldSkipFile(sourcePath, fo.config.SkipFiles.Extensions, fo.config.SkipFiles.Patterns, fo.config.SkipFiles.Directories) {
-		fo.logger.LogFileSkipped(sourcePath, "matches skip pattern")
-		return nil
+	if fo.detector.ShouldSkipFile(origin, fo.config.SkipFiles.Extensions, fo.config.SkipFiles.Patterns, fo.config.SkipFiles.Directories) {
+		fo.logger.LogFileSkipped(origin, "matches skip pattern")
+		fo.skipGrouped(sidecars, OutcomeFiltered, "grouped with filtered file")
+		outcome.Category = fo.categoryOf(sourcePath)
+		outcome.Outcome = OutcomeFiltered
+		outcome.Reason = "matches skip pattern"
+		return outcome
 	}
 
 	// Calculate file hash for duplicate detection
 	hash, err := calculateFileHash(sourcePath)
 	if err != nil {
-		return fmt.Errorf("failed to calculate file hash: %w", err)
+		return fail(fmt.Errorf("failed to calculate file hash: %w", err))
 	}
 
+	fc := newFileContext(sourcePath, fileInfo, fo.detector, fo.metadata)
+	fc.origin = origin
+	fc.takeoutPath = group.Takeout
+	outcome.Category = fo.detector.GetFileTypeString(fc.fileType)
+
 	// Check for duplicates
 	isDuplicate, existingPath, err := fo.db.CheckDuplicate(hash)
 	if err != nil {
-		return fmt.Errorf("failed to check for duplicates: %w", err)
+		return fail(fmt.Errorf("failed to check for duplicates: %w", err))
 	}
 
 	if isDuplicate {
-		fo.logger.LogFileDuplicate(sourcePath, existingPath, hash)
-		return nil // Skip duplicate files
+		fo.logger.LogFileDuplicate(origin, existingPath, hash, fc.Title())
+		fo.skipGrouped(sidecars, OutcomeSkipped, "grouped with duplicate file")
+		outcome.Outcome = OutcomeDuplicate
+		outcome.Reason = fmt.Sprintf("same content as %s", existingPath)
+		return outcome
 	}
 
-	// Detect file type
-	fileType := fo.detector.DetectFileType(sourcePath)
+	// Classify using the rule list (first match wins)
+	action := RuleActionCopy
 	
-	// Determine destination path
-	destPath, err := fo.getDestinationPath(sourcePath, fileType, fileInfo)
+	var destPath, reason, step string
+	if rule := fo.rules.Match(fc, fo.matchesBuiltin); rule != nil {
+		action = rule.action
+		step = rule.rule.Builtin
+		reason = fmt.Sprintf("matches rule %q", rule.rule.Name)
+		if action == RuleActionSkip {
+			fo.logger.LogFileSkipped(origin, reason)
+			fo.skipGrouped(sidecars, OutcomeSkipped, "grouped with skipped file")
+			outcome.Outcome = OutcomeSkipped
+			outcome.Reason = reason
+			return outcome
+		}
+		destPath, err = fo.getRuleDestinationPath(rule, fc)
+	} else {
+		// No rule matched, fall back to the built-in cascade
+		step = fo.builtinStep(fc)
+		reason = fmt.Sprintf("built-in %q classification", step)
+		destPath, err = fo.getDestinationPath(fc)
+	}
+	if errors.Is(err, errUnknownFileType) {
+		fo.logger.LogFileSkipped(origin, "unknown file type")
+		fo.skipGrouped(sidecars, OutcomeFiltered, "grouped with filtered file")
+		outcome.Outcome = OutcomeFiltered
+		outcome.Reason = "unknown file type"
+		return outcome
+	}
 	if err != nil {
-		return fmt.Errorf("failed to determine destination path: %w", err)
+		return fail(fmt.Errorf("failed to determine destination path: %w", err))
 	}
 
+	// Apply the rename template for this file type, if any
+	destPath = fo.applyRenameTemplate(fc, step, destPath)
+
 	// Create destination directory
 	destDir := filepath.Dir(destPath)
 	if err := os.MkdirAll(destDir, 0755); err != nil {
-		return fmt.Errorf("failed to create destination directory: %w", err)
+		return fail(fmt.Errorf("failed to create destination directory: %w", err))
 	}
 
 	// Handle naming conflicts
 	finalDestPath := fo.resolveNamingConflict(destPath)
 
 	// Copy file to destination
-	if err := fo.copyFile(sourcePath, finalDestPath); err != nil {
-		return fmt.Errorf("failed to copy file: %w", err)
+	if err := fo.copyFile(fc, finalDestPath, action == RuleActionExport); err != nil {
+		return fail(fmt.Errorf("failed to copy file: %w", err))
 	}
 
-	// Add to database
-	if err := fo.db.AddFile(hash, sourcePath, finalDestPath, fileInfo.Size()); err != nil {
-		fo.logger.LogError(LogLevelWarning, "Failed to add file to database", sourcePath, err)
+	// The video of a Live Photo goes next to its still, following any rename
+	var pairedWith, contentID, embeddedVideo string
+	if group.LiveVideo != "" {
+		contentID = fc.ContentIdentifier()
+		pairedWith = fo.placeLiveVideo(sourcePath, finalDestPath, hash, group.LiveVideo, contentID, action == RuleActionMove)
+	}
+
+	// The RAW of a RAW+JPEG pair goes next to its JPEG or into a subfolder there
+	if group.Raw != "" {
+		pairedWith = fo.placeRaw(sourcePath, finalDestPath, hash, group, action == RuleActionMove)
+	}
+
+	// Samsung/Pixel motion photos carry their video inside the image file
+	if fc.fileType == FileTypeImage && fo.config.MotionPhotos.Enabled && CanEmbedVideo(sourcePath) {
+		if video, err := FindEmbeddedVideo(sourcePath); err == nil && video != nil {
+			if fo.config.MotionPhotos.ExtractEmbedded {
+				embeddedVideo = fo.extractEmbeddedVideo(fc, video, finalDestPath, hash)
+			} else {
+				fo.logger.LogOperation("INFO", fmt.Sprintf("Motion photo with embedded video (%s, %d bytes)", video.Format, video.Length), origin)
+			}
+		}
+	}
+
+	// Add to database, keeping the original name for provenance
+	captureDate, dateSource := fc.CaptureDate()
+	record := FileRecord{
+		Hash:              hash,
+		OriginalPath:      origin,
+		OriginalName:      filepath.Base(sourcePath),
+		DestinationPath:   finalDestPath,
+		Size:              fileInfo.Size(),
+		CaptureDate:       captureDate,
+		DateSource:        string(dateSource),
+		PairedWith:        pairedWith,
+		EmbeddedVideo:     embeddedVideo,
+		ContentIdentifier: contentID,
+		Category:          fo.detector.GetFileTypeString(fc.fileType),
+	}
+	record.Make, record.Model = fc.CameraInfo()
+	if place, ok := fc.Place(); ok {
+		record.City = place.City
+		record.Country = place.Country
+	}
+	if takeout := fc.Takeout(); takeout != nil {
+		record.Description = takeout.Description
+	}
+	if document := fc.Document(); document != nil {
+		record.Title = document.Title
+		record.Author = document.Author
+	}
+	if gps, ok := fc.GPS(); ok {
+		record.GPS = &gps
+	}
+	if err := fo.db.AddRecord(record); err != nil {
+		fo.logger.LogError(LogLevelWarning, "Failed to add file to database", origin, err)
 		// Don't fail the operation if database update fails
 	}
 
+	// Sidecars go next to the primary, following any rename
+	if len(group.Sidecars) > 0 {
+		fo.placeSidecars(sourcePath, finalDestPath, hash, group.Sidecars, action == RuleActionMove)
+	}
+
+	// The Takeout JSON is consumed rather than copied, its metadata went into the record
+	if takeout != "" {
+		fo.recordGrouped(takeout, "", OutcomeOrganized, fmt.Sprintf("Takeout metadata of %s", filepath.Base(sourcePath)))
+	}
+
+	// Remove the source only after the copy is safely recorded
+	if action == RuleActionMove {
+		if err := os.Remove(sourcePath); err != nil {
+			fo.logger.LogError(LogLevelWarning, "Failed to remove source after move", sourcePath, err)
+		}
+	}
+
 	// Log successful processing
-	fo.logger.LogFileProcessed(sourcePath, finalDestPath, hash, fileInfo.Size())
+	if action != RuleActionCopy {
+		reason += fmt.Sprintf(", %s", action)
+	}
+	if dateSource != DateSourceNone {
+		reason += fmt.Sprintf(", date from %s", dateSource)
+	}
+	fo.logger.LogFileProcessed(origin, finalDestPath, hash, fileInfo.Size())
+	if dateSource != DateSourceNone {
+		fo.dateSourceCounts[dateSource]++
+		fo.logger.LogOperation("INFO", fmt.Sprintf("Capture date %s from %s", captureDate.Format("2006-01-02 15:04:05"), dateSource), origin)
+	}
+
+	outcome.Outcome = OutcomeOrganized
+	outcome.Reason = reason
+	outcome.Destination = finalDestPath
+	outcome.Camera = cameraName(record.Make, record.Model)
+	if !captureDate.IsZero() {
+		outcome.Date = captureDate.Format("2006-01-02")
+	}
+	return outcome
+}
+
+// getRuleDestinationPath determines the destination for a file matched by a rule
+func (fo *FileOrganizer) getRuleDestinationPath(rule *compiledRule, fc *fileContext) (string, error) {
+	if rule.rule.Destination != "" {
+		values := fo.fileTemplateValues(fc)
+		return filepath.Join(fo.destDir, fo.expandTemplate(rule.rule.Destination, values), filepath.Base(fc.path)), nil
+	}
+	if rule.rule.Builtin != "" {
+		return fo.builtinDestination(rule.rule.Builtin, fc)
+	}
+	// Rules without a destination only change the action, placement stays built-in
+	return fo.getDestinationPath(fc)
+}
+
+// getDestinationPath determines where a file should be placed using the built-in cascade
+func (fo *FileOrganizer) getDestinationPath(fc *fileContext) (string, error) {
+	step := fo.builtinStep(fc)
+	if step == "" {
+		return "", fmt.Errorf("no classification matched: %s", filepath.Base(fc.path))
+	}
+	return fo.builtinDestination(step, fc)
+}
 
-	return nil
+// builtinStep returns the first built-in classification step that applies to a file
+func (fo *FileOrganizer) builtinStep(fc *fileContext) string {
+	for _, step := range builtinSteps {
+		if fo.matchesBuiltin(step, fc) {
+			return step
+		}
+	}
+	return ""
+}
+
+// matchesBuiltin reports whether a built-in classification step applies to a file
+func (fo *FileOrganizer) matchesBuiltin(step string, fc *fileContext) bool {
+	// Hidden files are handled before everything else, so later steps exclude them
+	if step == BuiltinHidden {
+		return fc.hidden
+	}
+	if fc.hidden {
+		return false
+	}
+
+	switch step {
+	case BuiltinScreenshot:
+		return fo.detector.IsScreenshot(fc.path)
+	case BuiltinEdited:
+		if fc.fileType != FileTypeImage {
+			return false
+		}
+		exifData, err := fc.EXIF()
+		return err == nil && IsEditedImage(exifData, fo.config)
+	case BuiltinImage:
+		return fc.fileType == FileTypeImage
+	case BuiltinMotionPhoto:
+		if fc.fileType != FileTypeVideo {
+			return false
+		}
+		if !fo.detector.IsMotionPhoto(fc.path) && fc.ContentIdentifier() == "" {
+			return false
+		}
+		// Motion photo clips are a few seconds long, anything longer is a regular video
+		maxDuration := fo.config.MotionPhotos.MaxDurationSeconds
+		if maxDuration > 0 {
+			if duration, err := fc.Duration(); err == nil && duration > time.Duration(maxDuration)*time.Second {
+				return false
+			}
+		}
+		return true
+	case BuiltinShortVideo:
+		if fc.fileType != FileTypeVideo || fo.config.Processing.ShortVideoThreshold <= 0 {
+			return false
+		}
+		duration, err := fc.Duration()
+		return err == nil && duration < time.Duration(fo.config.Processing.ShortVideoThreshold)*time.Second
+	case BuiltinVideo:
+		return fc.fileType == FileTypeVideo
+	case BuiltinAudio:
+		return fc.fileType == FileTypeAudio
+	case BuiltinDocument:
+		return fc.fileType == FileTypeDocument
+	case BuiltinUnknown:
+		return fc.fileType == FileTypeUnknown
+	}
+	return false
 }
 
-// getDestinationPath determines where a file should be placed
-func (fo *FileOrganizer) getDestinationPath(sourcePath string, fileType FileType, fileInfo os.FileInfo) (string, error) {
+// builtinDestination returns the built-in placement of a classification step
+func (fo *FileOrganizer) builtinDestination(step string, fc *fileContext) (string, error) {
+	sourcePath := fc.path
 	filename := filepath.Base(sourcePath)
-	
-	// Check if file is hidden
-	isHidden := fo.detector.IsHiddenFile(sourcePath)
-	
-	// Handle hidden files first (common case optimization)
-	if isHidden {
+
+	switch step {
+	case BuiltinHidden:
 		var categoryDir string
-		switch fileType {
+		switch fc.fileType {
 		case FileTypeImage:
 			categoryDir = fo.config.Directories.Images
 		case FileTypeVideo:
@@ -125,83 +499,224 @@ func (fo *FileOrganizer) getDestinationPath(sourcePath string, fileType FileType
 		default:
 			// Check if unknown files should be skipped in hidden files too
 			if fo.config.SkipUnknown {
-				return "", fmt.Errorf("skipping unknown hidden file type: %s", filename)
+				return "", fmt.Errorf("%w (hidden): %s", errUnknownFileType, filename)
 			}
 			categoryDir = fo.config.Directories.Unknown
 		}
 		return filepath.Join(fo.destDir, categoryDir, fo.config.Directories.Hidden, filename), nil
-	}
 
-	// Handle non-hidden files by type
-	switch fileType {
-	case FileTypeImage:
+	case BuiltinScreenshot:
+		// Organize screenshots: Images/Screenshots/
+		return filepath.Join(fo.destDir, fo.config.Directories.Images, fo.config.Screenshots.FolderName, filename), nil
+
+	case BuiltinEdited:
+		return filepath.Join(fo.destDir, fo.config.Directories.Images, fo.config.EditedImages.FolderName, filename), nil
+
+	case BuiltinImage:
 		categoryDir := fo.config.Directories.Images
 		
-		// Check if it's a screenshot first
-		if fo.detector.IsScreenshot(sourcePath) {
-			// Organize screenshots: Images/Screenshots/
-			return filepath.Join(fo.destDir, categoryDir, fo.config.Screenshots.FolderName, filename), nil
-		}
-		
 		// For regular images, use EXIF-based organization
-		exifData, err := ExtractEXIF(sourcePath)
+		exifData, err := fc.EXIF()
 		if err != nil {
 			// No EXIF data, use collections folder
 			return filepath.Join(fo.destDir, categoryDir, fo.config.ImageDirs.Originals, "Collections", filename), nil
 		}
 		
-		// Check if image has been edited (contains photo editing software in EXIF)
-		if IsEditedImage(exifData, fo.config) {
-			return filepath.Join(fo.destDir, categoryDir, fo.config.EditedImages.FolderName, filename), nil
+		// Use the configured layout below Originals if a template is set
+		if template := fo.config.PathTemplates.Images; template != "" {
+			values := fo.fileTemplateValues(fc)
+			return filepath.Join(fo.destDir, categoryDir, fo.config.ImageDirs.Originals, fo.expandTemplate(template, values), filename), nil
 		}
-		
+
+		// Dates inferred from the filename replace the no-EXIF year folder
+		if captureDate, source := fc.CaptureDate(); source == DateSourceFilename || source == DateSourceTakeout {
+			if exifData.Make == "" || exifData.Model == "" {
+				return filepath.Join(fo.destDir, categoryDir, fo.config.ImageDirs.Originals, "Collections", fo.extractYear(captureDate), filename), nil
+			}
+			dated := *exifData
+			dated.DateTime = captureDate
+			dated.HasDateTime = true
+			return GetImageDestinationPath(fo.destDir, filename, &dated, fo.config, false), nil
+		}
+
 		return GetImageDestinationPath(fo.destDir, filename, exifData, fo.config, false), nil
-		
-	case FileTypeVideo:
+
+	case BuiltinMotionPhoto:
+		// Organize Motion Photos: Videos/Motion Photos/Year/
+		captureDate, _ := fc.CaptureDate()
+		year := fo.extractYear(captureDate)
+		return filepath.Join(fo.destDir, fo.config.Directories.Videos, "Motion Photos", year, filename), nil
+
+	case BuiltinShortVideo:
+		// Organize Short Videos: Videos/Short Videos/Year/
+		captureDate, _ := fc.CaptureDate()
+		year := fo.extractYear(captureDate)
+		return filepath.Join(fo.destDir, fo.config.Directories.Videos, "Short Videos", year, filename), nil
+
+	case BuiltinVideo:
 		categoryDir := fo.config.Directories.Videos
-		year := fo.extractYear(fileInfo.ModTime())
 		
-		// Special handling for Motion Photos (video files with specific patterns)
-		if fo.detector.IsMotionPhoto(sourcePath) {
-			// Organize Motion Photos: Videos/Motion Photos/Year/
-			return filepath.Join(fo.destDir, categoryDir, "Motion Photos", year, filename), nil
+		// Regular video organization by year (or configured template)
+		if template := fo.config.PathTemplates.Videos; template != "" {
+			values := fo.fileTemplateValues(fc)
+			return filepath.Join(fo.destDir, categoryDir, fo.expandTemplate(template, values), filename), nil
 		}
-		
-		// Check if it's a short video (after Live Photo detection)
-		if fo.config.Processing.ShortVideoThreshold > 0 {
-			videoAnalyzer := NewVideoAnalyzer()
-			if videoAnalyzer.IsShortVideo(sourcePath, fo.config.Processing.ShortVideoThreshold) {
-				// Organize Short Videos: Videos/Short Videos/Year/
-				return filepath.Join(fo.destDir, categoryDir, "Short Videos", year, filename), nil
-			}
+		captureDate, _ := fc.CaptureDate()
+		year := fo.extractYear(captureDate)
+
+		// Videos with camera metadata mirror the image layout: Videos/Make - Model/Year/
+		if cameraMake, cameraModel := fc.CameraInfo(); cameraMake != "" && cameraModel != "" {
+			cameraDir := sanitizePathSegment(fmt.Sprintf("%s - %s", cameraMake, cameraModel))
+			return filepath.Join(fo.destDir, categoryDir, cameraDir, year, filename), nil
 		}
-		
-		// Regular video organization by year
 		return filepath.Join(fo.destDir, categoryDir, year, filename), nil
-		
-	case FileTypeAudio:
+
+	case BuiltinAudio:
 		categoryDir := fo.config.Directories.Audios
+		if template := fo.config.PathTemplates.Audios; template != "" {
+			values := fo.fileTemplateValues(fc)
+			return filepath.Join(fo.destDir, categoryDir, fo.expandTemplate(template, values), filename), nil
+		}
+		// Tagged music goes to Songs/Artist/Album/NN - Title.ext
+		if tags := fc.AudioTags(); tags.IsMusic() {
+			return fo.songDestination(tags, filename), nil
+		}
 		// Categorize audio files
-		audioCategory := fo.categorizeAudio(filename)
+		audioCategory := fo.categorizeAudio(fc)
 		return filepath.Join(fo.destDir, categoryDir, audioCategory, filename), nil
-		
-	case FileTypeDocument:
+
+	case BuiltinDocument:
 		categoryDir := fo.config.Directories.Documents
+		if template := fo.config.PathTemplates.Documents; template != "" {
+			values := fo.fileTemplateValues(fc)
+			return filepath.Join(fo.destDir, categoryDir, fo.expandTemplate(template, values), filename), nil
+		}
 		// Organize by file extension
 		ext := strings.ToUpper(strings.TrimPrefix(filepath.Ext(filename), "."))
 		if ext == "" {
 			ext = "Other Documents"
 		}
 		return filepath.Join(fo.destDir, categoryDir, ext, filename), nil
-		
-	default:
+
+	case BuiltinUnknown:
 		// Check if unknown files should be skipped
 		if fo.config.SkipUnknown {
-			return "", fmt.Errorf("skipping unknown file type: %s", filename)
+			return "", fmt.Errorf("%w: %s", errUnknownFileType, filename)
 		}
 		categoryDir := fo.config.Directories.Unknown
+		if template := fo.config.PathTemplates.Unknown; template != "" {
+			values := fo.fileTemplateValues(fc)
+			return filepath.Join(fo.destDir, categoryDir, fo.expandTemplate(template, values), filename), nil
+		}
 		return filepath.Join(fo.destDir, categoryDir, filename), nil
 	}
+
+	return "", fmt.Errorf("unknown builtin step: %s", step)
+}
+
+// fileTemplateValues collects the placeholder values for a file.
+// Dates come from the best available source (see fileContext.CaptureDate); images
+// without an embedded date use the date fallbacks rather than the copy date.
+func (fo *FileOrganizer) fileTemplateValues(fc *fileContext) map[string]string {
+	values := map[string]string{
+		"ext":        strings.ToUpper(strings.TrimPrefix(filepath.Ext(fc.path), ".")),
+		"source_dir": strings.TrimSuffix(filepath.Base(filepath.Dir(fc.origin)), "!"),
+	}
+
+	values["make"], values["model"] = fc.CameraInfo()
+	captureDate, source := fc.CaptureDate()
+	setTemplateDate(values, captureDate, source != DateSourceNone)
+
+	if place, ok := fc.Place(); ok {
+		values["country"] = place.Country
+		values["country_code"] = place.CountryCode
+		values["city"] = place.City
+	}
+
+	if fc.fileType == FileTypeAudio {
+		values["audio_category"] = fo.categorizeAudio(fc)
+		if tags := fc.AudioTags(); tags != nil {
+			values["artist"] = tags.FolderArtist()
+			values["album"] = tags.Album
+			values["title"] = tags.Title
+			values["genre"] = tags.Genre
+			if tags.Track > 0 {
+				values["track"] = fmt.Sprintf("%02d", tags.Track)
+			}
+		}
+	}
+
+	if document := fc.Document(); document != nil {
+		values["title"] = document.Title
+		values["author"] = document.Author
+	}
+
+	return values
+}
+
+// applyRenameTemplate renames a file according to the rename template of its type.
+// step is the built-in classification step that placed the file ("" for rules without one);
+// the originals template only renames images classified as originals. Hidden files keep
+// their names. With a {counter} placeholder the lowest free counter
+// in the destination folder is used, so the numbering is stable and gap-free.
+func (fo *FileOrganizer) applyRenameTemplate(fc *fileContext, step, destPath string) string {
+	if fc.hidden {
+		return destPath
+	}
+
+	var template string
+	switch fc.fileType {
+	case FileTypeImage:
+		// Screenshots and edited images keep their names; rules without a built-in step
+		// are checked against the cascade
+		if step == "" {
+			step = fo.builtinStep(fc)
+		}
+		if step == BuiltinImage {
+			template = fo.config.Rename.Originals
+		}
+	case FileTypeVideo:
+		template = fo.config.Rename.Videos
+	case FileTypeAudio:
+		template = fo.config.Rename.Audio
+	}
+	if template == "" {
+		return destPath
+	}
+
+	dir := filepath.Dir(destPath)
+	ext := filepath.Ext(destPath)
+	values := fo.fileTemplateValues(fc)
+	values["name"] = strings.TrimSuffix(filepath.Base(fc.path), filepath.Ext(fc.path))
+
+	if !strings.Contains(template, "{counter}") {
+		name := ExpandNameTemplate(template, values, fo.config.PathTemplates.Fallbacks)
+		if name == "" {
+			return destPath
+		}
+		return filepath.Join(dir, name+ext)
+	}
+
+	digits := fo.config.Rename.CounterDigits
+	if digits <= 0 {
+		digits = 3
+	}
+	for counter := 1; ; counter++ {
+		values["counter"] = fmt.Sprintf("%0*d", digits, counter)
+		name := ExpandNameTemplate(template, values, fo.config.PathTemplates.Fallbacks)
+		if name == "" {
+			return destPath
+		}
+		candidate := filepath.Join(dir, name+ext)
+		if _, err := os.Stat(candidate); os.IsNotExist(err) {
+			return candidate
+		}
+	}
+}
+
+// expandTemplate expands a path template using the configured fallbacks
+func (fo *FileOrganizer) expandTemplate(template string, values map[string]string) string {
+	return ExpandPathTemplate(template, values, fo.config.PathTemplates.Fallbacks)
 }
 
 // extractYear extracts year from file modification time
@@ -212,69 +727,42 @@ func (fo *FileOrganizer) extractYear(modTime time.Time) string {
 	return fmt.Sprintf("%04d", modTime.Year())
 }
 
-// categorizeAudio categorizes audio files based on configurable patterns and extensions
-func (fo *FileOrganizer) categorizeAudio(filename string) string {
-	lowerName := strings.ToLower(filename)
-	ext := strings.ToLower(filepath.Ext(filename))
-	
-	// First pass: Check extension + pattern combinations (most specific)
-	for _, category := range fo.config.AudioCategories {
-		// Quick extension check first
-		hasMatchingExt := false
-		for _, configExt := range category.Extensions {
-			if ext == strings.ToLower(configExt) {
-				hasMatchingExt = true
-				break
-			}
-		}
-		
-		if hasMatchingExt {
-			// Extension matches - check patterns if any
-			if len(category.Patterns) == 0 {
-				// No patterns required, extension match is sufficient
-				return category.FolderName
-			}
-			
-			// Check patterns for this category
-			for _, pattern := range category.Patterns {
-				if strings.Contains(lowerName, strings.ToLower(pattern)) {
-					return category.FolderName
-				}
-			}
-		}
-	}
-	
-	// Second pass: Pattern-only matching (fallback)
-	for _, category := range fo.config.AudioCategories {
-		for _, pattern := range category.Patterns {
-			if strings.Contains(lowerName, strings.ToLower(pattern)) {
-				return category.FolderName
-			}
+// songDestination places a tagged song as Songs/{artist}/{album}/{track} - {title}
+func (fo *FileOrganizer) songDestination(tags *AudioTags, filename string) string {
+	ext := filepath.Ext(filename)
+	name := filename
+	if tags.Title != "" {
+		name = tags.Title + ext
+		if tags.Track > 0 {
+			name = fmt.Sprintf("%02d - %s%s", tags.Track, tags.Title, ext)
 		}
 	}
-	
-	// Third pass: Extension-only matching for categories without patterns
-	for _, category := range fo.config.AudioCategories {
-		if len(category.Patterns) == 0 {
-			for _, configExt := range category.Extensions {
-				if ext == strings.ToLower(configExt) {
-					return category.FolderName
-				}
-			}
-		}
+
+	album := tags.Album
+	if album == "" {
+		album = "Unknown Album"
 	}
-	
-	// Default fallbacks with early returns
-	if songsCategory, exists := fo.config.AudioCategories["songs"]; exists {
+
+	return filepath.Join(fo.destDir, fo.config.Directories.Audios, fo.songsFolder(),
+		sanitizePathSegment(tags.FolderArtist()), sanitizePathSegment(album), sanitizePathSegment(name))
+}
+
+// songsFolder returns the folder name of the songs audio category
+func (fo *FileOrganizer) songsFolder() string {
+	if songsCategory := fo.config.AudioCategories.Find("songs"); songsCategory != nil && songsCategory.FolderName != "" {
 		return songsCategory.FolderName
 	}
-	
-	// Return first available category
-	for _, category := range fo.config.AudioCategories {
-		return category.FolderName
+	return "Songs"
+}
+
+// categorizeAudio categorizes audio files based on their tags and the configured audio categories.
+// Categories are checked in priority order so the result never depends on configuration order alone.
+func (fo *FileOrganizer) categorizeAudio(fc *fileContext) string {
+	// Tagged music is a song whatever its file name says ("Phone Call" by a band)
+	if fc.AudioTags().IsMusic() {
+		return fo.songsFolder()
 	}
-	
-	return "Songs" // Ultimate fallback
+	return fo.audioCategories.Categorize(fc)
 }
 
 // resolveNamingConflict handles file naming conflicts by appending " -- n"
@@ -300,8 +788,10 @@ func (fo *FileOrganizer) resolveNamingConflict(destPath string) string {
 	}
 }
 
-// copyFile copies a file from source to destination with image processing support
-func (fo *FileOrganizer) copyFile(src, dst string) error {
+// copyFile copies a file to its destination with image processing support.
+// forceExport creates an image export even when exports are disabled in the config.
+func (fo *FileOrganizer) copyFile(fc *fileContext, dst string, forceExport bool) error {
+	src := fc.path
 	// Check if this is an image file that needs special processing
 	if IsImageFile(src) {
 		// Check if this is a hidden file - hidden images should not be exported
@@ -312,16 +802,22 @@ func (fo *FileOrganizer) copyFile(src, dst string) error {
 		}
 		
 		// Extract EXIF data for image processing
-		exifData, err := ExtractEXIF(src)
-		if err != nil {
+		exifData, err := fc.EXIF()
+		if err != nil || exifData == nil {
 			// If EXIF extraction fails, fall back to regular copy
 			fo.logger.LogError(LogLevelWarning, "EXIF extraction failed, using regular copy", src, err)
 			return fo.regularCopy(src, dst)
 		}
-		
+
+		// Exports are dated like their original, which may be dated by its Takeout JSON or name
+		exportData := *exifData
+		captureDate, source := fc.CaptureDate()
+		exportData.DateTime, exportData.HasDateTime = captureDate, source != DateSourceNone
+
 		// Use image processor for images
-		imageProcessor := NewImageProcessor(fo.config)
-		return imageProcessor.ProcessImage(src, dst, exifData)
+		imageProcessor := NewImageProcessor(fo.config, fo.destDir)
+		imageProcessor.forceExport = forceExport
+		return imageProcessor.ProcessImage(src, dst, &exportData)
 	}
 	
 	// Regular file copy for non-images
@@ -0,0 +1,170 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"zensort/internal/config"
+)
+
+// newTestOrganizer returns an organizer writing into destDir and recording into a manifest
+func newTestOrganizer(t *testing.T, cfg *config.Config, destDir string) (*FileOrganizer, *Manifest) {
+	t.Helper()
+	db, err := NewDatabase(destDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	logger, err := NewLogger(destDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { logger.Close() })
+
+	organizer, err := NewFileOrganizer(cfg, destDir, db, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest := NewManifest()
+	organizer.SetManifest(manifest)
+	return organizer, manifest
+}
+
+func TestOrganizeGroupRecordsGroupedFilesOfFailedPrimary(t *testing.T) {
+	srcDir := t.TempDir()
+	group := FileGroup{
+		Sidecars:    []string{writeTestFile(t, "IMG_0001.xmp", []byte("<x/>"))},
+		LiveVideo:   writeTestFile(t, "IMG_0001.mov", testMP4()),
+		Takeout:     writeTestFile(t, "IMG_0001.jpg.json", []byte("{}")),
+		Raw:         writeTestFile(t, "IMG_0001.cr2", []byte("raw")),
+		RawSidecars: []string{writeTestFile(t, "IMG_0001.cr2.xmp", []byte("<x/>"))},
+	}
+	organizer, manifest := newTestOrganizer(t, config.DefaultConfig(), t.TempDir())
+
+	// The primary is gone by the time it is organized
+	outcome := organizer.OrganizeGroup(filepath.Join(srcDir, "IMG_0001.jpg"), group)
+	if outcome.Outcome != OutcomeFailed {
+		t.Fatalf("OrganizeGroup() = %v, want failed", outcome.Outcome)
+	}
+
+	var recorded []string
+	for _, entry := range manifest.Entries() {
+		recorded = append(recorded, filepath.Base(entry.Source))
+		if entry.Outcome != OutcomeFailed || !strings.Contains(entry.Reason, "IMG_0001.jpg") {
+			t.Errorf("%s = %v (%s), want failed with the primary", entry.Source, entry.Outcome, entry.Reason)
+		}
+	}
+	sort.Strings(recorded)
+	want := []string{"IMG_0001.cr2", "IMG_0001.cr2.xmp", "IMG_0001.jpg.json", "IMG_0001.mov", "IMG_0001.xmp"}
+	if strings.Join(recorded, " ") != strings.Join(want, " ") {
+		t.Errorf("recorded %v, want %v", recorded, want)
+	}
+}
+
+func TestOrganizeGroupKeepsRawPairingOfMotionPhoto(t *testing.T) {
+	still := writeTestFile(t, "IMG_0002.jpg", samsungTrailer([]byte("\xFF\xD8\xFF\xD9"), "MotionPhoto_Data", testMP4()))
+	group := FileGroup{Raw: filepath.Join(filepath.Dir(still), "IMG_0002.dng")}
+	if err := os.WriteFile(group.Raw, []byte("raw"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	organizer, manifest := newTestOrganizer(t, config.DefaultConfig(), t.TempDir())
+
+	if outcome := organizer.OrganizeGroup(still, group); outcome.Outcome != OutcomeOrganized {
+		t.Fatalf("OrganizeGroup() = %v (%v), want organized", outcome.Outcome, outcome.Err)
+	}
+
+	records, err := organizer.db.Records()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashes := map[string]string{}
+	var image FileRecord
+	for _, record := range records {
+		hashes[filepath.Ext(record.DestinationPath)] = record.Hash
+		if filepath.Ext(record.DestinationPath) == ".jpg" {
+			image = record
+		}
+	}
+	if image.PairedWith == "" || image.PairedWith != hashes[".dng"] {
+		t.Errorf("image paired with %q, want the RAW %q", image.PairedWith, hashes[".dng"])
+	}
+	if image.EmbeddedVideo == "" || image.EmbeddedVideo != hashes[".mp4"] {
+		t.Errorf("image embedded video = %q, want %q", image.EmbeddedVideo, hashes[".mp4"])
+	}
+
+	var videos []ManifestEntry
+	for _, entry := range manifest.Entries() {
+		if filepath.Ext(entry.Destination) == ".mp4" {
+			videos = append(videos, entry)
+		}
+	}
+	if len(videos) != 1 || videos[0].Outcome != OutcomeOrganized || videos[0].Source != still {
+		t.Errorf("manifest entries of the embedded video = %+v, want one organized from %s", videos, still)
+	}
+}
+
+func TestOrganizeGroupRecordsDuplicateEmbeddedVideo(t *testing.T) {
+	video := testMP4()
+	organizer, manifest := newTestOrganizer(t, config.DefaultConfig(), t.TempDir())
+	// Two different images carrying the same video
+	for i, data := range []string{"\xFF\xD8\xFF\xD9", "\xFF\xD8\x00\xFF\xD9"} {
+		still := writeTestFile(t, fmt.Sprintf("IMG_000%d.jpg", i+1), samsungTrailer([]byte(data), "MotionPhoto_Data", video))
+		if outcome := organizer.OrganizeGroup(still, FileGroup{}); outcome.Outcome != OutcomeOrganized {
+			t.Fatalf("OrganizeGroup(%s) = %v (%v), want organized", still, outcome.Outcome, outcome.Err)
+		}
+	}
+
+	var outcomes []Outcome
+	for _, entry := range manifest.Entries() {
+		if entry.Category == organizer.detector.GetFileTypeString(FileTypeVideo) {
+			outcomes = append(outcomes, entry.Outcome)
+		}
+	}
+	if len(outcomes) != 2 || outcomes[0] != OutcomeOrganized || outcomes[1] != OutcomeDuplicate {
+		t.Errorf("embedded video outcomes = %v, want organized then duplicate", outcomes)
+	}
+}
+
+func TestOrganizeGroupDatesExportsLikeTheOriginal(t *testing.T) {
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, image.NewRGBA(image.Rect(0, 0, 16, 16)), nil); err != nil {
+		t.Fatal(err)
+	}
+	// A camera image whose only date is in its Takeout JSON
+	camera := testTIFF(binary.LittleEndian, []testTIFFField{asciiField(0x010F, "Canon"), asciiField(0x0110, "Canon EOS R5")})
+	var photo bytes.Buffer
+	if err := (&ImageProcessor{}).writeJPEGWithEXIF(&photo, encoded.Bytes(), &imageMetadata{EXIF: camera}); err != nil {
+		t.Fatal(err)
+	}
+	src := writeTestFile(t, "IMG_0001.jpg", photo.Bytes())
+	takeout := filepath.Join(filepath.Dir(src), "IMG_0001.jpg.json")
+	if err := os.WriteFile(takeout, []byte(`{"photoTakenTime": {"timestamp": "1620302400"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.DefaultConfig()
+	destDir := t.TempDir()
+	organizer, _ := newTestOrganizer(t, cfg, destDir)
+
+	outcome := organizer.OrganizeGroup(src, FileGroup{Takeout: takeout})
+	if outcome.Outcome != OutcomeOrganized {
+		t.Fatalf("OrganizeGroup() = %v (%v), want organized", outcome.Outcome, outcome.Err)
+	}
+	if filepath.Base(filepath.Dir(outcome.Destination)) != "2021" {
+		t.Errorf("original = %s, want a 2021 folder", outcome.Destination)
+	}
+	exports, err := filepath.Glob(filepath.Join(destDir, cfg.Directories.Images, cfg.ImageDirs.Exports, "2021", "2021-05-06 - *IMG_0001.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exports) != 1 {
+		t.Errorf("exports dated 2021 = %v, want one", exports)
+	}
+}
@@ -0,0 +1,417 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"regexp"
+	"sort"
+	"strings"
+
+	"zensort/internal/config"
+)
+
+// Export privacy policies
+const (
+	PrivacyKeepAll        = "keep_all"         // Exports keep all metadata
+	PrivacyStripGPS       = "strip_gps"        // Exports lose their GPS position
+	PrivacyDateCameraOnly = "date_camera_only" // Exports keep only the capture date and camera
+	PrivacyStripAll       = "strip_all"        // Exports keep no metadata
+)
+
+// TIFF tags written or dropped by the export privacy policies
+const (
+	tiffTagArtist    = 0x013B
+	tiffTagCopyright = 0x8298
+	tiffTagExifIFD   = 0x8769
+	tiffTagGPSIFD    = 0x8825
+	tiffTagInterop   = 0xA005
+	tiffTagMakerNote = 0x927C
+)
+
+// tiffOffsetTags hold offsets into data that is not carried over, so they are left out
+// of rebuilt EXIF structures: strip offsets, sub-IFDs, thumbnail and interoperability IFD
+var tiffOffsetTags = map[uint16]bool{0x0111: true, 0x014A: true, 0x0201: true, tiffTagInterop: true}
+
+// dateCameraIFD0Tags are the IFD0 tags kept by the "date_camera_only" policy:
+// Make, Model, Orientation and DateTime
+var dateCameraIFD0Tags = map[uint16]bool{0x010F: true, 0x0110: true, 0x0112: true, 0x0132: true}
+
+// dateCameraExifTags are the EXIF sub-IFD tags kept by the "date_camera_only" policy:
+// the original and digitized dates with their offsets and sub-seconds, the color space
+// and the lens make and model
+var dateCameraExifTags = map[uint16]bool{
+	0x9003: true, 0x9004: true, 0x9010: true, 0x9011: true, 0x9012: true,
+	0x9290: true, 0x9291: true, 0x9292: true, 0xA001: true, 0xA433: true, 0xA434: true,
+}
+
+// xmpGPSProperties matches the GPS properties of an XMP packet in attribute and element form
+var xmpGPSProperties = regexp.MustCompile(`(?s)\s+exif:GPS\w+="[^"]*"|<exif:GPS\w+[^>]*>.*?</exif:GPS\w+>`)
+
+// xmpExtendedXMPNote matches the reference of an XMP packet to its extended XMP
+var xmpExtendedXMPNote = regexp.MustCompile(`\s+xmpNote:HasExtendedXMP="[^"]*"|<xmpNote:HasExtendedXMP>[^<]*</xmpNote:HasExtendedXMP>`)
+
+// jpegMPFHeader identifies the APP2 segment indexing the images of a multi-picture JPEG
+var jpegMPFHeader = []byte("MPF\x00")
+
+// validPrivacyPolicy reports whether an export privacy policy is known
+func validPrivacyPolicy(policy string) bool {
+	switch policy {
+	case PrivacyKeepAll, PrivacyStripGPS, PrivacyDateCameraOnly, PrivacyStripAll:
+		return true
+	}
+	return false
+}
+
+// scrubsMetadata reports whether exports get different metadata than their originals
+func scrubsMetadata(cfg *config.Config) bool {
+	privacy := cfg.ExportPrivacy
+	return (privacy.Policy != "" && privacy.Policy != PrivacyKeepAll) || privacy.Artist != "" || privacy.Copyright != ""
+}
+
+// applyPrivacy returns the metadata an export may carry under the export privacy settings.
+// ICC profiles only describe colors and are always kept.
+func applyPrivacy(meta *imageMetadata, cfg *config.Config) *imageMetadata {
+	if meta == nil || !scrubsMetadata(cfg) {
+		return meta
+	}
+	privacy := cfg.ExportPrivacy
+	scrubbed := &imageMetadata{ICC: meta.ICC}
+
+	switch privacy.Policy {
+	case PrivacyStripGPS:
+		// Extended XMP is one packet split across segments and checksummed as a whole,
+		// so it is left out rather than scrubbed
+		scrubbed.XMP = xmpGPSProperties.ReplaceAll(meta.XMP, nil)
+		scrubbed.XMP = xmpExtendedXMPNote.ReplaceAll(scrubbed.XMP, nil)
+	case PrivacyDateCameraOnly, PrivacyStripAll:
+		// XMP carries far more than dates and cameras, so it goes entirely
+	default:
+		scrubbed.XMP = meta.XMP
+		scrubbed.ExtendedXMP = meta.ExtendedXMP
+	}
+
+	var fields exifFields
+	if privacy.Policy != PrivacyStripAll && len(meta.EXIF) > 0 {
+		parsed, ok := readEXIFFields(meta.EXIF)
+		if !ok {
+			// Without understanding the EXIF only keeping everything is safe
+			if privacy.Policy == PrivacyKeepAll || privacy.Policy == "" {
+				scrubbed.EXIF = meta.EXIF
+			}
+			return scrubbed
+		}
+		fields = parsed
+		// Maker notes hold offsets into the original EXIF that moving them would break
+		fields.exif = dropTIFFField(fields.exif, tiffTagMakerNote)
+
+		switch privacy.Policy {
+		case PrivacyStripGPS:
+			fields.gps = nil
+		case PrivacyDateCameraOnly:
+			fields.ifd0 = filterTIFFFields(fields.ifd0, dateCameraIFD0Tags)
+			fields.exif = filterTIFFFields(fields.exif, dateCameraExifTags)
+			fields.gps = nil
+		}
+	}
+
+	if privacy.Artist != "" {
+		fields.ifd0 = setTIFFString(fields.ifd0, tiffTagArtist, privacy.Artist)
+	}
+	if privacy.Copyright != "" {
+		fields.ifd0 = setTIFFString(fields.ifd0, tiffTagCopyright, privacy.Copyright)
+	}
+	if len(fields.ifd0) > 0 || len(fields.exif) > 0 || len(fields.gps) > 0 {
+		scrubbed.EXIF = fields.build()
+	}
+	return scrubbed
+}
+
+// tiffField is a TIFF field with its complete value
+type tiffField struct {
+	Tag   uint16
+	Type  uint16
+	Count uint32
+	Data  []byte
+}
+
+// exifFields are the fields of the IFDs of an EXIF structure.
+// The thumbnail IFD is not kept, it would show the original image.
+type exifFields struct {
+	order binary.ByteOrder
+	ifd0  []tiffField
+	exif  []tiffField
+	gps   []tiffField
+}
+
+// readEXIFFields reads IFD0 and the EXIF and GPS sub-IFDs of an EXIF TIFF structure.
+// Sub-IFD pointers are left out, they are recreated when the structure is built.
+func readEXIFFields(tiffData []byte) (exifFields, bool) {
+	t, ifd0, ok := newTIFFReader(bytes.NewReader(tiffData), 0)
+	if !ok {
+		return exifFields{}, false
+	}
+
+	fields := exifFields{order: t.order}
+	entries, err := t.readIFD(ifd0)
+	if err != nil {
+		return exifFields{}, false
+	}
+	for _, entry := range entries {
+		switch entry.Tag {
+		case tiffTagExifIFD:
+			if pointer, ok := t.uintValue(entry); ok {
+				fields.exif = t.readFields(pointer)
+			}
+		case tiffTagGPSIFD:
+			if pointer, ok := t.uintValue(entry); ok {
+				fields.gps = t.readFields(pointer)
+			}
+		default:
+			if field, ok := t.field(entry); ok && !tiffOffsetTags[entry.Tag] {
+				fields.ifd0 = append(fields.ifd0, field)
+			}
+		}
+	}
+	return fields, true
+}
+
+// readFields reads the fields of a sub-IFD, leaving out offsets to other data
+func (t *tiffReader) readFields(offset uint32) []tiffField {
+	entries, err := t.readIFD(offset)
+	if err != nil {
+		return nil
+	}
+	var fields []tiffField
+	for _, entry := range entries {
+		if tiffOffsetTags[entry.Tag] {
+			continue
+		}
+		if field, ok := t.field(entry); ok {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// field reads the complete value of an entry
+func (t *tiffReader) field(entry tiffEntry) (tiffField, bool) {
+	value, err := t.value(entry)
+	if err != nil {
+		return tiffField{}, false
+	}
+	return tiffField{Tag: entry.Tag, Type: entry.Type, Count: entry.Count, Data: append([]byte(nil), value...)}, true
+}
+
+// filterTIFFFields keeps the fields whose tags are listed
+func filterTIFFFields(fields []tiffField, keep map[uint16]bool) []tiffField {
+	var kept []tiffField
+	for _, field := range fields {
+		if keep[field.Tag] {
+			kept = append(kept, field)
+		}
+	}
+	return kept
+}
+
+// dropTIFFField removes the field with the given tag
+func dropTIFFField(fields []tiffField, tag uint16) []tiffField {
+	var kept []tiffField
+	for _, field := range fields {
+		if field.Tag != tag {
+			kept = append(kept, field)
+		}
+	}
+	return kept
+}
+
+// setTIFFString adds or replaces an ASCII field
+func setTIFFString(fields []tiffField, tag uint16, value string) []tiffField {
+	data := append([]byte(strings.TrimSpace(value)), 0)
+	field := tiffField{Tag: tag, Type: 2, Count: uint32(len(data)), Data: data}
+	for i := range fields {
+		if fields[i].Tag == tag {
+			fields[i] = field
+			return fields
+		}
+	}
+	return append(fields, field)
+}
+
+// build serializes the fields into a TIFF structure: IFD0, then the EXIF and GPS IFDs,
+// each followed by the values that do not fit in their entries
+func (f exifFields) build() []byte {
+	order := f.order
+	if order == nil {
+		order = binary.BigEndian
+	}
+
+	// Pointer entries have a fixed size, so the layout is known before their values are
+	ifd0 := append([]tiffField(nil), f.ifd0...)
+	if len(f.exif) > 0 {
+		ifd0 = append(ifd0, tiffField{Tag: tiffTagExifIFD, Type: 4, Count: 1, Data: make([]byte, 4)})
+	}
+	if len(f.gps) > 0 {
+		ifd0 = append(ifd0, tiffField{Tag: tiffTagGPSIFD, Type: 4, Count: 1, Data: make([]byte, 4)})
+	}
+
+	ifd0Offset := uint32(8)
+	exifOffset := ifd0Offset + tiffIFDSize(ifd0)
+	gpsOffset := exifOffset + tiffIFDSize(f.exif)
+	for i := range ifd0 {
+		switch ifd0[i].Tag {
+		case tiffTagExifIFD:
+			order.PutUint32(ifd0[i].Data, exifOffset)
+		case tiffTagGPSIFD:
+			order.PutUint32(ifd0[i].Data, gpsOffset)
+		}
+	}
+
+	var buf bytes.Buffer
+	if order == binary.LittleEndian {
+		buf.WriteString("II")
+	} else {
+		buf.WriteString("MM")
+	}
+	binary.Write(&buf, order, uint16(42))
+	binary.Write(&buf, order, ifd0Offset)
+
+	writeTIFFIFD(&buf, order, ifd0, ifd0Offset)
+	if len(f.exif) > 0 {
+		writeTIFFIFD(&buf, order, f.exif, exifOffset)
+	}
+	if len(f.gps) > 0 {
+		writeTIFFIFD(&buf, order, f.gps, gpsOffset)
+	}
+	return buf.Bytes()
+}
+
+// tiffIFDSize returns the size of an IFD including its out-of-line values (0 for no fields)
+func tiffIFDSize(fields []tiffField) uint32 {
+	if len(fields) == 0 {
+		return 0
+	}
+	size := uint32(2 + 12*len(fields) + 4)
+	for _, field := range fields {
+		if len(field.Data) > 4 {
+			size += uint32(len(field.Data) + len(field.Data)%2)
+		}
+	}
+	return size
+}
+
+// writeTIFFIFD writes an IFD at offset followed by its out-of-line values.
+// Entries are sorted by tag as TIFF requires.
+func writeTIFFIFD(buf *bytes.Buffer, order binary.ByteOrder, fields []tiffField, offset uint32) {
+	sorted := append([]tiffField(nil), fields...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Tag < sorted[j].Tag })
+
+	binary.Write(buf, order, uint16(len(sorted)))
+	valueOffset := offset + uint32(2+12*len(sorted)+4)
+	var values bytes.Buffer
+	for _, field := range sorted {
+		binary.Write(buf, order, field.Tag)
+		binary.Write(buf, order, field.Type)
+		binary.Write(buf, order, field.Count)
+		if len(field.Data) <= 4 {
+			inline := make([]byte, 4)
+			copy(inline, field.Data)
+			buf.Write(inline)
+			continue
+		}
+		binary.Write(buf, order, valueOffset+uint32(values.Len()))
+		values.Write(field.Data)
+		if len(field.Data)%2 == 1 {
+			values.WriteByte(0) // Values start on word boundaries
+		}
+	}
+	binary.Write(buf, order, uint32(0)) // No next IFD
+	buf.Write(values.Bytes())
+}
+
+// jpegWithoutTrailer returns a JPEG file up to its end of image marker. Data appended
+// after the image, such as motion photo videos and the secondary images of multi-picture
+// files, is left out along with the MPF segment pointing at it. It reports false when
+// the end of the image can't be found.
+func jpegWithoutTrailer(data []byte) ([]byte, bool) {
+	if len(data) < 2 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, false
+	}
+
+	out := append([]byte(nil), data[:2]...)
+	for pos := 2; pos+2 <= len(data); {
+		if data[pos] != 0xFF {
+			return nil, false
+		}
+		marker := data[pos+1]
+		switch {
+		case marker == 0xFF: // Fill byte
+			out = append(out, 0xFF)
+			pos++
+			continue
+		case marker == 0xD9: // End of image
+			return append(out, 0xFF, 0xD9), true
+		case marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7): // Markers without a length
+			out = append(out, data[pos:pos+2]...)
+			pos += 2
+			continue
+		}
+
+		if pos+4 > len(data) {
+			return nil, false
+		}
+		end := pos + 2 + int(binary.BigEndian.Uint16(data[pos+2:pos+4]))
+		if end < pos+4 || end > len(data) {
+			return nil, false
+		}
+		if marker != 0xE2 || !bytes.HasPrefix(data[pos+4:end], jpegMPFHeader) {
+			out = append(out, data[pos:end]...)
+		}
+		pos = end
+
+		if marker == 0xDA {
+			// The scan data runs up to the next marker that is neither a stuffed
+			// zero byte nor a restart marker
+			scanEnd := pos
+			for ; scanEnd+1 < len(data); scanEnd++ {
+				if next := data[scanEnd+1]; data[scanEnd] == 0xFF && next != 0x00 && (next < 0xD0 || next > 0xD7) {
+					break
+				}
+			}
+			out = append(out, data[pos:scanEnd]...)
+			pos = scanEnd
+		}
+	}
+	return nil, false
+}
+
+// stripWebPMetadata removes the EXIF and XMP chunks of a WebP file and their header flags
+func stripWebPMetadata(data []byte) []byte {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return data
+	}
+
+	out := append([]byte(nil), data[:12]...)
+	for pos := 12; pos+8 <= len(data); {
+		size := int(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+		end := pos + 8 + size + size%2
+		if size < 0 || end > len(data) {
+			end = len(data)
+		}
+
+		chunk := append([]byte(nil), data[pos:end]...)
+		switch string(chunk[0:4]) {
+		case "EXIF", "XMP ":
+			pos = end
+			continue
+		case "VP8X":
+			if len(chunk) > 8 {
+				chunk[8] &^= 0x08 | 0x04 // EXIF and XMP present flags
+			}
+		}
+		out = append(out, chunk...)
+		pos = end
+	}
+
+	binary.LittleEndian.PutUint32(out[4:8], uint32(len(out)-8))
+	return out
+}
@@ -0,0 +1,277 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"zensort/internal/config"
+)
+
+// testPrivacyMetadata returns metadata with camera, date, GPS, maker note and XMP fields
+func testPrivacyMetadata() *imageMetadata {
+	order := binary.LittleEndian
+	ifd0, exifIFD, gpsIFD := testCameraIFDs(order)
+	ifd0 = append(ifd0, asciiField(0x0131, "Firmware 1.0"), testTIFFField{tag: 0x8769, ifd: 1}, testTIFFField{tag: 0x8825, ifd: 2})
+	exifIFD = append(exifIFD,
+		testTIFFField{tag: 0x8827, typ: 3, data: order.AppendUint16(nil, 400)},
+		testTIFFField{tag: tiffTagMakerNote, typ: 7, data: []byte("Canon maker note with offsets")})
+
+	return &imageMetadata{
+		EXIF: testTIFF(order, ifd0, exifIFD, gpsIFD),
+		ICC:  []byte("icc profile"),
+		XMP: []byte(`<rdf:Description exif:GPSLatitude="52,30.0N" exif:GPSLongitude="13,15.6W"` +
+			` xmpNote:HasExtendedXMP="0123456789ABCDEF" tiff:Make="Canon"/>`),
+		ExtendedXMP: [][]byte{[]byte("0123456789ABCDEF extended packet with exif:GPSLatitude")},
+	}
+}
+
+// exifFieldTags returns the sorted tags of the fields
+func exifFieldTags(fields []tiffField) []uint16 {
+	tags := []uint16{}
+	for _, field := range fields {
+		tags = append(tags, field.Tag)
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i] < tags[j] })
+	return tags
+}
+
+func TestApplyPrivacy(t *testing.T) {
+	allIFD0 := []uint16{0x010F, 0x0110, 0x0112, 0x0131}
+	tests := []struct {
+		name      string
+		policy    string
+		artist    string
+		wantIFD0  []uint16
+		wantEXIF  []uint16
+		wantGPS   []uint16
+		wantXMP   string
+		extended  bool
+		unchanged bool
+	}{
+		{name: "keep all", policy: PrivacyKeepAll, unchanged: true},
+		{name: "keep all with artist", policy: PrivacyKeepAll, artist: "Jane",
+			wantIFD0: []uint16{0x010F, 0x0110, 0x0112, 0x0131, tiffTagArtist},
+			wantEXIF: []uint16{0x8827, 0x9003, 0x9011}, wantGPS: []uint16{1, 2, 3, 4},
+			wantXMP: string(testPrivacyMetadata().XMP), extended: true},
+		{name: "strip gps", policy: PrivacyStripGPS,
+			wantIFD0: allIFD0, wantEXIF: []uint16{0x8827, 0x9003, 0x9011}, wantGPS: []uint16{},
+			wantXMP: `<rdf:Description tiff:Make="Canon"/>`},
+		{name: "date and camera only", policy: PrivacyDateCameraOnly,
+			wantIFD0: []uint16{0x010F, 0x0110, 0x0112}, wantEXIF: []uint16{0x9003, 0x9011}, wantGPS: []uint16{}},
+		{name: "strip all", policy: PrivacyStripAll},
+		{name: "strip all with artist", policy: PrivacyStripAll, artist: "Jane",
+			wantIFD0: []uint16{tiffTagArtist}, wantEXIF: []uint16{}, wantGPS: []uint16{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.DefaultConfig()
+			cfg.ExportPrivacy.Policy = tt.policy
+			cfg.ExportPrivacy.Artist = tt.artist
+			meta := testPrivacyMetadata()
+
+			scrubbed := applyPrivacy(meta, cfg)
+			if tt.unchanged {
+				if scrubbed != meta {
+					t.Error("applyPrivacy() changed the metadata")
+				}
+				return
+			}
+			if !bytes.Equal(scrubbed.ICC, meta.ICC) {
+				t.Errorf("ICC = %q, want the original profile", scrubbed.ICC)
+			}
+			if string(scrubbed.XMP) != tt.wantXMP {
+				t.Errorf("XMP = %q, want %q", scrubbed.XMP, tt.wantXMP)
+			}
+			if got := len(scrubbed.ExtendedXMP) > 0; got != tt.extended {
+				t.Errorf("extended XMP kept = %v, want %v", got, tt.extended)
+			}
+
+			if tt.wantIFD0 == nil {
+				if len(scrubbed.EXIF) > 0 {
+					t.Errorf("EXIF = %d bytes, want none", len(scrubbed.EXIF))
+				}
+				return
+			}
+			fields, ok := readEXIFFields(scrubbed.EXIF)
+			if !ok {
+				t.Fatal("scrubbed EXIF does not parse")
+			}
+			if got := exifFieldTags(fields.ifd0); !reflect.DeepEqual(got, tt.wantIFD0) {
+				t.Errorf("IFD0 tags = %#x, want %#x", got, tt.wantIFD0)
+			}
+			if got := exifFieldTags(fields.exif); !reflect.DeepEqual(got, tt.wantEXIF) {
+				t.Errorf("EXIF tags = %#x, want %#x", got, tt.wantEXIF)
+			}
+			if got := exifFieldTags(fields.gps); !reflect.DeepEqual(got, tt.wantGPS) {
+				t.Errorf("GPS tags = %#x, want %#x", got, tt.wantGPS)
+			}
+		})
+	}
+}
+
+func TestApplyPrivacyRoundTripsValues(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.ExportPrivacy.Policy = PrivacyKeepAll
+	cfg.ExportPrivacy.Copyright = " (c) Jane "
+	meta := testPrivacyMetadata()
+
+	original, ok := readEXIFFields(meta.EXIF)
+	if !ok {
+		t.Fatal("test EXIF does not parse")
+	}
+	rebuilt, ok := readEXIFFields(applyPrivacy(meta, cfg).EXIF)
+	if !ok {
+		t.Fatal("rebuilt EXIF does not parse")
+	}
+
+	values := func(fields []tiffField) map[uint16]tiffField {
+		byTag := make(map[uint16]tiffField)
+		for _, field := range fields {
+			byTag[field.Tag] = field
+		}
+		return byTag
+	}
+	for name, ifds := range map[string][2][]tiffField{
+		"IFD0": {original.ifd0, rebuilt.ifd0},
+		"EXIF": {original.exif, rebuilt.exif},
+		"GPS":  {original.gps, rebuilt.gps},
+	} {
+		got := values(ifds[1])
+		for tag, want := range values(ifds[0]) {
+			if tag == tiffTagMakerNote {
+				continue
+			}
+			if !reflect.DeepEqual(got[tag], want) {
+				t.Errorf("%s tag %#x = %+v, want %+v", name, tag, got[tag], want)
+			}
+		}
+	}
+	if copyright := values(rebuilt.ifd0)[tiffTagCopyright]; string(copyright.Data) != "(c) Jane\x00" {
+		t.Errorf("copyright = %q", copyright.Data)
+	}
+}
+
+// testJPEGParts builds a JPEG stream from its segments, scan data and trailer
+func testJPEGParts(segments [][]byte, scan, trailer []byte) []byte {
+	parts := append([][]byte{{0xFF, 0xD8}}, segments...)
+	parts = append(parts, jpegSegment(0xDA, nil, []byte{1, 1, 0, 0, 0x3F, 0}), scan, []byte{0xFF, 0xD9}, trailer)
+	return bytes.Join(parts, nil)
+}
+
+func TestJPEGWithoutTrailer(t *testing.T) {
+	exif := jpegSegment(0xE1, jpegEXIFHeader, []byte("II*\x00"))
+	mpf := jpegSegment(0xE2, jpegMPFHeader, []byte("MM\x00*"))
+	quant := jpegSegment(0xDB, nil, make([]byte, 65))
+	scan := []byte{0x12, 0xFF, 0x00, 0x34, 0xFF, 0xD0, 0x56, 0xFF, 0xFF}
+	video := append(box("ftyp", []byte("mp42")), box("mdat", []byte("video"))...)
+	// Progressive files have several scans with tables between them
+	progressive := bytes.Join([][]byte{scan[:4], jpegSegment(0xC4, nil, []byte{0, 1}), jpegSegment(0xDA, nil, []byte{1, 1, 0, 0, 0x3F, 0}), scan[4:]}, nil)
+	noEnd := testJPEGParts([][]byte{exif}, scan, nil)
+	noEnd = noEnd[:len(noEnd)-2]
+
+	tests := []struct {
+		name string
+		data []byte
+		want []byte
+		ok   bool
+	}{
+		{"motion photo trailer", testJPEGParts([][]byte{exif, quant}, scan, video),
+			testJPEGParts([][]byte{exif, quant}, scan, nil), true},
+		{"MPF segment and secondary image", testJPEGParts([][]byte{exif, mpf, quant}, scan, testJPEGParts(nil, scan, nil)),
+			testJPEGParts([][]byte{exif, quant}, scan, nil), true},
+		{"no trailer", testJPEGParts([][]byte{exif}, scan, nil), testJPEGParts([][]byte{exif}, scan, nil), true},
+		{"progressive scans", testJPEGParts([][]byte{quant}, progressive, video), testJPEGParts([][]byte{quant}, progressive, nil), true},
+		{"not a JPEG", []byte("\x89PNG\r\n\x1a\n"), nil, false},
+		{"no end of image", noEnd, nil, false},
+		{"segment past the end", []byte{0xFF, 0xD8, 0xFF, 0xE1, 0x10, 0x00, 1, 2}, nil, false},
+		{"segment too short", []byte{0xFF, 0xD8, 0xFF, 0xE1, 0x00, 0x01, 0xFF, 0xD9}, nil, false},
+		{"garbage between segments", []byte{0xFF, 0xD8, 0x00, 0xFF, 0xD9}, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := jpegWithoutTrailer(tt.data)
+			if ok != tt.ok {
+				t.Fatalf("jpegWithoutTrailer() ok = %v, want %v", ok, tt.ok)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("jpegWithoutTrailer() = % x, want % x", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPassthroughExportScrubsJPEG(t *testing.T) {
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, image.NewRGBA(image.Rect(0, 0, 8, 8)), nil); err != nil {
+		t.Fatal(err)
+	}
+	var original bytes.Buffer
+	if err := (&ImageProcessor{}).writeJPEGWithEXIF(&original, encoded.Bytes(), testPrivacyMetadata()); err != nil {
+		t.Fatal(err)
+	}
+	trailer := append(box("ftyp", []byte("mp42")), box("mdat", []byte("video with ©xyz"))...)
+	src := writeTestFile(t, "motion.jpg", append(original.Bytes(), trailer...))
+
+	tests := []struct {
+		policy      string
+		wantTrailer bool
+		wantGPS     bool
+	}{
+		{PrivacyKeepAll, true, true},
+		{PrivacyStripGPS, false, false},
+		{PrivacyDateCameraOnly, false, false},
+		{PrivacyStripAll, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			cfg := config.DefaultConfig()
+			cfg.ExportPrivacy.Policy = tt.policy
+			cfg.ExportPrivacy.Artist = "Jane" // Scrubs even under keep_all
+			exportPath := filepath.Join(t.TempDir(), "export.jpg")
+			if err := NewImageProcessor(cfg, t.TempDir()).passthroughExport(src, exportPath); err != nil {
+				t.Fatalf("passthroughExport() error = %v", err)
+			}
+
+			data, err := os.ReadFile(exportPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := bytes.HasSuffix(data, trailer); got != tt.wantTrailer {
+				t.Errorf("trailer kept = %v, want %v", got, tt.wantTrailer)
+			}
+			if _, err := jpeg.Decode(bytes.NewReader(data)); err != nil {
+				t.Errorf("export does not decode: %v", err)
+			}
+
+			meta, err := readJPEGMetadata(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fields, ok := readEXIFFields(meta.EXIF)
+			if !ok {
+				t.Fatal("export EXIF does not parse")
+			}
+			if got := len(fields.gps) > 0; got != tt.wantGPS {
+				t.Errorf("GPS kept = %v, want %v", got, tt.wantGPS)
+			}
+			for _, field := range fields.exif {
+				if field.Tag == tiffTagMakerNote {
+					t.Error("maker note kept in rebuilt EXIF")
+				}
+			}
+			if len(meta.ExtendedXMP) > 0 && tt.policy != PrivacyKeepAll {
+				t.Error("extended XMP kept")
+			}
+		})
+	}
+}
@@ -21,6 +21,10 @@ type FileProcessor struct {
 	logger          *Logger
 	reportGen       *ReportGenerator
 	categoryStats   map[string]CategoryStats
+	groups          map[string]FileGroup // Primary file -> files grouped with it during scanning
+	origins         map[string]string    // Extracted file -> origin inside its archive (archive.zip!/inner/path)
+	archiveDir      string               // Staging folder of the archive being organized
+	manifest        *Manifest            // Decision taken for every file of the session
 }
 
 // NewFileProcessor creates a new file processor
@@ -51,6 +55,8 @@ func NewFileProcessor(cfg *config.Config, destDir string) (*FileProcessor, error
 		logger:          logger,
 		reportGen:       NewReportGenerator(destDir),
 		categoryStats:   make(map[string]CategoryStats),
+		origins:         make(map[string]string),
+		manifest:        NewManifest(),
 	}
 	
 	return fp, nil
@@ -66,14 +72,17 @@ func (fp *FileProcessor) ProcessDirectory(ctx context.Context, sourceDir string)
 	
 	fp.logger.LogOperation("INFO", fmt.Sprintf("Starting processing with %d workers", fp.workerPool.WorkerCount()), sourceDir)
 	
+	// Files extracted from archives only live for the duration of the run
+	defer fp.removeArchiveStaging()
+
 	// Scan directory to get file list and total size
-	files, totalSize, err := fp.scanDirectory(sourceDir)
+	files, archives, totalSize, err := fp.scanDirectory(sourceDir)
 	if err != nil {
 		return fmt.Errorf("failed to scan directory: %w", err)
 	}
 	
 	fp.progressTracker.SetTotal(int64(len(files)), totalSize)
-	fp.logger.LogOperation("INFO", fmt.Sprintf("Found %d files (%s total)", len(files), formatBytes(totalSize)), "")
+	fp.logger.LogOperation("INFO", fmt.Sprintf("Found %d files (%s total) and %d archives", len(files), formatBytes(totalSize), len(archives)), "")
 	
 	// Start worker pool
 	fp.workerPool.Start()
@@ -85,11 +94,25 @@ func (fp *FileProcessor) ProcessDirectory(ctx context.Context, sourceDir string)
 		TotalSize:  totalSize,
 	}
 	
-	err = fp.processFiles(ctx, files, &stats)
+	// Files left out while scanning show in the progress counts too
+	for _, entry := range fp.manifest.Entries() {
+		fp.progressTracker.AddOutcome(entry.Outcome)
+	}
+
+	err = fp.processFiles(ctx, files, archives, &stats)
 	if err != nil {
 		return err
 	}
 	
+	// Every source file has one decision in the manifest, grouped files included
+	entries := fp.manifest.Entries()
+	stats.TotalFiles = int64(len(entries))
+	stats.TotalSize = 0
+	for _, entry := range entries {
+		stats.TotalSize += entry.Size
+		fp.countOutcome(&stats, entry)
+	}
+
 	// Finalize processing
 	stats.EndTime = time.Now()
 	stats.Duration = stats.EndTime.Sub(stats.StartTime)
@@ -98,19 +121,30 @@ func (fp *FileProcessor) ProcessDirectory(ctx context.Context, sourceDir string)
 	fp.logger.LogStatistics(stats)
 	
 	// Generate final report
-	errors := fp.progressTracker.GetErrors()
-	err = fp.reportGen.GenerateReport(stats, fp.categoryStats, errors, fp.workerPool.WorkerCount(), sourceDir, "")
+	err = fp.reportGen.GenerateReport(stats, fp.categoryStats, fp.manifest.Entries(), fp.workerPool.WorkerCount(), sourceDir, "")
 	if err != nil {
 		fp.logger.LogError(LogLevelWarning, "Failed to generate report", "", err)
 	}
 	
+	// Bring the gallery up to date with the files organized in this session
+	if fp.config.Gallery.Enabled {
+		gallery := NewGalleryGenerator(fp.config, fp.destDir, fp.logger)
+		created, err := gallery.Update(fp.db)
+		if err != nil {
+			fp.logger.LogError(LogLevelWarning, "Failed to update gallery", gallery.dir, err)
+		} else {
+			fp.logger.LogOperation("INFO", fmt.Sprintf("Gallery updated with %d new thumbnails", created), gallery.dir)
+		}
+	}
+
 	return nil
 }
 
-// scanDirectory recursively scans directory and returns file list
-func (fp *FileProcessor) scanDirectory(sourceDir string) ([]string, int64, error) {
-	var files []string
+// scanDirectory recursively scans directory and returns file list and the archives to expand
+func (fp *FileProcessor) scanDirectory(sourceDir string) ([]string, []string, int64, error) {
+	var files, archives []string
 	var totalSize int64
+	sizes := make(map[string]int64)
 	
 	err := filepath.Walk(sourceDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
@@ -125,60 +159,217 @@ func (fp *FileProcessor) scanDirectory(sourceDir string) ([]string, int64, error
 		
 		// Check if file should be skipped
 		if fp.detector.ShouldSkipFile(path, fp.config.SkipFiles.Extensions, fp.config.SkipFiles.Patterns, fp.config.SkipFiles.Directories) {
-			fp.logger.LogFileSkipped(path, "matches skip pattern")
+			fp.skipFile(path, OutcomeFiltered, "matches skip pattern")
 			return nil
 		}
 		
+		// Archives act as directories, expanded one at a time once the other files are organized
+		if fp.config.Archives.Enabled && archiveFormat(path) != "" {
+			archives = append(archives, path)
+			return nil
+		}
+
 		files = append(files, path)
-		totalSize += info.Size()
+		sizes[path] = info.Size()
 		return nil
 	})
 	
-	return files, totalSize, err
+	// Sidecars and Live Photo videos travel with their primary file instead of being processed on their own
+	files, fp.groups = fp.groupFiles(files)
+	for _, path := range files {
+		totalSize += sizes[path]
+	}
+
+	return files, archives, totalSize, err
 }
 
-// processFiles processes the list of files using worker pool
-func (fp *FileProcessor) processFiles(ctx context.Context, files []string, stats *ProcessingStats) error {
+// groupFiles groups sidecars, Live Photo videos and RAW files with their primary files
+func (fp *FileProcessor) groupFiles(files []string) ([]string, map[string]FileGroup) {
+	groups := make(map[string]FileGroup)
+
+	// Takeout JSON files are consumed as metadata before anything else looks at them
+	if fp.config.Takeout.Enabled {
+		var takeout map[string]string
+		files, takeout = fp.matchTakeoutMetadata(files)
+		for media, jsonPath := range takeout {
+			groups[media] = FileGroup{Takeout: jsonPath}
+		}
+	}
+
+	files, sidecars := fp.groupSidecars(files)
+	for primary, list := range sidecars {
+		group := groups[primary]
+		group.Sidecars = list
+		groups[primary] = group
+	}
+
+	if fp.config.MotionPhotos.PairLivePhotos {
+		var pairs map[string]string
+		files, pairs = fp.pairLivePhotos(files)
+		for still, video := range pairs {
+			group := groups[still]
+			group.LiveVideo = video
+			// Sidecars and Takeout JSON of the video (e.g. subtitles) stay with the pair
+			group.Sidecars = append(group.Sidecars, sidecars[video]...)
+			group.Takeout = fp.mergeTakeout(group.Takeout, groups[video].Takeout, "Takeout metadata of the video of a Live Photo")
+			groups[still] = group
+			delete(groups, video)
+		}
+	}
+
+	if fp.config.RawPairs.Policy != RawPairIndependent {
+		var pairs map[string]string
+		files, pairs = fp.pairRawFiles(files)
+		for still, raw := range pairs {
+			switch fp.config.RawPairs.Policy {
+			case RawPairKeepRaw:
+				// Everything grouped with the skipped JPEG stays with the RAW
+				groups[raw] = fp.mergeGroup(groups[raw], groups[still])
+				delete(groups, still)
+			case RawPairKeepJPEG:
+				groups[still] = fp.mergeGroup(groups[still], groups[raw])
+				delete(groups, raw)
+			default:
+				group := groups[still]
+				group.Raw = raw
+				group.RawSidecars = groups[raw].Sidecars
+				group.Takeout = fp.mergeTakeout(group.Takeout, groups[raw].Takeout, rawPairTakeoutReason)
+				groups[still] = group
+				delete(groups, raw)
+			}
+		}
+	}
+
+	return files, groups
+}
+
+// mergeGroup moves the files grouped with a file that is left out onto the group of
+// the file that replaces it
+func (fp *FileProcessor) mergeGroup(into, from FileGroup) FileGroup {
+	into.Sidecars = append(into.Sidecars, from.Sidecars...)
+	if into.LiveVideo == "" {
+		into.LiveVideo = from.LiveVideo
+	}
+	into.Takeout = fp.mergeTakeout(into.Takeout, from.Takeout, rawPairTakeoutReason)
+	return into
+}
+
+// rawPairTakeoutReason is why the second Takeout JSON of a RAW+JPEG pair is skipped
+const rawPairTakeoutReason = "Takeout metadata of the other file of a RAW+JPEG pair"
+
+// mergeTakeout returns the Takeout JSON a merged group keeps. A group carries one, so a
+// second JSON is recorded as skipped with the given reason rather than dropped silently.
+func (fp *FileProcessor) mergeTakeout(kept, other, reason string) string {
+	if kept == "" {
+		return other
+	}
+	if other != "" && other != kept {
+		fp.skipFile(other, OutcomeSkipped, reason)
+	}
+	return kept
+}
+
+// processFiles processes the list of files using worker pool, then the archives
+func (fp *FileProcessor) processFiles(ctx context.Context, files, archives []string, stats *ProcessingStats) error {
 	// Create file organizer
-	organizer := NewFileOrganizer(fp.config, fp.destDir, fp.db, fp.logger)
+	organizer, err := NewFileOrganizer(fp.config, fp.destDir, fp.db, fp.logger)
+	if err != nil {
+		return err
+	}
+	organizer.SetOrigins(fp.origins)
+	organizer.SetManifest(fp.manifest)
 	
+	if err := fp.organizeFiles(ctx, organizer, files); err != nil {
+		return err
+	}
+	for _, archivePath := range archives {
+		if err := fp.organizeArchive(ctx, organizer, archivePath); err != nil {
+			return err
+		}
+	}
+
+	// Record where capture dates came from for the report
+	stats.DateSources = make(map[string]int64)
+	for source, count := range organizer.DateSourceCounts() {
+		stats.DateSources[string(source)] = count
+	}
+	stats.InferredDates = stats.DateSources[string(DateSourceFilename)]
+
+	return nil
+}
+
+// organizeFiles organizes scanned files and the files grouped with them
+func (fp *FileProcessor) organizeFiles(ctx context.Context, organizer *FileOrganizer, files []string) error {
 	// Process files directly (simplified approach)
 	for _, filePath := range files {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
 		default:
-			// Get file info for progress tracking
-			fileInfo, err := os.Stat(filePath)
-			var fileSize int64
-			if err == nil {
-				fileSize = fileInfo.Size()
+			// Process the file; the organizer records the files grouped with it itself
+			recorded := fp.manifest.Len()
+			outcome := organizer.OrganizeGroup(filePath, fp.groups[filePath])
+			for _, entry := range fp.manifest.EntriesFrom(recorded) {
+				fp.progressTracker.AddOutcome(entry.Outcome)
 			}
-			
-			// Process the file
-			err = organizer.OrganizeFile(filePath)
-			if err != nil {
-				stats.ErrorFiles++
-				fp.logger.LogError(LogLevelError, "Failed to process file", filePath, err)
-				fp.progressTracker.AddError(fmt.Sprintf("Error processing %s: %v", filePath, err))
-			} else {
-				stats.ProcessedFiles++
-				stats.ProcessedSize += fileSize
+			if outcome.Outcome == OutcomeFailed {
+				fp.logger.LogError(LogLevelError, "Failed to process file", outcome.Source, outcome.Err)
+				fp.progressTracker.AddError(fmt.Sprintf("Error processing %s: %v", outcome.Source, outcome.Err))
 			}
+			fp.manifest.Add(outcome.ManifestEntry)
 			
 			// Update progress
-			fp.progressTracker.IncrementProgress(fileSize, filePath)
+			fp.progressTracker.CompleteFile(outcome.Outcome, outcome.Size, outcome.Source)
 		}
 	}
-	
 	return nil
 }
 
+// countOutcome adds the outcome of a file to the session statistics
+func (fp *FileProcessor) countOutcome(stats *ProcessingStats, entry ManifestEntry) {
+	switch entry.Outcome {
+	case OutcomeOrganized:
+		stats.ProcessedFiles++
+		stats.ProcessedSize += entry.Size
+		category := fp.categoryStats[entry.Category]
+		category.Count++
+		category.Size += entry.Size
+		fp.categoryStats[entry.Category] = category
+	case OutcomeDuplicate:
+		stats.DuplicateFiles++
+	case OutcomeSkipped:
+		stats.SkippedFiles++
+	case OutcomeFiltered:
+		stats.FilteredFiles++
+	case OutcomeFailed:
+		stats.ErrorFiles++
+	}
+}
+
+// skipFile logs and records a file that is left out before organizing starts
+func (fp *FileProcessor) skipFile(path string, outcome Outcome, reason string) {
+	origin := fp.originOf(path)
+	fp.logger.LogFileSkipped(origin, reason)
+	fp.manifest.Add(ManifestEntry{
+		Source:   origin,
+		Category: fp.detector.GetFileTypeString(fp.detector.DetectFileType(path)),
+		Outcome:  outcome,
+		Reason:   reason,
+		Size:     fileSize(path),
+	})
+}
+
 // GetProgressTracker returns the progress tracker
 func (fp *FileProcessor) GetProgressTracker() *ProgressTracker {
 	return fp.progressTracker
 }
 
+// SetResultHandler registers a function called with the decision taken for every file,
+// including files left out while scanning and files grouped with another file
+func (fp *FileProcessor) SetResultHandler(handler func(ManifestEntry)) {
+	fp.manifest.SetHandler(handler)
+}
+
 // GetWorkerCount returns the number of workers
 func (fp *FileProcessor) GetWorkerCount() int {
 	if fp.workerPool != nil {
@@ -191,6 +382,8 @@ func (fp *FileProcessor) GetWorkerCount() int {
 func (fp *FileProcessor) Close() error {
 	var err error
 	
+	fp.removeArchiveStaging()
+
 	if fp.logger != nil {
 		if closeErr := fp.logger.Close(); closeErr != nil {
 			err = closeErr
@@ -7,38 +7,45 @@ import (
 
 // ProgressTracker manages progress reporting for file operations
 type ProgressTracker struct {
-	mu                sync.RWMutex
-	totalFiles        int64
-	processedFiles    int64
-	totalSize         int64
-	processedSize     int64
-	currentFile       string
-	startTime         time.Time
-	errors            []string
-	subscribers       []chan ProgressUpdate
-	done              bool
+	mu             sync.RWMutex
+	totalFiles     int64
+	processedFiles int64
+	totalSize      int64
+	processedSize  int64
+	currentFile    string
+	startTime      time.Time
+	errors         []string
+	outcomes       map[Outcome]int64
+	subscribers    []chan ProgressUpdate
+	done           bool
 }
 
 // ProgressUpdate contains current progress information
 type ProgressUpdate struct {
-	TotalFiles      int64
-	ProcessedFiles  int64
-	TotalSize       int64
-	ProcessedSize   int64
-	CurrentFile     string
-	Percentage      float64
-	ElapsedTime     time.Duration
-	EstimatedTime   time.Duration
-	FilesPerSecond  float64
-	BytesPerSecond  float64
-	ErrorCount      int
-	Done            bool
+	TotalFiles     int64
+	ProcessedFiles int64
+	TotalSize      int64
+	ProcessedSize  int64
+	CurrentFile    string
+	Percentage     float64
+	ElapsedTime    time.Duration
+	EstimatedTime  time.Duration
+	FilesPerSecond float64
+	BytesPerSecond float64
+	ErrorCount     int
+	OrganizedFiles int64 // Files per outcome, grouped files and files left out while scanning included
+	DuplicateFiles int64
+	SkippedFiles   int64
+	FilteredFiles  int64
+	FailedFiles    int64
+	Done           bool
 }
 
 // NewProgressTracker creates a new progress tracker
 func NewProgressTracker() *ProgressTracker {
 	return &ProgressTracker{
 		startTime:   time.Now(),
+		outcomes:    make(map[Outcome]int64),
 		subscribers: make([]chan ProgressUpdate, 0),
 	}
 }
@@ -53,6 +60,16 @@ func (pt *ProgressTracker) SetTotal(files int64, size int64) {
 	pt.notifySubscribers()
 }
 
+// AddTotal adds files found after the scan, such as the contents of an archive, to the totals
+func (pt *ProgressTracker) AddTotal(files int64, size int64) {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	pt.totalFiles += files
+	pt.totalSize += size
+	pt.notifySubscribers()
+}
+
 // UpdateProgress updates the current progress
 func (pt *ProgressTracker) UpdateProgress(filesProcessed int64, sizeProcessed int64, currentFile string) {
 	pt.mu.Lock()
@@ -75,6 +92,27 @@ func (pt *ProgressTracker) IncrementProgress(fileSize int64, fileName string) {
 	pt.notifySubscribers()
 }
 
+// CompleteFile increments progress by one file and counts its outcome
+func (pt *ProgressTracker) CompleteFile(outcome Outcome, fileSize int64, fileName string) {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	pt.processedFiles++
+	pt.processedSize += fileSize
+	pt.currentFile = fileName
+	pt.outcomes[outcome]++
+	pt.notifySubscribers()
+}
+
+// AddOutcome counts the outcome of a file that is not part of the progress total
+func (pt *ProgressTracker) AddOutcome(outcome Outcome) {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	pt.outcomes[outcome]++
+	pt.notifySubscribers()
+}
+
 // AddError adds an error to the tracker
 func (pt *ProgressTracker) AddError(err string) {
 	pt.mu.Lock()
@@ -129,18 +167,23 @@ func (pt *ProgressTracker) GetProgress() ProgressUpdate {
 	}
 	
 	return ProgressUpdate{
-		TotalFiles:      pt.totalFiles,
-		ProcessedFiles:  pt.processedFiles,
-		TotalSize:       pt.totalSize,
-		ProcessedSize:   pt.processedSize,
-		CurrentFile:     pt.currentFile,
-		Percentage:      percentage,
-		ElapsedTime:     elapsed,
-		EstimatedTime:   estimatedTime,
-		FilesPerSecond:  filesPerSecond,
-		BytesPerSecond:  bytesPerSecond,
-		ErrorCount:      len(pt.errors),
-		Done:            pt.done,
+		TotalFiles:     pt.totalFiles,
+		ProcessedFiles: pt.processedFiles,
+		TotalSize:      pt.totalSize,
+		ProcessedSize:  pt.processedSize,
+		CurrentFile:    pt.currentFile,
+		Percentage:     percentage,
+		ElapsedTime:    elapsed,
+		EstimatedTime:  estimatedTime,
+		FilesPerSecond: filesPerSecond,
+		BytesPerSecond: bytesPerSecond,
+		ErrorCount:     len(pt.errors),
+		OrganizedFiles: pt.outcomes[OutcomeOrganized],
+		DuplicateFiles: pt.outcomes[OutcomeDuplicate],
+		SkippedFiles:   pt.outcomes[OutcomeSkipped],
+		FilteredFiles:  pt.outcomes[OutcomeFiltered],
+		FailedFiles:    pt.outcomes[OutcomeFailed],
+		Done:           pt.done,
 	}
 }
 
@@ -192,17 +235,22 @@ func (pt *ProgressTracker) getProgressUnsafe() ProgressUpdate {
 	}
 	
 	return ProgressUpdate{
-		TotalFiles:      pt.totalFiles,
-		ProcessedFiles:  pt.processedFiles,
-		TotalSize:       pt.totalSize,
-		ProcessedSize:   pt.processedSize,
-		CurrentFile:     pt.currentFile,
-		Percentage:      percentage,
-		ElapsedTime:     elapsed,
-		EstimatedTime:   estimatedTime,
-		FilesPerSecond:  filesPerSecond,
-		BytesPerSecond:  bytesPerSecond,
-		ErrorCount:      len(pt.errors),
-		Done:            pt.done,
+		TotalFiles:     pt.totalFiles,
+		ProcessedFiles: pt.processedFiles,
+		TotalSize:      pt.totalSize,
+		ProcessedSize:  pt.processedSize,
+		CurrentFile:    pt.currentFile,
+		Percentage:     percentage,
+		ElapsedTime:    elapsed,
+		EstimatedTime:  estimatedTime,
+		FilesPerSecond: filesPerSecond,
+		BytesPerSecond: bytesPerSecond,
+		ErrorCount:     len(pt.errors),
+		OrganizedFiles: pt.outcomes[OutcomeOrganized],
+		DuplicateFiles: pt.outcomes[OutcomeDuplicate],
+		SkippedFiles:   pt.outcomes[OutcomeSkipped],
+		FilteredFiles:  pt.outcomes[OutcomeFiltered],
+		FailedFiles:    pt.outcomes[OutcomeFailed],
+		Done:           pt.done,
 	}
 }
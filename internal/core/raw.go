@@ -0,0 +1,533 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+// RAW+JPEG pairing policies
+const (
+	RawPairTogether    = "together"      // RAW goes next to its JPEG
+	RawPairSubfolder   = "raw_subfolder" // RAW goes to a subfolder next to its JPEG
+	RawPairKeepJPEG    = "keep_jpeg"     // Only the JPEG is organized
+	RawPairKeepRaw     = "keep_raw"      // Only the RAW is organized
+	RawPairIndependent = "independent"   // Both are organized on their own
+)
+
+// rawExtensions are the camera RAW formats
+var rawExtensions = map[string]bool{
+	".raw": true, ".cr2": true, ".cr3": true, ".nef": true, ".nrw": true, ".arw": true,
+	".dng": true, ".orf": true, ".rw2": true, ".raf": true, ".pef": true, ".srw": true,
+}
+
+// rawPairStillExts are the formats a camera writes next to a RAW file
+var rawPairStillExts = map[string]bool{".jpg": true, ".jpeg": true, ".heic": true, ".heif": true}
+
+// canonCR3UUID identifies the uuid box holding the CR3 metadata boxes (CMT1-CMT4)
+var canonCR3UUID = []byte{0x85, 0xc0, 0xb6, 0x87, 0x82, 0x0f, 0x11, 0xe0, 0x81, 0x11, 0xf4, 0xce, 0x46, 0x2b, 0x6a, 0x48}
+
+// rawStringTags maps the TIFF/EXIF ASCII tags read from RAW headers to field names
+var rawStringTags = map[uint16]exif.FieldName{
+	0x010F: exif.Make,
+	0x0110: exif.Model,
+	0x0131: exif.Software,
+	0x0132: exif.DateTime,
+	0x9003: exif.DateTimeOriginal,
+	0x9004: exif.DateTimeDigitized,
+	0x9010: exifOffsetTime,
+	0x9011: exifOffsetTimeOriginal,
+	0x9012: exifOffsetTimeDigitized,
+	0x9290: exif.SubSecTime,
+	0x9291: exif.SubSecTimeOriginal,
+	0x9292: exif.SubSecTimeDigitized,
+}
+
+// tiffTypeSizes are the sizes in bytes of the TIFF field types
+var tiffTypeSizes = map[uint16]int64{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8}
+
+// IsRawFile reports whether a file is a camera RAW file based on its extension
+func IsRawFile(filePath string) bool {
+	return rawExtensions[strings.ToLower(filepath.Ext(filePath))]
+}
+
+// validRawPairPolicy reports whether a RAW+JPEG pairing policy is known. An empty
+// policy is the default RawPairTogether.
+func validRawPairPolicy(policy string) bool {
+	switch policy {
+	case "", RawPairTogether, RawPairSubfolder, RawPairKeepJPEG, RawPairKeepRaw, RawPairIndependent:
+		return true
+	}
+	return false
+}
+
+// tiffEntry is a field of a TIFF image file directory
+type tiffEntry struct {
+	Tag   uint16
+	Type  uint16
+	Count uint32
+	Value []byte // The 4 value bytes: the value itself when it fits, otherwise its offset
+}
+
+// tiffReader reads the image file directories of a TIFF structure
+type tiffReader struct {
+	r     io.ReaderAt
+	base  int64 // Offset of the TIFF header, IFD and value offsets are relative to it
+	order binary.ByteOrder
+}
+
+// newTIFFReader checks the TIFF header at base and returns a reader with the offset of IFD0.
+// Besides the standard magic number it accepts the Olympus ORF and Panasonic RW2 variants.
+func newTIFFReader(r io.ReaderAt, base int64) (*tiffReader, uint32, bool) {
+	header := make([]byte, 8)
+	if _, err := r.ReadAt(header, base); err != nil {
+		return nil, 0, false
+	}
+
+	var order binary.ByteOrder
+	switch string(header[0:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, 0, false
+	}
+
+	switch order.Uint16(header[2:4]) {
+	case 42, 0x4F52, 0x5352, 0x55: // TIFF, ORF ("RO", "SR"), RW2
+	default:
+		return nil, 0, false
+	}
+	return &tiffReader{r: r, base: base, order: order}, order.Uint32(header[4:8]), true
+}
+
+// readIFD reads the entries of the image file directory at offset
+func (t *tiffReader) readIFD(offset uint32) ([]tiffEntry, error) {
+	countBuf := make([]byte, 2)
+	if _, err := t.r.ReadAt(countBuf, t.base+int64(offset)); err != nil {
+		return nil, err
+	}
+	count := int(t.order.Uint16(countBuf))
+	if count == 0 || count > 1000 {
+		return nil, fmt.Errorf("invalid IFD entry count %d", count)
+	}
+
+	buf := make([]byte, count*12)
+	if _, err := t.r.ReadAt(buf, t.base+int64(offset)+2); err != nil {
+		return nil, err
+	}
+
+	entries := make([]tiffEntry, count)
+	for i := range entries {
+		entry := buf[i*12 : i*12+12]
+		entries[i] = tiffEntry{
+			Tag:   t.order.Uint16(entry[0:2]),
+			Type:  t.order.Uint16(entry[2:4]),
+			Count: t.order.Uint32(entry[4:8]),
+			Value: entry[8:12],
+		}
+	}
+	return entries, nil
+}
+
+// value returns the value bytes of an entry, reading them from their offset when needed
+func (t *tiffReader) value(entry tiffEntry) ([]byte, error) {
+	size := tiffTypeSizes[entry.Type] * int64(entry.Count)
+	if size == 0 || size > 64*1024 {
+		return nil, fmt.Errorf("unsupported value of tag 0x%04x", entry.Tag)
+	}
+	if size <= 4 {
+		return entry.Value[:size], nil
+	}
+
+	buf := make([]byte, size)
+	if _, err := t.r.ReadAt(buf, t.base+int64(t.order.Uint32(entry.Value))); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// stringValue returns an ASCII value without its terminator and padding
+func (t *tiffReader) stringValue(entry tiffEntry) string {
+	if entry.Type != 2 {
+		return ""
+	}
+	value, err := t.value(entry)
+	if err != nil {
+		return ""
+	}
+	if end := bytes.IndexByte(value, 0); end >= 0 {
+		value = value[:end]
+	}
+	return strings.TrimSpace(string(value))
+}
+
+// uintValue returns the first value of a BYTE, SHORT or LONG entry
+func (t *tiffReader) uintValue(entry tiffEntry) (uint32, bool) {
+	switch entry.Type {
+	case 1, 7:
+		return uint32(entry.Value[0]), true
+	case 3:
+		return uint32(t.order.Uint16(entry.Value[0:2])), true
+	case 4:
+		return t.order.Uint32(entry.Value[0:4]), true
+	}
+	return 0, false
+}
+
+// rationalValues returns the values of a RATIONAL entry
+func (t *tiffReader) rationalValues(entry tiffEntry) []float64 {
+	if entry.Type != 5 {
+		return nil
+	}
+	value, err := t.value(entry)
+	if err != nil {
+		return nil
+	}
+
+	values := make([]float64, 0, entry.Count)
+	for i := 0; i+8 <= len(value); i += 8 {
+		num := t.order.Uint32(value[i : i+4])
+		den := t.order.Uint32(value[i+4 : i+8])
+		if den == 0 {
+			return nil
+		}
+		values = append(values, float64(num)/float64(den))
+	}
+	return values
+}
+
+// collectTags reads the string tags and orientation of an IFD into values and data,
+// following the EXIF and GPS sub-IFD pointers
+func (t *tiffReader) collectTags(offset uint32, values map[exif.FieldName]string, data *EXIFData, depth int) {
+	entries, err := t.readIFD(offset)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		if name, ok := rawStringTags[entry.Tag]; ok {
+			if value := t.stringValue(entry); value != "" {
+				values[name] = value
+			}
+			continue
+		}
+
+		switch entry.Tag {
+		case 0x0112: // Orientation
+			if orientation, ok := t.uintValue(entry); ok {
+				data.Orientation = int(orientation)
+			}
+		case 0x8769: // EXIF sub-IFD
+			if pointer, ok := t.uintValue(entry); ok && depth == 0 {
+				t.collectTags(pointer, values, data, depth+1)
+			}
+		case 0x8825: // GPS IFD
+			if pointer, ok := t.uintValue(entry); ok && depth == 0 {
+				t.collectGPS(pointer, data)
+			}
+		}
+	}
+}
+
+// collectGPS reads the position from a GPS IFD. 0,0 counts as no position.
+func (t *tiffReader) collectGPS(offset uint32, data *EXIFData) {
+	entries, err := t.readIFD(offset)
+	if err != nil {
+		return
+	}
+
+	var latRef, longRef string
+	var lat, long, alt []float64
+	belowSeaLevel := false
+	for _, entry := range entries {
+		switch entry.Tag {
+		case 0x0001:
+			latRef = t.stringValue(entry)
+		case 0x0002:
+			lat = t.rationalValues(entry)
+		case 0x0003:
+			longRef = t.stringValue(entry)
+		case 0x0004:
+			long = t.rationalValues(entry)
+		case 0x0005:
+			ref, _ := t.uintValue(entry)
+			belowSeaLevel = ref == 1
+		case 0x0006:
+			alt = t.rationalValues(entry)
+		}
+	}
+	if len(lat) != 3 || len(long) != 3 {
+		return
+	}
+
+	latitude := lat[0] + lat[1]/60 + lat[2]/3600
+	longitude := long[0] + long[1]/60 + long[2]/3600
+	if strings.EqualFold(latRef, "S") {
+		latitude = -latitude
+	}
+	if strings.EqualFold(longRef, "W") {
+		longitude = -longitude
+	}
+	if (latitude == 0 && longitude == 0) || math.Abs(latitude) > 90 || math.Abs(longitude) > 180 {
+		return
+	}
+
+	data.Latitude = latitude
+	data.Longitude = longitude
+	data.HasGPS = true
+	if len(alt) == 1 {
+		data.Altitude = alt[0]
+		if belowSeaLevel {
+			data.Altitude = -data.Altitude
+		}
+	}
+}
+
+// readRawEXIF reads the camera, date, orientation and position from the headers of a RAW file.
+// TIFF-based formats (CR2, NEF, ARW, DNG, ORF, RW2, PEF, ...) are read directly, CR3 through
+// its CMT boxes and RAF through its embedded JPEG. It reports whether anything useful was found.
+func readRawEXIF(file *os.File, opts EXIFOptions) (*EXIFData, bool) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, false
+	}
+
+	header := make([]byte, 16)
+	if _, err := file.ReadAt(header, 0); err != nil {
+		return nil, false
+	}
+
+	data := &EXIFData{}
+	values := make(map[exif.FieldName]string)
+	switch {
+	case bytes.HasPrefix(header, []byte("FUJIFILMCCD-RAW")):
+		return readRAFEXIF(file, opts)
+	case string(header[4:8]) == "ftyp":
+		readCR3Tags(file, info.Size(), values, data)
+	default:
+		t, ifd0, ok := newTIFFReader(file, 0)
+		if !ok {
+			return nil, false
+		}
+		t.collectTags(ifd0, values, data, 0)
+	}
+
+	data.Make = values[exif.Make]
+	data.Model = values[exif.Model]
+	data.Software = values[exif.Software]
+
+	defaultLocation := opts.DefaultLocation
+	if defaultLocation == nil {
+		defaultLocation = time.Local
+	}
+	applyEXIFDateTags(data, func(name exif.FieldName) string { return values[name] }, defaultLocation)
+
+	return data, data.Make != "" || data.HasDateTime
+}
+
+// readCR3Tags reads the TIFF structures Canon stores in CR3 files: CMT1 holds IFD0,
+// CMT2 the EXIF IFD and CMT4 the GPS IFD, each with its own TIFF header
+func readCR3Tags(r io.ReaderAt, size int64, values map[exif.FieldName]string, data *EXIFData) {
+	moov, ok := findBMFFBox(r, 0, size, "moov")
+	if !ok {
+		return
+	}
+
+	boxes, _ := readBMFFBoxes(r, moov.Offset, moov.End())
+	for _, box := range boxes {
+		id := make([]byte, 16)
+		if box.Type != "uuid" || box.Size < 16 {
+			continue
+		}
+		if _, err := r.ReadAt(id, box.Offset); err != nil || !bytes.Equal(id, canonCR3UUID) {
+			continue
+		}
+
+		children, _ := readBMFFBoxes(r, box.Offset+16, box.End())
+		for _, child := range children {
+			t, ifd, ok := newTIFFReader(r, child.Offset)
+			if !ok {
+				continue
+			}
+			switch child.Type {
+			case "CMT1", "CMT2":
+				// The depth keeps CMT2 from following pointers meant for other boxes
+				depth := 0
+				if child.Type == "CMT2" {
+					depth = 1
+				}
+				t.collectTags(ifd, values, data, depth)
+			case "CMT4":
+				t.collectGPS(ifd, data)
+			}
+		}
+		return
+	}
+}
+
+// readRAFEXIF decodes the EXIF of the JPEG preview embedded in a Fujifilm RAF file.
+// The RAF header records the preview offset and length at bytes 84 and 88.
+func readRAFEXIF(r io.ReaderAt, opts EXIFOptions) (*EXIFData, bool) {
+	header := make([]byte, 8)
+	if _, err := r.ReadAt(header, 84); err != nil {
+		return nil, false
+	}
+	offset := int64(binary.BigEndian.Uint32(header[0:4]))
+	length := int64(binary.BigEndian.Uint32(header[4:8]))
+	if offset == 0 || length == 0 {
+		return nil, false
+	}
+
+	x, err := exif.Decode(io.NewSectionReader(r, offset, length))
+	if err != nil && (x == nil || exif.IsCriticalError(err)) {
+		return nil, false
+	}
+	data := extractEXIFFields(x, opts)
+	return data, data.Make != "" || data.HasDateTime
+}
+
+// pairRawFiles matches RAW files with the JPEG or HEIF written next to them by the camera
+// (same directory, same stem) and returns the pairs keyed by the JPEG. Depending on the
+// policy the RAW or the JPEG of each pair is removed from the file list.
+func (fp *FileProcessor) pairRawFiles(files []string) ([]string, map[string]string) {
+	pairs := make(map[string]string)
+	policy := fp.config.RawPairs.Policy
+
+	stemKey := func(path string) string {
+		name := strings.ToLower(filepath.Base(path))
+		return filepath.Join(filepath.Dir(path), strings.TrimSuffix(name, filepath.Ext(name)))
+	}
+
+	// Index stills by directory + stem
+	stills := make(map[string]string)
+	for _, path := range files {
+		if !rawPairStillExts[strings.ToLower(filepath.Ext(path))] {
+			continue
+		}
+		if _, taken := stills[stemKey(path)]; !taken {
+			stills[stemKey(path)] = path
+		}
+	}
+	if len(stills) == 0 {
+		return files, pairs
+	}
+
+	removed := make(map[string]bool)
+	for _, path := range files {
+		if !IsRawFile(path) {
+			continue
+		}
+		still, found := stills[stemKey(path)]
+		if !found {
+			continue
+		}
+		if _, taken := pairs[still]; taken {
+			continue // Keep the first RAW, any others are organized on their own
+		}
+		pairs[still] = path
+
+		if policy == RawPairKeepRaw {
+			removed[still] = true
+			fp.skipFile(still, OutcomeSkipped, "RAW+JPEG pair keeps the RAW")
+		} else {
+			removed[path] = true
+			if policy == RawPairKeepJPEG {
+				fp.skipFile(path, OutcomeSkipped, "RAW+JPEG pair keeps the JPEG")
+			}
+		}
+	}
+
+	var remaining []string
+	for _, path := range files {
+		if !removed[path] {
+			remaining = append(remaining, path)
+		}
+	}
+	return remaining, pairs
+}
+
+// rawDestination returns where the RAW of a pair goes: next to its JPEG under the JPEG's
+// final name, or in a subfolder there for the "raw_subfolder" policy
+func (fo *FileOrganizer) rawDestination(rawPath, stillSource, stillDest string) string {
+	destPath := sidecarDestination(rawPath, stillSource, stillDest)
+	if fo.config.RawPairs.Policy == RawPairSubfolder && fo.config.RawPairs.Subfolder != "" {
+		destPath = filepath.Join(filepath.Dir(destPath), fo.config.RawPairs.Subfolder, filepath.Base(destPath))
+	}
+	return destPath
+}
+
+// placeRaw copies the RAW of a RAW+JPEG pair to its place next to the JPEG, together with
+// its own sidecars. It returns the hash of the RAW so the JPEG can record its pair.
+// Failures are logged but never fail the JPEG.
+func (fo *FileOrganizer) placeRaw(stillSource, stillDest, stillHash string, group FileGroup, move bool) string {
+	rawPath := group.Raw
+	hash, err := calculateFileHash(rawPath)
+	if err != nil {
+		fo.logger.LogError(LogLevelWarning, "Failed to hash RAW file", rawPath, err)
+		fo.recordGrouped(rawPath, "", OutcomeFailed, fmt.Sprintf("failed to calculate file hash: %v", err))
+		return ""
+	}
+
+	if exists, existingPath, _ := fo.db.CheckDuplicate(hash); exists {
+		fo.logger.LogFileDuplicate(fo.originOf(rawPath), existingPath, hash, "")
+		fo.recordGrouped(rawPath, "", OutcomeDuplicate, fmt.Sprintf("same content as %s", existingPath))
+		return hash
+	}
+
+	destPath := fo.rawDestination(rawPath, stillSource, stillDest)
+	if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
+		fo.logger.LogError(LogLevelWarning, "Failed to create RAW directory", rawPath, err)
+		fo.recordGrouped(rawPath, "", OutcomeFailed, fmt.Sprintf("failed to create destination directory: %v", err))
+		return ""
+	}
+	destPath = fo.resolveNamingConflict(destPath)
+	if err := fo.regularCopy(rawPath, destPath); err != nil {
+		fo.logger.LogError(LogLevelWarning, "Failed to copy RAW file", rawPath, err)
+		fo.recordGrouped(rawPath, "", OutcomeFailed, fmt.Sprintf("failed to copy file: %v", err))
+		return ""
+	}
+
+	record := FileRecord{
+		Hash:            hash,
+		OriginalPath:    fo.originOf(rawPath),
+		OriginalName:    filepath.Base(rawPath),
+		DestinationPath: destPath,
+		PairedWith:      stillHash,
+		Category:        fo.detector.GetFileTypeString(FileTypeImage),
+	}
+	if info, err := os.Stat(rawPath); err == nil {
+		record.Size = info.Size()
+		fc := newFileContext(rawPath, info, fo.detector, fo.metadata)
+		captureDate, dateSource := fc.CaptureDate()
+		record.CaptureDate = captureDate
+		record.DateSource = string(dateSource)
+		record.Make, record.Model = fc.CameraInfo()
+	}
+	if err := fo.db.AddRecord(record); err != nil {
+		fo.logger.LogError(LogLevelWarning, "Failed to add RAW file to database", rawPath, err)
+	}
+	fo.recordGrouped(rawPath, destPath, OutcomeOrganized, fmt.Sprintf("RAW file of %s", filepath.Base(stillSource)))
+
+	if len(group.RawSidecars) > 0 {
+		fo.placeSidecars(rawPath, destPath, hash, group.RawSidecars, move)
+	}
+
+	if move {
+		if err := os.Remove(rawPath); err != nil {
+			fo.logger.LogError(LogLevelWarning, "Failed to remove RAW file after move", rawPath, err)
+		}
+	}
+
+	fo.logger.LogOperation("RAW_PAIR", fmt.Sprintf("RAW file placed with %q - Destination: %q", stillDest, destPath), fo.originOf(rawPath))
+	return hash
+}
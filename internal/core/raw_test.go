@@ -0,0 +1,301 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"testing"
+	"time"
+)
+
+// testTIFFField is an entry of a test TIFF directory. A non-zero ifd makes it a LONG
+// pointer to that directory instead of carrying data.
+type testTIFFField struct {
+	tag  uint16
+	typ  uint16
+	data []byte
+	ifd  int
+}
+
+// testByteOrder reads and appends values in the byte order of a test TIFF
+type testByteOrder interface {
+	binary.ByteOrder
+	binary.AppendByteOrder
+}
+
+// asciiField builds a NUL-terminated ASCII field
+func asciiField(tag uint16, value string) testTIFFField {
+	return testTIFFField{tag: tag, typ: 2, data: append([]byte(value), 0)}
+}
+
+// rationalField builds a RATIONAL field from numerator/denominator pairs
+func rationalField(order testByteOrder, tag uint16, parts ...uint32) testTIFFField {
+	var data []byte
+	for _, part := range parts {
+		data = order.AppendUint32(data, part)
+	}
+	return testTIFFField{tag: tag, typ: 5, data: data}
+}
+
+// testTIFF lays out a TIFF header followed by the given directories, each followed by
+// the values that don't fit into its entries. Directory 0 is IFD0.
+func testTIFF(order testByteOrder, ifds ...[]testTIFFField) []byte {
+	// Every directory is followed by its out-of-line values
+	offsets := make([]uint32, len(ifds))
+	pos := uint32(8)
+	for i, fields := range ifds {
+		offsets[i] = pos
+		pos += uint32(2 + 12*len(fields) + 4)
+		for _, field := range fields {
+			if len(field.data) > 4 {
+				pos += uint32(len(field.data))
+			}
+		}
+	}
+
+	out := []byte("II")
+	if order == binary.BigEndian {
+		out = []byte("MM")
+	}
+	out = order.AppendUint16(out, 42)
+	out = order.AppendUint32(out, offsets[0])
+
+	for i, fields := range ifds {
+		dataPos := offsets[i] + uint32(2+12*len(fields)+4)
+		var values []byte
+		out = order.AppendUint16(out, uint16(len(fields)))
+		for _, field := range fields {
+			typ, data := field.typ, field.data
+			if field.ifd > 0 {
+				typ, data = 4, order.AppendUint32(nil, offsets[field.ifd])
+			}
+			count := uint32(len(data)) / uint32(tiffTypeSizes[typ])
+			out = order.AppendUint16(out, field.tag)
+			out = order.AppendUint16(out, typ)
+			out = order.AppendUint32(out, count)
+			if len(data) > 4 {
+				out = order.AppendUint32(out, dataPos+uint32(len(values)))
+				values = append(values, data...)
+			} else {
+				out = append(out, append(data, make([]byte, 4-len(data))...)...)
+			}
+		}
+		out = order.AppendUint32(out, 0) // No next IFD
+		out = append(out, values...)
+	}
+	return out
+}
+
+// testCameraIFDs returns IFD0, the EXIF IFD and the GPS IFD of a test camera file
+func testCameraIFDs(order testByteOrder) (ifd0, exifIFD, gpsIFD []testTIFFField) {
+	ifd0 = []testTIFFField{
+		asciiField(0x010F, "Canon"),
+		asciiField(0x0110, "Canon EOS R5"),
+		{tag: 0x0112, typ: 3, data: order.AppendUint16(nil, 6)},
+	}
+	exifIFD = []testTIFFField{
+		asciiField(0x9003, "2021:05:06 07:08:09"),
+		asciiField(0x9011, "+02:00"),
+	}
+	gpsIFD = []testTIFFField{
+		asciiField(0x0001, "N"),
+		rationalField(order, 0x0002, 52, 1, 30, 1, 0, 1),
+		asciiField(0x0003, "W"),
+		rationalField(order, 0x0004, 13, 1, 15, 1, 36, 1),
+	}
+	return ifd0, exifIFD, gpsIFD
+}
+
+// testTIFFRaw builds a TIFF-based RAW file (CR2, NEF, DNG, ...) with EXIF and GPS
+func testTIFFRaw(order testByteOrder) []byte {
+	ifd0, exifIFD, gpsIFD := testCameraIFDs(order)
+	ifd0 = append(ifd0, testTIFFField{tag: 0x8769, ifd: 1}, testTIFFField{tag: 0x8825, ifd: 2})
+	return testTIFF(order, ifd0, exifIFD, gpsIFD)
+}
+
+// testCR3 builds a CR3 file whose CMT boxes each hold a TIFF structure
+func testCR3() []byte {
+	order := binary.LittleEndian
+	ifd0, exifIFD, gpsIFD := testCameraIFDs(order)
+	uuid := box("uuid", canonCR3UUID,
+		box("CMT1", testTIFF(order, ifd0)),
+		box("CMT2", testTIFF(order, exifIFD)),
+		box("CMT4", testTIFF(order, gpsIFD)))
+	return append(box("ftyp", []byte("crx "), u32(1)), box("moov", box("mvhd", make([]byte, 20)), uuid)...)
+}
+
+// testRAF builds a Fujifilm RAF file with an embedded JPEG preview carrying EXIF
+func testRAF() []byte {
+	order := binary.BigEndian
+	ifd0, exifIFD, _ := testCameraIFDs(order)
+	ifd0[0], ifd0[1] = asciiField(0x010F, "FUJIFILM"), asciiField(0x0110, "X-T4")
+	tiff := testTIFF(order, append(ifd0, testTIFFField{tag: 0x8769, ifd: 1}), exifIFD)
+
+	app1 := append([]byte("Exif\x00\x00"), tiff...)
+	jpeg := bytes.Join([][]byte{{0xFF, 0xD8, 0xFF, 0xE1}, u16(uint16(len(app1) + 2)), app1, {0xFF, 0xD9}}, nil)
+
+	header := make([]byte, 100)
+	copy(header, "FUJIFILMCCD-RAW 0201FF383501")
+	binary.BigEndian.PutUint32(header[84:], uint32(len(header)))
+	binary.BigEndian.PutUint32(header[88:], uint32(len(jpeg)))
+	return append(header, jpeg...)
+}
+
+func TestReadRawEXIF(t *testing.T) {
+	taken := time.Date(2021, 5, 6, 7, 8, 9, 0, time.FixedZone("", 2*3600))
+
+	tests := []struct {
+		name        string
+		data        []byte
+		make, model string
+		orientation int
+		gps         bool
+	}{
+		{"TIFF little-endian", testTIFFRaw(binary.LittleEndian), "Canon", "Canon EOS R5", 6, true},
+		{"TIFF big-endian", testTIFFRaw(binary.BigEndian), "Canon", "Canon EOS R5", 6, true},
+		{"CR3", testCR3(), "Canon", "Canon EOS R5", 6, true},
+		{"RAF", testRAF(), "FUJIFILM", "X-T4", 6, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := os.Open(writeTestFile(t, "raw.bin", tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+
+			data, ok := readRawEXIF(file, EXIFOptions{DefaultLocation: time.UTC})
+			if !ok {
+				t.Fatal("readRawEXIF() found nothing")
+			}
+			if data.Make != tt.make || data.Model != tt.model || data.Orientation != tt.orientation {
+				t.Errorf("camera = %q %q orientation %d, want %q %q %d", data.Make, data.Model, data.Orientation, tt.make, tt.model, tt.orientation)
+			}
+			if !data.HasDateTime || !data.DateTime.Equal(taken) || !data.HasTimeZone {
+				t.Errorf("date = %v (%v, zone %v), want %v", data.DateTime, data.HasDateTime, data.HasTimeZone, taken)
+			}
+			if tt.gps {
+				if !data.HasGPS || data.Latitude != 52.5 || data.Longitude != -(13+15.0/60+36.0/3600) {
+					t.Errorf("GPS = %v, %v (%v)", data.Latitude, data.Longitude, data.HasGPS)
+				}
+			}
+		})
+	}
+}
+
+func TestReadRawEXIFMalformed(t *testing.T) {
+	order := binary.LittleEndian
+	valid := testTIFFRaw(order)
+
+	badIFDCount := append([]byte{}, valid...)
+	binary.LittleEndian.PutUint16(badIFDCount[8:], 5000)
+
+	cr3 := testCR3()
+	wrongUUID := bytes.Replace(cr3, canonCR3UUID, make([]byte, 16), 1)
+
+	raf := testRAF()
+	rafPastEnd := append([]byte{}, raf...)
+	binary.BigEndian.PutUint32(rafPastEnd[84:], 1<<20)
+	rafNoPreview := append([]byte{}, raf...)
+	binary.BigEndian.PutUint32(rafNoPreview[88:], 0)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"not a TIFF", []byte("this is not a raw file at all")},
+		{"TIFF wrong magic", append([]byte("II\x2b\x00"), valid[4:]...)},
+		{"TIFF header only", valid[:8]},
+		{"TIFF truncated in IFD0", valid[:20]},
+		{"TIFF IFD0 past the end", append([]byte("II*\x00\x00\x00\x10\x00"), make([]byte, 8)...)},
+		{"TIFF IFD count too large", badIFDCount},
+		{"TIFF no useful tags", testTIFF(order, []testTIFFField{{tag: 0x0112, typ: 3, data: order.AppendUint16(nil, 1)}})},
+		{"CR3 truncated", cr3[:len(cr3)-40]},
+		{"CR3 without moov", box("ftyp", []byte("crx "))},
+		{"CR3 wrong uuid", wrongUUID},
+		{"CR3 CMT box not TIFF", append(box("ftyp", []byte("crx ")), box("moov", box("uuid", canonCR3UUID, box("CMT1", []byte("garbage!"))))...)},
+		{"RAF header only", raf[:84]},
+		{"RAF preview past the end", rafPastEnd},
+		{"RAF no preview", rafNoPreview},
+		{"RAF truncated preview", raf[:len(raf)-30]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := os.Open(writeTestFile(t, "raw.bin", tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+
+			if data, ok := readRawEXIF(file, EXIFOptions{DefaultLocation: time.UTC}); ok {
+				t.Errorf("readRawEXIF() = %+v, want nothing", data)
+			}
+		})
+	}
+}
+
+func TestReadRawEXIFSkipsUnreadableValues(t *testing.T) {
+	data := testTIFFRaw(binary.LittleEndian)
+	// The Make value of IFD0 is stored out of line: point it past the end of the file
+	binary.LittleEndian.PutUint32(data[8+2+8:], 1<<20)
+
+	file, err := os.Open(writeTestFile(t, "raw.bin", data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	exifData, ok := readRawEXIF(file, EXIFOptions{DefaultLocation: time.UTC})
+	if !ok || exifData.Make != "" || exifData.Model != "Canon EOS R5" || !exifData.HasDateTime {
+		t.Errorf("readRawEXIF() = %+v, %v; want the tags other than Make", exifData, ok)
+	}
+}
+
+func TestTIFFReaderGPSRejectsBadValues(t *testing.T) {
+	order := binary.LittleEndian
+	tests := []struct {
+		name   string
+		fields []testTIFFField
+	}{
+		{"zero denominator", []testTIFFField{rationalField(order, 0x0002, 52, 0, 0, 1, 0, 1), rationalField(order, 0x0004, 13, 1, 0, 1, 0, 1)}},
+		{"null island", []testTIFFField{rationalField(order, 0x0002, 0, 1, 0, 1, 0, 1), rationalField(order, 0x0004, 0, 1, 0, 1, 0, 1)}},
+		{"latitude out of range", []testTIFFField{rationalField(order, 0x0002, 95, 1, 0, 1, 0, 1), rationalField(order, 0x0004, 13, 1, 0, 1, 0, 1)}},
+		{"missing longitude", []testTIFFField{rationalField(order, 0x0002, 52, 1, 0, 1, 0, 1)}},
+		{"wrong type", []testTIFFField{asciiField(0x0002, "52 30 0"), asciiField(0x0004, "13 0 0")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := testTIFF(order, tt.fields)
+			reader, ifd, ok := newTIFFReader(bytes.NewReader(data), 0)
+			if !ok {
+				t.Fatal("newTIFFReader() rejected the header")
+			}
+			var exifData EXIFData
+			reader.collectGPS(ifd, &exifData)
+			if exifData.HasGPS {
+				t.Errorf("collectGPS() = %v, %v, want no position", exifData.Latitude, exifData.Longitude)
+			}
+		})
+	}
+}
+
+func TestValidRawPairPolicy(t *testing.T) {
+	for policy, want := range map[string]bool{
+		"":                 true,
+		RawPairTogether:    true,
+		RawPairSubfolder:   true,
+		RawPairKeepJPEG:    true,
+		RawPairKeepRaw:     true,
+		RawPairIndependent: true,
+		"Together":         false,
+		"keep_both":        false,
+	} {
+		if got := validRawPairPolicy(policy); got != want {
+			t.Errorf("validRawPairPolicy(%q) = %v, want %v", policy, got, want)
+		}
+	}
+}
@@ -1,13 +1,26 @@
 package core
 
 import (
+	_ "embed"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
+// reportPage is the self-contained HTML report; the report data replaces its placeholder
+//
+//go:embed assets/report.html
+var reportPage string
+
+// reportDataPlaceholder marks where the report data goes in the HTML report
+const reportDataPlaceholder = "/*REPORT_DATA*/null"
+
 // StatusReport contains the final processing report
 type StatusReport struct {
 	SessionInfo struct {
@@ -21,13 +34,17 @@ type StatusReport struct {
 	} `json:"session_info"`
 	
 	FileCounts struct {
-		Total       int64 `json:"total_files"`
-		Processed   int64 `json:"processed_files"`
-		Skipped     int64 `json:"skipped_files"`
-		Duplicates  int64 `json:"duplicate_files"`
-		Errors      int64 `json:"error_files"`
+		Total         int64 `json:"total_files"`
+		Processed     int64 `json:"processed_files"`
+		Skipped       int64 `json:"skipped_files"`
+		Duplicates    int64 `json:"duplicate_files"`
+		Filtered      int64 `json:"filtered_files"`
+		Errors        int64 `json:"error_files"`
+		InferredDates int64 `json:"inferred_dates"`
 	} `json:"file_counts"`
 	
+	DateSources map[string]int64 `json:"date_sources"`
+
 	SizeInfo struct {
 		TotalBytes     int64  `json:"total_bytes"`
 		ProcessedBytes int64  `json:"processed_bytes"`
@@ -72,8 +89,9 @@ func NewReportGenerator(destDir string) *ReportGenerator {
 	}
 }
 
-// GenerateReport creates and saves the final status report
-func (rg *ReportGenerator) GenerateReport(stats ProcessingStats, categoryStats map[string]CategoryStats, errors []string, workerCount int, sourceDir, configFile string) error {
+// GenerateReport creates and saves the final status report, the HTML report and the
+// manifest of every file's decision
+func (rg *ReportGenerator) GenerateReport(stats ProcessingStats, categoryStats map[string]CategoryStats, manifest []ManifestEntry, workerCount int, sourceDir, configFile string) error {
 	report := StatusReport{}
 	
 	// Session info
@@ -90,7 +108,10 @@ func (rg *ReportGenerator) GenerateReport(stats ProcessingStats, categoryStats m
 	report.FileCounts.Processed = stats.ProcessedFiles
 	report.FileCounts.Skipped = stats.SkippedFiles
 	report.FileCounts.Duplicates = stats.DuplicateFiles
+	report.FileCounts.Filtered = stats.FilteredFiles
 	report.FileCounts.Errors = stats.ErrorFiles
+	report.FileCounts.InferredDates = stats.InferredDates
+	report.DateSources = stats.DateSources
 	
 	// Size info
 	report.SizeInfo.TotalBytes = stats.TotalSize
@@ -116,43 +137,71 @@ func (rg *ReportGenerator) GenerateReport(stats ProcessingStats, categoryStats m
 	}
 	
 	// Error summary
-	report.ErrorSummary = rg.summarizeErrors(errors)
+	report.ErrorSummary = rg.summarizeErrors(manifest)
+
+	// All files of a session share one timestamp
+	timestamp := time.Now().Format("2006-01-02_15-04-05")
 	
 	// Save JSON report
-	if err := rg.saveJSONReport(report); err != nil {
+	if err := rg.saveJSONReport(report, timestamp); err != nil {
 		return fmt.Errorf("failed to save JSON report: %w", err)
 	}
 	
 	// Save human-readable report
-	if err := rg.saveTextReport(report); err != nil {
+	if err := rg.saveTextReport(report, timestamp); err != nil {
 		return fmt.Errorf("failed to save text report: %w", err)
 	}
 	
+	// Save HTML report with charts and the searchable file table
+	if err := rg.saveHTMLReport(report, manifest, timestamp); err != nil {
+		return fmt.Errorf("failed to save HTML report: %w", err)
+	}
+
+	// Save the manifest for spreadsheets and scripts
+	if err := writeManifestCSV(rg.reportPath("zensort-manifest", timestamp, "csv"), manifest); err != nil {
+		return fmt.Errorf("failed to save manifest: %w", err)
+	}
+
 	return nil
 }
 
+// reportPath returns the path of a report file in the logs directory
+func (rg *ReportGenerator) reportPath(name, timestamp, ext string) string {
+	return filepath.Join(rg.destDir, "zensort-logs", fmt.Sprintf("%s_%s.%s", name, timestamp, ext))
+}
+
 // saveJSONReport saves the report in JSON format
-func (rg *ReportGenerator) saveJSONReport(report StatusReport) error {
-	timestamp := time.Now().Format("2006-01-02_15-04-05")
-	filename := fmt.Sprintf("zensort-report_%s.json", timestamp)
-	filepath := filepath.Join(rg.destDir, "zensort-logs", filename)
-	
+func (rg *ReportGenerator) saveJSONReport(report StatusReport, timestamp string) error {
 	data, err := json.MarshalIndent(report, "", "  ")
 	if err != nil {
 		return err
 	}
 	
-	return os.WriteFile(filepath, data, 0644)
+	return os.WriteFile(rg.reportPath("zensort-report", timestamp, "json"), data, 0644)
 }
 
 // saveTextReport saves a human-readable report
-func (rg *ReportGenerator) saveTextReport(report StatusReport) error {
-	timestamp := time.Now().Format("2006-01-02_15-04-05")
-	filename := fmt.Sprintf("zensort-report_%s.txt", timestamp)
-	filepath := filepath.Join(rg.destDir, "zensort-logs", filename)
-	
+func (rg *ReportGenerator) saveTextReport(report StatusReport, timestamp string) error {
 	content := rg.formatTextReport(report)
-	return os.WriteFile(filepath, []byte(content), 0644)
+	return os.WriteFile(rg.reportPath("zensort-report", timestamp, "txt"), []byte(content), 0644)
+}
+
+// saveHTMLReport saves a self-contained HTML report that works without network access
+func (rg *ReportGenerator) saveHTMLReport(report StatusReport, manifest []ManifestEntry, timestamp string) error {
+	if manifest == nil {
+		manifest = []ManifestEntry{}
+	}
+	// json.Marshal escapes "<", so file names can't close the script element
+	data, err := json.Marshal(struct {
+		Report StatusReport    `json:"report"`
+		Files  []ManifestEntry `json:"files"`
+	}{report, manifest})
+	if err != nil {
+		return err
+	}
+
+	page := strings.Replace(reportPage, reportDataPlaceholder, string(data), 1)
+	return os.WriteFile(rg.reportPath("zensort-report", timestamp, "html"), []byte(page), 0644)
 }
 
 // formatTextReport creates a human-readable report
@@ -174,7 +223,9 @@ File Processing Summary:
   Successfully Processed: %d
   Skipped Files: %d
   Duplicate Files: %d
+  Filtered Files: %d
   Files with Errors: %d
+  Dates Inferred from Filenames: %d
 
 Data Processing Summary:
   Total Data Size: %s (%d bytes)
@@ -197,7 +248,9 @@ Category Breakdown:
 		report.FileCounts.Processed,
 		report.FileCounts.Skipped,
 		report.FileCounts.Duplicates,
+		report.FileCounts.Filtered,
 		report.FileCounts.Errors,
+		report.FileCounts.InferredDates,
 		report.SizeInfo.TotalHuman,
 		report.SizeInfo.TotalBytes,
 		report.SizeInfo.ProcessedHuman,
@@ -207,7 +260,13 @@ Category Breakdown:
 	)
 	
 	// Add category breakdown
-	for category, stats := range report.CategoryBreakdown {
+	categories := make([]string, 0, len(report.CategoryBreakdown))
+	for category := range report.CategoryBreakdown {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	for _, category := range categories {
+		stats := report.CategoryBreakdown[category]
 		content += fmt.Sprintf("  %s: %d files (%s)\n", category, stats.Count, stats.SizeHuman)
 	}
 	
@@ -230,39 +289,51 @@ Category Breakdown:
 	return content
 }
 
-// summarizeErrors creates a summary of errors
-func (rg *ReportGenerator) summarizeErrors(errors []string) []ErrorInfo {
+// summarizeErrors groups the failed files by error type, most frequent first
+func (rg *ReportGenerator) summarizeErrors(manifest []ManifestEntry) []ErrorInfo {
 	errorMap := make(map[string][]string)
+	var errorTypes []string
 	
-	// Group errors by type (simplified approach)
-	for _, err := range errors {
-		errorType := "General Error"
-		if len(err) > 50 {
-			errorType = err[:50] + "..."
-		} else {
-			errorType = err
+	for _, entry := range manifest {
+		if entry.Outcome != OutcomeFailed {
+			continue
+		}
+		kind := errorType(entry.Reason)
+		if _, seen := errorMap[kind]; !seen {
+			errorTypes = append(errorTypes, kind)
 		}
-		
-		errorMap[errorType] = append(errorMap[errorType], err)
+		errorMap[kind] = append(errorMap[kind], entry.Source)
 	}
 	
 	var errorSummary []ErrorInfo
-	for errorType, errorList := range errorMap {
-		info := ErrorInfo{
-			Type:  errorType,
-			Count: len(errorList),
-		}
-		
-		// Add up to 3 sample files
-		sampleCount := min(len(errorList), 3)
-		info.SampleFiles = errorList[:sampleCount]
-		
-		errorSummary = append(errorSummary, info)
+	for _, kind := range errorTypes {
+		files := errorMap[kind]
+		errorSummary = append(errorSummary, ErrorInfo{
+			Type:        kind,
+			Count:       len(files),
+			SampleFiles: files[:min(len(files), 3)], // Up to 3 sample files
+		})
 	}
+	sort.SliceStable(errorSummary, func(i, j int) bool {
+		return errorSummary[i].Count > errorSummary[j].Count
+	})
 	
 	return errorSummary
 }
 
+// errorType names the kind of an error by its outermost description ("failed to copy file"),
+// so errors that differ only in file names or wrapped details are counted together
+func errorType(message string) string {
+	if i := strings.Index(message, ": "); i > 0 {
+		message = message[:i]
+	}
+	if message == "" {
+		return "Unknown error"
+	}
+	first, size := utf8.DecodeRuneInString(message)
+	return string(unicode.ToUpper(first)) + message[size:]
+}
+
 // formatBytes converts bytes to human-readable format
 func formatBytes(bytes int64) string {
 	const unit = 1024
@@ -0,0 +1,276 @@
+package core
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"zensort/internal/config"
+)
+
+// RuleAction defines what happens to a file matched by a rule
+type RuleAction string
+
+const (
+	RuleActionCopy   RuleAction = "copy"
+	RuleActionMove   RuleAction = "move"
+	RuleActionSkip   RuleAction = "skip"
+	RuleActionExport RuleAction = "export"
+)
+
+// Built-in classification steps, in the order of the default cascade
+const (
+	BuiltinHidden      = "hidden"
+	BuiltinScreenshot  = "screenshot"
+	BuiltinEdited      = "edited"
+	BuiltinImage       = "image"
+	BuiltinMotionPhoto = "motion_photo"
+	BuiltinShortVideo  = "short_video"
+	BuiltinVideo       = "video"
+	BuiltinAudio       = "audio"
+	BuiltinDocument    = "document"
+	BuiltinUnknown     = "unknown"
+)
+
+// builtinSteps is the built-in classification cascade used when no rule matches
+var builtinSteps = []string{
+	BuiltinHidden, BuiltinScreenshot, BuiltinEdited, BuiltinImage, BuiltinMotionPhoto,
+	BuiltinShortVideo, BuiltinVideo, BuiltinAudio, BuiltinDocument, BuiltinUnknown,
+}
+
+// compiledRule is a configured rule with its regular expressions compiled
+type compiledRule struct {
+	rule       config.Rule
+	action     RuleAction
+	extensions []string // Lowercase, without the leading dot
+	pathRegex  *regexp.Regexp
+	nameRegex  *regexp.Regexp
+	exifRegex  map[string]*regexp.Regexp
+}
+
+// RuleEngine evaluates the ordered rule list from the configuration
+type RuleEngine struct {
+	rules []compiledRule
+}
+
+// NewRuleEngine compiles and validates the configured rules
+func NewRuleEngine(rules []config.Rule) (*RuleEngine, error) {
+	engine := &RuleEngine{}
+
+	for i, rule := range rules {
+		name := rule.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+
+		compiled := compiledRule{rule: rule, action: RuleAction(strings.ToLower(rule.Action))}
+		if compiled.action == "" {
+			compiled.action = RuleActionCopy
+		}
+
+		switch compiled.action {
+		case RuleActionCopy, RuleActionMove, RuleActionSkip, RuleActionExport:
+		default:
+			return nil, fmt.Errorf("rule %s: unknown action %q", name, rule.Action)
+		}
+
+		if rule.Builtin != "" && !isBuiltinStep(rule.Builtin) {
+			return nil, fmt.Errorf("rule %s: unknown builtin %q", name, rule.Builtin)
+		}
+
+		for _, ext := range rule.Match.Extensions {
+			compiled.extensions = append(compiled.extensions, normalizeExtension(ext))
+		}
+
+		var err error
+		if rule.Match.PathRegex != "" {
+			if compiled.pathRegex, err = regexp.Compile(rule.Match.PathRegex); err != nil {
+				return nil, fmt.Errorf("rule %s: invalid path_regex: %w", name, err)
+			}
+		}
+		if rule.Match.NameRegex != "" {
+			if compiled.nameRegex, err = regexp.Compile(rule.Match.NameRegex); err != nil {
+				return nil, fmt.Errorf("rule %s: invalid name_regex: %w", name, err)
+			}
+		}
+
+		if len(rule.Match.EXIF) > 0 {
+			compiled.exifRegex = make(map[string]*regexp.Regexp)
+			for field, pattern := range rule.Match.EXIF {
+				re, err := regexp.Compile(pattern)
+				if err != nil {
+					return nil, fmt.Errorf("rule %s: invalid exif pattern for %s: %w", name, field, err)
+				}
+				compiled.exifRegex[strings.ToLower(field)] = re
+			}
+		}
+
+		engine.rules = append(engine.rules, compiled)
+	}
+
+	return engine, nil
+}
+
+// isBuiltinStep checks whether a name refers to a built-in classification step
+func isBuiltinStep(name string) bool {
+	for _, step := range builtinSteps {
+		if step == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Match returns the first rule whose conditions match the file, or nil if none does.
+// builtinMatch evaluates the built-in step named by a rule.
+func (re *RuleEngine) Match(fc *fileContext, builtinMatch func(step string, fc *fileContext) bool) *compiledRule {
+	for i := range re.rules {
+		rule := &re.rules[i]
+		if !rule.matches(fc) {
+			continue
+		}
+		if rule.rule.Builtin != "" && !builtinMatch(rule.rule.Builtin, fc) {
+			continue
+		}
+		return rule
+	}
+	return nil
+}
+
+// matches checks all configured conditions of a rule, cheapest first
+func (cr *compiledRule) matches(fc *fileContext) bool {
+	match := &cr.rule.Match
+
+	if len(match.FileTypes) > 0 && !containsFold(match.FileTypes, fileTypeName(fc.fileType)) {
+		return false
+	}
+
+	if len(cr.extensions) > 0 && !containsFold(cr.extensions, normalizeExtension(filepath.Ext(fc.origin))) {
+		return false
+	}
+
+	if match.MinSize > 0 && fc.info.Size() < match.MinSize {
+		return false
+	}
+	if match.MaxSize > 0 && fc.info.Size() > match.MaxSize {
+		return false
+	}
+
+	if cr.nameRegex != nil && !cr.nameRegex.MatchString(filepath.Base(fc.origin)) {
+		return false
+	}
+	if cr.pathRegex != nil && !cr.pathRegex.MatchString(filepath.ToSlash(fc.origin)) {
+		return false
+	}
+
+	if len(match.MimeTypes) > 0 && !matchesMimeType(match.MimeTypes, fc.MIME()) {
+		return false
+	}
+
+	if len(cr.exifRegex) > 0 {
+		if fc.fileType != FileTypeImage {
+			return false
+		}
+		exifData, err := fc.EXIF()
+		if err != nil || exifData == nil {
+			return false
+		}
+		for field, re := range cr.exifRegex {
+			if !re.MatchString(exifFieldValue(exifData, field)) {
+				return false
+			}
+		}
+	}
+
+	if match.MinDurationSeconds > 0 || match.MaxDurationSeconds > 0 {
+		if fc.fileType != FileTypeVideo && fc.fileType != FileTypeAudio {
+			return false
+		}
+		duration, err := fc.Duration()
+		if err != nil {
+			return false
+		}
+		if match.MinDurationSeconds > 0 && duration < secondsToDuration(match.MinDurationSeconds) {
+			return false
+		}
+		if match.MaxDurationSeconds > 0 && duration > secondsToDuration(match.MaxDurationSeconds) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// fileTypeName returns the rule name of a file type
+func fileTypeName(fileType FileType) string {
+	switch fileType {
+	case FileTypeImage:
+		return "image"
+	case FileTypeVideo:
+		return "video"
+	case FileTypeAudio:
+		return "audio"
+	case FileTypeDocument:
+		return "document"
+	default:
+		return "unknown"
+	}
+}
+
+// matchesMimeType checks a MIME type against exact entries and "type/*" wildcards
+func matchesMimeType(patterns []string, mimeType string) bool {
+	if mimeType == "" {
+		return false
+	}
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if strings.HasSuffix(pattern, "/*") {
+			if strings.HasPrefix(mimeType, strings.TrimSuffix(pattern, "*")) {
+				return true
+			}
+		} else if pattern == mimeType {
+			return true
+		}
+	}
+	return false
+}
+
+// exifFieldValue returns an EXIF field by its rule name
+func exifFieldValue(exifData *EXIFData, field string) string {
+	switch field {
+	case "make":
+		return exifData.Make
+	case "model":
+		return exifData.Model
+	case "software":
+		return exifData.Software
+	case "datetime":
+		if exifData.HasDateTime {
+			return exifData.DateTime.Format("2006-01-02 15:04:05")
+		}
+	}
+	return ""
+}
+
+// normalizeExtension returns an extension in lowercase without its leading dot, so rules
+// can list "jpg" as well as ".JPG"
+func normalizeExtension(ext string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), "."))
+}
+
+// containsFold checks whether a list contains a value, ignoring case
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// secondsToDuration converts fractional seconds to a duration
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
@@ -0,0 +1,190 @@
+package core
+
+import (
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"zensort/internal/config"
+)
+
+// testFileContext writes a file of the given size and returns its context
+func testFileContext(t *testing.T, name string, size int, fileType FileType) *fileContext {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &fileContext{path: path, origin: path, info: info, fileType: fileType}
+}
+
+func TestRuleMatches(t *testing.T) {
+	tests := []struct {
+		name     string
+		match    config.RuleMatch
+		file     string
+		size     int
+		fileType FileType
+		want     bool
+	}{
+		{"no conditions", config.RuleMatch{}, "a.bin", 1, FileTypeUnknown, true},
+		{"extension with dot", config.RuleMatch{Extensions: []string{".jpg"}}, "a.jpg", 1, FileTypeImage, true},
+		{"extension without dot", config.RuleMatch{Extensions: []string{"jpg"}}, "a.jpg", 1, FileTypeImage, true},
+		{"extension case", config.RuleMatch{Extensions: []string{"JPG"}}, "a.Jpg", 1, FileTypeImage, true},
+		{"extension with spaces", config.RuleMatch{Extensions: []string{" .png "}}, "a.png", 1, FileTypeImage, true},
+		{"other extension", config.RuleMatch{Extensions: []string{"jpg"}}, "a.jpeg", 1, FileTypeImage, false},
+		{"no extension", config.RuleMatch{Extensions: []string{"jpg"}}, "jpg", 1, FileTypeImage, false},
+		{"file type", config.RuleMatch{FileTypes: []string{"Video"}}, "a.mp4", 1, FileTypeVideo, true},
+		{"other file type", config.RuleMatch{FileTypes: []string{"audio"}}, "a.mp4", 1, FileTypeVideo, false},
+		{"min size", config.RuleMatch{MinSize: 10}, "a.bin", 9, FileTypeUnknown, false},
+		{"max size", config.RuleMatch{MaxSize: 10}, "a.bin", 10, FileTypeUnknown, true},
+		{"name regex", config.RuleMatch{NameRegex: `^IMG_\d+`}, "IMG_0001.jpg", 1, FileTypeImage, true},
+		{"name regex miss", config.RuleMatch{NameRegex: `^IMG_\d+`}, "DSC_0001.jpg", 1, FileTypeImage, false},
+		{"exif on non-image", config.RuleMatch{EXIF: map[string]string{"make": "Canon"}}, "a.mp4", 1, FileTypeVideo, false},
+		{"duration on image", config.RuleMatch{MaxDurationSeconds: 10}, "a.jpg", 1, FileTypeImage, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine, err := NewRuleEngine([]config.Rule{{Name: tt.name, Match: tt.match}})
+			if err != nil {
+				t.Fatalf("NewRuleEngine() error = %v", err)
+			}
+			fc := testFileContext(t, tt.file, tt.size, tt.fileType)
+			if got := engine.rules[0].matches(fc); got != tt.want {
+				t.Errorf("matches(%s) = %v, want %v", tt.file, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRuleMatchPathRegexUsesOrigin(t *testing.T) {
+	engine, err := NewRuleEngine([]config.Rule{{Name: "camera", Match: config.RuleMatch{PathRegex: `!/DCIM/`}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fc := testFileContext(t, "IMG_0001.jpg", 1, FileTypeImage)
+	if engine.rules[0].matches(fc) {
+		t.Error("matched the staging path")
+	}
+	fc.origin = "export.zip!/DCIM/Camera/IMG_0001.jpg"
+	if !engine.rules[0].matches(fc) {
+		t.Error("did not match the archive origin")
+	}
+}
+
+func TestRuleEngineMatchOrder(t *testing.T) {
+	engine, err := NewRuleEngine([]config.Rule{
+		{Name: "screenshots", Builtin: BuiltinScreenshot},
+		{Name: "jpegs", Match: config.RuleMatch{Extensions: []string{"jpg"}}, Action: "SKIP"},
+		{Name: "everything"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	never := func(string, *fileContext) bool { return false }
+	always := func(string, *fileContext) bool { return true }
+
+	fc := testFileContext(t, "a.jpg", 1, FileTypeImage)
+	if rule := engine.Match(fc, always); rule == nil || rule.rule.Name != "screenshots" {
+		t.Errorf("Match() = %v, want screenshots", rule)
+	}
+	rule := engine.Match(fc, never)
+	if rule == nil || rule.rule.Name != "jpegs" {
+		t.Fatalf("Match() = %v, want jpegs", rule)
+	}
+	if rule.action != RuleActionSkip {
+		t.Errorf("action = %q, want %q", rule.action, RuleActionSkip)
+	}
+	if rule := engine.Match(testFileContext(t, "a.png", 1, FileTypeImage), never); rule == nil || rule.rule.Name != "everything" {
+		t.Errorf("Match() = %v, want everything", rule)
+	}
+	if rule := engine.rules[2]; rule.action != RuleActionCopy {
+		t.Errorf("default action = %q, want %q", rule.action, RuleActionCopy)
+	}
+}
+
+func TestRuleDestinationExportsBelowDestination(t *testing.T) {
+	srcDir, destDir := t.TempDir(), t.TempDir()
+	src := filepath.Join(srcDir, "photo.jpg")
+	file, err := os.Create(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := jpeg.Encode(file, image.NewRGBA(image.Rect(0, 0, 16, 16)), nil); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	cfg := config.DefaultConfig()
+	cfg.Directories.Images = "Pictures"
+	cfg.Rules = []config.Rule{{
+		Name:        "phone",
+		Match:       config.RuleMatch{Extensions: []string{"jpg"}},
+		Destination: "Phone/{year}",
+		Action:      string(RuleActionExport),
+	}}
+	organizer, _ := newTestOrganizer(t, cfg, destDir)
+
+	outcome := organizer.OrganizeFile(src)
+	if outcome.Outcome != OutcomeOrganized {
+		t.Fatalf("OrganizeFile() = %v (%s), want organized", outcome.Outcome, outcome.Reason)
+	}
+	if !strings.HasPrefix(outcome.Destination, filepath.Join(destDir, "Phone")+string(filepath.Separator)) {
+		t.Errorf("destination = %s, want below Phone", outcome.Destination)
+	}
+	exports, err := filepath.Glob(filepath.Join(destDir, "Pictures", cfg.ImageDirs.Exports, "Collections", "photo.*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exports) != 1 {
+		t.Errorf("exports below the destination = %v, want one", exports)
+	}
+}
+
+func TestNewRuleEngineErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		rule config.Rule
+	}{
+		{"unknown action", config.Rule{Action: "delete"}},
+		{"unknown builtin", config.Rule{Builtin: "pictures"}},
+		{"bad path regex", config.Rule{Match: config.RuleMatch{PathRegex: "("}}},
+		{"bad name regex", config.Rule{Match: config.RuleMatch{NameRegex: "[a-"}}},
+		{"bad exif regex", config.Rule{Match: config.RuleMatch{EXIF: map[string]string{"make": "*"}}}},
+	}
+
+	for _, tt := range tests {
+		if _, err := NewRuleEngine([]config.Rule{tt.rule}); err == nil {
+			t.Errorf("%s: NewRuleEngine() error = nil", tt.name)
+		}
+	}
+}
+
+func TestMatchesMimeType(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		mimeType string
+		want     bool
+	}{
+		{[]string{"image/jpeg"}, "image/jpeg", true},
+		{[]string{"IMAGE/*"}, "image/png", true},
+		{[]string{"image/*"}, "video/mp4", false},
+		{[]string{"image/*"}, "", false},
+		{[]string{"application/pdf"}, "application/pdfx", false},
+	}
+
+	for _, tt := range tests {
+		if got := matchesMimeType(tt.patterns, tt.mimeType); got != tt.want {
+			t.Errorf("matchesMimeType(%v, %q) = %v, want %v", tt.patterns, tt.mimeType, got, tt.want)
+		}
+	}
+}
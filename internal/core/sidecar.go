@@ -0,0 +1,159 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// videoSidecarExts are sidecars that describe videos rather than photos (subtitles, GoPro previews)
+var videoSidecarExts = map[string]bool{".srt": true, ".thm": true, ".lrv": true}
+
+// groupSidecars splits scanned files into primary files and sidecars that belong to them.
+// A sidecar belongs to a primary file in the same directory with the same stem
+// (IMG_1234.xmp, IMG_1234.AAE) or the same full name (IMG_1234.JPG.xmp).
+// Sidecars without a matching primary file stay in the file list.
+func (fp *FileProcessor) groupSidecars(files []string) ([]string, map[string][]string) {
+	sidecars := make(map[string][]string)
+	if !fp.config.Sidecars.Enabled || len(fp.config.Sidecars.Extensions) == 0 {
+		return files, sidecars
+	}
+
+	isSidecar := func(path string) bool {
+		return containsFold(fp.config.Sidecars.Extensions, filepath.Ext(path))
+	}
+
+	// Index primary files by directory + lowercase name and stem
+	byName := make(map[string]string)
+	byStem := make(map[string][]string)
+	for _, path := range files {
+		if isSidecar(path) {
+			continue
+		}
+		dir := filepath.Dir(path)
+		name := strings.ToLower(filepath.Base(path))
+		byName[filepath.Join(dir, name)] = path
+		stemKey := filepath.Join(dir, strings.TrimSuffix(name, filepath.Ext(name)))
+		byStem[stemKey] = append(byStem[stemKey], path)
+	}
+
+	var primaries []string
+	for _, path := range files {
+		if !isSidecar(path) {
+			primaries = append(primaries, path)
+			continue
+		}
+
+		dir := filepath.Dir(path)
+		name := strings.ToLower(filepath.Base(path))
+		stemKey := filepath.Join(dir, strings.TrimSuffix(name, filepath.Ext(name)))
+
+		// Full-name match first (darktable style), then same stem
+		primary, found := byName[stemKey]
+		if !found {
+			primary, found = fp.preferredPrimary(byStem[stemKey], filepath.Ext(name))
+		}
+
+		if found {
+			sidecars[primary] = append(sidecars[primary], path)
+		} else {
+			primaries = append(primaries, path) // Orphaned sidecar, organize it on its own
+		}
+	}
+
+	return primaries, sidecars
+}
+
+// preferredPrimary picks the file a sidecar most likely describes when several share its stem
+// (e.g. IMG_1234.HEIC and IMG_1234.MOV): videos for subtitles and previews, RAW files for
+// XMP edits, images otherwise
+func (fp *FileProcessor) preferredPrimary(candidates []string, sidecarExt string) (string, bool) {
+	if len(candidates) == 0 {
+		return "", false
+	}
+
+	// Raw developers write their XMP files for the RAW of a RAW+JPEG pair
+	if strings.EqualFold(sidecarExt, ".xmp") {
+		for _, candidate := range candidates {
+			if IsRawFile(candidate) {
+				return candidate, true
+			}
+		}
+	}
+
+	preferred := FileTypeImage
+	if videoSidecarExts[strings.ToLower(sidecarExt)] {
+		preferred = FileTypeVideo
+	}
+	for _, candidate := range candidates {
+		if fp.detector.DetectFileType(candidate) == preferred {
+			return candidate, true
+		}
+	}
+	return candidates[0], true
+}
+
+// sidecarDestination returns where a sidecar goes, keeping its suffix relative to the primary's name
+func sidecarDestination(sidecarPath, primarySource, primaryDest string) string {
+	sidecarName := filepath.Base(sidecarPath)
+	primaryName := filepath.Base(primarySource)
+	destName := filepath.Base(primaryDest)
+
+	var newName string
+	if len(sidecarName) > len(primaryName) && strings.EqualFold(sidecarName[:len(primaryName)], primaryName) {
+		// IMG_1234.JPG.xmp -> <renamed>.JPG.xmp
+		newName = destName + sidecarName[len(primaryName):]
+	} else {
+		// IMG_1234.xmp -> <renamed>.xmp
+		newName = strings.TrimSuffix(destName, filepath.Ext(destName)) + filepath.Ext(sidecarName)
+	}
+
+	return filepath.Join(filepath.Dir(primaryDest), newName)
+}
+
+// placeSidecars copies the sidecars of a primary file next to wherever the primary went.
+// Failures are logged but never fail the primary file.
+func (fo *FileOrganizer) placeSidecars(primarySource, primaryDest, primaryHash string, sidecars []string, move bool) {
+	for _, sidecar := range sidecars {
+		destPath := fo.resolveNamingConflict(sidecarDestination(sidecar, primarySource, primaryDest))
+
+		if err := fo.regularCopy(sidecar, destPath); err != nil {
+			fo.logger.LogError(LogLevelWarning, "Failed to copy sidecar file", sidecar, err)
+			fo.recordGrouped(sidecar, "", OutcomeFailed, fmt.Sprintf("failed to copy file: %v", err))
+			continue
+		}
+
+		info, err := os.Stat(sidecar)
+		var size int64
+		if err == nil {
+			size = info.Size()
+		}
+
+		// Record the sidecar unless identical content is already known
+		if hash, err := calculateFileHash(sidecar); err == nil {
+			if exists, _, _ := fo.db.CheckDuplicate(hash); !exists {
+				record := FileRecord{
+					Hash:            hash,
+					OriginalPath:    fo.originOf(sidecar),
+					DestinationPath: destPath,
+					Size:            size,
+					SidecarOf:       primaryHash,
+				}
+				if err := fo.db.AddRecord(record); err != nil {
+					fo.logger.LogError(LogLevelWarning, "Failed to add sidecar to database", sidecar, err)
+				}
+			}
+		}
+
+		fo.recordGrouped(sidecar, destPath, OutcomeOrganized, fmt.Sprintf("sidecar of %s", filepath.Base(primarySource)))
+
+		if move {
+			if err := os.Remove(sidecar); err != nil {
+				fo.logger.LogError(LogLevelWarning, "Failed to remove sidecar after move", sidecar, err)
+			}
+		}
+
+		fo.logger.LogOperation("SIDECAR", fmt.Sprintf("Sidecar placed with %q - Destination: %q", primaryDest, destPath), sidecar)
+	}
+}
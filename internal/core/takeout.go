@@ -0,0 +1,278 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// takeoutDateTag marks EXIF dates that came from a Google Takeout sidecar
+const takeoutDateTag = "Takeout"
+
+// takeoutMaxStemLength is where Google truncates the names of Takeout JSON files (before ".json")
+const takeoutMaxStemLength = 46
+
+// takeoutSupplementalSuffix is the suffix of newer Takeout JSON names, often truncated
+const takeoutSupplementalSuffix = "supplemental-metadata"
+
+// takeoutMinSuffixLength is how much of the supplemental suffix must survive truncation
+// for it to be stripped from a name that does not end in a media extension
+const takeoutMinSuffixLength = 3
+
+// takeoutDuplicateSuffix matches the "(1)" Google appends to duplicate names
+var takeoutDuplicateSuffix = regexp.MustCompile(`\((\d+)\)$`)
+
+// TakeoutMetadata is the metadata Google Photos Takeout stores next to each photo or video
+type TakeoutMetadata struct {
+	Title        string
+	Description  string
+	TakenTime    time.Time
+	HasTakenTime bool
+	Latitude     float64
+	Longitude    float64
+	Altitude     float64
+	HasGPS       bool
+}
+
+// takeoutFile mirrors the JSON layout of a Takeout metadata file
+type takeoutFile struct {
+	Title          string           `json:"title"`
+	Description    string           `json:"description"`
+	PhotoTakenTime *takeoutTime     `json:"photoTakenTime"`
+	CreationTime   *takeoutTime     `json:"creationTime"`
+	GeoData        *takeoutGeoData  `json:"geoData"`
+	GeoDataExif    *takeoutGeoData  `json:"geoDataExif"`
+	AlbumData      *json.RawMessage `json:"albumData"`
+}
+
+// takeoutTime is a Takeout timestamp (Unix seconds as a string)
+type takeoutTime struct {
+	Timestamp string `json:"timestamp"`
+}
+
+// takeoutGeoData is a Takeout location (0, 0 means unknown)
+type takeoutGeoData struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Altitude  float64 `json:"altitude"`
+}
+
+// readTakeoutFile parses a Takeout JSON file
+func readTakeoutFile(path string) (*takeoutFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file takeoutFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid Takeout metadata %s: %w", filepath.Base(path), err)
+	}
+	return &file, nil
+}
+
+// ReadTakeoutMetadata reads the metadata of a Takeout JSON sidecar.
+// Timestamps are converted to the given location.
+func ReadTakeoutMetadata(path string, location *time.Location) (*TakeoutMetadata, error) {
+	file, err := readTakeoutFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if location == nil {
+		location = time.Local
+	}
+
+	metadata := &TakeoutMetadata{
+		Title:       file.Title,
+		Description: strings.TrimSpace(file.Description),
+	}
+
+	// photoTakenTime is the capture time, creationTime only the upload time
+	if file.PhotoTakenTime != nil {
+		if seconds, err := strconv.ParseInt(file.PhotoTakenTime.Timestamp, 10, 64); err == nil {
+			takenTime := time.Unix(seconds, 0).In(location)
+			if isPlausibleDate(takenTime) {
+				metadata.TakenTime = takenTime
+				metadata.HasTakenTime = true
+			}
+		}
+	}
+
+	// geoData holds user edits, geoDataExif the camera location
+	for _, geo := range []*takeoutGeoData{file.GeoData, file.GeoDataExif} {
+		if geo != nil && (geo.Latitude != 0 || geo.Longitude != 0) {
+			metadata.Latitude = geo.Latitude
+			metadata.Longitude = geo.Longitude
+			metadata.Altitude = geo.Altitude
+			metadata.HasGPS = true
+			break
+		}
+	}
+
+	return metadata, nil
+}
+
+// applyTo fills in the EXIF fields that the image itself is missing.
+// Takeout strips dates from many files, but embedded values still win.
+func (m *TakeoutMetadata) applyTo(data *EXIFData) {
+	if !data.HasDateTime && m.HasTakenTime {
+		data.DateTime = m.TakenTime
+		data.HasDateTime = true
+		data.HasTimeZone = true
+		data.DateTag = takeoutDateTag
+	}
+	if !data.HasGPS && m.HasGPS {
+		data.Latitude = m.Latitude
+		data.Longitude = m.Longitude
+		data.Altitude = m.Altitude
+		data.HasGPS = true
+	}
+}
+
+// takeoutJSONKey returns the media name a Takeout JSON file refers to (possibly truncated),
+// its duplicate index and whether Google truncated the name.
+// IMG_1234.jpg.json, IMG_1234.jpg.supplemental-metad.json and IMG_1234.jpg(1).json all refer to IMG_1234.jpg.
+// A short leftover of the suffix (IMG_1234.jpg.s.json) is only stripped after a media extension,
+// so real extensions such as notes.s.json are kept.
+func takeoutJSONKey(jsonName string, detector *FileTypeDetector) (string, string, bool) {
+	stem := jsonName[:len(jsonName)-len(filepath.Ext(jsonName))]
+
+	var duplicate string
+	if match := takeoutDuplicateSuffix.FindStringSubmatch(stem); match != nil {
+		duplicate = match[1]
+		stem = stem[:len(stem)-len(match[0])]
+	}
+	truncated := len(stem) >= takeoutMaxStemLength
+
+	if dot := strings.LastIndex(stem, "."); dot >= 0 {
+		suffix := strings.ToLower(stem[dot+1:])
+		afterMedia := detector.IsMediaExtension(filepath.Ext(stem[:dot]))
+		if dot > 0 && strings.HasPrefix(takeoutSupplementalSuffix, suffix) &&
+			(len(suffix) >= takeoutMinSuffixLength || afterMedia && (suffix != "" || truncated)) {
+			stem = stem[:dot]
+		}
+	}
+
+	return strings.ToLower(stem), duplicate, truncated
+}
+
+// takeoutMediaKey returns the name Google uses for a media file's JSON and its duplicate index.
+// Edited copies (IMG_1234-edited.jpg) and duplicates (IMG_1234(1).jpg) share the original's name.
+func takeoutMediaKey(mediaName string) (string, string) {
+	ext := filepath.Ext(mediaName)
+	stem := strings.TrimSuffix(mediaName, ext)
+
+	var duplicate string
+	if match := takeoutDuplicateSuffix.FindStringSubmatch(stem); match != nil {
+		duplicate = match[1]
+		stem = stem[:len(stem)-len(match[0])]
+	}
+	if strings.HasSuffix(strings.ToLower(stem), "-edited") {
+		stem = stem[:len(stem)-len("-edited")]
+	}
+
+	return strings.ToLower(stem + ext), duplicate
+}
+
+// looksLikeTakeoutMetadata reports whether a JSON file was written by Google Takeout
+// (photo metadata or album metadata.json) rather than being a user document
+func looksLikeTakeoutMetadata(path string) bool {
+	file, err := readTakeoutFile(path)
+	if err != nil {
+		return false
+	}
+	return file.PhotoTakenTime != nil || file.CreationTime != nil || file.AlbumData != nil
+}
+
+// matchTakeoutMetadata pairs photos and videos with their Takeout JSON files.
+// Matched JSON files, and other Takeout JSON files such as album metadata, are
+// removed from the file list so they are consumed instead of organized as documents.
+func (fp *FileProcessor) matchTakeoutMetadata(files []string) ([]string, map[string]string) {
+	matches := make(map[string]string)
+
+	type jsonEntry struct {
+		path      string
+		key       string
+		duplicate string
+		truncated bool
+	}
+	jsonsByDir := make(map[string][]jsonEntry)
+	for _, path := range files {
+		if strings.EqualFold(filepath.Ext(path), ".json") {
+			key, duplicate, truncated := takeoutJSONKey(filepath.Base(path), fp.detector)
+			dir := filepath.Dir(path)
+			jsonsByDir[dir] = append(jsonsByDir[dir], jsonEntry{path, key, duplicate, truncated})
+		}
+	}
+	if len(jsonsByDir) == 0 {
+		return files, matches
+	}
+
+	used := make(map[string]bool)
+	titles := make(map[string]map[string]string) // Directory -> lowercase title -> JSON path, loaded on demand
+	for _, path := range files {
+		entries := jsonsByDir[filepath.Dir(path)]
+		if len(entries) == 0 || strings.EqualFold(filepath.Ext(path), ".json") {
+			continue
+		}
+		fileType := fp.detector.DetectFileType(path)
+		if fileType != FileTypeImage && fileType != FileTypeVideo {
+			continue
+		}
+
+		key, duplicate := takeoutMediaKey(filepath.Base(path))
+
+		// Exact name first, then names Google cut short
+		var jsonPath string
+		for _, entry := range entries {
+			if entry.duplicate == duplicate && entry.key == key {
+				jsonPath = entry.path
+				break
+			}
+		}
+		if jsonPath == "" {
+			for _, entry := range entries {
+				if entry.duplicate == duplicate && entry.truncated && entry.key != "" && strings.HasPrefix(key, entry.key) {
+					jsonPath = entry.path
+					break
+				}
+			}
+		}
+
+		// Fall back to the original name recorded inside the JSON files
+		if jsonPath == "" && duplicate == "" {
+			dir := filepath.Dir(path)
+			if titles[dir] == nil {
+				titles[dir] = make(map[string]string)
+				for _, entry := range entries {
+					if file, err := readTakeoutFile(entry.path); err == nil && file.Title != "" {
+						titles[dir][strings.ToLower(file.Title)] = entry.path
+					}
+				}
+			}
+			jsonPath = titles[dir][key]
+		}
+
+		if jsonPath != "" {
+			matches[path] = jsonPath
+			used[jsonPath] = true
+		}
+	}
+
+	var remaining []string
+	for _, path := range files {
+		if used[path] {
+			continue
+		}
+		if strings.EqualFold(filepath.Ext(path), ".json") && looksLikeTakeoutMetadata(path) {
+			fp.skipFile(path, OutcomeSkipped, "Google Takeout metadata without matching media")
+			continue
+		}
+		remaining = append(remaining, path)
+	}
+	return remaining, matches
+}
@@ -0,0 +1,118 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTakeoutJSONKey(t *testing.T) {
+	detector := NewFileTypeDetector()
+
+	tests := []struct {
+		json          string
+		wantKey       string
+		wantDuplicate string
+		wantTruncated bool
+	}{
+		{"IMG_1234.jpg.json", "img_1234.jpg", "", false},
+		{"IMG_1234.JPG.supplemental-metadata.json", "img_1234.jpg", "", false},
+		{"IMG_1234.jpg.supplemental-metad.json", "img_1234.jpg", "", false},
+		{"IMG_1234.jpg.sup.json", "img_1234.jpg", "", false},
+		{"IMG_1234.jpg.s.json", "img_1234.jpg", "", false},
+		{"IMG_1234.jpg(1).json", "img_1234.jpg", "1", false},
+		{"IMG_1234.jpg.supplemental-metadata(2).json", "img_1234.jpg", "2", false},
+		{"notes.s.json", "notes.s", "", false},
+		{"report.su.json", "report.su", "", false},
+		{"IMG_1234.jpg.other.json", "img_1234.jpg.other", "", false},
+		{".supplemental-metadata.json", ".supplemental-metadata", "", false},
+		{"metadata.json", "metadata", "", false},
+		// Google cuts names to 46 characters before ".json"
+		{"Screenshot_20230102-030405_Some Long App Name1.json", "screenshot_20230102-030405_some long app name1", "", true},
+		{"PXL_20220304_123456789.NIGHT.RAW-01.COVER.jpg..json", "pxl_20220304_123456789.night.raw-01.cover.jpg", "", true},
+		{"PXL_20220304_1234567890.NIGHT.RAW-01.MP.COVER..json", "pxl_20220304_1234567890.night.raw-01.mp.cover.", "", true},
+		{"PXL_20220304_123456789.NIGHT.RAW-01.COVER.jpg.s.json", "pxl_20220304_123456789.night.raw-01.cover.jpg", "", true},
+	}
+
+	for _, tt := range tests {
+		key, duplicate, truncated := takeoutJSONKey(tt.json, detector)
+		if key != tt.wantKey || duplicate != tt.wantDuplicate || truncated != tt.wantTruncated {
+			t.Errorf("takeoutJSONKey(%q) = %q, %q, %v; want %q, %q, %v",
+				tt.json, key, duplicate, truncated, tt.wantKey, tt.wantDuplicate, tt.wantTruncated)
+		}
+	}
+}
+
+func TestTakeoutMediaKey(t *testing.T) {
+	tests := []struct {
+		media         string
+		wantKey       string
+		wantDuplicate string
+	}{
+		{"IMG_1234.jpg", "img_1234.jpg", ""},
+		{"IMG_1234-edited.jpg", "img_1234.jpg", ""},
+		{"IMG_1234-EDITED.JPG", "img_1234.jpg", ""},
+		{"IMG_1234(1).jpg", "img_1234.jpg", "1"},
+		{"IMG_1234-edited(1).jpg", "img_1234.jpg", "1"},
+		{"IMG_1234(a).jpg", "img_1234(a).jpg", ""},
+		{"noext", "noext", ""},
+		{"-edited.jpg", ".jpg", ""},
+	}
+
+	for _, tt := range tests {
+		key, duplicate := takeoutMediaKey(tt.media)
+		if key != tt.wantKey || duplicate != tt.wantDuplicate {
+			t.Errorf("takeoutMediaKey(%q) = %q, %q; want %q, %q", tt.media, key, duplicate, tt.wantKey, tt.wantDuplicate)
+		}
+	}
+}
+
+func TestReadTakeoutMetadata(t *testing.T) {
+	path := writeTestFile(t, "IMG_1234.jpg.json", []byte(`{
+		"title": "IMG_1234.jpg",
+		"description": " At the beach ",
+		"photoTakenTime": {"timestamp": "1620302400"},
+		"geoData": {"latitude": 0, "longitude": 0},
+		"geoDataExif": {"latitude": 52.5, "longitude": 13.4, "altitude": 34}
+	}`))
+
+	metadata, err := ReadTakeoutMetadata(path, time.UTC)
+	if err != nil {
+		t.Fatalf("ReadTakeoutMetadata() error = %v", err)
+	}
+	if metadata.Title != "IMG_1234.jpg" || metadata.Description != "At the beach" {
+		t.Errorf("title/description = %q/%q", metadata.Title, metadata.Description)
+	}
+	if !metadata.HasTakenTime || !metadata.TakenTime.Equal(time.Date(2021, 5, 6, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("taken time = %v (%v)", metadata.TakenTime, metadata.HasTakenTime)
+	}
+	if !metadata.HasGPS || metadata.Latitude != 52.5 || metadata.Longitude != 13.4 || metadata.Altitude != 34 {
+		t.Errorf("GPS = %v, %v, %v (%v)", metadata.Latitude, metadata.Longitude, metadata.Altitude, metadata.HasGPS)
+	}
+}
+
+func TestReadTakeoutMetadataMalformed(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		wantErr bool
+	}{
+		{"truncated", `{"title": "IMG_1234.jpg", "photoTakenTime": {"timest`, true},
+		{"not an object", `[1, 2, 3]`, true},
+		{"empty", ``, true},
+		{"timestamp not a number", `{"photoTakenTime": {"timestamp": "yesterday"}}`, false},
+		{"timestamp implausible", `{"photoTakenTime": {"timestamp": "-100"}}`, false},
+		{"unknown location", `{"geoData": {"latitude": 0, "longitude": 0}}`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metadata, err := ReadTakeoutMetadata(writeTestFile(t, "test.json", []byte(tt.json)), time.UTC)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadTakeoutMetadata() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (metadata.HasTakenTime || metadata.HasGPS) {
+				t.Errorf("ReadTakeoutMetadata() = %+v, want no date or location", metadata)
+			}
+		})
+	}
+}
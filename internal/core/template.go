@@ -0,0 +1,83 @@
+package core
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"zensort/internal/config"
+)
+
+// templatePlaceholder matches {name} placeholders in path templates
+var templatePlaceholder = regexp.MustCompile(`\{([a-z_]+)\}`)
+
+// invalidPathChars matches characters that are not allowed in folder names on common filesystems
+var invalidPathChars = regexp.MustCompile(`[<>:"/\\|?*\x00-\x1f]`)
+
+// ExpandPathTemplate replaces {placeholder} tokens in a path template with sanitized values.
+// Missing values use the configured fallback, then the built-in fallback, so every
+// placeholder always expands to a usable folder name.
+func ExpandPathTemplate(template string, values map[string]string, fallbacks map[string]string) string {
+	// Templates always use forward slashes as separators, regardless of platform
+	segments := strings.Split(filepath.ToSlash(template), "/")
+
+	var parts []string
+	for _, segment := range segments {
+		expanded := templatePlaceholder.ReplaceAllStringFunc(segment, func(token string) string {
+			name := token[1 : len(token)-1]
+			return sanitizePathSegment(lookupTemplateValue(name, values, fallbacks))
+		})
+
+		expanded = sanitizePathSegment(expanded)
+		if expanded == "" {
+			continue // Drop empty segments instead of creating unnamed folders
+		}
+		parts = append(parts, expanded)
+	}
+
+	return filepath.Join(parts...)
+}
+
+// lookupTemplateValue resolves a placeholder value with fallback handling
+func lookupTemplateValue(name string, values map[string]string, fallbacks map[string]string) string {
+	if value := strings.TrimSpace(values[name]); value != "" {
+		return value
+	}
+	if fallback := strings.TrimSpace(fallbacks[name]); fallback != "" {
+		return fallback
+	}
+	if fallback, exists := config.DefaultTemplateFallback(name); exists {
+		return fallback
+	}
+	return "Unknown"
+}
+
+// sanitizePathSegment makes a string safe to use as a single folder name
+func sanitizePathSegment(segment string) string {
+	segment = invalidPathChars.ReplaceAllString(segment, "_")
+	// Windows does not allow folder names ending in dots or spaces
+	return strings.Trim(segment, " .")
+}
+
+// setTemplateDate fills the date placeholders from a timestamp
+func setTemplateDate(values map[string]string, t time.Time, hasDate bool) {
+	if !hasDate || t.IsZero() {
+		return
+	}
+	values["year"] = fmt.Sprintf("%04d", t.Year())
+	values["month"] = fmt.Sprintf("%02d", int(t.Month()))
+	values["day"] = fmt.Sprintf("%02d", t.Day())
+	values["date"] = t.Format("2006-01-02")
+	values["time"] = t.Format("15-04-05")
+}
+
+// ExpandNameTemplate expands a filename template into a single sanitized name (without extension)
+func ExpandNameTemplate(template string, values map[string]string, fallbacks map[string]string) string {
+	expanded := templatePlaceholder.ReplaceAllStringFunc(template, func(token string) string {
+		name := token[1 : len(token)-1]
+		return lookupTemplateValue(name, values, fallbacks)
+	})
+	return sanitizePathSegment(expanded)
+}
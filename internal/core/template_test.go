@@ -0,0 +1,124 @@
+package core
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestExpandPathTemplate(t *testing.T) {
+	tests := []struct {
+		name      string
+		template  string
+		values    map[string]string
+		fallbacks map[string]string
+		want      string
+	}{
+		{
+			name:     "all values",
+			template: "{year}/{month}/{make} - {model}",
+			values:   map[string]string{"year": "2021", "month": "05", "make": "Canon", "model": "EOS R5"},
+			want:     filepath.Join("2021", "05", "Canon - EOS R5"),
+		},
+		{
+			name:     "built-in fallbacks",
+			template: "{year}/{make}",
+			want:     filepath.Join("0000", "Unknown Make"),
+		},
+		{
+			name:      "configured fallback wins over built-in",
+			template:  "{year}",
+			fallbacks: map[string]string{"year": "Undated"},
+			want:      "Undated",
+		},
+		{
+			name:     "blank value uses fallback",
+			template: "{make}",
+			values:   map[string]string{"make": "   "},
+			want:     "Unknown Make",
+		},
+		{
+			name:     "placeholder without fallback",
+			template: "{nonsense}",
+			want:     "Unknown",
+		},
+		{
+			name:     "separators in values stay in one folder",
+			template: "{album}",
+			values:   map[string]string{"album": "AC/DC: Live"},
+			want:     "AC_DC_ Live",
+		},
+		{
+			name:     "empty segments are dropped",
+			template: "Photos//{year}/",
+			values:   map[string]string{"year": "2020"},
+			want:     filepath.Join("Photos", "2020"),
+		},
+		{
+			name:     "trailing dots are trimmed",
+			template: "{title}",
+			values:   map[string]string{"title": "Etc..."},
+			want:     "Etc",
+		},
+		{
+			name:     "text around placeholders",
+			template: "Year {year} (scans)",
+			values:   map[string]string{"year": "1999"},
+			want:     "Year 1999 (scans)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExpandPathTemplate(tt.template, tt.values, tt.fallbacks); got != tt.want {
+				t.Errorf("ExpandPathTemplate(%q) = %q, want %q", tt.template, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSanitizePathSegment(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"plain", "plain"},
+		{`a<b>c:d"e/f\g|h?i*j`, "a_b_c_d_e_f_g_h_i_j"},
+		{"tab\there", "tab_here"},
+		{" padded ", "padded"},
+		{"dots...", "dots"},
+		{"...", ""},
+		{"", ""},
+		{"Café", "Café"},
+	}
+
+	for _, tt := range tests {
+		if got := sanitizePathSegment(tt.in); got != tt.want {
+			t.Errorf("sanitizePathSegment(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestExpandNameTemplate(t *testing.T) {
+	values := map[string]string{}
+	setTemplateDate(values, time.Date(2021, 5, 6, 7, 8, 9, 0, time.UTC), true)
+	values["model"] = "EOS/R5"
+
+	got := ExpandNameTemplate("{date}_{time}_{model}", values, nil)
+	if want := "2021-05-06_07-08-09_EOS_R5"; got != want {
+		t.Errorf("ExpandNameTemplate() = %q, want %q", got, want)
+	}
+
+	if got := ExpandNameTemplate("{date}", map[string]string{}, nil); got != "0000-00-00" {
+		t.Errorf("ExpandNameTemplate() without date = %q, want fallback", got)
+	}
+}
+
+func TestSetTemplateDateWithoutDate(t *testing.T) {
+	values := map[string]string{}
+	setTemplateDate(values, time.Time{}, true)
+	setTemplateDate(values, time.Now(), false)
+	if len(values) != 0 {
+		t.Errorf("setTemplateDate() set %v without a date", values)
+	}
+}
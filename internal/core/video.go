@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os/exec"
+	"sort"
 	"strconv"
 	"strings"
 	"syscall"
@@ -17,6 +18,10 @@ type VideoMetadata struct {
 	CreationTime time.Time
 	HasDateTime  bool
 	Duration     time.Duration
+	Latitude     float64
+	Longitude    float64
+	Altitude     float64
+	HasGPS       bool
 }
 
 // VideoAnalyzer handles video file analysis
@@ -27,10 +32,10 @@ func NewVideoAnalyzer() *VideoAnalyzer {
 	return &VideoAnalyzer{}
 }
 
-// GetVideoDuration extracts video duration using ffprobe if available
+// GetVideoDuration extracts video duration from the container, or with ffprobe if available
 func (va *VideoAnalyzer) GetVideoDuration(filePath string) (time.Duration, error) {
-	// Try ffprobe first (most accurate)
-	if duration, err := va.getFFProbeDuration(filePath); err == nil {
+	// Measure first (container headers, then ffprobe)
+	if duration, err := va.MeasureDuration(filePath); err == nil {
 		return duration, nil
 	}
 	
@@ -38,6 +43,16 @@ func (va *VideoAnalyzer) GetVideoDuration(filePath string) (time.Duration, error
 	return va.estimateDurationFromSize(filePath)
 }
 
+// MeasureDuration returns the real duration of a media file, or an error when it
+// cannot be determined (unlike GetVideoDuration, it never estimates).
+// MP4/MOV, Matroska/WebM, AVI, WAV and MP3 are read directly; ffprobe is only a fallback.
+func (va *VideoAnalyzer) MeasureDuration(filePath string) (time.Duration, error) {
+	if info, err := ReadContainerInfo(filePath); err == nil && info.HasDuration {
+		return info.Duration, nil
+	}
+	return va.getFFProbeDuration(filePath)
+}
+
 // getFFProbeDuration uses ffprobe to get exact video duration with timeout
 func (va *VideoAnalyzer) getFFProbeDuration(filePath string) (time.Duration, error) {
 	// Check if ffprobe is available with timeout
@@ -110,15 +125,74 @@ func (va *VideoAnalyzer) IsShortVideo(filePath string, thresholdSeconds int) boo
 	
 	threshold := time.Duration(thresholdSeconds) * time.Second
 	
-	// Debug logging to help identify the issue
-	fmt.Printf("DEBUG: Video %s - Duration: %.2f seconds, Threshold: %d seconds, IsShort: %v\n", 
-		filePath, duration.Seconds(), thresholdSeconds, duration < threshold)
-	
 	return duration < threshold
 }
 
-// ExtractVideoMetadata extracts metadata from video file using ffprobe with timeout
+// ExtractVideoMetadata extracts metadata from the video container, asking ffprobe
+// only for what the native parsers could not read
 func (va *VideoAnalyzer) ExtractVideoMetadata(filePath string) (*VideoMetadata, error) {
+	metadata := &VideoMetadata{}
+	allTags := make(map[string]string)
+
+	container, containerErr := ReadContainerInfo(filePath)
+	if containerErr == nil {
+		metadata.Duration = container.Duration
+		for key, value := range container.Tags {
+			allTags[strings.ToLower(key)] = value
+		}
+	}
+
+	if containerErr != nil || !container.HasDuration || (!container.HasCreationTime && extractVideoTags(allTags)["creation_time"] == "") {
+		duration, tags, err := va.probeVideoMetadata(filePath)
+		if err != nil {
+			if containerErr != nil {
+				return &VideoMetadata{}, err
+			}
+		} else {
+			if metadata.Duration == 0 {
+				metadata.Duration = duration
+			}
+			// Tags read from the container win over ffprobe's copies
+			for key, value := range tags {
+				if _, exists := allTags[key]; !exists {
+					allTags[key] = value
+				}
+			}
+		}
+	}
+
+	// Extract metadata using tag patterns
+	extractedData := extractVideoTags(allTags)
+	metadata.Make = extractedData["make"]
+	metadata.Model = extractedData["model"]
+
+	if creationTime, exists := extractedData["creation_time"]; exists {
+		if parsedTime := parseVideoDateTime(creationTime); !parsedTime.IsZero() {
+			metadata.CreationTime = parsedTime
+			metadata.HasDateTime = true
+		}
+	}
+	// Tags such as com.apple.quicktime.creationdate keep the local offset; the movie header is UTC
+	if !metadata.HasDateTime && container != nil && container.HasCreationTime {
+		metadata.CreationTime = container.CreationTime
+		metadata.HasDateTime = true
+	}
+
+	// Extract GPS position (ISO 6709, e.g. "+37.7749-122.4194+010.000/")
+	for _, key := range []string{quickTimeLocationKey, "location"} {
+		if lat, long, alt, ok := parseISO6709(allTags[key]); ok {
+			metadata.Latitude, metadata.Longitude, metadata.Altitude = lat, long, alt
+			metadata.HasGPS = true
+			break
+		}
+	}
+
+	return metadata, nil
+}
+
+// probeVideoMetadata uses ffprobe with timeout to read the duration and tags of a video.
+// Tag names are lowercased.
+func (va *VideoAnalyzer) probeVideoMetadata(filePath string) (time.Duration, map[string]string, error) {
 	// Use ffprobe to get comprehensive metadata with timeout
 	cmd := exec.Command("ffprobe", "-v", "quiet", "-print_format", "json", "-show_format", "-show_streams", filePath)
 	
@@ -138,14 +212,14 @@ func (va *VideoAnalyzer) ExtractVideoMetadata(filePath string) (*VideoMetadata,
 	select {
 	case err := <-done:
 		if err != nil {
-			return &VideoMetadata{}, fmt.Errorf("ffprobe not available or failed: %w", err)
+			return 0, nil, fmt.Errorf("ffprobe not available or failed: %w", err)
 		}
 	case <-time.After(15 * time.Second):
 		// Kill the process if it's taking too long
 		if cmd.Process != nil {
 			cmd.Process.Kill()
 		}
-		return &VideoMetadata{}, fmt.Errorf("ffprobe metadata timeout after 15 seconds for file: %s", filePath)
+		return 0, nil, fmt.Errorf("ffprobe metadata timeout after 15 seconds for file: %s", filePath)
 	}
 	
 	// Parse JSON output
@@ -160,15 +234,14 @@ func (va *VideoAnalyzer) ExtractVideoMetadata(filePath string) (*VideoMetadata,
 	}
 	
 	if err := json.Unmarshal(output, &probe); err != nil {
-		return &VideoMetadata{}, fmt.Errorf("failed to parse ffprobe output: %w", err)
+		return 0, nil, fmt.Errorf("failed to parse ffprobe output: %w", err)
 	}
 	
-	metadata := &VideoMetadata{}
-	
 	// Extract duration
+	var duration time.Duration
 	if probe.Format.Duration != "" {
 		if durationFloat, err := strconv.ParseFloat(probe.Format.Duration, 64); err == nil {
-			metadata.Duration = time.Duration(durationFloat * float64(time.Second))
+			duration = time.Duration(durationFloat * float64(time.Second))
 		}
 	}
 	
@@ -187,41 +260,53 @@ func (va *VideoAnalyzer) ExtractVideoMetadata(filePath string) (*VideoMetadata,
 		}
 	}
 	
-	// Extract metadata using tag patterns
-	extractedData := extractVideoTags(allTags)
-	metadata.Make = extractedData["make"]
-	metadata.Model = extractedData["model"]
-	
-	if creationTime, exists := extractedData["creation_time"]; exists {
-		if parsedTime := parseVideoDateTime(creationTime); !parsedTime.IsZero() {
-			metadata.CreationTime = parsedTime
-			metadata.HasDateTime = true
-		}
-	}
-	
-	return metadata, nil
+	return duration, allTags, nil
+}
+
+// videoTagSources lists the tags each video detail is read from, most trusted first.
+// When none of them is set, other tags whose name contains one of the fragments are
+// used in name order, so the same file always gives the same result.
+var videoTagSources = []struct {
+	detail    string
+	keys      []string
+	fragments []string
+}{
+	{"make", []string{"com.apple.quicktime.make", "com.android.manufacturer", "make", "manufacturer"}, []string{"make", "manufacturer"}},
+	{"model", []string{"com.apple.quicktime.model", "com.android.model", "model"}, []string{"model"}},
+	// creationdate keeps the local offset, creation_time is UTC
+	{"creation_time", []string{"com.apple.quicktime.creationdate", "creation_time", "date"}, []string{"creationdate", "creation_time", "date"}},
 }
 
 // extractVideoTags extracts relevant metadata from video tags
 func extractVideoTags(tags map[string]string) map[string]string {
+	names := make([]string, 0, len(tags))
+	for tag := range tags {
+		names = append(names, tag)
+	}
+	sort.Strings(names)
+
 	videoDetails := make(map[string]string)
-	
-	for tag, value := range tags {
-		// Check for make/manufacturer
-		if strings.Contains(tag, "make") || strings.Contains(tag, "manufacturer") {
-			videoDetails["make"] = value
+	for _, source := range videoTagSources {
+		for _, key := range source.keys {
+			if value := tags[key]; value != "" {
+				videoDetails[source.detail] = value
+				break
+			}
 		}
-		// Check for model
-		if strings.Contains(tag, "model") {
-			videoDetails["model"] = value
+		if _, found := videoDetails[source.detail]; found {
+			continue
 		}
-		// Check for creation date/time
-		if strings.Contains(tag, "creationdate") || strings.Contains(tag, "creation_time") || 
-		   strings.Contains(tag, "date") || strings.Contains(tag, "datetime") {
-			videoDetails["creation_time"] = value
+	fallback:
+		for _, tag := range names {
+			for _, fragment := range source.fragments {
+				if strings.Contains(tag, fragment) && tags[tag] != "" {
+					videoDetails[source.detail] = tags[tag]
+					break fallback
+				}
+			}
 		}
 	}
-	
+
 	return videoDetails
 }
 
@@ -229,13 +314,15 @@ func extractVideoTags(tags map[string]string) map[string]string {
 func parseVideoDateTime(dateTimeStr string) time.Time {
 	// Common video metadata datetime formats
 	formats := []string{
-		"2006-01-02T15:04:05.000000Z",  // ISO with microseconds
-		"2006-01-02T15:04:05Z",         // ISO basic
-		"2006-01-02 15:04:05",          // Standard format
-		"2006:01:02 15:04:05",          // EXIF-like format
-		"2006-01-02T15:04:05",          // ISO without timezone
-		"2006/01/02 15:04:05",          // Slash format
-		"Mon Jan 2 15:04:05 2006",      // RFC822 format
+		time.RFC3339Nano,              // ISO with offset and fractional seconds
+		"2006-01-02T15:04:05-0700",    // Apple QuickTime creationdate
+		"2006-01-02T15:04:05.000000Z", // ISO with microseconds
+		"2006-01-02T15:04:05Z",        // ISO basic
+		"2006-01-02 15:04:05",         // Standard format
+		"2006:01:02 15:04:05",         // EXIF-like format
+		"2006-01-02T15:04:05",         // ISO without timezone
+		"2006/01/02 15:04:05",         // Slash format
+		"Mon Jan 2 15:04:05 2006",     // RFC822 format
 	}
 	
 	for _, format := range formats {
@@ -0,0 +1,55 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractVideoTags(t *testing.T) {
+	tests := []struct {
+		name string
+		tags map[string]string
+		want map[string]string
+	}{
+		{"quicktime creationdate wins over creation_time", map[string]string{
+			"creation_time":                    "2023-04-15T08:11:12.000000Z",
+			"com.apple.quicktime.creationdate": "2023-04-15T10:11:12+0200",
+			"date":                             "2023",
+		}, map[string]string{"creation_time": "2023-04-15T10:11:12+0200"}},
+		{"creation_time wins over date", map[string]string{
+			"date":          "2023",
+			"creation_time": "2023-04-15T08:11:12.000000Z",
+		}, map[string]string{"creation_time": "2023-04-15T08:11:12.000000Z"}},
+		{"quicktime make and model win", map[string]string{
+			"com.apple.quicktime.make":  "Apple",
+			"com.apple.quicktime.model": "iPhone 12",
+			"make":                      "Other",
+			"model":                     "Other model",
+		}, map[string]string{"make": "Apple", "model": "iPhone 12"}},
+		{"android manufacturer", map[string]string{
+			"com.android.manufacturer": "Google",
+			"com.android.model":        "Pixel 7",
+		}, map[string]string{"make": "Google", "model": "Pixel 7"}},
+		{"other tags in name order", map[string]string{
+			"b.recording_date":  "2022-01-01",
+			"a.encoded_date":    "2021-01-01",
+			"vendor.make.brand": "Sony",
+		}, map[string]string{"make": "Sony", "creation_time": "2021-01-01"}},
+		{"empty values skipped", map[string]string{
+			"com.apple.quicktime.creationdate": "",
+			"creation_time":                    "2023-04-15T08:11:12.000000Z",
+		}, map[string]string{"creation_time": "2023-04-15T08:11:12.000000Z"}},
+		{"no tags", nil, map[string]string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Map order changes between runs, the result must not
+			for i := 0; i < 20; i++ {
+				if got := extractVideoTags(tt.tags); !reflect.DeepEqual(got, tt.want) {
+					t.Fatalf("extractVideoTags() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -455,6 +455,9 @@ func (g *GUI) openSettingsWindow() {
 	enableExportsCheck := widget.NewCheck("Enable Image Exports (slower processing)", nil)
 	enableExportsCheck.SetChecked(g.currentConfig.Processing.EnableImageExports)
 	
+	passthroughCheck := widget.NewCheck("Copy Originals That Already Fit Unchanged", nil)
+	passthroughCheck.SetChecked(g.currentConfig.Processing.Passthrough)
+
 	jpegQualityEntry := widget.NewEntry()
 	jpegQualityEntry.SetText(fmt.Sprintf("%d", g.currentConfig.Processing.JPEGQuality))
 	
@@ -523,7 +526,8 @@ func (g *GUI) openSettingsWindow() {
 	})
 	
 	audioContainer := container.NewVBox()
-	for categoryKey, category := range g.currentConfig.AudioCategories {
+	for _, category := range g.currentConfig.AudioCategories {
+		categoryKey := category.Key
 		folderEntry := widget.NewEntry()
 		folderEntry.SetText(category.FolderName)
 		
@@ -561,7 +565,7 @@ func (g *GUI) openSettingsWindow() {
 	// Buttons
 	saveButton := widget.NewButton("Save Settings", func() {
 		g.saveSettingsFromFormWithAudio(imagesEntry, videosEntry, audiosEntry, documentsEntry, unknownEntry, hiddenEntry,
-			originalsEntry, exportsEntry, noExifYearEntry, maxWidthEntry, maxHeightEntry, enableExportsCheck, jpegQualityEntry, shortVideoThresholdEntry, livePhotosEnabledCheck, iPhonePatternsEntry, samsungPatternsEntry, livePhotoExtensionsEntry, maxLiveDurationEntry, screenshotsEnabledCheck, screenshotPatternsEntry, screenshotExtensionsEntry, screenshotFolderEntry, editedImagesEntry, editedImagesFolderEntry, skipUnknownCheck, skipExtEntry, skipPatternsEntry, audioEntries)
+			originalsEntry, exportsEntry, noExifYearEntry, maxWidthEntry, maxHeightEntry, enableExportsCheck, passthroughCheck, jpegQualityEntry, shortVideoThresholdEntry, livePhotosEnabledCheck, iPhonePatternsEntry, samsungPatternsEntry, livePhotoExtensionsEntry, maxLiveDurationEntry, screenshotsEnabledCheck, screenshotPatternsEntry, screenshotExtensionsEntry, screenshotFolderEntry, editedImagesEntry, editedImagesFolderEntry, skipUnknownCheck, skipExtEntry, skipPatternsEntry, audioEntries)
 		settingsWindow.Close()
 	})
 	saveButton.Importance = widget.HighImportance
@@ -607,6 +611,7 @@ func (g *GUI) openSettingsWindow() {
 			widget.NewSeparator(),
 			container.NewVBox(
 				enableExportsCheck,
+				passthroughCheck,
 				container.NewGridWithColumns(2,
 					widget.NewLabel("JPEG Quality (1-100):"), jpegQualityEntry,
 					widget.NewLabel("Short Video Duration Threshold (seconds):"), shortVideoThresholdEntry,
@@ -659,7 +664,7 @@ func (g *GUI) openSettingsWindow() {
 
 // saveSettingsFromFormWithAudio saves the configuration from form inputs including audio settings
 func (g *GUI) saveSettingsFromFormWithAudio(imagesEntry, videosEntry, audiosEntry, documentsEntry, unknownEntry, hiddenEntry,
-	originalsEntry, exportsEntry, noExifYearEntry, maxWidthEntry, maxHeightEntry *widget.Entry, enableExportsCheck *widget.Check, jpegQualityEntry, shortVideoThresholdEntry *widget.Entry, livePhotosEnabledCheck *widget.Check, iPhonePatternsEntry, samsungPatternsEntry, livePhotoExtensionsEntry, maxLiveDurationEntry *widget.Entry, screenshotsEnabledCheck *widget.Check, screenshotPatternsEntry, screenshotExtensionsEntry, screenshotFolderEntry *widget.Entry, editedImagesEntry, editedImagesFolderEntry *widget.Entry, skipUnknownCheck *widget.Check, skipExtEntry, skipPatternsEntry *widget.Entry,
+	originalsEntry, exportsEntry, noExifYearEntry, maxWidthEntry, maxHeightEntry *widget.Entry, enableExportsCheck, passthroughCheck *widget.Check, jpegQualityEntry, shortVideoThresholdEntry *widget.Entry, livePhotosEnabledCheck *widget.Check, iPhonePatternsEntry, samsungPatternsEntry, livePhotoExtensionsEntry, maxLiveDurationEntry *widget.Entry, screenshotsEnabledCheck *widget.Check, screenshotPatternsEntry, screenshotExtensionsEntry, screenshotFolderEntry *widget.Entry, editedImagesEntry, editedImagesFolderEntry *widget.Entry, skipUnknownCheck *widget.Check, skipExtEntry, skipPatternsEntry *widget.Entry,
 	audioEntries map[string]struct {
 		folderEntry    *widget.Entry
 		extensionsEntry *widget.Entry
@@ -689,6 +694,7 @@ func (g *GUI) saveSettingsFromFormWithAudio(imagesEntry, videosEntry, audiosEntr
 	
 	// Update export settings
 	g.currentConfig.Processing.EnableImageExports = enableExportsCheck.Checked
+	g.currentConfig.Processing.Passthrough = passthroughCheck.Checked
 	if quality, err := strconv.Atoi(jpegQualityEntry.Text); err == nil && quality > 0 && quality <= 100 {
 		g.currentConfig.Processing.JPEGQuality = quality
 	}
@@ -785,7 +791,7 @@ func (g *GUI) saveSettingsFromFormWithAudio(imagesEntry, videosEntry, audiosEntr
 	
 	// Update audio categories
 	for categoryKey, entries := range audioEntries {
-		if category, exists := g.currentConfig.AudioCategories[categoryKey]; exists {
+		if category := g.currentConfig.AudioCategories.Find(categoryKey); category != nil {
 			category.FolderName = entries.folderEntry.Text
 			
 			// Parse extensions (comma-separated)
@@ -809,8 +815,6 @@ func (g *GUI) saveSettingsFromFormWithAudio(imagesEntry, videosEntry, audiosEntr
 			} else {
 				category.Patterns = []string{}
 			}
-			
-			g.currentConfig.AudioCategories[categoryKey] = category
 		}
 	}
 	
@@ -854,7 +858,7 @@ func (g *GUI) refreshSettingsFormWithAudio(imagesEntry, videosEntry, audiosEntry
 	
 	// Update audio categories
 	for categoryKey, entries := range audioEntries {
-		if category, exists := g.currentConfig.AudioCategories[categoryKey]; exists {
+		if category := g.currentConfig.AudioCategories.Find(categoryKey); category != nil {
 			entries.folderEntry.SetText(category.FolderName)
 			entries.extensionsEntry.SetText(strings.Join(category.Extensions, ", "))
 			entries.patternsEntry.SetText(strings.Join(category.Patterns, ", "))
@@ -14,6 +14,7 @@ func main() {
 	var source = flag.String("source", "", "Source directory path")
 	var dest = flag.String("dest", "", "Destination directory path")
 	var config = flag.String("config", "", "Configuration file path")
+	var output = flag.String("output", "text", "Output format: text or json")
 	
 	flag.Parse()
 
@@ -27,7 +28,7 @@ func main() {
 			fmt.Println("Usage:")
 			fmt.Println("  GUI Mode (default):    zensort")
 			fmt.Println("  GUI Mode (explicit):   zensort -gui")
-			fmt.Println("  CLI Mode:              zensort -source <path> -dest <path> [-config <path>]")
+			fmt.Println("  CLI Mode:              zensort -source <path> -dest <path> [-config <path>] [-output text|json]")
 			fmt.Println("  CLI Mode (explicit):   zensort -cli -source <path> -dest <path>")
 			fmt.Println()
 			fmt.Println("Examples:")
@@ -35,10 +36,10 @@ func main() {
 			fmt.Println("  zensort -gui")
 			fmt.Println("  zensort -source \"C:\\Source\" -dest \"C:\\Organized\"")
 			fmt.Println("  zensort -cli -source \"./files\" -dest \"./sorted\"")
-			os.Exit(1)
+			os.Exit(cli.ExitFatal)
 		}
 		
-		cli.Run(*source, *dest, *config)
+		cli.Run(*source, *dest, *config, *output)
 	} else {
 		// Default to GUI mode
 		gui.Launch()